@@ -2,16 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/myersguo/cc-mono/internal/tui"
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
 	"github.com/myersguo/cc-mono/pkg/ai/providers/openai"
 	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
 	"github.com/myersguo/cc-mono/pkg/codingagent/extensions"
+	"github.com/myersguo/cc-mono/pkg/codingagent/hooks"
+	"github.com/myersguo/cc-mono/pkg/codingagent/lsp"
+	"github.com/myersguo/cc-mono/pkg/codingagent/repomap"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
+	"github.com/myersguo/cc-mono/pkg/codingagent/subagent"
 	"github.com/myersguo/cc-mono/pkg/codingagent/tools"
+	"github.com/myersguo/cc-mono/pkg/codingagent/web"
 	"github.com/myersguo/cc-mono/pkg/rpc"
 	"github.com/myersguo/cc-mono/pkg/shared"
 	"github.com/spf13/cobra"
@@ -32,6 +44,10 @@ var (
 	providerName   string
 	extensionNames []string
 	mode           string // "text" (default), "json", "rpc"
+	printPrompt    string
+	printImages    []string
+	sandboxBash    bool
+	sandboxSet     bool // --sandbox was given, either way
 )
 
 // rootCmd represents the base command
@@ -40,6 +56,9 @@ var rootCmd = &cobra.Command{
 	Short: "CC-Mono - AI coding agent",
 	Long: `CC-Mono is an interactive AI coding agent that helps with software development tasks.
 It supports multiple LLM providers, has a rich TUI, and an extensible plugin system.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		sandboxSet = cmd.Flags().Changed("sandbox")
+	},
 	RunE: runChat,
 }
 
@@ -207,6 +226,215 @@ var extensionListCmd = &cobra.Command{
 	},
 }
 
+// commandCmd manages custom slash commands
+var commandCmd = &cobra.Command{
+	Use:   "command",
+	Short: "Manage custom slash commands",
+	Long:  "List the slash commands defined by markdown files in .cc-mono/commands and ~/.cc-mono/commands.",
+}
+
+// commandListCmd lists custom slash commands
+var commandListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List custom slash commands",
+	Long:  "List the custom slash commands of this project and the global ones.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		custom, err := loadCustomCommands()
+		if err != nil {
+			return err
+		}
+		if len(custom) == 0 {
+			fmt.Println("No custom commands. Add markdown files to .cc-mono/commands to create some.")
+			return nil
+		}
+
+		fmt.Printf("Custom commands (%d):\n", len(custom))
+		for _, def := range custom {
+			synopsis := "/" + def.Name
+			if def.ArgumentHint != "" {
+				synopsis += " " + def.ArgumentHint
+			}
+			fmt.Printf("  %-30s %s\n", synopsis, def.Description)
+			if verbose {
+				fmt.Printf("    Path: %s\n", def.Path)
+				if def.Model != "" {
+					fmt.Printf("    Model: %s\n", def.Model)
+				}
+				if len(def.AllowedTools) > 0 {
+					fmt.Printf("    Allowed tools: %s\n", strings.Join(def.AllowedTools, ", "))
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+// themeCmd manages TUI themes
+var themeCmd = &cobra.Command{
+	Use:   "theme",
+	Short: "Manage TUI themes",
+	Long:  "List and check the themes defined by YAML or JSON files in ~/.cc-mono/themes.",
+}
+
+// themeListCmd lists the built-in and user themes
+var themeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List themes",
+	Long:  "List the built-in themes and those loaded from ~/.cc-mono/themes.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getThemesDir()
+		if err != nil {
+			return err
+		}
+		issues, err := tui.LoadThemes(dir)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Themes:")
+		for _, name := range tui.ThemeNames() {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Println("  auto (dark or light, following the terminal background)")
+		if len(issues) > 0 {
+			fmt.Printf("\n%d problem(s) found, run 'cc theme validate' for details\n", len(issues))
+		}
+		return nil
+	},
+}
+
+// themeValidateCmd checks theme files
+var themeValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check theme files",
+	Long: `Check theme files for unknown keys, invalid colors and missing base themes.
+
+Without arguments, every theme in ~/.cc-mono/themes is checked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getThemesDir()
+		if err != nil {
+			return err
+		}
+		issues, err := tui.LoadThemes(dir)
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			issues = nil
+			for _, path := range args {
+				fileIssues, err := tui.ValidateThemeFile(path)
+				if err != nil {
+					issues = append(issues, tui.ThemeIssue{Path: path, Message: err.Error()})
+					continue
+				}
+				issues = append(issues, fileIssues...)
+			}
+		}
+
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("%d problem(s) found in theme files", len(issues))
+		}
+		fmt.Println("Themes are valid.")
+		return nil
+	},
+}
+
+// auditCmd queries the permission and tool-execution audit log
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the permission and tool-execution audit log",
+	Long: `Show what the agent asked to do in this project, who allowed or denied it, and how it went.
+Entries can be filtered by session, tool and time range. --since and --until accept
+a duration (e.g. 24h) or a timestamp (RFC3339 or 2006-01-02).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := agent.AuditFilter{}
+		filter.SessionID, _ = cmd.Flags().GetString("session")
+		filter.ToolName, _ = cmd.Flags().GetString("tool")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		since, _ := cmd.Flags().GetString("since")
+		if since != "" {
+			t, err := parseTimeFlag(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			filter.Since = t
+		}
+
+		until, _ := cmd.Flags().GetString("until")
+		if until != "" {
+			t, err := parseTimeFlag(until)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+			filter.Until = t
+		}
+
+		configDir, err := getConfigDir()
+		if err != nil {
+			return err
+		}
+		wDir, err := resolveWorkingDir()
+		if err != nil {
+			return err
+		}
+
+		entries, err := agent.QueryAuditLog(agent.AuditLogPath(configDir, wDir), filter)
+		if err != nil {
+			return fmt.Errorf("failed to query audit log: %w", err)
+		}
+
+		if jsonOutput {
+			encoder := json.NewEncoder(os.Stdout)
+			for _, entry := range entries {
+				if err := encoder.Encode(entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("No audit entries found.")
+			return nil
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s  %-8s %-7s by %-9s %s\n",
+				entry.Timestamp.Format("2006-01-02 15:04:05"),
+				entry.ToolName,
+				entry.Decision,
+				entry.DecidedBy,
+				entry.Resource,
+			)
+			if verbose {
+				fmt.Printf("    Session: %s\n", entry.SessionID)
+				if entry.MatchedRule != "" {
+					fmt.Printf("    Rule: %s\n", entry.MatchedRule)
+				}
+				if entry.RiskLevel != "" {
+					fmt.Printf("    Risk: %s\n", entry.RiskLevel)
+				}
+				if entry.Executed {
+					fmt.Printf("    Duration: %dms\n", entry.DurationMs)
+				}
+				if entry.ExitCode != nil {
+					fmt.Printf("    Exit code: %d\n", *entry.ExitCode)
+				}
+				if entry.Error != "" {
+					fmt.Printf("    Error: %s\n", entry.Error)
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
 // versionCmd shows version information
 var versionCmd = &cobra.Command{
 	Use:   "version",
@@ -229,13 +457,20 @@ The server supports HTTP API and WebSocket connections.`,
 			return err
 		}
 
-		agentInst, modelRegistry, providersConfig, sessionMgr, _, err := setupAgent()
+		setup, err := setupAgent()
 		if err != nil {
 			return err
 		}
+		defer setup.Close()
 
 		fmt.Println("CC-Mono HTTP server starting...")
-		httpServer := rpc.NewHTTPServer(addr, agentInst, modelRegistry, providersConfig, sessionMgr)
+		session := setup.Sessions.NewSession(fmt.Sprintf("Serve %s", time.Now().Format("2006-01-02 15:04")), setup.Agent.GetState())
+		httpServer := rpc.NewHTTPServer(addr, setup.Agent, setup.Models, setup.Providers, setup.Sessions)
+		httpServer.SetAuditLog(openAuditLog(session.Metadata.ID))
+		httpServer.SetCheckpoints(openCheckpoints(session.Metadata.ID))
+		httpServer.SetBackgroundJobs(setup.Background)
+		httpServer.SetCommands(newCommandRegistry(setup))
+		setHookSession(setup.Agent, session.Metadata.ID)
 
 		fmt.Printf("HTTP server listening on %s\n", addr)
 		fmt.Println("Health check: GET /health")
@@ -251,17 +486,31 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config directory path")
 	rootCmd.PersistentFlags().StringVar(&modelsPath, "models", "configs/models.json", "Models configuration file")
 	rootCmd.PersistentFlags().StringVar(&providersPath, "providers", "configs/providers.json", "Providers configuration file")
-	rootCmd.PersistentFlags().StringVar(&themeName, "theme", "dark", "TUI theme (dark/light)")
+	rootCmd.PersistentFlags().StringVar(&themeName, "theme", "dark", "TUI theme (dark, light, auto or a theme from ~/.cc-mono/themes)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
 	rootCmd.PersistentFlags().StringVar(&workingDir, "dir", ".", "Working directory")
 	rootCmd.PersistentFlags().StringVar(&modelID, "model", "", "Model ID to use")
 	rootCmd.PersistentFlags().StringVar(&providerName, "provider", "", "Provider to use")
 	rootCmd.PersistentFlags().StringSliceVar(&extensionNames, "extensions", nil, "Extension names to load")
 	rootCmd.PersistentFlags().StringVar(&mode, "mode", "", "Output mode: text (default), json, or rpc")
+	rootCmd.Flags().StringVarP(&printPrompt, "print", "p", "", "Answer a single prompt without the TUI and exit (- reads it from stdin)")
+	rootCmd.Flags().StringSliceVar(&printImages, "image", nil, "Image file to attach to the --print prompt (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&sandboxBash, "sandbox", false, "Run bash commands in a sandbox; --sandbox=false turns off a sandbox enabled in settings.json")
 
 	// Serve command flags
 	serveCmd.PersistentFlags().StringP("addr", "a", ":8080", "HTTP server address (host:port)")
 
+	// Audit command flags
+	auditCmd.Flags().String("session", "", "Only show entries from this session ID")
+	auditCmd.Flags().String("tool", "", "Only show entries for this tool")
+	auditCmd.Flags().String("since", "", "Only show entries after this time or duration ago")
+	auditCmd.Flags().String("until", "", "Only show entries before this time or duration ago")
+	auditCmd.Flags().Bool("json", false, "Output entries as JSON lines")
+
+	// Commit command flags
+	commitCmd.Flags().BoolP("all", "a", false, "Stage changes to tracked files first")
+	commitCmd.Flags().BoolP("yes", "y", false, "Commit without asking to confirm the message")
+
 	// Chat command flags
 	chatCmd.PersistentFlags().Bool("serve", false, "Start RPC server simultaneously")
 	chatCmd.PersistentFlags().String("addr", ":8080", "RPC server address (if --serve is set)")
@@ -271,8 +520,13 @@ func init() {
 	rootCmd.AddCommand(modelCmd)
 	rootCmd.AddCommand(sessionCmd)
 	rootCmd.AddCommand(extensionCmd)
+	rootCmd.AddCommand(commandCmd)
+	rootCmd.AddCommand(themeCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(commitCmd)
+	rootCmd.AddCommand(reviewCmd)
 
 	// Model subcommands
 	modelCmd.AddCommand(modelListCmd)
@@ -283,19 +537,47 @@ func init() {
 
 	// Extension subcommands
 	extensionCmd.AddCommand(extensionListCmd)
+
+	// Command subcommands
+	commandCmd.AddCommand(commandListCmd)
+
+	// Theme subcommands
+	themeCmd.AddCommand(themeListCmd)
+	themeCmd.AddCommand(themeValidateCmd)
 }
 
 // runChat starts the interactive chat TUI
 func runChat(cmd *cobra.Command, args []string) error {
-	agentInst, modelRegistry, providersConfig, sessionMgr, extensionRunner, err := setupAgent()
+	if printPrompt != "" {
+		return runPrintMode(cmd.Context(), printPrompt)
+	}
+	if len(printImages) > 0 {
+		return fmt.Errorf("--image needs --print; paste images into the TUI with Ctrl+V instead")
+	}
+
+	setup, err := setupAgent()
 	if err != nil {
 		return err
 	}
 
+	// Kill background jobs, the shell and language servers when the session ends
+	defer setup.Close()
+
+	// Start a session for this run and open the audit log
+	session := setup.Sessions.NewSession(fmt.Sprintf("Chat %s", time.Now().Format("2006-01-02 15:04")), setup.Agent.GetState())
+	auditLog := openAuditLog(session.Metadata.ID)
+	checkpoints := openCheckpoints(session.Metadata.ID)
+	setHookSession(setup.Agent, session.Metadata.ID)
+	commandRegistry := newCommandRegistry(setup)
+
 	// Check if we should run in RPC mode
 	if mode == "rpc" {
 		// Create RPC server
-		rpcServer := rpc.NewServer(agentInst, modelRegistry, providersConfig, sessionMgr, os.Stdin, os.Stdout)
+		rpcServer := rpc.NewServer(setup.Agent, setup.Models, setup.Providers, setup.Sessions, os.Stdin, os.Stdout)
+		rpcServer.SetAuditLog(auditLog)
+		rpcServer.SetCheckpoints(checkpoints)
+		rpcServer.SetBackgroundJobs(setup.Background)
+		rpcServer.SetCommands(commandRegistry)
 		fmt.Println("Starting RPC server...")
 
 		// Run RPC server
@@ -307,7 +589,11 @@ func runChat(cmd *cobra.Command, args []string) error {
 	serve, _ := cmd.Flags().GetBool("serve")
 	if serve {
 		addr, _ := cmd.Flags().GetString("addr")
-		httpServer := rpc.NewHTTPServer(addr, agentInst, modelRegistry, providersConfig, sessionMgr)
+		httpServer := rpc.NewHTTPServer(addr, setup.Agent, setup.Models, setup.Providers, setup.Sessions)
+		httpServer.SetAuditLog(auditLog)
+		httpServer.SetCheckpoints(checkpoints)
+		httpServer.SetBackgroundJobs(setup.Background)
+		httpServer.SetCommands(commandRegistry)
 		go func() {
 			if err := httpServer.Start(); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: Failed to start RPC server: %v\n", err)
@@ -316,10 +602,32 @@ func runChat(cmd *cobra.Command, args []string) error {
 	}
 
 	// Start TUI (default)
-	return runTUI(agentInst, themeName, extensionRunner)
+	return runTUI(setup, themeName, auditLog, session, checkpoints, commandRegistry)
 }
 
-func setupAgent() (*agent.Agent, *codingagent.ModelRegistry, *codingagent.ProvidersConfig, *codingagent.SessionManager, *extensions.Runner, error) {
+// agentSetup is the agent setupAgent builds, what it was configured from and
+// the resources that live as long as it does
+type agentSetup struct {
+	Agent      *agent.Agent
+	Models     *codingagent.ModelRegistry
+	Providers  *codingagent.ProvidersConfig
+	Sessions   *codingagent.SessionManager
+	Extensions *extensions.Runner
+	Background *tools.BackgroundManager
+	Shell      *tools.Shell
+	Sandbox    *sandbox.Sandbox        // nil unless bash commands are sandboxed
+	LSP        *lsp.Manager            // nil unless language servers are enabled
+	Files      *tools.FileStateTracker // nil unless files must be read before writes
+}
+
+// Close kills background jobs, the shell and language servers
+func (s *agentSetup) Close() {
+	s.Background.Close()
+	s.Shell.Close()
+	s.LSP.Close()
+}
+
+func setupAgent() (*agentSetup, error) {
 	// Resolve paths
 	resolvedModelsPath := modelsPath
 	resolvedProvidersPath := providersPath
@@ -337,13 +645,13 @@ func setupAgent() (*agent.Agent, *codingagent.ModelRegistry, *codingagent.Provid
 	// Load model registry
 	modelRegistry := codingagent.NewModelRegistry()
 	if err := modelRegistry.LoadFromFile(resolvedModelsPath); err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("failed to load models: %w", err)
+		return nil, fmt.Errorf("failed to load models: %w", err)
 	}
 
 	// Load providers config
 	providersConfig, err := codingagent.LoadProvidersConfig(resolvedProvidersPath)
 	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("failed to load providers config: %w", err)
+		return nil, fmt.Errorf("failed to load providers config: %w", err)
 	}
 
 	// Get provider name (use flag, or default from config, or "openai")
@@ -357,51 +665,124 @@ func setupAgent() (*agent.Agent, *codingagent.ModelRegistry, *codingagent.Provid
 	// Get provider config
 	providerConfig, ok := providersConfig.Providers[providerName]
 	if !ok {
-		return nil, nil, nil, nil, nil, fmt.Errorf("provider %s not found in config", providerName)
+		return nil, fmt.Errorf("provider %s not found in config", providerName)
 	}
 
 	// Get model ID (use flag, or default from provider config)
 	if modelID == "" {
 		modelID = providerConfig.DefaultModel
 		if modelID == "" {
-			return nil, nil, nil, nil, nil, fmt.Errorf("no model specified and no default model in provider config")
+			return nil, fmt.Errorf("no model specified and no default model in provider config")
 		}
 	}
 
 	// Get AI model from registry
 	aiModel, err := modelRegistry.ToAIModel(modelID)
 	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("failed to get model: %w", err)
+		return nil, fmt.Errorf("failed to get model: %w", err)
 	}
 
 	// Create provider
 	provider, err := createProvider(providerName, providerConfig)
 	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("failed to create provider: %w", err)
+		return nil, fmt.Errorf("failed to create provider: %w", err)
 	}
 
 	// Get working directory
-	wDir := workingDir
-	if wDir == "." {
-		wDir, err = os.Getwd()
+	wDir, err := resolveWorkingDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Load settings
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := codingagent.LoadSettings(configDir, wDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the bash sandbox if enabled, falling back to unrestricted commands
+	bashOptions := tools.BashToolOptions{}
+	if sandboxSet {
+		settings.Sandbox.Enabled = sandboxBash
+	}
+	if settings.Sandbox.Enabled {
+		sb, err := sandbox.New(settings.Sandbox, wDir)
 		if err != nil {
-			return nil, nil, nil, nil, nil, fmt.Errorf("failed to get working directory: %w", err)
+			fmt.Fprintf(os.Stderr, "Warning: bash sandbox disabled, commands will run with full privileges: %v\n", err)
+		} else {
+			bashOptions.Sandbox = sb
 		}
 	}
 
+	// Long-running commands run as background jobs next to the shell
+	background := tools.NewBackgroundManager(bashOptions.Sandbox)
+	bashOptions.Background = background
+
+	// The caller kills the shell when the session ends
+	bashOptions.Shell = tools.NewShell(wDir, bashOptions.Sandbox)
+
 	// Create tools
 	agentTools := []agent.AgentTool{
 		tools.CreateReadTool(wDir),
 		tools.CreateWriteTool(wDir),
 		tools.CreateEditTool(wDir),
-		tools.CreateBashTool(wDir),
+		tools.CreateMultiEditTool(wDir),
+		tools.CreateApplyPatchTool(wDir),
+		tools.CreateBashToolWithOptions(wDir, bashOptions),
+		tools.CreateBashOutputTool(background),
+		tools.CreateKillShellTool(background),
+	}
+
+	// Refuse changes to files the agent hasn't read or that changed since it did
+	var fileState *tools.FileStateTracker
+	if settings.RequireReadBeforeWrite() {
+		fileState = tools.NewFileStateTracker(wDir)
+		agentTools = fileState.WrapAllTools(agentTools)
+	}
+
+	// Report new language server diagnostics after edits and add navigation tools
+	var lspManager *lsp.Manager
+	if settings.LSP.Enabled {
+		lspManager = lsp.NewManager(settings.LSP, wDir)
+		agentTools = lspManager.WrapAllTools(agentTools)
+		agentTools = append(agentTools,
+			lsp.CreateDefinitionTool(lspManager),
+			lsp.CreateReferencesTool(lspManager),
+			lsp.CreateHoverTool(lspManager),
+		)
+	}
+
+	// Outline of the repository's symbols, cached under the config directory
+	repoMap := repomap.New(wDir, filepath.Join(configDir, "repomap"))
+	agentTools = append(agentTools, repomap.CreateRepoMapTool(repoMap, settings.RepoMap))
+
+	// Web access; questions about fetched pages go to the summary model if one is set
+	fetchOptions := web.FetchOptions{Provider: provider, Model: aiModel}
+	if settings.Web.SummaryModel != "" {
+		summaryModel, summaryProvider, err := resolveModel(modelRegistry, providersConfig, settings.Web.SummaryModel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: web summary model unavailable, using %s: %v\n", aiModel.ID, err)
+		} else {
+			fetchOptions.Provider, fetchOptions.Model = summaryProvider, summaryModel
+		}
+	}
+	agentTools = append(agentTools, web.CreateWebFetchTool(fetchOptions))
+	searchBackend, err := web.NewSearchBackend(settings.Web.Search, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: web search disabled: %v\n", err)
+	} else if searchBackend != nil {
+		agentTools = append(agentTools, web.CreateWebSearchTool(searchBackend))
 	}
 
 	// Load extensions
 	extensionLoader := extensions.NewLoader()
 	if len(extensionNames) > 0 {
 		if err := extensionLoader.LoadFromRegistry(extensionNames, nil); err != nil {
-			return nil, nil, nil, nil, nil, fmt.Errorf("failed to load extensions: %w", err)
+			return nil, fmt.Errorf("failed to load extensions: %w", err)
 		}
 	}
 
@@ -415,12 +796,21 @@ func setupAgent() (*agent.Agent, *codingagent.ModelRegistry, *codingagent.Provid
 	// Wrap tools with extension hooks
 	agentTools = extensionRunner.WrapAllTools(agentTools)
 
+	// Wrap tools with the shell-command hooks from settings.json
+	hookManager := hooks.NewManager(settings.Hooks, wDir, codingagent.LoggerFromConfig(settings.Config()))
+	agentTools = hookManager.WrapAllTools(agentTools)
+
 	// System prompt
 	systemPrompt := `You are a helpful AI coding assistant. You can:
 - Read files from the filesystem
 - Write new files or update existing files
-- Edit files with precise text replacements
-- Run bash commands
+- Edit files with precise text replacements, several at once with multi_edit
+- Apply multi-file changes as a patch with apply_patch
+- Run bash commands, including long-running ones in the background
+- Get an outline of the repository's functions and types with repo_map
+- Navigate code by symbol with lsp_definition, lsp_references and lsp_hover when they are available
+- Fetch web pages with web_fetch, and search the web with web_search when it is available
+- Delegate self-contained research to sub-agents with the task tool
 
 When working with code:
 1. Always read files before editing them
@@ -430,20 +820,69 @@ When working with code:
 
 Be concise but thorough in your responses.`
 
+	// Start with the repository map so the model spends fewer turns exploring
+	if settings.RepoMap.SystemPrompt {
+		outline, err := repoMap.Render(context.Background(), repomap.RenderOptions{MaxTokens: settings.RepoMap.MaxTokens})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: repository map unavailable: %v\n", err)
+		} else if outline.Symbols > 0 {
+			systemPrompt += "\n\nRepository map (top-level symbols, most referenced first):\n" + outline.Text
+		}
+	}
+
 	// Create agent instance
 	agentInst := agent.NewAgent(provider, systemPrompt, aiModel, agentTools)
+	agentInst.SetLoopHooks(hookManager)
+
+	// Let the agent delegate tasks to sub-agents that share its tools
+	subagents, err := subagent.LoadDefinitions(subagent.Dirs(configDir, wDir)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: custom sub-agents not loaded: %v\n", err)
+		subagents = subagent.DefaultDefinitions()
+	}
+	taskTool := subagent.CreateTaskTool(subagent.TaskToolOptions{
+		Parent:      agentInst,
+		Definitions: subagents,
+		ResolveModel: func(id string) (ai.Model, ai.Provider, error) {
+			return resolveModel(modelRegistry, providersConfig, id)
+		},
+		// Each sub-agent gets a shell of its own, so its cd and export don't
+		// leak into this conversation and concurrent tasks don't share one
+		NewBash: func() (agent.AgentTool, func()) {
+			options := bashOptions
+			options.Shell = tools.NewShell(wDir, bashOptions.Sandbox)
+			bash := tools.CreateBashToolWithOptions(wDir, options)
+			return hookManager.WrapTool(extensionRunner.WrapTool(bash)), options.Shell.Close
+		},
+	})
+	agentInst.AddTool(hookManager.WrapTool(extensionRunner.WrapTool(taskTool)))
 
 	// Create session manager
 	sessionsDir, err := getSessionsDir()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, err
 	}
 	sessionMgr, err := codingagent.NewSessionManager(sessionsDir)
 	if err != nil {
-		return nil, nil, nil, nil, nil, fmt.Errorf("failed to create session manager: %w", err)
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
+	}
+	// A new or rewound conversation hasn't read the files the old one did
+	if fileState != nil {
+		sessionMgr.OnReset(fileState.Reset)
 	}
 
-	return agentInst, modelRegistry, providersConfig, sessionMgr, extensionRunner, nil
+	return &agentSetup{
+		Agent:      agentInst,
+		Models:     modelRegistry,
+		Providers:  providersConfig,
+		Sessions:   sessionMgr,
+		Extensions: extensionRunner,
+		Background: background,
+		Shell:      bashOptions.Shell,
+		Sandbox:    bashOptions.Sandbox,
+		LSP:        lspManager,
+		Files:      fileState,
+	}, nil
 }
 
 // createProvider creates a provider based on name and config
@@ -458,6 +897,78 @@ func createProvider(name string, config codingagent.ProviderConfig) (ai.Provider
 	})
 }
 
+// resolveModel returns a model from the registry and a provider for it
+func resolveModel(registry *codingagent.ModelRegistry, providersConfig *codingagent.ProvidersConfig, id string) (ai.Model, ai.Provider, error) {
+	model, err := registry.ToAIModel(id)
+	if err != nil {
+		return ai.Model{}, nil, err
+	}
+
+	name := model.Provider
+	if name == "" {
+		name = providerName
+	}
+	config, ok := providersConfig.Providers[name]
+	if !ok {
+		return ai.Model{}, nil, fmt.Errorf("provider %s not found in config", name)
+	}
+
+	provider, err := createProvider(name, config)
+	if err != nil {
+		return ai.Model{}, nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+	return model, provider, nil
+}
+
+// newCommandRegistry creates the slash commands: the built-in ones and those
+// registered by extensions
+func newCommandRegistry(setup *agentSetup) *commands.Registry {
+	registry := commands.NewRegistry()
+	wDir, _ := resolveWorkingDir()
+	env := commands.Env{
+		Agent:      setup.Agent,
+		Sessions:   setup.Sessions,
+		Models:     setup.Models,
+		WorkingDir: wDir,
+		Sandbox:    setup.Sandbox,
+		ResolveModel: func(id string) (ai.Model, ai.Provider, error) {
+			return resolveModel(setup.Models, setup.Providers, id)
+		},
+	}
+	if err := registry.RegisterBuiltins(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to register commands: %v\n", err)
+	}
+
+	// Prompt files in .cc-mono/commands become commands too
+	if custom, err := loadCustomCommands(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: custom commands not loaded: %v\n", err)
+	} else if err := registry.RegisterCustom(env, custom); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Skipping custom commands: %v\n", err)
+	}
+
+	if setup.Extensions != nil {
+		for _, cmd := range setup.Extensions.GetRegisteredCommands() {
+			if err := registry.Register(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Skipping extension command: %v\n", err)
+			}
+		}
+	}
+	return registry
+}
+
+// loadCustomCommands loads the global and project prompt file commands
+func loadCustomCommands() ([]commands.CustomCommand, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	wDir, err := resolveWorkingDir()
+	if err != nil {
+		return nil, err
+	}
+	return commands.LoadCustomCommands(commands.Dirs(configDir, wDir)...)
+}
+
 // resolveConfigPath resolves a config file path based on --config flag
 func resolveConfigPath(path string) string {
 	// If path is absolute, use it as-is
@@ -488,6 +999,128 @@ func getConfigDir() (string, error) {
 	return filepath.Join(home, ".cc-mono"), nil
 }
 
+// resolveWorkingDir returns the working directory from --dir
+func resolveWorkingDir() (string, error) {
+	if workingDir != "." {
+		return workingDir, nil
+	}
+
+	wDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return wDir, nil
+}
+
+// setHookSession tags hook command payloads with sessionID
+func setHookSession(agentInst *agent.Agent, sessionID string) {
+	if hookManager, ok := agentInst.GetLoopHooks().(*hooks.Manager); ok {
+		hookManager.SetSessionID(sessionID)
+	}
+}
+
+// openAuditLog opens the audit log for the working directory, tagging entries with sessionID.
+// Auditing is best effort: failures are reported and the agent runs without it.
+func openAuditLog(sessionID string) *agent.AuditLog {
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: audit log disabled: %v\n", err)
+		return nil
+	}
+	wDir, err := resolveWorkingDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: audit log disabled: %v\n", err)
+		return nil
+	}
+
+	auditLog, err := agent.NewAuditLog(agent.AuditLogPath(configDir, wDir), sessionID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: audit log disabled: %v\n", err)
+		return nil
+	}
+	return auditLog
+}
+
+// openCheckpoints opens the file checkpoint store for a session.
+// Checkpoints are best effort: failures are reported and /rewind cannot restore files.
+func openCheckpoints(sessionID string) *checkpoint.Store {
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: file checkpoints disabled: %v\n", err)
+		return nil
+	}
+
+	store, err := checkpoint.Open(checkpoint.Dir(configDir, sessionID))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: file checkpoints disabled: %v\n", err)
+		return nil
+	}
+	return store
+}
+
+// loadTUISettings returns the settings the TUI reads: the status line and
+// vim mode. Failures are reported and the defaults are used.
+func loadTUISettings() *codingagent.Settings {
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: TUI settings not loaded: %v\n", err)
+		return &codingagent.Settings{}
+	}
+	wDir, err := resolveWorkingDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: TUI settings not loaded: %v\n", err)
+		return &codingagent.Settings{}
+	}
+
+	settings, err := codingagent.LoadSettings(configDir, wDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: TUI settings not loaded: %v\n", err)
+		return &codingagent.Settings{}
+	}
+	return settings
+}
+
+// getThemesDir returns the directory of the user theme files
+func getThemesDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "themes"), nil
+}
+
+// loadThemes loads the user theme files for the TUI, reporting their problems
+func loadThemes() {
+	dir, err := getThemesDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: themes not loaded: %v\n", err)
+		return
+	}
+	issues, err := tui.LoadThemes(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: themes not loaded: %v\n", err)
+		return
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "Warning: theme %s\n", issue)
+	}
+}
+
+// parseTimeFlag parses a duration ago (e.g. "24h") or an absolute timestamp
+func parseTimeFlag(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("expected a duration or timestamp, got %q", value)
+}
+
 // getSessionsDir returns the sessions directory path
 func getSessionsDir() (string, error) {
 	configDir, err := getConfigDir()
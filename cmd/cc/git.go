@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/spf13/cobra"
+)
+
+// maxDiffBytes caps the diff sent to the model
+const maxDiffBytes = 200 * 1024
+
+// commitPrompt is the system prompt for drafting commit messages
+const commitPrompt = `You write git commit messages for staged changes.
+
+Match the style of the repository's recent commit subjects: capitalization, prefixes, tense and length.
+Write a subject line of at most 72 characters. Add a body, separated by a blank line and wrapped at
+72 columns, only when the change needs explaining; say why, not how.
+
+Reply with the commit message only: no code fences, no quotes, no commentary.`
+
+// reviewPrompt is the system prompt for reviewing a branch
+const reviewPrompt = `You are a senior engineer reviewing a branch before it is merged.
+
+Look for bugs, regressions, security problems, race conditions, missing error handling, and
+missing or wrong tests. Use the read and lsp tools to check surrounding code before reporting
+something; do not report style nits or things you have not verified. Report each problem once.
+
+Reply with a JSON array only, no code fences or commentary. Each finding is an object:
+{"file": "path", "line": 12, "severity": "high" | "medium" | "low", "title": "short summary", "body": "explanation and suggested fix"}
+Reply with [] when there is nothing to report.`
+
+// reviewTools are the read-only tools available during a review
+var reviewTools = []string{"read", "lsp_definition", "lsp_references", "lsp_hover"}
+
+// reviewFinding is one problem reported by a review
+type reviewFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+// commitCmd drafts a commit message for the staged changes and commits
+var commitCmd = &cobra.Command{
+	Use:   "commit [instructions]",
+	Short: "Commit staged changes with a drafted message",
+	Long: `Draft a commit message for the staged changes in the style of the recent history,
+then commit after you accept or edit it. Extra arguments are passed to the model as
+instructions, e.g. cc commit "mention the issue number".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		all, _ := cmd.Flags().GetBool("all")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if all {
+			if _, err := git(ctx, "add", "--update"); err != nil {
+				return err
+			}
+		}
+		diff, err := git(ctx, "diff", "--cached")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(diff) == "" {
+			return fmt.Errorf("nothing to commit: stage changes with git add, or use --all")
+		}
+		stat, _ := git(ctx, "diff", "--cached", "--stat")
+		history, _ := git(ctx, "log", "-n", "15", "--format=%s")
+
+		var prompt strings.Builder
+		if strings.TrimSpace(history) != "" {
+			prompt.WriteString("Recent commit subjects:\n" + history + "\n")
+		}
+		if len(args) > 0 {
+			prompt.WriteString("Instructions: " + strings.Join(args, " ") + "\n\n")
+		}
+		prompt.WriteString("Staged changes:\n" + stat + "\n" + capDiff(diff))
+
+		writer, closeAgent, err := newWorkflowAgent(commitPrompt, nil)
+		if err != nil {
+			return err
+		}
+		defer closeAgent()
+		fmt.Fprintln(os.Stderr, "Drafting commit message...")
+		message, err := runPrint(ctx, writer, prompt.String())
+		if err != nil {
+			return err
+		}
+		message = stripFences(message)
+
+		editMessage := false
+		if !yes {
+			fmt.Printf("\n%s\n\n", message)
+			choice, err := confirm("Commit with this message? [Y]es / [e]dit / [n]o: ")
+			if err != nil {
+				return err
+			}
+			switch choice {
+			case "", "y", "yes":
+			case "e", "edit":
+				editMessage = true
+			default:
+				fmt.Println("Commit cancelled.")
+				return nil
+			}
+		}
+
+		file, err := os.CreateTemp("", "cc-commit-*.txt")
+		if err != nil {
+			return fmt.Errorf("failed to write commit message: %w", err)
+		}
+		defer os.Remove(file.Name())
+		if _, err := file.WriteString(message + "\n"); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write commit message: %w", err)
+		}
+		file.Close()
+
+		// git opens the configured editor itself with --edit
+		commitArgs := []string{"commit", "--file", file.Name()}
+		if editMessage {
+			commitArgs = append(commitArgs, "--edit")
+		}
+		gitCmd := exec.CommandContext(ctx, "git", commitArgs...)
+		gitCmd.Dir, _ = resolveWorkingDir()
+		gitCmd.Stdin, gitCmd.Stdout, gitCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			return fmt.Errorf("git commit failed: %w", err)
+		}
+		return nil
+	},
+}
+
+// reviewCmd reviews the changes on the current branch
+var reviewCmd = &cobra.Command{
+	Use:   "review [base]",
+	Short: "Review the changes between a base branch and HEAD",
+	Long: `Review the changes in git diff base...HEAD with read-only tools and print the findings.
+The base defaults to the remote's default branch, or main or master. Use --mode json
+for machine-readable findings.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		base := ""
+		if len(args) > 0 {
+			base = args[0]
+		} else {
+			var err error
+			if base, err = defaultBase(ctx); err != nil {
+				return err
+			}
+		}
+
+		diff, err := git(ctx, "diff", base+"...HEAD")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(diff) == "" {
+			if mode == "json" {
+				return writeJSON(os.Stdout, map[string]any{"base": base, "findings": []reviewFinding{}})
+			}
+			fmt.Printf("No changes between %s and HEAD.\n", base)
+			return nil
+		}
+		stat, _ := git(ctx, "diff", "--stat", base+"...HEAD")
+		commits, _ := git(ctx, "log", "--format=%s", base+"..HEAD")
+
+		var prompt strings.Builder
+		prompt.WriteString(fmt.Sprintf("Review the changes from %s to HEAD.\n\n", base))
+		if strings.TrimSpace(commits) != "" {
+			prompt.WriteString("Commits:\n" + commits + "\n")
+		}
+		prompt.WriteString("Changed files:\n" + stat + "\n" + capDiff(diff))
+
+		reviewer, closeAgent, err := newWorkflowAgent(reviewPrompt, reviewTools)
+		if err != nil {
+			return err
+		}
+		defer closeAgent()
+		fmt.Fprintf(os.Stderr, "Reviewing %s...HEAD...\n", base)
+		answer, err := runPrint(ctx, reviewer, prompt.String())
+		if err != nil {
+			return err
+		}
+
+		findings, parseErr := parseFindings(answer)
+		if mode == "json" {
+			if parseErr != nil {
+				return fmt.Errorf("failed to parse review findings: %w\n%s", parseErr, answer)
+			}
+			return writeJSON(os.Stdout, map[string]any{"base": base, "findings": findings})
+		}
+		if parseErr != nil {
+			// Show the review as written rather than losing it
+			fmt.Println(answer)
+			return nil
+		}
+		printFindings(findings)
+		return nil
+	},
+}
+
+// newWorkflowAgent creates an agent with the configured model, a system prompt
+// and the named tools from the regular tool set. Call cleanup when done with it.
+func newWorkflowAgent(systemPrompt string, toolNames []string) (*agent.Agent, func(), error) {
+	setup, err := setupAgent()
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		setup.Agent.Close()
+		setup.Close()
+	}
+
+	var tools []agent.AgentTool
+	for _, name := range toolNames {
+		if tool, ok := setup.Agent.FindTool(name); ok {
+			tools = append(tools, tool)
+		}
+	}
+
+	state := setup.Agent.GetState()
+	return agent.NewAgent(setup.Agent.GetProvider(), systemPrompt, state.GetModel(), tools), cleanup, nil
+}
+
+// git runs a git command in the working directory and returns its output
+func git(ctx context.Context, args ...string) (string, error) {
+	wDir, err := resolveWorkingDir()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = wDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(output), nil
+}
+
+// defaultBase returns the branch to review against: the remote's default
+// branch if known, otherwise main or master
+func defaultBase(ctx context.Context) (string, error) {
+	if ref, err := git(ctx, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimSpace(ref), nil
+	}
+	for _, branch := range []string{"main", "master"} {
+		if _, err := git(ctx, "rev-parse", "--verify", "--quiet", branch); err == nil {
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("could not find a base branch; pass one, e.g. cc review main")
+}
+
+// capDiff truncates a diff to maxDiffBytes
+func capDiff(diff string) string {
+	if len(diff) <= maxDiffBytes {
+		return diff
+	}
+	cut := strings.LastIndex(diff[:maxDiffBytes], "\n")
+	if cut < 0 {
+		cut = maxDiffBytes
+	}
+	return diff[:cut] + fmt.Sprintf("\n... (diff truncated, %d of %d bytes shown)\n", cut, len(diff))
+}
+
+// stripFences removes a code fence the model wrapped its reply in
+func stripFences(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	lines = lines[1:]
+	if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "```" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// parseFindings parses the JSON array a review replies with
+func parseFindings(answer string) ([]reviewFinding, error) {
+	text := stripFences(answer)
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in the reply")
+	}
+	findings := []reviewFinding{}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &findings); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+// printFindings prints review findings as text
+func printFindings(findings []reviewFinding) {
+	if len(findings) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+	fmt.Printf("Found %d problem(s):\n\n", len(findings))
+	for _, f := range findings {
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		fmt.Printf("[%s] %s  %s\n", strings.ToUpper(f.Severity), location, f.Title)
+		if f.Body != "" {
+			for _, line := range strings.Split(strings.TrimSpace(f.Body), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// confirm prints a question and reads a lowercase answer from stdin
+func confirm(question string) (string, error) {
+	fmt.Print(question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no answer: %w", err)
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapDiff(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	long := strings.Repeat(line, maxDiffBytes/len(line)+10)
+	noNewline := strings.Repeat("y", maxDiffBytes+5)
+
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"Short", "+a\n-b\n", "+a\n-b\n"},
+		{"Exact", strings.Repeat("z", maxDiffBytes), strings.Repeat("z", maxDiffBytes)},
+		{"CutAtLine", long, long[:maxDiffBytes/len(line)*len(line)-1] + "\n... (diff truncated, 204799 of 205800 bytes shown)\n"},
+		{"NoNewline", noNewline, noNewline[:maxDiffBytes] + "\n... (diff truncated, 204800 of 204805 bytes shown)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, capDiff(tt.diff))
+		})
+	}
+}
+
+func TestStripFences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"Plain", "  Fix the parser\n", "Fix the parser"},
+		{"Fenced", "```\nFix the parser\n\nBody\n```", "Fix the parser\n\nBody"},
+		{"FencedWithLanguage", "```text\nFix the parser\n```\n", "Fix the parser"},
+		{"Unclosed", "```json\n[]", "[]"},
+		{"FenceInside", "Subject\n```\ncode\n```", "Subject\n```\ncode\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stripFences(tt.text))
+		})
+	}
+}
+
+func TestParseFindings(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		want    []reviewFinding
+		wantErr string
+	}{
+		{name: "Empty", answer: "[]", want: []reviewFinding{}},
+		{
+			name:   "Fenced",
+			answer: "```json\n[{\"file\": \"main.go\", \"line\": 12, \"severity\": \"high\", \"title\": \"Nil map\", \"body\": \"Initialize it\"}]\n```",
+			want:   []reviewFinding{{File: "main.go", Line: 12, Severity: "high", Title: "Nil map", Body: "Initialize it"}},
+		},
+		{
+			name:   "SurroundingText",
+			answer: "Here are the findings:\n[{\"file\": \"a.go\", \"severity\": \"low\", \"title\": \"Typo\"}]\nDone.",
+			want:   []reviewFinding{{File: "a.go", Severity: "low", Title: "Typo"}},
+		},
+		{name: "NoArray", answer: "Looks good to me", wantErr: "no JSON array"},
+		{name: "Invalid", answer: "[{\"file\": 1}]", wantErr: "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := parseFindings(tt.answer)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, findings)
+		})
+	}
+}
@@ -10,10 +10,16 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/myersguo/cc-mono/internal/tui"
 	"github.com/myersguo/cc-mono/pkg/agent"
-	"github.com/myersguo/cc-mono/pkg/codingagent/extensions"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
 )
 
 func main() {
+	// Act as the sandbox helper when re-executed by the bash tool
+	sandbox.MaybeRunHelper(os.Args)
+
 	// Create context with signal handling
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -27,12 +33,24 @@ func main() {
 
 // runTUI starts the bubbletea TUI interface
 func runTUI(
-	agentInst *agent.Agent,
+	setup *agentSetup,
 	theme string,
-	extensionRunner *extensions.Runner,
+	auditLog *agent.AuditLog,
+	session *codingagent.Session,
+	checkpoints *checkpoint.Store,
+	commandRegistry *commands.Registry,
 ) error {
 	// Create chat model
-	chatModel := tui.NewChatModel(agentInst, theme)
+	loadThemes()
+	chatModel := tui.NewChatModel(setup.Agent, theme)
+	chatModel.SetModels(setup.Models)
+	chatModel.SetAuditLog(auditLog)
+	chatModel.SetSession(setup.Sessions, session, checkpoints)
+	chatModel.SetBackgroundJobs(setup.Background)
+	chatModel.SetCommands(commandRegistry)
+	settings := loadTUISettings()
+	chatModel.SetStatusLine(settings.StatusLine)
+	chatModel.SetVimMode(settings.VimMode)
 
 	// Create bubbletea program
 	p := tea.NewProgram(
@@ -41,14 +59,26 @@ func runTUI(
 		tea.WithMouseCellMotion(), // Enable wheel scrolling for long content
 	)
 
+	// Redraw when theme files change
+	if dir, err := getThemesDir(); err == nil {
+		stop, err := tui.WatchThemes(dir, func(issues []tui.ThemeIssue) {
+			p.Send(tui.ThemeChangedMsg{Issues: issues})
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: theme files not watched: %v\n", err)
+		} else {
+			defer stop()
+		}
+	}
+
 	// Run TUI
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("TUI error: %w", err)
 	}
 
 	// Call extension OnAgentEnd when TUI exits
-	if extensionRunner != nil {
-		if err := extensionRunner.OnAgentEnd(context.Background()); err != nil {
+	if setup.Extensions != nil {
+		if err := setup.Extensions.OnAgentEnd(context.Background()); err != nil {
 			// Log error but don't fail
 			fmt.Fprintf(os.Stderr, "Warning: extension cleanup error: %v\n", err)
 		}
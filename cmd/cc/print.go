@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+)
+
+// printResult is the output of print mode with --mode json
+type printResult struct {
+	Result    string `json:"result"`
+	SessionID string `json:"session_id,omitempty"` // Tags the run's audit log entries
+	Model     string `json:"model"`
+	Turns     int    `json:"turns"`
+}
+
+// runPrintMode answers a single prompt without the TUI and writes the answer to stdout
+func runPrintMode(ctx context.Context, prompt string) error {
+	if prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt from stdin: %w", err)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("prompt is empty")
+	}
+
+	var attachments []ai.Content
+	for _, path := range printImages {
+		image, err := images.Load(path)
+		if err != nil {
+			return fmt.Errorf("failed to attach %s: %w", path, err)
+		}
+		attachments = append(attachments, image)
+	}
+
+	setup, err := setupAgent()
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+	agentInst := setup.Agent
+	if err := images.CheckModel(agentInst.GetState().GetModel(), attachments); err != nil {
+		return err
+	}
+
+	session := setup.Sessions.NewSession(fmt.Sprintf("Print %s", time.Now().Format("2006-01-02 15:04")), agentInst.GetState())
+	setHookSession(agentInst, session.Metadata.ID)
+	if auditLog := openAuditLog(session.Metadata.ID); auditLog != nil {
+		ctx = context.WithValue(ctx, "audit_log", auditLog)
+	}
+
+	answer, err := runPrint(ctx, agentInst, prompt, attachments...)
+	if setup.Extensions != nil {
+		if err := setup.Extensions.OnAgentEnd(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: extension cleanup error: %v\n", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if mode == "json" {
+		return writeJSON(os.Stdout, printResult{
+			Result:    answer,
+			SessionID: session.Metadata.ID,
+			Model:     agentInst.GetState().GetModel().ID,
+			Turns:     countAssistantMessages(agentInst.GetState().GetMessages()),
+		})
+	}
+	fmt.Println(answer)
+	return nil
+}
+
+// runPrint runs the agent on one prompt, with any attachments, closes it and
+// returns its final answer. Nobody is there to answer permission prompts, so
+// only safe tools and tools allowed by settings.json rules run; everything else
+// is denied.
+func runPrint(ctx context.Context, agentInst *agent.Agent, prompt string, attachments ...ai.Content) (string, error) {
+	if ctx.Value("permission_manager") == nil {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return "", err
+		}
+		wDir, err := resolveWorkingDir()
+		if err != nil {
+			return "", err
+		}
+		permManager, err := agent.NewPermissionManager(configDir, wDir)
+		if err != nil {
+			return "", err
+		}
+		ctx = context.WithValue(ctx, "permission_manager", permManager)
+	}
+	permManager := ctx.Value("permission_manager").(*agent.PermissionManager)
+	permManager.SetRequestHandler(func(req *agent.PermissionRequest) {
+		answerPermission(permManager, req)
+	})
+	defer permManager.SetRequestHandler(nil)
+
+	events := agentInst.GetEventBus().Subscribe(1000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			if e, ok := event.(agent.ToolExecutionStartEvent); ok && verbose {
+				fmt.Fprintf(os.Stderr, "[tool] %s\n", e.ToolName)
+			}
+		}
+	}()
+
+	userMsg := agent.NewAgentMessage(
+		ai.NewUserMessage(append([]ai.Content{ai.NewTextContent(prompt)}, attachments...)),
+		fmt.Sprintf("user-%d", time.Now().UnixNano()),
+		time.Now().UnixMilli(),
+	)
+	runErr := agentInst.Run(ctx, []agent.AgentMessage{userMsg})
+	agentInst.Close()
+	<-done
+
+	if runErr != nil {
+		return "", runErr
+	}
+	if stateErr := agentInst.GetState().GetError(); stateErr != "" {
+		return "", fmt.Errorf("%s", stateErr)
+	}
+
+	answer := finalAnswer(agentInst.GetState().GetMessages())
+	if answer == "" {
+		return "", fmt.Errorf("the model returned no answer")
+	}
+	return answer, nil
+}
+
+// answerPermission approves safe tool calls and denies the rest
+func answerPermission(permManager *agent.PermissionManager, req *agent.PermissionRequest) {
+	allowed := req.RiskLevel == "safe"
+	_ = permManager.RespondToRequestAs(req.RequestID, allowed, false, "project", agent.DecidedByAuto)
+	if !allowed {
+		fmt.Fprintf(os.Stderr, "Denied %s (%s): add an allow rule to settings.json to permit it in print mode\n", req.ToolName, req.Resource)
+	}
+}
+
+// finalAnswer returns the text of the last assistant message
+func finalAnswer(messages []agent.AgentMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg, ok := messages[i].Message.(ai.AssistantMessage)
+		if !ok {
+			continue
+		}
+		var parts []string
+		for _, content := range msg.Content {
+			if text, ok := content.(ai.TextContent); ok && strings.TrimSpace(text.Text) != "" {
+				parts = append(parts, text.Text)
+			}
+		}
+		return strings.TrimSpace(strings.Join(parts, "\n"))
+	}
+	return ""
+}
+
+// countAssistantMessages counts the model turns in a conversation
+func countAssistantMessages(messages []agent.AgentMessage) int {
+	count := 0
+	for _, msg := range messages {
+		if _, ok := msg.Message.(ai.AssistantMessage); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+// message wraps an ai message for the helpers below
+func message(msg ai.Message) agent.AgentMessage {
+	return agent.NewAgentMessage(msg, "", 0)
+}
+
+// assistant returns an assistant message with the given content
+func assistant(content ...ai.Content) agent.AgentMessage {
+	return message(ai.NewAssistantMessage(content, "", "", "", ai.Usage{}, ai.StopReasonEndTurn))
+}
+
+func TestFinalAnswer(t *testing.T) {
+	user := message(ai.NewUserMessage([]ai.Content{ai.NewTextContent("Question")}))
+
+	tests := []struct {
+		name     string
+		messages []agent.AgentMessage
+		want     string
+	}{
+		{"NoMessages", nil, ""},
+		{"OnlyUser", []agent.AgentMessage{user}, ""},
+		{"Last", []agent.AgentMessage{user, assistant(ai.NewTextContent("first")), user, assistant(ai.NewTextContent(" second \n"))}, "second"},
+		{"JoinsText", []agent.AgentMessage{assistant(ai.NewTextContent("one"), ai.NewTextContent("  "), ai.NewTextContent("two"))}, "one\ntwo"},
+		{"LastHasNoText", []agent.AgentMessage{assistant(ai.NewTextContent("earlier")), assistant()}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, finalAnswer(tt.messages))
+		})
+	}
+}
+
+func TestCountAssistantMessages(t *testing.T) {
+	user := message(ai.NewUserMessage([]ai.Content{ai.NewTextContent("Question")}))
+
+	tests := []struct {
+		name     string
+		messages []agent.AgentMessage
+		want     int
+	}{
+		{"None", nil, 0},
+		{"OnlyUser", []agent.AgentMessage{user}, 0},
+		{"Turns", []agent.AgentMessage{user, assistant(), user, assistant(), assistant()}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, countAssistantMessages(tt.messages))
+		})
+	}
+}
@@ -43,6 +43,30 @@ func (e *LoggerExtension) Init(config map[string]any) error {
 	return nil
 }
 
+// RegisterCommands adds /log-verbose to turn detailed logging on or off
+func (e *LoggerExtension) RegisterCommands() []shared.Command {
+	return []shared.Command{
+		{
+			Name:        "log-verbose",
+			Description: "Turn detailed tool call logging on or off",
+			Usage:       "[on|off]",
+			Run: func(ctx context.Context, args []string) (shared.CommandResult, error) {
+				if len(args) > 0 {
+					switch args[0] {
+					case "on":
+						e.verbose = true
+					case "off":
+						e.verbose = false
+					default:
+						return shared.CommandResult{}, fmt.Errorf("expected on or off, got %q", args[0])
+					}
+				}
+				return shared.CommandResult{Output: fmt.Sprintf("Verbose logging: %v", e.verbose)}, nil
+			},
+		},
+	}
+}
+
 // OnToolCall logs tool calls
 func (e *LoggerExtension) OnToolCall(ctx context.Context, toolName string, params map[string]any) (map[string]any, error) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +15,14 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+	"github.com/myersguo/cc-mono/pkg/codingagent/mentions"
+	"github.com/myersguo/cc-mono/pkg/codingagent/statusline"
+	"github.com/myersguo/cc-mono/pkg/codingagent/tools"
+	"github.com/myersguo/cc-mono/pkg/shared"
 )
 
 // ChatModel represents the main TUI model
@@ -37,8 +46,38 @@ type ChatModel struct {
 	messageView      *MessageView
 	spinner          spinner.Model
 	permissionDialog *PermissionDialogModel
+	rewindPicker     *RewindPickerModel
+	listPicker       *ListPicker // Model, session and theme pickers
+	commandPopup     *CommandPopup
+	filePicker       *FilePicker
 	historyManager   *HistoryManager
 
+	// Images pasted or dropped into the editor, referenced there as [Image #N]
+	pendingImages []ai.ImageContent
+
+	// Slash commands; uiCommands are handled by the TUI itself
+	commands    *commands.Registry
+	uiCommands  map[string]func(args []string) tea.Cmd
+	commandDone func() // Undoes what a command set up for its prompt, once answered
+
+	// Models offered by the /model picker
+	models *codingagent.ModelRegistry
+
+	// Session and file checkpoints (for /rewind and /resume)
+	sessionManager  *codingagent.SessionManager
+	session         *codingagent.Session
+	checkpoints     *checkpoint.Store
+	sessionPreviews map[string]string // Previews shown in the session picker, by session ID
+
+	// Background jobs started by the bash tool (for /jobs)
+	background *tools.BackgroundManager
+
+	// Status line above the footer, refreshed after agent events and on a timer
+	statusLineConfig statusline.Config
+	statusLine       string
+	statusLineErr    string
+	statusLineBusy   bool // A refresh is running
+
 	// Hybrid rendering components
 	useHybridMode        bool // Enable hybrid rendering mode
 	lastRenderedIdx      int  // Index of last message rendered to stdout
@@ -54,6 +93,7 @@ type ChatModel struct {
 	statusMessage    string
 	events           <-chan agent.AgentEvent
 	workingDir       string
+	shellCwd         string // Working directory of the bash shell, once known
 
 	// Config
 	autoScroll bool
@@ -127,13 +167,17 @@ func NewChatModel(
 		workingDir = cwd
 	}
 
-	return &ChatModel{
+	m := &ChatModel{
 		styles:              styles,
 		viewport:            vp,
 		editor:              editor,
 		messageView:         messageView,
 		spinner:             s,
 		permissionDialog:    permDialog,
+		rewindPicker:        NewRewindPicker(styles),
+		listPicker:          NewListPicker(styles),
+		commandPopup:        NewCommandPopup(styles),
+		filePicker:          NewFilePicker(styles),
 		historyManager:      historyManager,
 		// 默认使用 hybrid 模式：把已完成消息写入 stdout，交给终端 scrollback 负责“丝滑滚动”。
 		useHybridMode:       true,
@@ -153,11 +197,97 @@ func NewChatModel(
 		showWelcome:         true,
 		workingDir:          workingDir,
 	}
+	m.uiCommands = map[string]func(args []string) tea.Cmd{
+		"rewind": func(args []string) tea.Cmd {
+			m.showRewindPicker()
+			return nil
+		},
+		"jobs": func(args []string) tea.Cmd {
+			return tea.Println(m.renderBackgroundJobs())
+		},
+		"model": func(args []string) tea.Cmd {
+			if len(args) > 0 || m.models == nil {
+				return m.executeCommand(strings.TrimSpace("/model " + strings.Join(args, " ")))
+			}
+			return m.showModelPicker()
+		},
+		"resume": func(args []string) tea.Cmd {
+			if len(args) > 0 {
+				return m.switchSession(args[0])
+			}
+			return m.showSessionPicker()
+		},
+		"vim": func(args []string) tea.Cmd {
+			m.SetVimMode(m.editor.VimMode() == "")
+			if m.editor.VimMode() != "" {
+				m.statusMessage = "Vim mode: on (Esc for normal mode)"
+			} else {
+				m.statusMessage = "Vim mode: off"
+			}
+			return nil
+		},
+		"theme": func(args []string) tea.Cmd {
+			if len(args) > 0 {
+				if !slices.Contains(ThemeNames(), args[0]) {
+					m.error = fmt.Sprintf("Unknown theme %s (themes: %s)", args[0], strings.Join(ThemeNames(), ", "))
+					return nil
+				}
+				return m.applyPick(PickerSelectMsg{Kind: PickerTheme, Value: args[0]})
+			}
+			return m.showThemePicker()
+		},
+	}
+	m.SetCommands(commands.NewRegistry())
+	return m
+}
+
+// SetAuditLog records permission decisions and tool executions to auditLog
+func (m *ChatModel) SetAuditLog(auditLog *agent.AuditLog) {
+	if auditLog != nil {
+		m.ctx = context.WithValue(m.ctx, "audit_log", auditLog)
+	}
+}
+
+// SetSession sets the session the chat belongs to and the checkpoint store that
+// write/edit tools snapshot files into. Both are needed for /rewind.
+func (m *ChatModel) SetSession(sessionManager *codingagent.SessionManager, session *codingagent.Session, checkpoints *checkpoint.Store) {
+	m.sessionManager = sessionManager
+	m.session = session
+	m.checkpoints = checkpoints
+	m.ctx = checkpoint.WithStore(m.ctx, checkpoints)
+}
+
+// SetCommands sets the slash commands and adds the commands only the TUI handles
+func (m *ChatModel) SetCommands(registry *commands.Registry) {
+	m.commands = registry
+	uiCommands := []shared.Command{
+		{Name: "rewind", Description: "Restore the code and/or conversation to before a message"},
+		{Name: "jobs", Description: "List the background jobs started by the bash tool"},
+		{Name: "model", Description: "Pick a model, or switch to the given one", Usage: "[model-id]"},
+		{Name: "resume", Description: "Pick a session to continue, or continue the given one", Usage: "[session-id]"},
+		{Name: "theme", Description: "Pick a theme, or switch to the given one", Usage: "[name]"},
+		{Name: "vim", Description: "Toggle vim keybindings in the editor"},
+	}
+	for _, cmd := range uiCommands {
+		if _, exists := registry.Get(cmd.Name); !exists {
+			_ = registry.Register(cmd)
+		}
+	}
+}
+
+// SetVimMode turns the vim keybindings of the editor on or off
+func (m *ChatModel) SetVimMode(enabled bool) {
+	m.editor.SetVimMode(enabled)
+}
+
+// SetBackgroundJobs sets the manager of background jobs started by the bash tool
+func (m *ChatModel) SetBackgroundJobs(background *tools.BackgroundManager) {
+	m.background = background
 }
 
 // Init initializes the model
 func (m *ChatModel) Init() tea.Cmd {
-	cmds := []tea.Cmd{m.listenForEvents(), m.editor.Focus()}
+	cmds := []tea.Cmd{m.listenForEvents(), m.editor.Focus(), m.refreshStatusLine(), m.statusLineTick()}
 	// 在 hybrid 模式下 UI 不展示 header 的 spinner；禁用 tick 可显著减少无意义重绘，
 	// 否则程序会持续输出 ANSI 更新，导致你在终端 scrollback 顶部很难“稳住”继续滚。
 	if !m.useHybridMode {
@@ -180,6 +310,12 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.MouseMsg:
+		// Pickers take the mouse while they are open
+		if m.listPicker.IsVisible() {
+			var pickerCmd tea.Cmd
+			m.listPicker, pickerCmd = m.listPicker.Update(msg)
+			return m, tea.Batch(pickerCmd, m.pickerClosed())
+		}
 		// Support mouse wheel scrolling even when the editor is focused.
 		// This makes long responses easily scrollable without relying on Ctrl+K/J.
 		if !m.mouseWheelEnabled {
@@ -213,9 +349,13 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Update permission dialog size
 		m.permissionDialog.SetSize(msg.Width, msg.Height)
+		m.rewindPicker.SetSize(msg.Width, msg.Height)
+		m.listPicker.SetSize(msg.Width, msg.Height)
 
 		// Update editor width first
 		m.editor.SetWidth(msg.Width)
+		m.commandPopup.SetWidth(msg.Width)
+		m.filePicker.SetWidth(msg.Width)
 
 		// Calculate component heights
 		headerHeight := lipgloss.Height(m.renderHeader())
@@ -262,6 +402,22 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(cmds...)
 		}
 
+		// Same for the rewind picker
+		if m.rewindPicker.IsVisible() {
+			var pickerCmd tea.Cmd
+			m.rewindPicker, pickerCmd = m.rewindPicker.Update(msg)
+			return m, pickerCmd
+		}
+		if m.listPicker.IsVisible() {
+			if msg.String() == "ctrl+c" {
+				m.listPicker.Hide()
+				return m, m.pickerClosed()
+			}
+			var pickerCmd tea.Cmd
+			m.listPicker, pickerCmd = m.listPicker.Update(msg)
+			return m, tea.Batch(pickerCmd, m.pickerClosed())
+		}
+
 		// Handle global keys first
 		switch msg.String() {
 		case "ctrl+c":
@@ -274,10 +430,22 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Make sure mouse tracking is disabled on exit so terminal scrollback works normally.
 			return m, tea.Batch(disableMouseTracking(), tea.Quit)
 
+		case "ctrl+v":
+			// Paste an image from the clipboard, or else text
+			return m, pasteImage()
+
 		case "ctrl+r":
 			// Regenerate last response
 			return m, m.regenerateLastResponse()
 
+		case "ctrl+o":
+			// Show the whole diff of the last file change
+			return m, m.expandLastDiff()
+
+		case "ctrl+g":
+			// Write the prompt in $VISUAL or $EDITOR
+			return m, m.openExternalEditor()
+
 		case "ctrl+m":
 			// Toggle between "TUI captures mouse wheel" and "terminal handles smooth scrollback".
 			m.mouseWheelEnabled = !m.mouseWheelEnabled
@@ -314,39 +482,92 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.showWelcome {
 				m.showWelcome = false
 			}
+			// The command popup takes the navigation keys while it is open
+			if m.commandPopup.IsVisible() && m.handleCommandPopupKey(msg) {
+				return m, nil
+			}
+			// So does the file picker
+			if m.filePicker.IsVisible() && m.handleFilePickerKey(msg) {
+				return m, nil
+			}
+			// A dropped image file is pasted as its path; attach the image instead
+			if msg.Type == tea.KeyRunes && (msg.Paste || len(msg.Runes) > 1) {
+				if path, ok := droppedPath(string(msg.Runes), m.workingDir); ok {
+					m.attachImage(images.Load(path))
+					return m, nil
+				}
+			}
 			// Let editor handle all other keys
 			var editorCmd tea.Cmd
 			m.editor, editorCmd = m.editor.Update(msg)
+			m.commandPopup.Update(m.commands, m.editor.Value())
+			m.filePicker.Update(m.editor.Value())
+			if _, ok := MentionQuery(m.editor.Value()); ok {
+				return m, tea.Batch(editorCmd, m.filePicker.Load(m.workingDir))
+			}
 			return m, editorCmd
 		}
 
 	case EditorSubmitMsg:
 		// User submitted a message
 		m.editor.Reset()
+		m.commandPopup.Hide()
+		m.filePicker.Hide()
 
 		// Hide welcome screen once user starts chatting
 		m.showWelcome = false
 
-		// Add user message
-		userMsg := ai.UserMessage{
-			Type:      ai.MessageTypeUser,
-			Content:   []ai.Content{ai.NewTextContent(msg.Content)},
-			Timestamp: time.Now().UnixMilli(),
+		if m.isCommand(msg.Content) {
+			return m, m.runCommand(strings.TrimSpace(msg.Content))
 		}
 
-		agentMsg := agent.NewAgentMessage(
-			userMsg,
-			fmt.Sprintf("user-%d", time.Now().UnixNano()),
-			time.Now().UnixMilli(),
-		)
+		cmd := m.sendPrompt(msg.Content)
+		if cmd == nil {
+			// Keep the prompt, e.g. to switch to a model that can see its images
+			m.editor.SetValue(msg.Content)
+		}
+		return m, cmd
 
-		m.messages = append(m.messages, agentMsg)
-		m.agentState.AddMessage(agentMsg)
-		m.autoScroll = true // Enable auto-scroll for new user message
-		m.updateViewportContent()
+	case ClipboardImageMsg:
+		if !msg.Found && msg.Err == nil {
+			var editorCmd tea.Cmd
+			m.editor, editorCmd = m.editor.Update(tea.KeyMsg{Type: tea.KeyCtrlV})
+			return m, editorCmd
+		}
+		m.attachImage(msg.Image, msg.Err)
+		return m, nil
 
-		// Start agent loop
-		return m, m.startAgent([]agent.AgentMessage{agentMsg})
+	case CommandResultMsg:
+		return m, tea.Batch(m.applyCommandResult(msg), m.refreshStatusLine())
+
+	case FileListMsg:
+		if msg.Err != nil {
+			m.statusMessage = fmt.Sprintf("Failed to list files: %v", msg.Err)
+		}
+		m.filePicker.SetFiles(msg.Files)
+		m.filePicker.Update(m.editor.Value())
+		return m, nil
+
+	case RewindMsg:
+		return m, tea.Batch(m.rewind(msg), m.refreshStatusLine())
+
+	case PickerSelectMsg:
+		return m, tea.Batch(m.applyPick(msg), m.refreshStatusLine())
+
+	case StatusLineMsg:
+		m.applyStatusLine(msg)
+		return m, nil
+
+	case ExternalEditorMsg:
+		m.applyExternalEdit(msg)
+		return m, nil
+
+	case ThemeChangedMsg:
+		m.applyThemeChange(msg)
+		return m, nil
+
+	case statusLineTickMsg:
+		return m, tea.Batch(m.refreshStatusLine(), m.statusLineTick())
 
 	case EditorCancelMsg:
 		// User cancelled editing (Esc key)
@@ -356,12 +577,18 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case agent.AgentEvent:
 		// Handle agent events
 		model, cmd := m.handleAgentEvent(msg)
+		if refreshesStatusLine(msg) {
+			cmd = tea.Batch(cmd, m.refreshStatusLine())
+		}
 		// Continue listening for more events
 		return model, tea.Batch(cmd, m.listenForEvents())
 
 	case AgentEventMsg:
 		// Received event from listener
 		model, cmd := m.handleAgentEvent(msg.Event)
+		if refreshesStatusLine(msg.Event) {
+			cmd = tea.Batch(cmd, m.refreshStatusLine())
+		}
 		// Continue listening for more events
 		return model, tea.Batch(cmd, m.listenForEvents())
 
@@ -420,6 +647,12 @@ func (m *ChatModel) View() string {
 		// Just render the dialog, it will be centered
 		return m.permissionDialog.View()
 	}
+	if m.rewindPicker.IsVisible() {
+		return m.rewindPicker.View()
+	}
+	if m.listPicker.IsVisible() {
+		return m.listPicker.View()
+	}
 
 	// In hybrid mode, only render streaming content (if any), editor and footer
 	// All completed messages are output to stdout via tea.Println
@@ -431,6 +664,14 @@ func (m *ChatModel) View() string {
 			sections = append(sections, m.streamingContent)
 		}
 
+		// Command and file completions go above the editor
+		if m.commandPopup.IsVisible() {
+			sections = append(sections, m.commandPopup.View())
+		}
+		if m.filePicker.IsVisible() {
+			sections = append(sections, m.filePicker.View())
+		}
+
 		// Always show editor and footer
 		sections = append(sections, m.editor.View(), m.renderFooter())
 
@@ -441,9 +682,14 @@ func (m *ChatModel) View() string {
 	sections := []string{
 		m.renderHeader(),
 		m.viewport.View(),
-		m.editor.View(),
-		m.renderFooter(),
 	}
+	if m.commandPopup.IsVisible() {
+		sections = append(sections, m.commandPopup.View())
+	}
+	if m.filePicker.IsVisible() {
+		sections = append(sections, m.filePicker.View())
+	}
+	sections = append(sections, m.editor.View(), m.renderFooter())
 
 	return lipgloss.JoinVertical(lipgloss.Left, sections...)
 }
@@ -462,23 +708,45 @@ func (m *ChatModel) renderHeader() string {
 func (m *ChatModel) renderFooter() string {
 	var parts []string
 
+	// Vim mode, e.g. -- NORMAL --
+	if status := m.editor.VimStatus(); status != "" {
+		parts = append(parts, m.styles.HelpKey.Render(status))
+	}
+
 	if m.error != "" {
 		parts = append(parts, m.styles.Error.Render("Error: "+m.error))
 	} else if m.statusMessage != "" {
 		parts = append(parts, m.styles.HelpValue.Render(m.statusMessage))
 	}
 
+	// Running background jobs
+	if m.background != nil {
+		if running := m.background.Running(); running == 1 {
+			parts = append(parts, m.styles.HelpValue.Render("1 background job"))
+		} else if running > 1 {
+			parts = append(parts, m.styles.HelpValue.Render(fmt.Sprintf("%d background jobs", running)))
+		}
+	}
+
 	// Help text
 	help := []string{
 		m.styles.HelpKey.Render("Ctrl+C") + m.styles.HelpValue.Render(" quit"),
 		m.styles.HelpKey.Render("Ctrl+K/J") + m.styles.HelpValue.Render(" scroll"),
 		m.styles.HelpKey.Render("Ctrl+M") + m.styles.HelpValue.Render(" mouse"),
 		m.styles.HelpKey.Render("Ctrl+R") + m.styles.HelpValue.Render(" regenerate"),
+		m.styles.HelpKey.Render("Ctrl+O") + m.styles.HelpValue.Render(" diff"),
+		m.styles.HelpKey.Render("/") + m.styles.HelpValue.Render(" commands"),
+		m.styles.HelpKey.Render("@") + m.styles.HelpValue.Render(" files"),
 	}
 	parts = append(parts, strings.Join(help, " • "))
 
-	footer := strings.Join(parts, " | ")
-	return m.styles.Footer.Width(m.width).Render(footer)
+	footer := m.styles.Footer.Width(m.width).Render(strings.Join(parts, " | "))
+
+	// The status line shows the model, context, cost, working directory and branch
+	if status := m.renderStatusLine(); status != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, status, footer)
+	}
+	return footer
 }
 
 func enableMouseTracking() tea.Cmd {
@@ -620,8 +888,10 @@ func (m *ChatModel) handleAgentEvent(event agent.AgentEvent) (tea.Model, tea.Cmd
 
 	case agent.AgentEndEvent:
 		m.isAgentRunning = false
+		m.finishCommand()
 		m.statusMessage = "Agent completed"
 		m.messages = e.Messages
+		m.saveSession()
 		// Reset render tracking since messages were replaced
 		if m.useHybridMode {
 			m.lastRenderedIdx = -1
@@ -695,7 +965,13 @@ func (m *ChatModel) handleAgentEvent(event agent.AgentEvent) (tea.Model, tea.Cmd
 		m.messages = append(m.messages, toolCallMsg)
 		m.updateViewportContent()
 
+	case agent.SubAgentEvent:
+		m.handleSubAgentEvent(e)
+
 	case agent.ToolExecutionEndEvent:
+		if cwd := shellCwd(e.Result); cwd != "" {
+			m.shellCwd = cwd
+		}
 		if e.IsError {
 			m.statusMessage = fmt.Sprintf("Tool %s failed", e.ToolName)
 		} else {
@@ -710,15 +986,20 @@ func (m *ChatModel) handleAgentEvent(event agent.AgentEvent) (tea.Model, tea.Cmd
 		if e.Request.RiskLevel == "safe" {
 			if pm := m.ctx.Value("permission_manager"); pm != nil {
 				permManager := pm.(*agent.PermissionManager)
-				permManager.RespondToRequest(e.Request.RequestID, true, false, "project")
+				permManager.RespondToRequestAs(e.Request.RequestID, true, false, "project", agent.DecidedByAuto)
 			}
 		} else {
 			// For medium/dangerous operations, show dialog
 			m.permissionDialog.Show(e.Request)
+			// along with the change a file tool is about to make
+			if preview, ok := tools.PreviewDiff(m.workingDir, e.Request.ToolName, e.Request.Params); ok {
+				m.permissionDialog.SetDiff(preview)
+			}
 		}
 
 	case agent.ErrorEvent:
 		m.isAgentRunning = false
+		m.finishCommand()
 		if e.Context != "" {
 			m.error = fmt.Sprintf("%s: %s", e.Context, e.Error)
 			// Print error to stderr for debugging
@@ -733,6 +1014,341 @@ func (m *ChatModel) handleAgentEvent(event agent.AgentEvent) (tea.Model, tea.Cmd
 	return m, nil
 }
 
+// handleSubAgentEvent shows the progress of a sub-agent started by a task tool call
+func (m *ChatModel) handleSubAgentEvent(e agent.SubAgentEvent) {
+	switch inner := e.Event.(type) {
+	case agent.TurnStartEvent:
+		m.statusMessage = fmt.Sprintf("Sub-agent %s working...", e.AgentType)
+
+	case agent.ToolExecutionStartEvent:
+		m.statusMessage = fmt.Sprintf("Sub-agent %s running tool: %s", e.AgentType, inner.ToolName)
+
+		// Show the sub-agent's tool calls nested under the task call
+		prefix := lipgloss.NewStyle().Foreground(m.styles.Theme.Muted).Render(fmt.Sprintf("  ⎿ %s ", e.AgentType))
+		toolCallMsg := agent.AgentMessage{
+			Message: ai.AssistantMessage{
+				Type: ai.MessageTypeAssistant,
+				Content: []ai.Content{
+					ai.NewTextContent(prefix + m.messageView.RenderToolCallDisplay(inner.ToolName, inner.Args)),
+				},
+				Model:     "", // Empty model name to avoid showing role header
+				Timestamp: time.Now().UnixMilli(),
+			},
+			ID:        fmt.Sprintf("toolcall-%s-%s", e.ParentToolCallID, inner.ToolCallID),
+			CreatedAt: time.Now().UnixMilli(),
+		}
+		m.messages = append(m.messages, toolCallMsg)
+		m.updateViewportContent()
+
+	case agent.ToolExecutionEndEvent:
+		if cwd := shellCwd(inner.Result); cwd != "" {
+			m.shellCwd = cwd
+		}
+
+	case agent.ErrorEvent:
+		m.statusMessage = fmt.Sprintf("Sub-agent %s error: %s", e.AgentType, inner.Error)
+	}
+}
+
+// renderBackgroundJobs lists the background jobs started by the bash tool
+func (m *ChatModel) renderBackgroundJobs() string {
+	var jobs []tools.BackgroundJobInfo
+	if m.background != nil {
+		jobs = m.background.List()
+	}
+	if len(jobs) == 0 {
+		return m.styles.HelpValue.Render("No background jobs.")
+	}
+
+	var b strings.Builder
+	b.WriteString(m.styles.HelpKey.Render("Background jobs:"))
+	for _, job := range jobs {
+		status := job.Status
+		if job.Status != tools.JobRunning {
+			status = fmt.Sprintf("%s (%d)", job.Status, job.ExitCode)
+		}
+		b.WriteString(fmt.Sprintf("\n  %s  %-12s %s  %s", job.ID, status,
+			job.StartedAt.Format("15:04:05"), truncateLine(job.Command, 60)))
+	}
+	return b.String()
+}
+
+// expandLastDiff expands the diff of the last edit, write or patch, which the
+// transcript caps. Printed output cannot change in hybrid mode, so the whole
+// diff is printed again there; in viewport mode the diff toggles in place.
+func (m *ChatModel) expandLastDiff() tea.Cmd {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		result, ok := m.messages[i].Message.(ai.ToolResultMessage)
+		if !ok || result.IsError || toolResultDiff(result.Details) == "" {
+			continue
+		}
+		if !m.useHybridMode {
+			m.messageView.ToggleExpanded(result.ToolCallID)
+			m.updateViewportContent()
+			return nil
+		}
+		if !m.messageView.IsExpanded(result.ToolCallID) {
+			m.messageView.ToggleExpanded(result.ToolCallID)
+		}
+		return tea.Println(m.messageView.Render(m.messages[i], m.width))
+	}
+	m.statusMessage = "No diff to expand"
+	return nil
+}
+
+// shellCwd returns the shell working directory reported by a bash tool result, if any
+func shellCwd(result any) string {
+	toolResult, ok := result.(agent.AgentToolResult)
+	if !ok {
+		return ""
+	}
+	details, ok := toolResult.Details.(map[string]any)
+	if !ok {
+		return ""
+	}
+	cwd, _ := details["cwd"].(string)
+	return cwd
+}
+
+// sendPrompt sends text to the agent as a user message
+func (m *ChatModel) sendPrompt(text string) tea.Cmd {
+	// Files mentioned as @path go along with the prompt
+	content := []ai.Content{ai.NewTextContent(text)}
+	attachments, err := mentions.Attach(m.workingDir, text)
+	if err != nil {
+		m.statusMessage = err.Error()
+	}
+	content = append(content, attachments...)
+
+	// So do the pasted images still referenced in it
+	for i, image := range m.pendingImages {
+		if strings.Contains(text, imagePlaceholder(i+1)) {
+			content = append(content, image)
+		}
+	}
+	if err := images.CheckModel(m.agentState.GetModel(), content); err != nil {
+		m.statusMessage = err.Error()
+		return nil
+	}
+	m.pendingImages = nil
+
+	userMsg := ai.UserMessage{
+		Type:      ai.MessageTypeUser,
+		Content:   content,
+		Timestamp: time.Now().UnixMilli(),
+	}
+
+	agentMsg := agent.NewAgentMessage(
+		userMsg,
+		fmt.Sprintf("user-%d", time.Now().UnixNano()),
+		time.Now().UnixMilli(),
+	)
+
+	// Start a checkpoint so file changes made for this message can be rewound
+	if m.checkpoints != nil {
+		if err := m.checkpoints.Begin(agentMsg.ID); err != nil {
+			m.statusMessage = fmt.Sprintf("Checkpoint failed: %v", err)
+		}
+	}
+
+	// The agent loop adds the prompt to the history
+	m.messages = append(m.messages, agentMsg)
+	m.autoScroll = true // Enable auto-scroll for new user message
+	m.updateViewportContent()
+
+	// Start agent loop
+	return m.startAgent([]agent.AgentMessage{agentMsg})
+}
+
+// attachImage adds a pasted image to the pending images and puts its
+// placeholder in the editor
+func (m *ChatModel) attachImage(image ai.ImageContent, err error) {
+	if err != nil {
+		m.statusMessage = err.Error()
+		return
+	}
+	m.pendingImages = append(m.pendingImages, image)
+	placeholder := imagePlaceholder(len(m.pendingImages))
+	if value := m.editor.Value(); value != "" && !strings.HasSuffix(value, " ") && !strings.HasSuffix(value, "\n") {
+		placeholder = " " + placeholder
+	}
+	m.editor.InsertString(placeholder + " ")
+	m.showWelcome = false
+	m.statusMessage = fmt.Sprintf("Attached image (%s)", image.Source.MediaType)
+}
+
+// handleFilePickerKey moves through or completes the file picker, and reports
+// whether it used the key
+func (m *ChatModel) handleFilePickerKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyUp:
+		m.filePicker.Previous()
+	case tea.KeyDown:
+		m.filePicker.Next()
+	case tea.KeyTab, tea.KeyEnter:
+		if completed, ok := m.filePicker.Complete(m.editor.Value()); ok {
+			m.editor.SetValue(completed)
+		}
+		m.filePicker.Update(m.editor.Value())
+	case tea.KeyEsc:
+		m.filePicker.Hide()
+	default:
+		return false
+	}
+	return true
+}
+
+// handleCommandPopupKey moves through or completes the command popup, and
+// reports whether it used the key
+func (m *ChatModel) handleCommandPopupKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyUp:
+		m.commandPopup.Previous()
+	case tea.KeyDown:
+		m.commandPopup.Next()
+	case tea.KeyTab:
+		if cmd, ok := m.commandPopup.Selected(); ok {
+			m.editor.SetValue("/" + cmd.Name + " ")
+		}
+		m.commandPopup.Hide()
+	case tea.KeyEsc:
+		m.commandPopup.Hide()
+	case tea.KeyEnter:
+		// Enter runs the selected command when the name is only partly typed
+		if cmd, ok := m.commandPopup.Selected(); ok && strings.TrimSpace(m.editor.Value()) != "/"+cmd.Name {
+			m.editor.SetValue("/" + cmd.Name)
+		}
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// isCommand reports whether input runs a known command. Other input starting
+// with a slash, such as "/tmp is full", is sent as a prompt.
+func (m *ChatModel) isCommand(input string) bool {
+	if !commands.IsCommand(input) {
+		return false
+	}
+	name, _, err := commands.Split(input)
+	if err != nil {
+		return false
+	}
+	if _, ok := m.uiCommands[name]; ok {
+		return true
+	}
+	if m.commands == nil {
+		return false
+	}
+	_, ok := m.commands.Get(name)
+	return ok
+}
+
+// runCommand runs a slash command. Commands from the registry run in the
+// background and report back with a CommandResultMsg.
+func (m *ChatModel) runCommand(line string) tea.Cmd {
+	name, _, err := commands.Split(line)
+	if err != nil {
+		m.error = err.Error()
+		return nil
+	}
+	m.error = ""
+
+	if run, ok := m.uiCommands[name]; ok {
+		_, args, err := commands.Parse(line)
+		if err != nil {
+			m.error = err.Error()
+			return nil
+		}
+		return run(args)
+	}
+	return m.executeCommand(line)
+}
+
+// executeCommand runs a command from the registry in the background
+func (m *ChatModel) executeCommand(line string) tea.Cmd {
+	name, _, _ := commands.Split(line)
+	m.statusMessage = fmt.Sprintf("Running /%s...", name)
+	ctx := m.ctx
+	return func() tea.Msg {
+		result, err := m.commands.Execute(ctx, line)
+		return CommandResultMsg{Line: line, Result: result, Err: err}
+	}
+}
+
+// applyCommandResult shows the output of a command and picks up the changes it
+// made to the conversation, model or session
+func (m *ChatModel) applyCommandResult(msg CommandResultMsg) tea.Cmd {
+	m.statusMessage = ""
+	if msg.Err != nil {
+		m.error = msg.Err.Error()
+		return nil
+	}
+
+	// Commands such as /clear and /compact replace the history. Already printed
+	// messages stay in the terminal scrollback; only render new ones.
+	if !m.isAgentRunning {
+		m.messages = m.agentState.GetMessages()
+		m.streamingMessage = nil
+		m.lastRenderedIdx = len(m.messages) - 1
+		m.updateViewportContent()
+	}
+	m.modelName = m.agentState.GetModel().Name
+
+	// /clear and /fork continue in a new session
+	if m.sessionManager != nil {
+		if current := m.sessionManager.GetCurrent(); current != nil && current != m.session {
+			m.session = current
+			if auditLog, ok := m.ctx.Value("audit_log").(*agent.AuditLog); ok {
+				auditLog.SetSessionID(current.Metadata.ID)
+			}
+			m.openCheckpoints(current.Metadata.ID)
+		}
+	}
+
+	var cmds []tea.Cmd
+	output := m.styles.HelpKey.Render("> " + msg.Line)
+	if msg.Result.Output != "" {
+		output += "\n" + msg.Result.Output
+	}
+	cmds = append(cmds, tea.Println(output))
+	if msg.Result.Prompt != "" {
+		m.commandDone = msg.Result.Done
+		if cmd := m.sendPrompt(msg.Result.Prompt); cmd != nil {
+			cmds = append(cmds, cmd)
+		} else {
+			m.finishCommand()
+		}
+	} else if msg.Result.Done != nil {
+		msg.Result.Done()
+	}
+	return tea.Sequence(cmds...)
+}
+
+// openCheckpoints switches to the file checkpoints of the session, so each
+// session rewinds to its own
+func (m *ChatModel) openCheckpoints(sessionID string) {
+	if m.checkpoints == nil {
+		return
+	}
+	store, err := checkpoint.Open(filepath.Join(filepath.Dir(m.checkpoints.Dir()), sessionID))
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("File checkpoints disabled: %v", err)
+		return
+	}
+	m.checkpoints = store
+	m.ctx = checkpoint.WithStore(m.ctx, store)
+}
+
+// finishCommand undoes the settings of the command whose prompt was answered
+func (m *ChatModel) finishCommand() {
+	if m.commandDone != nil {
+		m.commandDone()
+		m.commandDone = nil
+	}
+}
+
 // startAgent starts the agent loop
 func (m *ChatModel) startAgent(prompts []agent.AgentMessage) tea.Cmd {
 	return func() tea.Msg {
@@ -774,6 +1390,69 @@ func (m *ChatModel) regenerateLastResponse() tea.Cmd {
 	return nil
 }
 
+// showRewindPicker opens the /rewind picker
+func (m *ChatModel) showRewindPicker() {
+	if m.isAgentRunning {
+		m.error = "Cannot rewind while the agent is running"
+		return
+	}
+	if m.sessionManager == nil || m.session == nil {
+		m.error = "Rewind is not available without a session"
+		return
+	}
+	m.error = ""
+	m.rewindPicker.Show(codingagent.GetRewindPoints(m.agentState, m.checkpoints))
+}
+
+// rewind restores the code and/or conversation picked in the rewind picker
+func (m *ChatModel) rewind(msg RewindMsg) tea.Cmd {
+	if m.isAgentRunning {
+		m.error = "Cannot rewind while the agent is running"
+		return nil
+	}
+
+	result, err := m.sessionManager.Rewind(m.session, m.checkpoints, msg.MessageID, msg.Mode, msg.Fork)
+	if err != nil {
+		m.error = fmt.Sprintf("Rewind failed: %v", err)
+		return nil
+	}
+	m.error = ""
+
+	var summary []string
+	if msg.Mode != codingagent.RewindConversation {
+		summary = append(summary, fmt.Sprintf("restored %d file(s)", len(result.RestoredFiles)))
+	}
+	if msg.Mode != codingagent.RewindCode {
+		summary = append(summary, "rewound the conversation")
+
+		// Already printed messages stay in the terminal scrollback; only render new ones
+		m.messages = m.agentState.GetMessages()
+		m.streamingMessage = nil
+		m.lastRenderedIdx = len(m.messages) - 1
+		m.updateViewportContent()
+
+		// Put the prompt back so it can be edited and resent
+		m.editor.SetValue(result.Prompt)
+	}
+
+	// Forking continues in a new session with its own checkpoints
+	if result.Session != m.session {
+		m.session = result.Session
+		if auditLog, ok := m.ctx.Value("audit_log").(*agent.AuditLog); ok {
+			auditLog.SetSessionID(result.Session.Metadata.ID)
+		}
+		summary = append(summary, fmt.Sprintf("branched into %s", result.Session.Metadata.ID))
+	}
+	if result.Checkpoints != m.checkpoints {
+		m.checkpoints = result.Checkpoints
+		m.ctx = checkpoint.WithStore(m.ctx, result.Checkpoints)
+	}
+
+	notice := "⏪ Rewind: " + strings.Join(summary, ", ")
+	m.statusMessage = notice
+	return tea.Println(m.styles.HelpValue.Render(notice))
+}
+
 // listenForEvents listens for agent events
 func (m *ChatModel) listenForEvents() tea.Cmd {
 	// Subscribe to events
@@ -794,6 +1473,13 @@ type AgentEventMsg struct {
 // RefreshMsg triggers a view refresh without any state change
 type RefreshMsg struct{}
 
+// CommandResultMsg carries the result of a slash command run in the background
+type CommandResultMsg struct {
+	Line   string
+	Result shared.CommandResult
+	Err    error
+}
+
 // renderWelcomeScreen renders the welcome screen
 func (m *ChatModel) renderWelcomeScreen() string {
 	if m.width == 0 || m.height == 0 {
@@ -810,7 +1496,8 @@ func (m *ChatModel) renderWelcomeScreen() string {
 	if m.useHybridMode {
 		welcomeMsg := fmt.Sprintf("\n✨ Welcome back %s!\n", username)
 		welcomeMsg += fmt.Sprintf("   Model: %s\n", m.modelName)
-		welcomeMsg += fmt.Sprintf("   Working directory: %s\n\n", m.workingDir)
+		welcomeMsg += fmt.Sprintf("   Working directory: %s\n", m.workingDir)
+		welcomeMsg += "   Type /help for commands\n\n"
 		fmt.Print(welcomeMsg)
 
 		// Hide welcome after first render in hybrid mode
@@ -831,7 +1518,7 @@ func (m *ChatModel) renderWelcomeScreen() string {
 	leftPanel := lipgloss.NewStyle().
 		Width(m.width/2).
 		Height(contentHeight).
-		BorderStyle(lipgloss.RoundedBorder()).
+		BorderStyle(m.styles.Border).
 		BorderForeground(m.styles.Theme.Primary).
 		Padding(2, 4)
 
@@ -878,7 +1565,7 @@ func (m *ChatModel) renderWelcomeScreen() string {
 
 	tipsContent := lipgloss.NewStyle().
 		Foreground(m.styles.Theme.Foreground).
-		Render("• Just start typing to begin\n• Use Ctrl+J to insert a new line\n• Press Enter to send your message\n• Use Esc to clear input")
+		Render("• Just start typing to begin\n• Use Ctrl+J to insert a new line\n• Press Enter to send your message\n• Use Esc to clear input\n• Type / for commands, @ to mention a file")
 
 	// Recent activity section
 	activityTitle := lipgloss.NewStyle().
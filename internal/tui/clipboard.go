@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+)
+
+// ClipboardImageMsg carries the image pasted from the clipboard. Found is
+// false when the clipboard holds no image, so the paste goes to the editor.
+type ClipboardImageMsg struct {
+	Image ai.ImageContent
+	Found bool
+	Err   error
+}
+
+// clipboardCommands read a PNG from the clipboard, tried in order
+var clipboardCommands = [][]string{
+	{"wl-paste", "--no-newline", "--type", "image/png"},
+	{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+}
+
+// pasteImage reads an image from the clipboard with wl-paste or xclip,
+// whichever is installed
+func pasteImage() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		for _, args := range clipboardCommands {
+			if args[0] == "wl-paste" && os.Getenv("WAYLAND_DISPLAY") == "" {
+				continue
+			}
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			// Both fail when the clipboard holds no PNG
+			data, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			image, err := images.FromBytes(data, "image/png")
+			if err != nil {
+				return ClipboardImageMsg{Err: fmt.Errorf("failed to paste image: %w", err)}
+			}
+			return ClipboardImageMsg{Image: image, Found: true}
+		}
+		return ClipboardImageMsg{}
+	}
+}
+
+// droppedPath returns the image file that pasted text names, as terminals
+// paste a file dropped onto them: possibly quoted, with backslash-escaped
+// spaces or as a file:// URL
+func droppedPath(text, workingDir string) (string, bool) {
+	path := strings.TrimSpace(text)
+	if path == "" || strings.ContainsAny(path, "\n\r") {
+		return "", false
+	}
+
+	if len(path) >= 2 && (path[0] == '\'' || path[0] == '"') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	} else if strings.HasPrefix(path, "file://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", false
+		}
+		path = u.Path
+	} else {
+		path = strings.ReplaceAll(path, `\ `, " ")
+	}
+
+	if images.MediaType(path) == "" {
+		return "", false
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
+
+// imagePlaceholder is the text that stands for the nth attached image in the editor
+func imagePlaceholder(n int) string {
+	return fmt.Sprintf("[Image #%d]", n)
+}
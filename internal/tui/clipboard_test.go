@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDroppedPath(t *testing.T) {
+	dir := t.TempDir()
+	shot := filepath.Join(dir, "my shot.png")
+	require.NoError(t, os.WriteFile(shot, []byte("\x89PNG"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644))
+
+	for _, text := range []string{
+		shot,
+		"'" + shot + "'",
+		filepath.Join(dir, `my\ shot.png`) + " ",
+		"file://" + filepath.ToSlash(dir) + "/my%20shot.png",
+		"my shot.png",
+	} {
+		path, ok := droppedPath(text, dir)
+		assert.True(t, ok, text)
+		assert.Equal(t, shot, path, text)
+	}
+
+	for _, text := range []string{"notes.txt", "missing.png", "look at my shot.png", shot + "\n" + shot} {
+		_, ok := droppedPath(text, dir)
+		assert.False(t, ok, text)
+	}
+}
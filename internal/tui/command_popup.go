@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/shared"
+)
+
+// maxCommandMatches is the number of completions shown at once
+const maxCommandMatches = 8
+
+// CommandPopup lists the commands matching what is typed after "/"
+type CommandPopup struct {
+	styles   *Styles
+	matches  []shared.Command
+	selected int
+	width    int
+}
+
+// NewCommandPopup creates a new command completion popup
+func NewCommandPopup(styles *Styles) *CommandPopup {
+	return &CommandPopup{styles: styles}
+}
+
+// Update shows the commands that complete input, or hides the popup once
+// input is no longer a command name
+func (p *CommandPopup) Update(registry *commands.Registry, input string) {
+	if !strings.HasPrefix(input, "/") || strings.ContainsAny(input, " \t\n") {
+		p.Hide()
+		return
+	}
+
+	var selectedName string
+	if cmd, ok := p.Selected(); ok {
+		selectedName = cmd.Name
+	}
+
+	p.matches = registry.Complete(input[1:])
+	p.selected = 0
+	for i, cmd := range p.matches {
+		if cmd.Name == selectedName {
+			p.selected = i
+		}
+	}
+}
+
+// Hide hides the popup
+func (p *CommandPopup) Hide() {
+	p.matches = nil
+	p.selected = 0
+}
+
+// IsVisible returns whether any commands match
+func (p *CommandPopup) IsVisible() bool {
+	return len(p.matches) > 0
+}
+
+// SetWidth sets the popup width
+func (p *CommandPopup) SetWidth(width int) {
+	p.width = width
+}
+
+// Next selects the next match
+func (p *CommandPopup) Next() {
+	if len(p.matches) > 0 {
+		p.selected = (p.selected + 1) % len(p.matches)
+	}
+}
+
+// Previous selects the previous match
+func (p *CommandPopup) Previous() {
+	if len(p.matches) > 0 {
+		p.selected = (p.selected - 1 + len(p.matches)) % len(p.matches)
+	}
+}
+
+// Selected returns the selected command
+func (p *CommandPopup) Selected() (shared.Command, bool) {
+	if p.selected >= len(p.matches) {
+		return shared.Command{}, false
+	}
+	return p.matches[p.selected], true
+}
+
+// View renders the matches around the selection
+func (p *CommandPopup) View() string {
+	if !p.IsVisible() {
+		return ""
+	}
+
+	start := 0
+	if p.selected >= maxCommandMatches {
+		start = p.selected - maxCommandMatches + 1
+	}
+	end := min(start+maxCommandMatches, len(p.matches))
+
+	width := 0
+	for _, cmd := range p.matches[start:end] {
+		width = max(width, len(commands.Synopsis(cmd)))
+	}
+
+	muted := lipgloss.NewStyle().Foreground(p.styles.Theme.Muted)
+	var lines []string
+	for i := start; i < end; i++ {
+		cmd := p.matches[i]
+		name := fmt.Sprintf("%-*s", width, commands.Synopsis(cmd))
+		description := truncateLine(cmd.Description, p.width-width-6)
+		if i == p.selected {
+			selected := lipgloss.NewStyle().Foreground(p.styles.Theme.Primary).Bold(true)
+			lines = append(lines, selected.Render("❯ "+name)+"  "+description)
+		} else {
+			lines = append(lines, "  "+name+"  "+muted.Render(description))
+		}
+	}
+	if len(p.matches) > end-start {
+		lines = append(lines, muted.Render(fmt.Sprintf("  %d of %d · ↑/↓ to select · Tab to complete", p.selected+1, len(p.matches))))
+	} else {
+		lines = append(lines, muted.Render("  ↑/↓ to select · Tab to complete"))
+	}
+	return strings.Join(lines, "\n")
+}
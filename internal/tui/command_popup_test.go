@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandPopup(t *testing.T) {
+	registry := commands.NewRegistry()
+	for _, name := range []string{"clear", "compact", "cost", "help"} {
+		require.NoError(t, registry.Register(shared.Command{Name: name, Description: "Run " + name}))
+	}
+	popup := NewCommandPopup(NewStyles(GetTheme("dark")))
+	popup.SetWidth(80)
+
+	popup.Update(registry, "/c")
+	require.True(t, popup.IsVisible())
+	selected, _ := popup.Selected()
+	assert.Equal(t, "clear", selected.Name)
+
+	popup.Next()
+	popup.Next()
+	selected, _ = popup.Selected()
+	assert.Equal(t, "cost", selected.Name)
+
+	// The selection follows the command while more is typed
+	popup.Update(registry, "/co")
+	selected, _ = popup.Selected()
+	assert.Equal(t, "cost", selected.Name)
+	assert.Contains(t, popup.View(), "/compact")
+	assert.NotContains(t, popup.View(), "/clear")
+
+	popup.Previous()
+	selected, _ = popup.Selected()
+	assert.Equal(t, "compact", selected.Name)
+
+	// Arguments or unknown names close the popup
+	popup.Update(registry, "/cost now")
+	assert.False(t, popup.IsVisible())
+	popup.Update(registry, "/x")
+	assert.False(t, popup.IsVisible())
+	popup.Update(registry, "hello")
+	assert.False(t, popup.IsVisible())
+}
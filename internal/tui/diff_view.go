@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
+)
+
+// maxCollapsedDiffLines caps the diff shown under a tool result until it is expanded
+const maxCollapsedDiffLines = 40
+
+// fileDiff is the part of a unified diff about one file
+type fileDiff struct {
+	name  string
+	hunks []diff.Hunk
+}
+
+// splitFileDiffs splits a unified diff into its files at the "--- "/"+++ " headers
+func splitFileDiffs(text string) []fileDiff {
+	lines := strings.Split(text, "\n")
+	var files []fileDiff
+	start, name := 0, ""
+	flush := func(end int) {
+		if hunks := diff.Parse(strings.Join(lines[start:end], "\n")); len(hunks) > 0 {
+			files = append(files, fileDiff{name: name, hunks: hunks})
+		}
+	}
+	for i := 0; i+1 < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "--- ") && strings.HasPrefix(lines[i+1], "+++ ") {
+			flush(i)
+			start, name = i+2, strings.TrimPrefix(lines[i+1], "+++ ")
+			i++
+		}
+	}
+	flush(len(lines))
+	return files
+}
+
+// renderDiff renders a unified diff with old and new line numbers, colored
+// changes and the changed words of a replaced line highlighted. Unchanged
+// lines between hunks are folded into a marker. At most maxLines lines are
+// rendered, or all of them when maxLines is 0; hidden counts the rest.
+func renderDiff(styles *Styles, text string, width, maxLines int) (rendered string, hidden int) {
+	var lines []string
+	for _, file := range splitFileDiffs(text) {
+		lines = append(lines, renderFileDiff(styles, file, width)...)
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		hidden = len(lines) - maxLines
+		lines = lines[:maxLines]
+	}
+	return strings.Join(lines, "\n"), hidden
+}
+
+// renderFileDiff renders the diff of one file, headed by its name and stats
+func renderFileDiff(styles *Styles, file fileDiff, width int) []string {
+	var lines []string
+
+	added, removed, last := 0, 0, 0
+	for _, hunk := range file.hunks {
+		a, r := diff.Stats(hunk.Lines)
+		added += a
+		removed += r
+		last = max(last, hunk.OldStart+hunk.OldLines, hunk.NewStart+hunk.NewLines)
+	}
+	if file.name != "" {
+		header := styles.ToolName.Render(file.name) + " " +
+			styles.DiffAdded.Render(fmt.Sprintf("+%d", added)) + " " +
+			styles.DiffRemoved.Render(fmt.Sprintf("-%d", removed))
+		lines = append(lines, ansi.Truncate(header, width, "…"))
+	}
+
+	numWidth := len(strconv.Itoa(last))
+	gutter := 2*numWidth + 2
+	fold := func(n int) {
+		if n > 0 {
+			marker := fmt.Sprintf("%s⋯ %d unchanged line%s", strings.Repeat(" ", gutter), n, plural(n))
+			lines = append(lines, styles.DiffFold.Render(ansi.Truncate(marker, width, "…")))
+		}
+	}
+
+	lastOld := 0
+	for _, hunk := range file.hunks {
+		firstOld := hunk.OldStart
+		if hunk.OldLines == 0 {
+			firstOld++
+		}
+		fold(firstOld - 1 - lastOld)
+		lastOld = firstOld - 1 + hunk.OldLines
+
+		words := pairWords(hunk.Lines)
+		for i, line := range hunk.Lines {
+			lines = append(lines, renderDiffLine(styles, line, words[i], numWidth, width))
+		}
+	}
+	return lines
+}
+
+// renderDiffLine renders one line of a hunk; spans, if any, mark the words
+// that changed
+func renderDiffLine(styles *Styles, line diff.Line, spans []diff.Span, numWidth, width int) string {
+	number := func(n int) string {
+		if n == 0 {
+			return strings.Repeat(" ", numWidth)
+		}
+		return fmt.Sprintf("%*d", numWidth, n)
+	}
+	gutter := styles.DiffLineNumber.Render(number(line.Old) + " " + number(line.New) + " ")
+
+	style, wordStyle, sign := styles.DiffContext, styles.DiffContext, " "
+	switch line.Op {
+	case diff.Delete:
+		style, wordStyle, sign = styles.DiffRemoved, styles.DiffRemovedWord, "-"
+	case diff.Insert:
+		style, wordStyle, sign = styles.DiffAdded, styles.DiffAddedWord, "+"
+	}
+	if spans == nil {
+		spans = []diff.Span{{Text: line.Text}}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(gutter)
+	sb.WriteString(style.Render(sign + " "))
+	for _, span := range spans {
+		text := strings.ReplaceAll(span.Text, "\t", "    ")
+		if span.Changed {
+			sb.WriteString(wordStyle.Render(text))
+		} else if text != "" {
+			sb.WriteString(style.Render(text))
+		}
+	}
+	return ansi.Truncate(sb.String(), width, "…")
+}
+
+// pairWords compares each removed line with the added line in the same
+// position of the run that replaces it. Lines that share too little are
+// left to be colored as a whole.
+func pairWords(lines []diff.Line) [][]diff.Span {
+	words := make([][]diff.Span, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Op != diff.Delete {
+			i++
+			continue
+		}
+		dels := i
+		for i < len(lines) && lines[i].Op == diff.Delete {
+			i++
+		}
+		ins := i
+		for i < len(lines) && lines[i].Op == diff.Insert {
+			i++
+		}
+		for p := 0; p < ins-dels && ins+p < i; p++ {
+			oldLine, newLine := lines[dels+p].Text, lines[ins+p].Text
+			oldSpans, newSpans := diff.Words(oldLine, newLine)
+			if similar(oldSpans, max(len(oldLine), len(newLine))) {
+				words[dels+p], words[ins+p] = oldSpans, newSpans
+			}
+		}
+	}
+	return words
+}
+
+// similar reports whether the unchanged spans make up at least 40% of a line
+// of the given length
+func similar(spans []diff.Span, length int) bool {
+	same := 0
+	for _, span := range spans {
+		if !span.Changed {
+			same += len(strings.TrimSpace(span.Text))
+		}
+	}
+	return same > 0 && same*5 >= length*2
+}
+
+// plural returns "s" unless n is 1
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// toolResultDiff returns the diff an edit, write or apply_patch result
+// carries in its details, which are typed maps while the session runs and
+// decoded JSON once it is reloaded
+func toolResultDiff(details any) string {
+	fields, ok := details.(map[string]any)
+	if !ok {
+		return ""
+	}
+	if text, ok := fields["diff"].(string); ok {
+		return text
+	}
+
+	var files []map[string]any
+	switch list := fields["files"].(type) {
+	case []map[string]any:
+		files = list
+	case []any:
+		for _, item := range list {
+			if file, ok := item.(map[string]any); ok {
+				files = append(files, file)
+			}
+		}
+	}
+	var sb strings.Builder
+	for _, file := range files {
+		if text, ok := file["diff"].(string); ok {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
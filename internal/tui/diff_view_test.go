@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numberedLines(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestRenderDiff(t *testing.T) {
+	styles := NewStyles(GetTheme("dark"))
+	oldText := numberedLines(1, 20)
+	newText := strings.Replace(strings.Replace(oldText, "line 2\n", "line two\n", 1), "line 19\n", "", 1)
+
+	rendered, hidden := renderDiff(styles, diff.Unified(oldText, newText, "f.txt"), 60, 0)
+	assert.Zero(t, hidden)
+	assert.Equal(t, []string{
+		"f.txt +1 -2",
+		" 1  1   line 1",
+		" 2    - line 2",
+		"    2 + line two",
+		" 3  3   line 3",
+		" 4  4   line 4",
+		" 5  5   line 5",
+		"      ⋯ 10 unchanged lines",
+		"16 16   line 16",
+		"17 17   line 17",
+		"18 18   line 18",
+		"19    - line 19",
+		"20 19   line 20",
+	}, strings.Split(ansi.Strip(rendered), "\n"))
+
+	rendered, hidden = renderDiff(styles, diff.Unified(oldText, newText, "f.txt"), 60, 4)
+	assert.Equal(t, 9, hidden)
+	assert.Len(t, strings.Split(rendered, "\n"), 4)
+
+	// Long lines are cut to the width
+	rendered, _ = renderDiff(styles, diff.Unified("", strings.Repeat("x", 100), "long"), 30, 0)
+	for _, line := range strings.Split(ansi.Strip(rendered), "\n") {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 30)
+	}
+}
+
+func TestPairWords(t *testing.T) {
+	lines := diff.Lines("total := a + b\nunrelated\n", "total := a - b\ncompletely different text\n")
+	words := pairWords(lines)
+	require.Len(t, words, 4)
+	assert.Equal(t, []diff.Span{{Text: "total := a ", Changed: false}, {Text: "+", Changed: true}, {Text: " b", Changed: false}}, words[0])
+	assert.Nil(t, words[1], "lines with nothing in common are colored whole")
+	assert.Equal(t, []diff.Span{{Text: "total := a ", Changed: false}, {Text: "-", Changed: true}, {Text: " b", Changed: false}}, words[2])
+}
+
+func TestToolResultDiff(t *testing.T) {
+	assert.Equal(t, "d", toolResultDiff(map[string]any{"diff": "d"}))
+	assert.Equal(t, "ab", toolResultDiff(map[string]any{"files": []map[string]any{{"diff": "a"}, {"diff": "b"}}}))
+	assert.Equal(t, "ab", toolResultDiff(map[string]any{"files": []any{map[string]any{"diff": "a"}, map[string]any{"diff": "b"}}}), "details reloaded from JSON")
+	assert.Equal(t, "", toolResultDiff(map[string]any{"stdout": "x"}))
+	assert.Equal(t, "", toolResultDiff(nil))
+}
+
+func TestMessageView_ToolResultDiff(t *testing.T) {
+	mv := NewMessageView(NewStyles(GetTheme("dark")))
+	result := ai.ToolResultMessage{
+		Type:       ai.MessageTypeToolResult,
+		ToolCallID: "call-1",
+		Details:    map[string]any{"diff": diff.Unified("", numberedLines(1, 100), "big.txt")},
+	}
+	msg := agent.AgentMessage{Message: result}
+
+	collapsed := ansi.Strip(mv.Render(msg, 80))
+	assert.Contains(t, collapsed, "big.txt +100 -0")
+	assert.Contains(t, collapsed, "… 61 more lines (ctrl+o to expand)")
+
+	mv.ToggleExpanded("call-1")
+	expanded := ansi.Strip(mv.Render(msg, 80))
+	assert.Contains(t, expanded, "100 + line 100")
+	assert.NotContains(t, expanded, "more lines")
+
+	result.IsError = true
+	assert.Equal(t, "", mv.Render(agent.AgentMessage{Message: result}, 80))
+}
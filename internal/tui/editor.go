@@ -20,6 +20,7 @@ type Editor struct {
 	historyCache   []string        // Cached history for quick access
 	historyIndex   int             // Current position in history (-1 means not browsing)
 	currentDraft   string          // Current draft when browsing history
+	vim            *vimState       // Vim keybindings, nil when off
 }
 
 // NewEditor creates a new editor
@@ -33,12 +34,7 @@ func NewEditor(styles *Styles, prompt string, historyManager *HistoryManager) *E
 	// Disable default Enter key handling in textarea
 	ta.KeyMap.InsertNewline.SetEnabled(false)
 
-	// Set styles
-	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	ta.FocusedStyle.Prompt = styles.InputPrompt
-	ta.FocusedStyle.Text = lipgloss.NewStyle().Foreground(styles.Theme.Foreground)
-	ta.BlurredStyle.Prompt = lipgloss.NewStyle().Foreground(styles.Theme.Muted)
-	ta.BlurredStyle.Text = lipgloss.NewStyle().Foreground(styles.Theme.Muted)
+	applyEditorStyles(&ta, styles)
 
 	// Load history from manager
 	var historyCache []string
@@ -61,6 +57,21 @@ func NewEditor(styles *Styles, prompt string, historyManager *HistoryManager) *E
 	}
 }
 
+// applyEditorStyles styles the textarea in the colors of the theme
+func applyEditorStyles(ta *textarea.Model, styles *Styles) {
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.FocusedStyle.Prompt = styles.InputPrompt
+	ta.FocusedStyle.Text = lipgloss.NewStyle().Foreground(styles.Theme.Foreground)
+	ta.BlurredStyle.Prompt = lipgloss.NewStyle().Foreground(styles.Theme.Muted)
+	ta.BlurredStyle.Text = lipgloss.NewStyle().Foreground(styles.Theme.Muted)
+}
+
+// SetStyles switches to other styles, e.g. after a theme change
+func (e *Editor) SetStyles(styles *Styles) {
+	e.styles = styles
+	applyEditorStyles(&e.textarea, styles)
+}
+
 // Init initializes the editor
 func (e *Editor) Init() tea.Cmd {
 	return nil
@@ -72,6 +83,11 @@ func (e *Editor) Update(msg tea.Msg) (*Editor, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if e.vim != nil && e.focused {
+			if cmd, handled := e.updateVim(msg); handled {
+				return e, cmd
+			}
+		}
 		switch msg.Type {
 		case tea.KeyEnter:
 			// Submit message on Enter
@@ -108,37 +124,13 @@ func (e *Editor) Update(msg tea.Msg) (*Editor, tea.Cmd) {
 			}
 		case tea.KeyUp:
 			// Navigate up in history
-			if e.focused && len(e.historyCache) > 0 {
-				// Only single-line input can use arrow keys for history
-				if strings.Count(e.textarea.Value(), "\n") == 0 {
-					if e.historyIndex == -1 {
-						// Save current draft
-						e.currentDraft = e.textarea.Value()
-						e.historyIndex = len(e.historyCache) - 1
-					} else if e.historyIndex > 0 {
-						e.historyIndex--
-					}
-					e.textarea.SetValue(e.historyCache[e.historyIndex])
-					e.textarea.CursorEnd()
-					return e, nil
-				}
+			if e.focused && e.historyUp() {
+				return e, nil
 			}
 		case tea.KeyDown:
 			// Navigate down in history
-			if e.focused && e.historyIndex != -1 {
-				// Only single-line input can use arrow keys for history
-				if strings.Count(e.textarea.Value(), "\n") == 0 {
-					if e.historyIndex < len(e.historyCache)-1 {
-						e.historyIndex++
-						e.textarea.SetValue(e.historyCache[e.historyIndex])
-					} else {
-						// Restore draft
-						e.historyIndex = -1
-						e.textarea.SetValue(e.currentDraft)
-					}
-					e.textarea.CursorEnd()
-					return e, nil
-				}
+			if e.focused && e.historyDown() {
+				return e, nil
 			}
 		}
 	}
@@ -159,6 +151,42 @@ func (e *Editor) Update(msg tea.Msg) (*Editor, tea.Cmd) {
 	return e, cmd
 }
 
+// historyUp shows the previous history entry, saving the draft when browsing
+// starts. It reports false for multi-line input, which uses the arrow keys to
+// move between lines.
+func (e *Editor) historyUp() bool {
+	if len(e.historyCache) == 0 || strings.Count(e.textarea.Value(), "\n") != 0 {
+		return false
+	}
+	if e.historyIndex == -1 {
+		// Save current draft
+		e.currentDraft = e.textarea.Value()
+		e.historyIndex = len(e.historyCache) - 1
+	} else if e.historyIndex > 0 {
+		e.historyIndex--
+	}
+	e.textarea.SetValue(e.historyCache[e.historyIndex])
+	e.textarea.CursorEnd()
+	return true
+}
+
+// historyDown shows the next history entry, or the draft after the last one
+func (e *Editor) historyDown() bool {
+	if e.historyIndex == -1 || strings.Count(e.textarea.Value(), "\n") != 0 {
+		return false
+	}
+	if e.historyIndex < len(e.historyCache)-1 {
+		e.historyIndex++
+		e.textarea.SetValue(e.historyCache[e.historyIndex])
+	} else {
+		// Restore draft
+		e.historyIndex = -1
+		e.textarea.SetValue(e.currentDraft)
+	}
+	e.textarea.CursorEnd()
+	return true
+}
+
 // View renders the editor
 func (e *Editor) View() string {
 	if !e.focused {
@@ -178,8 +206,18 @@ func (e *Editor) View() string {
 		e.styles.HelpValue.Render(" send") +
 		e.styles.HelpKey.Render(" • Ctrl+J") +
 		e.styles.HelpValue.Render(" new line") +
+		e.styles.HelpKey.Render(" • Ctrl+G") +
+		e.styles.HelpValue.Render(" edit in $EDITOR") +
 		e.styles.HelpKey.Render(" • Esc") +
 		e.styles.HelpValue.Render(" clear")
+	if e.vim != nil {
+		help = e.styles.HelpKey.Render("Enter") +
+			e.styles.HelpValue.Render(" send") +
+			e.styles.HelpKey.Render(" • Ctrl+G") +
+			e.styles.HelpValue.Render(" edit in $EDITOR") +
+			e.styles.HelpKey.Render(" • Esc") +
+			e.styles.HelpValue.Render(" normal mode")
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, bordered, help)
 }
@@ -212,11 +250,33 @@ func (e *Editor) Value() string {
 	return e.textarea.Value()
 }
 
+// SetValue replaces the editor content and moves the cursor to the end
+func (e *Editor) SetValue(value string) {
+	e.textarea.SetValue(value)
+	e.textarea.CursorEnd()
+
+	lines := strings.Count(value, "\n") + 1
+	if lines < e.minHeight {
+		lines = e.minHeight
+	} else if lines > e.maxHeight {
+		lines = e.maxHeight
+	}
+	e.textarea.SetHeight(lines)
+}
+
+// InsertString inserts text at the cursor
+func (e *Editor) InsertString(text string) {
+	e.textarea.InsertString(text)
+}
+
 // Reset resets the editor
 func (e *Editor) Reset() {
 	e.textarea.Reset()
 	e.historyIndex = -1
 	e.currentDraft = ""
+	if e.vim != nil {
+		e.vim.reset()
+	}
 }
 
 // AddToHistory adds a message to the input history
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ExternalEditorMsg carries the draft written in the external editor
+type ExternalEditorMsg struct {
+	Content string
+	Err     error
+}
+
+// externalEditor returns the editor command from $VISUAL or $EDITOR, vi if neither is set
+func externalEditor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// prepareExternalEdit writes the draft to a temp file and returns the file
+// and the command editing it. Like git, the editor is run by the shell so
+// that it can carry arguments, e.g. "code --wait".
+func prepareExternalEdit(draft string) (string, *exec.Cmd, error) {
+	file, err := os.CreateTemp("", "cc-prompt-*.md")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create prompt file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(draft); err != nil {
+		os.Remove(file.Name())
+		return "", nil, fmt.Errorf("failed to write prompt file: %w", err)
+	}
+
+	cmd := exec.Command("sh", "-c", externalEditor()+` "$1"`, "sh", file.Name())
+	return file.Name(), cmd, nil
+}
+
+// finishExternalEdit reads back and removes the prompt file once the editor exits
+func finishExternalEdit(path string, err error) ExternalEditorMsg {
+	defer os.Remove(path)
+	if err != nil {
+		return ExternalEditorMsg{Err: fmt.Errorf("editor failed: %w", err)}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ExternalEditorMsg{Err: fmt.Errorf("failed to read prompt file: %w", err)}
+	}
+	// Editors end files with a newline the prompt doesn't need
+	return ExternalEditorMsg{Content: strings.TrimRight(string(data), "\n")}
+}
+
+// openExternalEditor suspends the TUI to edit the draft in $VISUAL or $EDITOR
+func (m *ChatModel) openExternalEditor() tea.Cmd {
+	path, cmd, err := prepareExternalEdit(m.editor.Value())
+	if err != nil {
+		m.error = err.Error()
+		return nil
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return finishExternalEdit(path, err)
+	})
+}
+
+// applyExternalEdit replaces the draft with what was written in the editor
+func (m *ChatModel) applyExternalEdit(msg ExternalEditorMsg) {
+	if msg.Err != nil {
+		m.error = msg.Err.Error()
+		return
+	}
+	m.error = ""
+	m.editor.SetValue(msg.Content)
+	m.commandPopup.Update(m.commands, m.editor.Value())
+}
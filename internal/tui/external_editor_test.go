@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalEditor(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	assert.Equal(t, "vi", externalEditor())
+	t.Setenv("EDITOR", "nano")
+	assert.Equal(t, "nano", externalEditor())
+	t.Setenv("VISUAL", "code --wait")
+	assert.Equal(t, "code --wait", externalEditor())
+
+	// The editor gets the draft and its arguments
+	script := filepath.Join(t.TempDir(), "edit.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n{ printf '%s|' \"$1\"; cat \"$2\"; echo ' more'; } > \"$2.new\" && mv \"$2.new\" \"$2\"\n"), 0755))
+	t.Setenv("VISUAL", script+" --flag")
+
+	path, cmd, err := prepareExternalEdit("draft")
+	require.NoError(t, err)
+	msg := finishExternalEdit(path, cmd.Run())
+	require.NoError(t, msg.Err)
+	assert.Equal(t, "--flag|draft more", msg.Content)
+	assert.NoFileExists(t, path)
+
+	t.Setenv("VISUAL", "exit 3")
+	path, cmd, err = prepareExternalEdit("draft")
+	require.NoError(t, err)
+	msg = finishExternalEdit(path, cmd.Run())
+	assert.ErrorContains(t, msg.Err, "editor failed")
+	assert.NoFileExists(t, path)
+}
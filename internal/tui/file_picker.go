@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/myersguo/cc-mono/pkg/codingagent/mentions"
+)
+
+const (
+	maxFileMatches = 8                // Files shown at once
+	maxFileResults = 50               // Files the selection can move through
+	fileListTTL    = 30 * time.Second // How long the project file list is reused
+)
+
+// FilePicker completes the @path typed at the end of the input with a fuzzy
+// search over the project files
+type FilePicker struct {
+	styles   *Styles
+	files    []mentions.File
+	listedAt time.Time
+	loading  bool
+	matches  []mentions.File
+	selected int
+	width    int
+}
+
+// FileListMsg carries the project files listed for the picker
+type FileListMsg struct {
+	Files []mentions.File
+	Err   error
+}
+
+// NewFilePicker creates a new file picker
+func NewFilePicker(styles *Styles) *FilePicker {
+	return &FilePicker{styles: styles}
+}
+
+// MentionQuery returns what follows the @ of the word at the end of input,
+// and whether that word is a mention being typed
+func MentionQuery(input string) (string, bool) {
+	word := input[strings.LastIndexAny(input, " \t\n")+1:]
+	if !strings.HasPrefix(word, "@") || strings.Contains(word, "#") {
+		return "", false
+	}
+	return word[1:], true
+}
+
+// Load lists the project files in the background unless a recent list is at hand
+func (p *FilePicker) Load(workingDir string) tea.Cmd {
+	if p.loading || time.Since(p.listedAt) < fileListTTL {
+		return nil
+	}
+	p.loading = true
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		files, err := mentions.ListFiles(ctx, workingDir)
+		return FileListMsg{Files: files, Err: err}
+	}
+}
+
+// SetFiles sets the files to search
+func (p *FilePicker) SetFiles(files []mentions.File) {
+	p.files = files
+	p.listedAt = time.Now()
+	p.loading = false
+}
+
+// Update shows the files matching the mention at the end of input, or hides
+// the picker when there is none
+func (p *FilePicker) Update(input string) {
+	query, ok := MentionQuery(input)
+	if !ok {
+		p.Hide()
+		return
+	}
+
+	var selectedPath string
+	if file, ok := p.Selected(); ok {
+		selectedPath = file.Path
+	}
+
+	p.matches = mentions.Match(p.files, query, maxFileResults)
+	p.selected = 0
+	for i, file := range p.matches {
+		if file.Path == selectedPath {
+			p.selected = i
+		}
+	}
+}
+
+// Complete returns input with the mention at its end replaced by the selected
+// path. Files are followed by a space; directories are not, so typing can go on
+// inside them.
+func (p *FilePicker) Complete(input string) (string, bool) {
+	file, ok := p.Selected()
+	if _, typing := MentionQuery(input); !ok || !typing {
+		return input, false
+	}
+
+	completed := input[:strings.LastIndex(input, "@")] + "@" + file.Path
+	if !file.IsDir() {
+		completed += " "
+	}
+	return completed, true
+}
+
+// Hide hides the picker
+func (p *FilePicker) Hide() {
+	p.matches = nil
+	p.selected = 0
+}
+
+// IsVisible returns whether any files match
+func (p *FilePicker) IsVisible() bool {
+	return len(p.matches) > 0
+}
+
+// SetWidth sets the picker width
+func (p *FilePicker) SetWidth(width int) {
+	p.width = width
+}
+
+// Next selects the next match
+func (p *FilePicker) Next() {
+	if len(p.matches) > 0 {
+		p.selected = (p.selected + 1) % len(p.matches)
+	}
+}
+
+// Previous selects the previous match
+func (p *FilePicker) Previous() {
+	if len(p.matches) > 0 {
+		p.selected = (p.selected - 1 + len(p.matches)) % len(p.matches)
+	}
+}
+
+// Selected returns the selected file
+func (p *FilePicker) Selected() (mentions.File, bool) {
+	if p.selected >= len(p.matches) {
+		return mentions.File{}, false
+	}
+	return p.matches[p.selected], true
+}
+
+// View renders the matches around the selection
+func (p *FilePicker) View() string {
+	if !p.IsVisible() {
+		return ""
+	}
+
+	start := 0
+	if p.selected >= maxFileMatches {
+		start = p.selected - maxFileMatches + 1
+	}
+	end := min(start+maxFileMatches, len(p.matches))
+
+	muted := lipgloss.NewStyle().Foreground(p.styles.Theme.Muted)
+	var lines []string
+	for i := start; i < end; i++ {
+		path := truncateLine(p.matches[i].Path, p.width-4)
+		if i == p.selected {
+			selected := lipgloss.NewStyle().Foreground(p.styles.Theme.Primary).Bold(true)
+			lines = append(lines, selected.Render("❯ "+path))
+		} else {
+			lines = append(lines, "  "+path)
+		}
+	}
+	if len(p.matches) > end-start {
+		lines = append(lines, muted.Render(fmt.Sprintf("  %d of %d · ↑/↓ to select · Tab to insert", p.selected+1, len(p.matches))))
+	} else {
+		lines = append(lines, muted.Render("  ↑/↓ to select · Tab to insert"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/mentions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMentionQuery(t *testing.T) {
+	query, ok := MentionQuery("look at @pkg/ed")
+	assert.True(t, ok)
+	assert.Equal(t, "pkg/ed", query)
+
+	query, ok = MentionQuery("@")
+	assert.True(t, ok)
+	assert.Empty(t, query)
+
+	_, ok = MentionQuery("look at @main.go ")
+	assert.False(t, ok)
+	_, ok = MentionQuery("mail me@example.com")
+	assert.False(t, ok)
+	_, ok = MentionQuery("@main.go#L10")
+	assert.False(t, ok)
+}
+
+func TestFilePicker(t *testing.T) {
+	now := time.Now()
+	picker := NewFilePicker(NewStyles(GetTheme("dark")))
+	picker.SetWidth(80)
+	picker.SetFiles([]mentions.File{
+		{Path: "internal/tui/editor.go", ModTime: now},
+		{Path: "internal/tui/", ModTime: now},
+		{Path: "README.md", ModTime: now.Add(-time.Hour)},
+	})
+	assert.Nil(t, picker.Load("."), "a fresh list is reused")
+
+	picker.Update("fix @edit")
+	require.True(t, picker.IsVisible())
+	selected, _ := picker.Selected()
+	assert.Equal(t, "internal/tui/editor.go", selected.Path)
+	assert.Contains(t, picker.View(), "internal/tui/editor.go")
+
+	completed, ok := picker.Complete("fix @edit")
+	assert.True(t, ok)
+	assert.Equal(t, "fix @internal/tui/editor.go ", completed)
+
+	// Directories are completed without a space so the path can go on
+	picker.Update(completed)
+	assert.False(t, picker.IsVisible())
+	picker.Update("see @tui")
+	selected, _ = picker.Selected()
+	assert.Equal(t, "internal/tui/", selected.Path)
+	completed, _ = picker.Complete("see @tui")
+	assert.Equal(t, "see @internal/tui/", completed)
+
+	picker.Update("no mention")
+	assert.False(t, picker.IsVisible())
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// tokenKind classifies a piece of source code for highlighting
+type tokenKind int
+
+const (
+	tokenPlain tokenKind = iota
+	tokenKeyword
+	tokenString
+	tokenNumber
+	tokenComment
+	tokenAdded
+	tokenRemoved
+	tokenMeta
+)
+
+// token is a piece of a source line with its kind
+type token struct {
+	text string
+	kind tokenKind
+}
+
+// highlight splits the lines of a code block into tokens, using the chroma
+// lexer of the language named after the fence. Chroma's token types are
+// folded into the few kinds the theme has colors for. Unknown languages are
+// not highlighted.
+func highlight(lang string, lines []string) [][]token {
+	rows := make([][]token, len(lines))
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	var iterator chroma.Iterator
+	if lexer != nil {
+		var err error
+		iterator, err = chroma.Coalesce(lexer).Tokenise(nil, strings.Join(lines, "\n")+"\n")
+		if err != nil {
+			lexer = nil
+		}
+	}
+	if lexer == nil {
+		for i, line := range lines {
+			if line != "" {
+				rows[i] = []token{{text: line, kind: tokenPlain}}
+			}
+		}
+		return rows
+	}
+
+	row := 0
+	for t := iterator(); t != chroma.EOF; t = iterator() {
+		kind := tokenKindOf(t.Type)
+		for i, part := range strings.Split(t.Value, "\n") {
+			if i > 0 {
+				row++
+			}
+			if part == "" || row >= len(rows) {
+				continue
+			}
+			if n := len(rows[row]); n > 0 && rows[row][n-1].kind == kind {
+				rows[row][n-1].text += part
+				continue
+			}
+			rows[row] = append(rows[row], token{text: part, kind: kind})
+		}
+	}
+	return rows
+}
+
+// tokenKindOf returns the kind of a chroma token type
+func tokenKindOf(t chroma.TokenType) tokenKind {
+	switch {
+	case t == chroma.GenericInserted:
+		return tokenAdded
+	case t == chroma.GenericDeleted:
+		return tokenRemoved
+	case t == chroma.GenericHeading, t == chroma.GenericSubheading, t == chroma.GenericPrompt, t == chroma.CommentPreproc:
+		return tokenMeta
+	case t.InCategory(chroma.Keyword), t == chroma.NameTag:
+		return tokenKeyword
+	case t.InSubCategory(chroma.LiteralString):
+		return tokenString
+	case t.InSubCategory(chroma.LiteralNumber):
+		return tokenNumber
+	case t.InCategory(chroma.Comment):
+		return tokenComment
+	default:
+		return tokenPlain
+	}
+}
@@ -0,0 +1,384 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PickerKind tells which picker an item was picked from
+type PickerKind string
+
+const (
+	PickerModel   PickerKind = "model"
+	PickerSession PickerKind = "session"
+	PickerTheme   PickerKind = "theme"
+)
+
+// PickerItem is an entry of a list picker
+type PickerItem struct {
+	Value   string // Sent back when the item is picked
+	Label   string
+	Detail  string // Shown muted after the label
+	Group   string // Consecutive items of a group are listed under a heading
+	Current bool   // The item in use, marked in the list
+}
+
+// ListPicker is a modal list to pick an item from, with optional search and
+// a preview of the selected item. It is driven by the keyboard and the mouse.
+type ListPicker struct {
+	styles     *Styles
+	kind       PickerKind
+	title      string
+	items      []PickerItem
+	matches    []int // Indexes of the items matching the query
+	query      string
+	searchable bool
+	preview    func(item PickerItem) string
+	selected   int // Index into matches
+	offset     int // First visible match
+	width      int
+	height     int
+	visible    bool
+}
+
+// NewListPicker creates a new list picker
+func NewListPicker(styles *Styles) *ListPicker {
+	return &ListPicker{styles: styles}
+}
+
+// Show displays the picker with the given items and selects the current one.
+// Searchable pickers filter the items by what is typed; preview, if set,
+// describes the selected item below the list.
+func (p *ListPicker) Show(kind PickerKind, title string, items []PickerItem, searchable bool, preview func(item PickerItem) string) {
+	p.kind = kind
+	p.title = title
+	p.items = items
+	p.searchable = searchable
+	p.preview = preview
+	p.query = ""
+	p.offset = 0
+	p.filter()
+	for i, index := range p.matches {
+		if items[index].Current {
+			p.selected = i
+		}
+	}
+	p.visible = true
+}
+
+// Hide hides the picker
+func (p *ListPicker) Hide() {
+	p.visible = false
+	p.items = nil
+	p.matches = nil
+	p.preview = nil
+}
+
+// IsVisible returns whether the picker is visible
+func (p *ListPicker) IsVisible() bool {
+	return p.visible
+}
+
+// Kind returns which picker is shown
+func (p *ListPicker) Kind() PickerKind {
+	return p.kind
+}
+
+// SetSize sets the picker size
+func (p *ListPicker) SetSize(width, height int) {
+	p.width = width
+	p.height = height
+}
+
+// Selected returns the selected item
+func (p *ListPicker) Selected() (PickerItem, bool) {
+	if p.selected >= len(p.matches) {
+		return PickerItem{}, false
+	}
+	return p.items[p.matches[p.selected]], true
+}
+
+// Update handles keys and mouse events
+func (p *ListPicker) Update(msg tea.Msg) (*ListPicker, tea.Cmd) {
+	if !p.visible {
+		return p, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.MouseMsg:
+		return p, p.handleMouse(msg)
+	case tea.KeyMsg:
+		return p, p.handleKey(msg)
+	}
+	return p, nil
+}
+
+// handleKey moves through, searches or picks from the list
+func (p *ListPicker) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyCtrlP, tea.KeyShiftTab:
+		p.move(-1)
+	case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+		p.move(1)
+	case tea.KeyPgUp:
+		p.move(-p.pageSize())
+	case tea.KeyPgDown:
+		p.move(p.pageSize())
+	case tea.KeyHome:
+		p.move(-len(p.matches))
+	case tea.KeyEnd:
+		p.move(len(p.matches))
+	case tea.KeyEnter:
+		return p.pick()
+	case tea.KeyEsc:
+		// Esc clears the search first
+		if p.query != "" {
+			p.setQuery("")
+		} else {
+			p.Hide()
+		}
+	case tea.KeyBackspace:
+		if p.query != "" {
+			runes := []rune(p.query)
+			p.setQuery(string(runes[:len(runes)-1]))
+		}
+	case tea.KeyCtrlU:
+		p.setQuery("")
+	case tea.KeyRunes, tea.KeySpace:
+		if p.searchable {
+			p.setQuery(p.query + string(msg.Runes))
+			break
+		}
+		switch msg.String() {
+		case "k":
+			p.move(-1)
+		case "j":
+			p.move(1)
+		}
+	}
+	return nil
+}
+
+// handleMouse scrolls with the wheel and selects the clicked item; clicking
+// the selected item picks it
+func (p *ListPicker) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		p.move(-1)
+	case tea.MouseButtonWheelDown:
+		p.move(1)
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return nil
+		}
+		_, rows := p.render()
+		match, ok := rows[msg.Y]
+		if !ok {
+			return nil
+		}
+		if match == p.selected {
+			return p.pick()
+		}
+		p.selected = match
+	}
+	return nil
+}
+
+// pick hides the picker and reports the selected item
+func (p *ListPicker) pick() tea.Cmd {
+	item, ok := p.Selected()
+	if !ok {
+		return nil
+	}
+	kind := p.kind
+	p.Hide()
+	return func() tea.Msg {
+		return PickerSelectMsg{Kind: kind, Value: item.Value}
+	}
+}
+
+// move moves the selection by delta matches
+func (p *ListPicker) move(delta int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.selected = max(0, min(len(p.matches)-1, p.selected+delta))
+}
+
+// setQuery filters the items by query and selects the first match
+func (p *ListPicker) setQuery(query string) {
+	p.query = query
+	p.filter()
+	p.selected = 0
+	p.offset = 0
+}
+
+// filter keeps the items that contain every word of the query, in label,
+// detail or group, ignoring case
+func (p *ListPicker) filter() {
+	p.matches = p.matches[:0]
+	words := strings.Fields(strings.ToLower(p.query))
+	for i, item := range p.items {
+		text := strings.ToLower(item.Label + " " + item.Detail + " " + item.Group + " " + item.Value)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			p.matches = append(p.matches, i)
+		}
+	}
+}
+
+// pageSize is the number of items listed at once
+func (p *ListPicker) pageSize() int {
+	size := 10
+	if p.height > 0 {
+		// Leave room for the frame, title, search, hints and preview
+		chrome := 12
+		if p.preview != nil {
+			chrome += previewLines + 2
+		}
+		size = min(size, p.height-chrome)
+	}
+	return max(size, 3)
+}
+
+// previewLines is the height of the preview of the selected item
+const previewLines = 8
+
+// View renders the picker
+func (p *ListPicker) View() string {
+	view, _ := p.render()
+	return view
+}
+
+// render renders the picker filling the screen, and returns which match each
+// screen row shows so that clicks can select items
+func (p *ListPicker) render() (string, map[int]int) {
+	if !p.visible {
+		return "", nil
+	}
+
+	dialogWidth := 80
+	if p.width > 0 && p.width < dialogWidth+4 {
+		dialogWidth = p.width - 4
+	}
+	textWidth := dialogWidth - 6 // Border and padding
+
+	muted := lipgloss.NewStyle().Foreground(p.styles.Theme.Muted)
+	var lines []string
+	rows := make(map[int]int) // Content line -> match
+
+	lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(p.styles.Theme.Primary).Render(p.title), "")
+	if p.searchable {
+		search := muted.Render("Type to search")
+		if p.query != "" {
+			search = lipgloss.NewStyle().Foreground(p.styles.Theme.Foreground).Render(p.query + "█")
+		}
+		lines = append(lines, muted.Render("🔍 ")+search, "")
+	}
+
+	if len(p.matches) == 0 {
+		lines = append(lines, muted.Render("Nothing matches."))
+	} else {
+		// Keep the selection in view
+		pageSize := p.pageSize()
+		if p.selected < p.offset {
+			p.offset = p.selected
+		} else if p.selected >= p.offset+pageSize {
+			p.offset = p.selected - pageSize + 1
+		}
+		end := min(p.offset+pageSize, len(p.matches))
+
+		group := ""
+		if p.offset > 0 {
+			group = p.items[p.matches[p.offset-1]].Group
+		}
+		for i := p.offset; i < end; i++ {
+			item := p.items[p.matches[i]]
+			if item.Group != "" && (item.Group != group || i == p.offset) {
+				lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(p.styles.Theme.Accent).Render(item.Group))
+			}
+			group = item.Group
+			rows[len(lines)] = i
+			lines = append(lines, p.renderItem(item, i == p.selected, textWidth))
+		}
+		if len(p.matches) > end-p.offset {
+			lines = append(lines, muted.Render(fmt.Sprintf("  %d of %d", p.selected+1, len(p.matches))))
+		}
+	}
+
+	if p.preview != nil {
+		if item, ok := p.Selected(); ok {
+			lines = append(lines, "", muted.Render(strings.Repeat("─", textWidth)))
+			previewText := strings.Split(strings.TrimRight(p.preview(item), "\n"), "\n")
+			if len(previewText) > previewLines {
+				previewText = previewText[len(previewText)-previewLines:]
+			}
+			for _, line := range previewText {
+				lines = append(lines, truncateLine(line, textWidth))
+			}
+		}
+	}
+
+	hint := "↑/↓ to select · Enter to pick · Esc to close"
+	if p.searchable {
+		hint = "↑/↓ to select · Enter to pick · Esc to clear or close"
+	}
+	lines = append(lines, "", muted.Render(hint))
+
+	dialog := lipgloss.NewStyle().
+		BorderStyle(p.styles.Border).
+		BorderForeground(p.styles.Theme.Primary).
+		Padding(1, 2).
+		Width(dialogWidth).
+		Render(strings.Join(lines, "\n"))
+
+	// Fill the screen so that rows of the view are rows of the terminal
+	top := 0
+	if p.height > 0 {
+		top = max(0, (p.height-lipgloss.Height(dialog))/2)
+		bottom := max(0, p.height-lipgloss.Height(dialog)-top)
+		dialog = strings.Repeat("\n", top) + dialog + strings.Repeat("\n", bottom)
+	}
+
+	screenRows := make(map[int]int, len(rows))
+	for line, match := range rows {
+		screenRows[top+2+line] = match // Below the border and padding
+	}
+	return dialog, screenRows
+}
+
+// renderItem renders a selectable line
+func (p *ListPicker) renderItem(item PickerItem, selected bool, width int) string {
+	prefix := "  "
+	style := lipgloss.NewStyle().Foreground(p.styles.Theme.Foreground)
+	if selected {
+		prefix = lipgloss.NewStyle().Foreground(p.styles.Theme.Primary).Render("❯ ")
+		style = lipgloss.NewStyle().Foreground(p.styles.Theme.Primary).Bold(true)
+	}
+
+	label := item.Label
+	if item.Current {
+		label += " ✓"
+	}
+	label = truncateLine(label, width-2)
+	line := prefix + style.Render(label)
+	if room := width - 4 - lipgloss.Width(label); item.Detail != "" && room > 8 {
+		line += "  " + lipgloss.NewStyle().Foreground(p.styles.Theme.Muted).Render(truncateLine(item.Detail, room))
+	}
+	return line
+}
+
+// PickerSelectMsg is sent when the user picks an item from a list picker
+type PickerSelectMsg struct {
+	Kind  PickerKind
+	Value string
+}
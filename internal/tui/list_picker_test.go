@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPicker(t *testing.T) {
+	models := []codingagent.ModelConfig{
+		{ID: "gpt-4o", Provider: "openai", Name: "GPT-4o", ContextWindow: 128000, InputCostPer1M: 2.5, OutputCostPer1M: 10},
+		{ID: "deepseek-chat", Provider: "deepseek", Name: "DeepSeek Chat", ContextWindow: 64000},
+		{ID: "gpt-4o-mini", Provider: "openai", Name: "GPT-4o mini", ContextWindow: 1_000_000},
+	}
+	items := modelPickerItems(models, "gpt-4o")
+	assert.Equal(t, []string{"deepseek", "openai", "openai"}, []string{items[0].Group, items[1].Group, items[2].Group})
+	assert.Equal(t, "128K context · $2.50/$10.00 per 1M tokens", items[1].Detail)
+	assert.Equal(t, "1M context", items[2].Detail)
+
+	picker := NewListPicker(NewStyles(GetTheme("dark")))
+	picker.SetSize(100, 40)
+	picker.Show(PickerModel, "Switch model", items, true, func(item PickerItem) string { return "about " + item.Label })
+	require.True(t, picker.IsVisible())
+
+	// The current model is selected and the models are grouped by provider
+	selected, _ := picker.Selected()
+	assert.Equal(t, "gpt-4o", selected.Value)
+	view := picker.View()
+	assert.Contains(t, view, "deepseek")
+	assert.Contains(t, view, "GPT-4o ✓")
+	assert.Contains(t, view, "about GPT-4o")
+	assert.Equal(t, 40, strings.Count(view, "\n")+1, "the picker fills the screen")
+
+	// Typing searches, Esc clears the search first
+	for _, r := range "mini" {
+		picker.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	selected, _ = picker.Selected()
+	assert.Equal(t, "gpt-4o-mini", selected.Value)
+	assert.NotContains(t, picker.View(), "DeepSeek")
+	picker.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.True(t, picker.IsVisible())
+	assert.Contains(t, picker.View(), "DeepSeek")
+
+	picker.Update(tea.KeyMsg{Type: tea.KeyDown})
+	selected, _ = picker.Selected()
+	assert.Equal(t, "gpt-4o", selected.Value)
+
+	// Clicking an item selects it and clicking it again picks it
+	_, rows := picker.render()
+	var row int
+	for y, match := range rows {
+		if match == 0 {
+			row = y
+		}
+	}
+	click := tea.MouseMsg{X: 10, Y: row, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}
+	_, cmd := picker.Update(click)
+	assert.Nil(t, cmd)
+	selected, _ = picker.Selected()
+	assert.Equal(t, "deepseek-chat", selected.Value)
+	_, cmd = picker.Update(click)
+	require.NotNil(t, cmd)
+	assert.Equal(t, PickerSelectMsg{Kind: PickerModel, Value: "deepseek-chat"}, cmd())
+	assert.False(t, picker.IsVisible())
+
+	// Pickers without search move with j/k
+	picker.Show(PickerTheme, "Theme", themePickerItems("dark"), false, nil)
+	picker.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	_, cmd = picker.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	require.NotNil(t, cmd)
+	assert.Equal(t, PickerSelectMsg{Kind: PickerTheme, Value: "light"}, cmd())
+}
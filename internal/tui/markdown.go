@@ -0,0 +1,561 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	// maxCachedBlocks bounds the rendered blocks kept by a MarkdownRenderer
+	maxCachedBlocks = 2000
+
+	// minBlockWidth is the narrowest that quotes and list items are indented
+	// to; blocks nested deeper stop indenting so that their lines still fit
+	minBlockWidth = 12
+)
+
+// markdownParser parses GitHub-flavored markdown: CommonMark with tables,
+// strikethrough, task lists and bare links
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+// listBullets are the bullets of unordered lists, by nesting depth
+var listBullets = []string{"•", "◦", "▪"}
+
+// blockKey identifies a rendered block in the cache
+type blockKey struct {
+	source string
+	width  int
+}
+
+// MarkdownRenderer renders markdown for the terminal in the colors of the
+// theme. Rendered blocks are cached by their source, so while a message
+// streams in only its last block is rendered again and the lines above it stay
+// the same, which keeps line-diffing renderers from redrawing them. Terminals
+// without color get the markdown as it is, wrapped.
+//
+// Markdown is parsed by goldmark and code highlighted by chroma; the renderer
+// only lays the tree out in the theme's styles. Glamour isn't used because its
+// styles are separate from the theme and it renders whole documents, which
+// rules out caching finished blocks while the rest streams in.
+type MarkdownRenderer struct {
+	styles   *Styles
+	plain    bool
+	cache    map[blockKey]string
+	streamed streamedCode
+}
+
+// streamedCode holds the highlighting of the complete lines of the code block
+// streaming in, which would otherwise be lexed again on every update
+type streamedCode struct {
+	lang string
+	code string
+	rows [][]token
+}
+
+// NewMarkdownRenderer creates a markdown renderer, in plain-text mode for dumb terminals
+func NewMarkdownRenderer(styles *Styles) *MarkdownRenderer {
+	return &MarkdownRenderer{
+		styles: styles,
+		plain:  os.Getenv("TERM") == "dumb" || lipgloss.ColorProfile() == termenv.Ascii,
+		cache:  make(map[blockKey]string),
+	}
+}
+
+// SetPlain turns the plain-text mode on or off
+func (r *MarkdownRenderer) SetPlain(plain bool) {
+	r.plain = plain
+	r.cache = make(map[blockKey]string)
+}
+
+// SetStyles switches to other styles, e.g. after a theme change
+func (r *MarkdownRenderer) SetStyles(styles *Styles) {
+	r.styles = styles
+	r.cache = make(map[blockKey]string)
+}
+
+// Render renders markdown text wrapped to width
+func (r *MarkdownRenderer) Render(text string, width int) string {
+	width = max(width, 20)
+	if r.plain {
+		return wrap(text, width)
+	}
+
+	source := []byte(text)
+	var blocks []ast.Node
+	for n := parseMarkdown(source).FirstChild(); n != nil; n = n.NextSibling() {
+		blocks = append(blocks, n)
+	}
+
+	var rendered []string
+	for i, b := range blocks {
+		// The last block may still be streaming in
+		key, cached := blockKey{}, false
+		if i < len(blocks)-1 && b.Pos() >= 0 && blocks[i+1].Pos() >= 0 {
+			start, end := lineStart(source, b.Pos()), lineStart(source, blocks[i+1].Pos())
+			key = blockKey{source: strings.TrimRight(text[start:end], " \t\n"), width: width}
+			cached = true
+		}
+
+		out, ok := r.cache[key]
+		if !cached || !ok {
+			out = r.renderBlock(b, source, width, 0, r.styles.Markdown)
+		}
+		if cached && !ok {
+			if len(r.cache) >= maxCachedBlocks {
+				r.cache = make(map[blockKey]string)
+			}
+			r.cache[key] = out
+		}
+		if out != "" {
+			rendered = append(rendered, out)
+		}
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+// parseMarkdown parses markdown into its tree of blocks
+func parseMarkdown(source []byte) ast.Node {
+	return markdownParser.Parse(text.NewReader(source), parser.WithContext(parser.NewContext()))
+}
+
+// lineStart returns the offset of the start of the line holding source[pos]
+func lineStart(source []byte, pos int) int {
+	return bytes.LastIndexByte(source[:pos], '\n') + 1
+}
+
+// wrap wraps text at words to width. ansi.Wrap can leave a hyphen that ends
+// a word one cell past the width, so the lines are broken again to be sure.
+func wrap(text string, width int) string {
+	return ansi.Hardwrap(ansi.Wrap(text, width, ""), width, true)
+}
+
+// renderBlocks renders the blocks nested in a node, such as the content of a
+// list item or quote, with sep between them
+func (r *MarkdownRenderer) renderBlocks(parent ast.Node, source []byte, width, depth int, base lipgloss.Style, sep string) string {
+	var rendered []string
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		if out := r.renderBlock(n, source, width, depth, base); out != "" {
+			rendered = append(rendered, out)
+		}
+	}
+	return strings.Join(rendered, sep)
+}
+
+// renderBlock renders a block, with base as the style of its text
+func (r *MarkdownRenderer) renderBlock(n ast.Node, source []byte, width, depth int, base lipgloss.Style) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		style := r.styles.Heading
+		switch n.Level {
+		case 1:
+			style = style.Underline(true)
+		case 2:
+		default:
+			style = style.Foreground(r.styles.Theme.Secondary)
+		}
+		return wrap(r.inline(n, source, style), width)
+
+	case *ast.ThematicBreak:
+		return r.styles.Separator.UnsetMargins().Render(strings.Repeat("─", width))
+
+	case *ast.FencedCodeBlock:
+		lines := codeLines(n, source)
+		// A fence still streaming in has no end yet; leave out the line being started
+		segments := n.Lines()
+		streaming := segments.Len() > 0 && len(bytes.TrimSpace(source[segments.At(segments.Len()-1).Stop:])) == 0
+		if streaming {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+		return r.renderCode(string(n.Language(source)), lines, width, streaming)
+
+	case *ast.CodeBlock:
+		return r.renderCode("", codeLines(n, source), width, false)
+
+	case *ast.Blockquote:
+		style := base.Foreground(r.styles.Theme.Muted).Italic(true)
+		if width-2 < minBlockWidth {
+			return r.renderBlocks(n, source, width, depth, style, "\n\n")
+		}
+		inner := r.renderBlocks(n, source, width-2, depth, style, "\n\n")
+		bar := lipgloss.NewStyle().Foreground(r.styles.Theme.Muted).Render("│")
+		quoted := strings.Split(inner, "\n")
+		for i, line := range quoted {
+			quoted[i] = bar + " " + line
+		}
+		return strings.Join(quoted, "\n")
+
+	case *ast.List:
+		return r.renderList(n, source, width, depth, base)
+
+	case *extast.Table:
+		return r.renderTable(n, source, width, base)
+
+	case *ast.HTMLBlock:
+		var lines []string
+		for i := 0; i < n.Lines().Len(); i++ {
+			segment := n.Lines().At(i)
+			lines = append(lines, base.Render(strings.TrimRight(string(segment.Value(source)), "\r\n")))
+		}
+		return wrap(strings.Join(lines, "\n"), width)
+
+	case *ast.Paragraph, *ast.TextBlock:
+		return wrap(r.inline(n, source, base), width)
+
+	default:
+		return r.renderBlocks(n, source, width, depth, base, "\n\n")
+	}
+}
+
+// codeLines returns the lines of a code block
+func codeLines(n ast.Node, source []byte) []string {
+	lines := make([]string, n.Lines().Len())
+	for i := range lines {
+		segment := n.Lines().At(i)
+		line := strings.TrimRight(string(segment.Value(source)), "\r\n")
+		lines[i] = strings.Repeat(" ", segment.Padding) + line
+	}
+	return lines
+}
+
+// renderList renders list items with their bullets or numbers and renders the
+// content of each item, nested lists included, indented below its marker
+func (r *MarkdownRenderer) renderList(list *ast.List, source []byte, width, depth int, base lipgloss.Style) string {
+	markerStyle := lipgloss.NewStyle().Foreground(r.styles.Theme.Accent)
+	var out []string
+	number := list.Start
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := listBullets[depth%len(listBullets)]
+		if list.IsOrdered() {
+			marker = fmt.Sprintf("%d%c", number, list.Marker)
+			number++
+		}
+		if box := taskCheckBox(item); box != nil {
+			marker = "☐"
+			if box.IsChecked {
+				marker = "☑"
+			}
+		}
+
+		// Past the narrowest width items go below their marker
+		indent := ansi.StringWidth(marker) + 1
+		if width-indent < minBlockWidth {
+			indent = 0
+			out = append(out, markerStyle.Render(marker))
+		}
+		content := r.renderBlocks(item, source, width-indent, depth+1, base, "\n")
+		for i, line := range strings.Split(content, "\n") {
+			switch {
+			case indent == 0 || line == "":
+				out = append(out, line)
+			case i == 0:
+				out = append(out, markerStyle.Render(marker)+" "+line)
+			default:
+				out = append(out, strings.Repeat(" ", indent)+line)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// taskCheckBox returns the check box starting a task list item, or nil
+func taskCheckBox(item ast.Node) *extast.TaskCheckBox {
+	if first := item.FirstChild(); first != nil {
+		box, _ := first.FirstChild().(*extast.TaskCheckBox)
+		return box
+	}
+	return nil
+}
+
+// renderTable renders a table with borders, narrowing its columns to fit
+// width. A table with too many columns to fit has its rows listed instead.
+func (r *MarkdownRenderer) renderTable(t *extast.Table, source []byte, width int, base lipgloss.Style) string {
+	aligns := make([]lipgloss.Position, len(t.Alignments))
+	for i, align := range t.Alignments {
+		switch align {
+		case extast.AlignCenter:
+			aligns[i] = lipgloss.Center
+		case extast.AlignRight:
+			aligns[i] = lipgloss.Right
+		default:
+			aligns[i] = lipgloss.Left
+		}
+	}
+
+	var headers []string
+	var rows [][]string
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		style := base
+		if _, ok := row.(*extast.TableHeader); ok {
+			style = base.Bold(true)
+		}
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, r.inline(cell, source, style))
+		}
+		if headers == nil {
+			headers = cells
+		} else {
+			rows = append(rows, cells)
+		}
+	}
+
+	tbl := table.New().
+		Border(r.styles.Border).
+		BorderStyle(lipgloss.NewStyle().Foreground(r.styles.Theme.Muted)).
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if col < len(aligns) {
+				style = style.Align(aligns[col])
+			}
+			return style
+		})
+	out := tbl.String()
+	if lipgloss.Width(out) > width {
+		out = tbl.Width(width).String()
+	}
+	if lipgloss.Width(out) <= width {
+		return out
+	}
+
+	sep := lipgloss.NewStyle().Foreground(r.styles.Theme.Muted).Render(" │ ")
+	lines := []string{strings.Join(headers, sep)}
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, sep))
+	}
+	return wrap(strings.Join(lines, "\n"), width)
+}
+
+// renderCode renders a code block on the code background, highlighted for its
+// language. Lines too long for width are broken rather than wrapped at words.
+func (r *MarkdownRenderer) renderCode(lang string, lines []string, width int, streaming bool) string {
+	inner := max(width-4, 8)
+	expanded := make([]string, len(lines))
+	codeWidth := 0
+	for i, line := range lines {
+		expanded[i] = strings.ReplaceAll(line, "\t", "    ")
+		codeWidth = max(codeWidth, ansi.StringWidth(expanded[i]))
+	}
+	codeWidth = min(max(codeWidth, ansi.StringWidth(lang)), inner)
+
+	bg := r.styles.Code
+	padded := func(s string, used int) string {
+		return bg.Render("  ") + s + bg.Render(strings.Repeat(" ", max(codeWidth-used, 0)+2))
+	}
+
+	var out []string
+	if lang != "" {
+		// A fence label wider than the code area is cut to fit
+		label := ansi.Truncate(lang, codeWidth, "…")
+		out = append(out, padded(r.styles.CodeComment.UnsetItalic().Render(label), ansi.StringWidth(label)))
+	} else {
+		out = append(out, padded("", 0))
+	}
+
+	for _, line := range r.highlight(lang, expanded, streaming) {
+		for _, row := range splitTokens(line, codeWidth) {
+			var b strings.Builder
+			used := 0
+			for _, t := range row {
+				b.WriteString(r.tokenStyle(t.kind).Render(t.text))
+				used += ansi.StringWidth(t.text)
+			}
+			out = append(out, padded(b.String(), used))
+		}
+	}
+	out = append(out, padded("", 0))
+	return strings.Join(out, "\n")
+}
+
+// highlight highlights the lines of a code block. Of a block streaming in, the
+// complete lines are lexed once and the line being written is lexed alone.
+func (r *MarkdownRenderer) highlight(lang string, lines []string, streaming bool) [][]token {
+	if !streaming || len(lines) < 2 {
+		return highlight(lang, lines)
+	}
+	complete := lines[:len(lines)-1]
+	code := strings.Join(complete, "\n")
+	if r.streamed.lang != lang || r.streamed.code != code {
+		r.streamed = streamedCode{lang: lang, code: code, rows: highlight(lang, complete)}
+	}
+	return append(slices.Clone(r.streamed.rows), highlight(lang, lines[len(lines)-1:])...)
+}
+
+// tokenStyle returns the style of a kind of code token
+func (r *MarkdownRenderer) tokenStyle(kind tokenKind) lipgloss.Style {
+	switch kind {
+	case tokenKeyword:
+		return r.styles.CodeKeyword
+	case tokenString:
+		return r.styles.CodeString
+	case tokenNumber:
+		return r.styles.CodeNumber
+	case tokenComment:
+		return r.styles.CodeComment
+	case tokenAdded:
+		return r.styles.Code.Foreground(r.styles.Theme.Success)
+	case tokenRemoved:
+		return r.styles.Code.Foreground(r.styles.Theme.Error)
+	case tokenMeta:
+		return r.styles.Code.Foreground(r.styles.Theme.Secondary)
+	default:
+		return r.styles.Code
+	}
+}
+
+// splitTokens breaks a line of tokens into rows at most width cells wide
+func splitTokens(tokens []token, width int) [][]token {
+	rows := [][]token{nil}
+	used := 0
+	for _, t := range tokens {
+		for t.text != "" {
+			if used == width {
+				rows = append(rows, nil)
+				used = 0
+			}
+			fit := ansi.Truncate(t.text, width-used, "")
+			if fit == "" {
+				// A wide character that doesn't fit goes to the next row
+				if used == 0 {
+					fit = string([]rune(t.text)[:1])
+				} else {
+					used = width
+					continue
+				}
+			}
+			rows[len(rows)-1] = append(rows[len(rows)-1], token{text: fit, kind: t.kind})
+			used += ansi.StringWidth(fit)
+			t.text = t.text[len(fit):]
+		}
+	}
+	return rows
+}
+
+// inline renders the inline content of a block: code spans, emphasis,
+// strikethrough, links and images, with base as the style of plain text.
+// Line breaks in the source are kept.
+func (r *MarkdownRenderer) inline(parent ast.Node, source []byte, base lipgloss.Style) string {
+	var out, plain strings.Builder
+	flush := func() {
+		if plain.Len() == 0 {
+			return
+		}
+		// Styles pad the lines of multi-line text to one width; render each alone
+		lines := strings.Split(plain.String(), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = base.Render(line)
+			}
+		}
+		out.WriteString(strings.Join(lines, "\n"))
+		plain.Reset()
+	}
+
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			plain.Write(textValue(n.Segment.Value(source), n.IsRaw()))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				plain.WriteByte('\n')
+			}
+
+		case *ast.String:
+			plain.Write(textValue(n.Value, n.IsRaw() || n.IsCode()))
+
+		case *ast.CodeSpan:
+			flush()
+			out.WriteString(r.styles.InlineCode.Render(plainText(n, source)))
+
+		case *ast.Emphasis:
+			flush()
+			if n.Level >= 2 {
+				out.WriteString(r.inline(n, source, base.Bold(true)))
+			} else {
+				out.WriteString(r.inline(n, source, base.Italic(true)))
+			}
+
+		case *extast.Strikethrough:
+			flush()
+			out.WriteString(r.inline(n, source, base.Strikethrough(true)))
+
+		case *ast.Link:
+			flush()
+			url := string(n.Destination)
+			out.WriteString(r.inline(n, source, r.styles.Link))
+			if url != plainText(n, source) && !strings.HasPrefix(url, "#") {
+				out.WriteString(r.styles.HelpKey.Render(" (" + url + ")"))
+			}
+
+		case *ast.Image:
+			flush()
+			out.WriteString(r.styles.Link.Render("🖼 " + plainText(n, source)))
+
+		case *ast.AutoLink:
+			flush()
+			out.WriteString(r.styles.Link.Render(string(n.Label(source))))
+
+		case *ast.RawHTML:
+			for i := 0; i < n.Segments.Len(); i++ {
+				segment := n.Segments.At(i)
+				plain.Write(segment.Value(source))
+			}
+
+		case *extast.TaskCheckBox:
+			// Shown as the marker of its list item
+
+		default:
+			flush()
+			out.WriteString(r.inline(n, source, base))
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// plainText returns the text of an inline node without its markup
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(textValue(n.Segment.Value(source), n.IsRaw()))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(textValue(n.Value, n.IsRaw() || n.IsCode()))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// textValue resolves the backslash escapes and entities of markdown text;
+// raw text, such as that of code spans, is taken as it is
+func textValue(value []byte, raw bool) []byte {
+	if raw {
+		return value
+	}
+	return util.ResolveNumericReferences(util.ResolveEntityNames(util.UnescapePunctuations(value)))
+}
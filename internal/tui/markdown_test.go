@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMarkdownRenderer() *MarkdownRenderer {
+	r := NewMarkdownRenderer(NewStyles(GetTheme("dark")))
+	r.SetPlain(false)
+	return r
+}
+
+func TestMarkdownRenderer_Render(t *testing.T) {
+	r := newTestMarkdownRenderer()
+	out := ansi.Strip(r.Render("## Steps\n\n1. Run **it**\n   - [x] nested\n* see [docs](https://example.com) and `code`\n\n| Name | Count |\n|------|------:|\n| a | 1 |\n\n> quoted", 60))
+
+	assert.Contains(t, out, "Steps")
+	assert.NotContains(t, out, "##")
+	assert.Contains(t, out, "1. Run it\n   ☑ nested")
+	assert.Contains(t, out, "• see docs (https://example.com) and  code ")
+	assert.Contains(t, out, "│ Name │ Count │")
+	assert.Contains(t, out, "│ a    │     1 │")
+	assert.Contains(t, out, "│ quoted")
+
+	for _, line := range strings.Split(r.Render(strings.Repeat("word ", 40), 30), "\n") {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 30)
+	}
+}
+
+func TestMarkdownRenderer_Inline(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "emphasis", text: "**bold**, *italic* and ~~struck~~", want: "bold, italic and struck"},
+		{name: "intraword underscores", text: "snake_case_name and 2 * 3 * 4", want: "snake_case_name and 2 * 3 * 4"},
+		{name: "code span", text: "`` a `tick` ``", want: " a `tick` "},
+		{name: "escapes", text: `\*literal\* and **unclosed`, want: "*literal* and **unclosed"},
+		{name: "entities", text: "AT&amp;T &copy; &#65;", want: "AT&T © A"},
+		{name: "image", text: "![diagram](img.png)", want: "🖼 diagram"},
+		{name: "anchor link", text: "[section](#usage)", want: "section"},
+		{name: "link", text: "[docs](https://example.com)", want: "docs (https://example.com)"},
+		{name: "autolink", text: "see <https://example.com> or https://go.dev", want: "see https://example.com or https://go.dev"},
+		{name: "line breaks", text: "one\ntwo  \nthree", want: "one\ntwo\nthree"},
+		{name: "raw html", text: "a <br> b", want: "a <br> b"},
+	}
+	r := newTestMarkdownRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ansi.Strip(r.Render(tt.text, 80)))
+		})
+	}
+}
+
+func TestMarkdownRenderer_Blocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "headings", text: "# One\n## Two\nThree\n-----", want: "One\n\nTwo\n\nThree"},
+		{name: "rule", text: "a\n\n***\n\nb", want: "a\n\n" + strings.Repeat("─", 20) + "\n\nb"},
+		{name: "ordered list", text: "3. three\n4) four", want: "3. three\n\n4) four"},
+		{name: "nested list", text: "- a\n  - b\n    - c\n- d", want: "• a\n  ◦ b\n    ▪ c\n• d"},
+		{name: "loose list item", text: "- a\n\n  more\n- b", want: "• a\n  more\n• b"},
+		{name: "task list", text: "- [ ] todo\n- [x] done", want: "☐ todo\n☑ done"},
+		{name: "quote", text: "> quoted\n> > nested", want: "│ quoted\n│\n│ │ nested"},
+		{name: "html block", text: "<details>\n<summary>x</summary>\n</details>", want: "<details>\n<summary>x</summary>\n</details>"},
+		{name: "table", text: "| a | b |\n|--:|---|\n| 1 | 2 |", want: "╭───┬───╮\n│ a │ b │\n├───┼───┤\n│ 1 │ 2 │\n╰───┴───╯"},
+	}
+	r := newTestMarkdownRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			for _, line := range strings.Split(ansi.Strip(r.Render(tt.text, 20)), "\n") {
+				lines = append(lines, strings.TrimRight(line, " "))
+			}
+			assert.Equal(t, tt.want, strings.Join(lines, "\n"))
+		})
+	}
+}
+
+func TestMarkdownRenderer_StreamingFence(t *testing.T) {
+	r := newTestMarkdownRenderer()
+	out := ansi.Strip(r.Render("intro\n\n```sh\necho streaming\n\n", 40))
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 5, "an unclosed fence runs to the end, less the line being started")
+	assert.Equal(t, "  echo streaming", strings.TrimRight(lines[3], " "))
+
+	// Lines streamed so far are highlighted once
+	out = r.Render("```go\nreturn 42\nfor", 40)
+	assert.Equal(t, "return 42", r.streamed.code)
+	assert.Equal(t, [][]token{{{text: "return", kind: tokenKeyword}, {text: " ", kind: tokenPlain}, {text: "42", kind: tokenNumber}}}, r.streamed.rows)
+	assert.Equal(t, out, r.Render("```go\nreturn 42\nfor", 40))
+
+	out = ansi.Strip(r.Render("```sh\necho closed\n\n```\nafter", 40))
+	assert.Contains(t, out, "  echo closed")
+	assert.True(t, strings.HasSuffix(out, "\n\nafter"))
+}
+
+func TestMarkdownRenderer_CodeBlock(t *testing.T) {
+	r := newTestMarkdownRenderer()
+	out := r.Render("```go\nfunc main() {\n\tfmt.Println(\"hi\") // greet\n}\n```", 40)
+	lines := strings.Split(ansi.Strip(out), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, "  go", strings.TrimRight(lines[0], " "))
+	assert.Equal(t, "      fmt.Println(\"hi\") // greet", strings.TrimRight(lines[2], " "))
+	for _, line := range lines {
+		assert.Equal(t, ansi.StringWidth(lines[0]), ansi.StringWidth(line), "code lines are padded to one width")
+	}
+
+	// Long lines are broken to fit
+	out = ansi.Strip(r.Render("```\n"+strings.Repeat("x", 50)+"\n```", 30))
+	for _, line := range strings.Split(out, "\n") {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 30)
+	}
+}
+
+func TestMarkdownRenderer_LongFenceLabel(t *testing.T) {
+	r := newTestMarkdownRenderer()
+	out := ansi.Strip(r.Render("```00000000000000000000\nx\n```", 21))
+	lines := strings.Split(out, "\n")
+	assert.Equal(t, "  0000000000000000…", strings.TrimRight(lines[0], " "))
+	for _, line := range lines {
+		assert.Equal(t, 21, ansi.StringWidth(line))
+	}
+}
+
+func FuzzRenderCode(f *testing.F) {
+	f.Add("00000000000000000000", "x", 21)
+	f.Add("go", "func f() {\n\t/* open\n}", 10)
+	f.Add("", "日本語のテキスト\tand tabs", 30)
+	f.Fuzz(func(t *testing.T, lang, code string, width int) {
+		// Widths of control and format characters and of broken UTF-8 are
+		// up to the terminal
+		if !utf8.ValidString(lang) || !utf8.ValidString(code) || strings.ContainsFunc(lang+code, func(r rune) bool {
+			return (unicode.IsControl(r) || unicode.Is(unicode.Cf, r)) && r != '\n' && r != '\t'
+		}) {
+			t.Skip()
+		}
+		if strings.ContainsFunc(lang, unicode.IsSpace) {
+			t.Skip() // Not a fence language
+		}
+		width = 20 + (width%200+200)%200
+		r := newTestMarkdownRenderer()
+		lines := strings.Split(ansi.Strip(r.renderCode(lang, strings.Split(code, "\n"), width, false)), "\n")
+		for _, line := range lines {
+			if ansi.StringWidth(line) != ansi.StringWidth(lines[0]) || ansi.StringWidth(line) > width {
+				t.Fatalf("line %q is %d cells wide, want %d and at most %d", line, ansi.StringWidth(line), ansi.StringWidth(lines[0]), width)
+			}
+		}
+	})
+}
+
+func TestMarkdownRenderer_CachesFinishedBlocks(t *testing.T) {
+	r := newTestMarkdownRenderer()
+	first := r.Render("Intro paragraph.\n\nStreaming", 40)
+	assert.Len(t, r.cache, 1, "the last block may still change")
+
+	second := r.Render("Intro paragraph.\n\nStreaming more text", 40)
+	assert.Equal(t, strings.Split(first, "\n")[0], strings.Split(second, "\n")[0])
+	assert.Len(t, r.cache, 1)
+
+	r.SetPlain(true)
+	assert.Equal(t, "**raw**", r.Render("**raw**", 40))
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, [][]token{{
+		{text: "return", kind: tokenKeyword},
+		{text: " x + ", kind: tokenPlain},
+		{text: "42", kind: tokenNumber},
+		{text: " ", kind: tokenPlain},
+		{text: "// done", kind: tokenComment},
+	}}, highlight("go", []string{"return x + 42 // done"}))
+
+	// Comments and strings spanning lines are split at the line ends
+	assert.Equal(t, [][]token{
+		{{text: "/* open", kind: tokenComment}},
+		{{text: "close */", kind: tokenComment}, {text: " x", kind: tokenPlain}},
+		{{text: "s := ", kind: tokenPlain}, {text: "`raw", kind: tokenString}},
+		{{text: "still raw`", kind: tokenString}},
+	}, highlight("golang", []string{"/* open", "close */ x", "s := `raw", "still raw`"}))
+
+	assert.Equal(t, [][]token{{{text: "runs-on", kind: tokenKeyword}, {text: ": ", kind: tokenPlain}, {text: "true", kind: tokenKeyword}}},
+		highlight("yaml", []string{"runs-on: true"}))
+	assert.Equal(t, tokenKeyword, highlight("SQL", []string{"select 1"})[0][0].kind)
+
+	diff := highlight("diff", []string{"@@ -1 +1 @@", "+added", "-removed", " same"})
+	assert.Equal(t, tokenMeta, diff[0][0].kind)
+	assert.Equal(t, tokenAdded, diff[1][0].kind)
+	assert.Equal(t, tokenRemoved, diff[2][0].kind)
+	assert.Equal(t, tokenPlain, diff[3][0].kind)
+
+	assert.Equal(t, [][]token{{{text: "plain text", kind: tokenPlain}}, nil}, highlight("unknown-language", []string{"plain text", ""}))
+	assert.Equal(t, [][]token{{{text: "x := 1", kind: tokenPlain}}}, highlight("", []string{"x := 1"}))
+}
+
+func FuzzMarkdownRender(f *testing.F) {
+	f.Add("# Title\n\n- a\n  - b\n\n| x | y |\n|---|---|\n| 1 | 2 |\n\n> **quote** `code` [link](url)", 40)
+	f.Add(">>>>>>>>>>>>", 3)
+	f.Add("000000000000000000000-", 1)
+	f.Add("- - - - - - - - - - 1. 2. 3. deep", 0)
+	f.Add("|a|b|c|d|e|f|g|h|i|j|k|\n|-|-|-|-|-|-|-|-|-|-|-|\n|1|2|3|4|5|6|7|8|9|10|11|", 0)
+	f.Add("```go\nfunc f() {\n\t/* open\n}\n", 10)
+	f.Fuzz(func(t *testing.T, text string, width int) {
+		// Widths of control and format characters and of broken UTF-8 are
+		// up to the terminal
+		if !utf8.ValidString(text) || strings.ContainsFunc(text, func(r rune) bool {
+			return (unicode.IsControl(r) || unicode.Is(unicode.Cf, r)) && r != '\n' && r != '\t'
+		}) {
+			t.Skip()
+		}
+		width = 20 + (width%200+200)%200
+		r := newTestMarkdownRenderer()
+		for _, line := range strings.Split(r.Render(text, width), "\n") {
+			if w := ansi.StringWidth(line); w > width {
+				t.Fatalf("line %q is %d cells wide, more than %d", line, w, width)
+			}
+		}
+	})
+}
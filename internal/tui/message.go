@@ -8,11 +8,13 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/mentions"
 )
 
 // MessageView renders a message
 type MessageView struct {
 	styles   *Styles
+	markdown *MarkdownRenderer
 	expanded map[string]bool // Tool call IDs that are expanded
 }
 
@@ -20,10 +22,17 @@ type MessageView struct {
 func NewMessageView(styles *Styles) *MessageView {
 	return &MessageView{
 		styles:   styles,
+		markdown: NewMarkdownRenderer(styles),
 		expanded: make(map[string]bool),
 	}
 }
 
+// SetStyles switches to other styles, e.g. after a theme change
+func (mv *MessageView) SetStyles(styles *Styles) {
+	mv.styles = styles
+	mv.markdown.SetStyles(styles)
+}
+
 // Render renders an agent message
 func (mv *MessageView) Render(msg agent.AgentMessage, width int) string {
 	message := msg.Message
@@ -52,9 +61,22 @@ func (mv *MessageView) renderUserMessage(msg ai.UserMessage, width int) string {
 	header := lipgloss.JoinHorizontal(lipgloss.Top, roleHeader, " ", timestamp)
 	parts = append(parts, header)
 
-	// Content
-	content := mv.renderContent(msg.Content, width-4)
-	parts = append(parts, content)
+	// Content, with the files attached by @mentions listed by name
+	var contents []ai.Content
+	var attached []string
+	for _, content := range msg.Content {
+		if text, ok := content.(ai.TextContent); ok {
+			if label, ok := mentions.Describe(text.Text); ok {
+				attached = append(attached, label)
+				continue
+			}
+		}
+		contents = append(contents, content)
+	}
+	parts = append(parts, mv.renderContent(contents, width-4))
+	for _, label := range attached {
+		parts = append(parts, mv.styles.HelpKey.Render("📎 "+label))
+	}
 
 	// Combine and apply style
 	combined := lipgloss.JoinVertical(lipgloss.Left, parts...)
@@ -98,9 +120,26 @@ func (mv *MessageView) renderAssistantMessage(msg ai.AssistantMessage, width int
 
 // renderToolResultMessage renders a tool result message
 func (mv *MessageView) renderToolResultMessage(msg ai.ToolResultMessage, width int) string {
-	// Don't render tool results separately - they're shown inline in the response
-	// Just return empty string or a minimal indicator
-	return ""
+	// Tool results are shown inline in the response, except for the diff of
+	// a file change
+	text := toolResultDiff(msg.Details)
+	if msg.IsError || text == "" {
+		return ""
+	}
+
+	maxLines := maxCollapsedDiffLines
+	if mv.expanded[msg.ToolCallID] {
+		maxLines = 0
+	}
+	// The tool output style pads the diff by two columns on each side
+	rendered, hidden := renderDiff(mv.styles, text, width-4, maxLines)
+	if rendered == "" {
+		return ""
+	}
+	if hidden > 0 {
+		rendered += "\n" + mv.styles.DiffFold.Render(fmt.Sprintf("… %d more line%s (ctrl+o to expand)", hidden, plural(hidden)))
+	}
+	return mv.styles.ToolOutput.Render(rendered)
 }
 
 // renderContent renders message content
@@ -123,135 +162,10 @@ func (mv *MessageView) renderContent(contents []ai.Content, width int) string {
 	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
-// renderText renders text content
+// renderText renders text content as markdown. The message style pads the
+// text by a column on each side.
 func (mv *MessageView) renderText(text string, width int) string {
-	// Simple markdown-like rendering
-	var lines []string
-
-	for _, line := range strings.Split(text, "\n") {
-		// Code blocks (lines starting with 4 spaces or tab)
-		if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
-			lines = append(lines, mv.styles.CodeBlock.Render(strings.TrimPrefix(strings.TrimPrefix(line, "    "), "\t")))
-			continue
-		}
-
-		// Bullet points (lines starting with - or *)
-		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
-			bullet := lipgloss.NewStyle().
-				Foreground(mv.styles.Theme.Accent).
-				Render("●")
-			content := strings.TrimPrefix(strings.TrimPrefix(line, "- "), "* ")
-			lines = append(lines, bullet+" "+content)
-			continue
-		}
-
-		// Numbered lists (lines starting with number.)
-		if len(line) > 2 && line[0] >= '0' && line[0] <= '9' {
-			// Check if there's a ". " pattern in the first few characters
-			checkLen := len(line)
-			if checkLen > 4 {
-				checkLen = 4
-			}
-			if strings.Contains(line[:checkLen], ". ") {
-				// Keep numbered format
-				lines = append(lines, line)
-				continue
-			}
-		}
-
-		// Inline code (backticks)
-		if strings.Contains(line, "`") {
-			line = mv.renderInlineCode(line)
-		}
-
-		// Quotes (lines starting with >)
-		if strings.HasPrefix(line, ">") {
-			lines = append(lines, mv.styles.Quote.Render(strings.TrimPrefix(line, "> ")))
-			continue
-		}
-
-		// Bold text (**text**)
-		if strings.Contains(line, "**") {
-			line = mv.renderBoldText(line)
-		}
-
-		lines = append(lines, line)
-	}
-
-	return strings.Join(lines, "\n")
-}
-
-// renderInlineCode renders inline code with backticks
-func (mv *MessageView) renderInlineCode(text string) string {
-	var result strings.Builder
-	inCode := false
-	var currentCode strings.Builder
-
-	for i := 0; i < len(text); i++ {
-		if text[i] == '`' {
-			if inCode {
-				// End of code block
-				result.WriteString(mv.styles.InlineCode.Render(currentCode.String()))
-				currentCode.Reset()
-				inCode = false
-			} else {
-				// Start of code block
-				inCode = true
-			}
-		} else {
-			if inCode {
-				currentCode.WriteByte(text[i])
-			} else {
-				result.WriteByte(text[i])
-			}
-		}
-	}
-
-	// Handle unclosed code block
-	if inCode {
-		result.WriteString(currentCode.String())
-	}
-
-	return result.String()
-}
-
-// renderBoldText renders bold text with **text**
-func (mv *MessageView) renderBoldText(text string) string {
-	var result strings.Builder
-	inBold := false
-	var currentBold strings.Builder
-	i := 0
-
-	for i < len(text) {
-		if i+1 < len(text) && text[i:i+2] == "**" {
-			if inBold {
-				// End of bold
-				boldStyle := lipgloss.NewStyle().Bold(true)
-				result.WriteString(boldStyle.Render(currentBold.String()))
-				currentBold.Reset()
-				inBold = false
-			} else {
-				// Start of bold
-				inBold = true
-			}
-			i += 2
-		} else {
-			if inBold {
-				currentBold.WriteByte(text[i])
-			} else {
-				result.WriteByte(text[i])
-			}
-			i++
-		}
-	}
-
-	// Handle unclosed bold
-	if inBold {
-		result.WriteString("**")
-		result.WriteString(currentBold.String())
-	}
-
-	return result.String()
+	return mv.markdown.Render(text, width-2)
 }
 
 // renderThinking renders thinking content
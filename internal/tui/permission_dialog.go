@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
@@ -24,6 +25,7 @@ func getUserName() string {
 // PermissionDialogModel represents the permission confirmation dialog
 type PermissionDialogModel struct {
 	request       *agent.PermissionRequest
+	diff          string // Unified diff of the change a file tool would make
 	styles        *Styles
 	selectedIndex int
 	width         int
@@ -43,6 +45,7 @@ func NewPermissionDialog(styles *Styles) *PermissionDialogModel {
 // Show displays the permission dialog with a request
 func (m *PermissionDialogModel) Show(req *agent.PermissionRequest) {
 	m.request = req
+	m.diff = ""
 	m.selectedIndex = 0
 	m.visible = true
 }
@@ -51,6 +54,12 @@ func (m *PermissionDialogModel) Show(req *agent.PermissionRequest) {
 func (m *PermissionDialogModel) Hide() {
 	m.visible = false
 	m.request = nil
+	m.diff = ""
+}
+
+// SetDiff shows the change the request would make to files below the resource
+func (m *PermissionDialogModel) SetDiff(diff string) {
+	m.diff = diff
 }
 
 // IsVisible returns whether the dialog is visible
@@ -143,6 +152,12 @@ func (m *PermissionDialogModel) View() string {
 	content.WriteString(commandBox)
 	content.WriteString("\n\n")
 
+	// The change to files, cut to the room the screen leaves
+	if m.diff != "" {
+		content.WriteString(m.renderDiff(dialogWidth - 4))
+		content.WriteString("\n\n")
+	}
+
 	// Question
 	question := lipgloss.NewStyle().
 		Foreground(m.styles.Theme.Foreground).
@@ -163,7 +178,7 @@ func (m *PermissionDialogModel) View() string {
 
 	// Add border
 	dialogStyle := lipgloss.NewStyle().
-		BorderStyle(lipgloss.RoundedBorder()).
+		BorderStyle(m.styles.Border).
 		BorderForeground(m.styles.Theme.Primary).
 		Padding(1, 2).
 		Width(dialogWidth)
@@ -192,8 +207,12 @@ func (m *PermissionDialogModel) getActionLabel() string {
 		return "read"
 	case "write":
 		return "write"
-	case "edit":
+	case "edit", "multi_edit":
 		return "edit"
+	case "web_fetch":
+		return "fetch"
+	case "web_search":
+		return "search"
 	default:
 		return m.request.Action
 	}
@@ -229,6 +248,23 @@ func (m *PermissionDialogModel) renderCommandBox(width int) string {
 	return strings.Join(lines, "\n")
 }
 
+// renderDiff renders the diff of the request. The rest of the dialog takes
+// about 18 lines, so the diff gets what the screen height leaves.
+func (m *PermissionDialogModel) renderDiff(width int) string {
+	maxLines := 20
+	if m.height > 0 {
+		maxLines = max(m.height-18, 5)
+	}
+	rendered, hidden := renderDiff(m.styles, m.diff, width, maxLines)
+	if rendered == "" {
+		return m.styles.DiffFold.Render("No changes")
+	}
+	if hidden > 0 {
+		rendered += "\n" + m.styles.DiffFold.Render(fmt.Sprintf("… %d more line%s", hidden, plural(hidden)))
+	}
+	return rendered
+}
+
 // renderOptions renders the choice options
 func (m *PermissionDialogModel) renderOptions(width int) string {
 	options := []string{
@@ -293,8 +329,17 @@ func (m *PermissionDialogModel) getRememberOptionLabel() string {
 		return fmt.Sprintf("Yes, allow %s from %s/ from this project", action, username)
 	case "read":
 		return fmt.Sprintf("Yes, allow reading from %s/ from this project", username)
-	case "write", "edit":
+	case "write", "edit", "multi_edit":
 		return fmt.Sprintf("Yes, allow %s from %s/ from this project", action, username)
+	case "web_fetch":
+		if rawURL, ok := m.request.Params["url"].(string); ok {
+			if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+				return fmt.Sprintf("Yes, allow fetching from %s from this project", u.Hostname())
+			}
+		}
+		return "Yes, allow fetching from any site from this project"
+	case "web_search":
+		return "Yes, allow web searches from this project"
 	default:
 		return fmt.Sprintf("Yes, allow %s from %s/ from this project", action, username)
 	}
@@ -0,0 +1,304 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+)
+
+// SetModels sets the models offered by the /model picker
+func (m *ChatModel) SetModels(registry *codingagent.ModelRegistry) {
+	m.models = registry
+}
+
+// SetTheme switches the TUI to the theme with the given name. Messages
+// already printed to the terminal keep their colors.
+func (m *ChatModel) SetTheme(name string) {
+	*m.styles = *NewStyles(GetTheme(name))
+	m.editor.SetStyles(m.styles)
+	m.messageView.SetStyles(m.styles)
+	m.spinner.Style = m.styles.Spinner
+	m.viewport.Style = m.styles.App
+	m.updateViewportContent()
+}
+
+// ThemeChangedMsg is sent when the theme files are reloaded, with the problems
+// found in them
+type ThemeChangedMsg struct {
+	Issues []ThemeIssue
+}
+
+// applyThemeChange redraws with the reloaded version of the current theme
+func (m *ChatModel) applyThemeChange(msg ThemeChangedMsg) {
+	m.SetTheme(m.styles.Theme.Name)
+	if len(msg.Issues) > 0 {
+		m.error = "Theme: " + msg.Issues[0].String()
+	}
+}
+
+// showModelPicker opens the picker of the models in the registry
+func (m *ChatModel) showModelPicker() tea.Cmd {
+	if m.isAgentRunning {
+		m.error = "Cannot switch models while the agent is running"
+		return nil
+	}
+	m.error = ""
+	items := modelPickerItems(m.models.List(), m.agentState.GetModel().ID)
+	m.listPicker.Show(PickerModel, "Switch model", items, true, nil)
+	return m.pickerMouse()
+}
+
+// showSessionPicker opens the picker of the saved sessions and the sessions of this run
+func (m *ChatModel) showSessionPicker() tea.Cmd {
+	if m.isAgentRunning {
+		m.error = "Cannot switch sessions while the agent is running"
+		return nil
+	}
+	if m.sessionManager == nil || m.session == nil {
+		m.error = "Sessions are not available"
+		return nil
+	}
+	list, err := m.sessionManager.List()
+	if err != nil {
+		m.error = fmt.Sprintf("Failed to list sessions: %v", err)
+		return nil
+	}
+	m.error = ""
+	m.sessionPreviews = make(map[string]string)
+	m.listPicker.Show(PickerSession, "Resume session", sessionPickerItems(list, m.session.Metadata.ID), true, m.previewSession)
+	return m.pickerMouse()
+}
+
+// showThemePicker opens the picker of the themes
+func (m *ChatModel) showThemePicker() tea.Cmd {
+	m.error = ""
+	m.listPicker.Show(PickerTheme, "Theme", themePickerItems(m.styles.Theme.Name), false, nil)
+	return m.pickerMouse()
+}
+
+// pickerMouse turns on mouse tracking while a picker is open, so that it can
+// be scrolled and clicked
+func (m *ChatModel) pickerMouse() tea.Cmd {
+	if m.mouseWheelEnabled {
+		return nil
+	}
+	return enableMouseTracking()
+}
+
+// pickerClosed turns mouse tracking off again once a picker is closed, unless
+// the mouse wheel scrolls the TUI
+func (m *ChatModel) pickerClosed() tea.Cmd {
+	if m.listPicker.IsVisible() || m.mouseWheelEnabled {
+		return nil
+	}
+	return disableMouseTracking()
+}
+
+// applyPick acts on the item picked from a list picker
+func (m *ChatModel) applyPick(msg PickerSelectMsg) tea.Cmd {
+	switch msg.Kind {
+	case PickerModel:
+		if msg.Value == m.agentState.GetModel().ID {
+			return nil
+		}
+		// The /model command finds the provider of the model
+		return m.executeCommand("/model " + msg.Value)
+	case PickerSession:
+		return m.switchSession(msg.Value)
+	case PickerTheme:
+		m.SetTheme(msg.Value)
+		m.statusMessage = fmt.Sprintf("Theme: %s", msg.Value)
+	}
+	return nil
+}
+
+// previewSession describes the last messages of a session for the session picker
+func (m *ChatModel) previewSession(item PickerItem) string {
+	if preview, ok := m.sessionPreviews[item.Value]; ok {
+		return preview
+	}
+
+	var preview string
+	if item.Value == m.session.Metadata.ID {
+		preview = describeMessages(m.agentState.GetMessages())
+	} else if session, err := m.sessionManager.Load(item.Value); err != nil {
+		preview = fmt.Sprintf("Failed to load the session: %v", err)
+	} else if session.State == nil {
+		preview = "The session has no messages."
+	} else {
+		preview = describeMessages(session.State.GetMessages())
+	}
+	m.sessionPreviews[item.Value] = preview
+	return preview
+}
+
+// switchSession continues the session with the given ID and prints its history
+func (m *ChatModel) switchSession(id string) tea.Cmd {
+	if m.isAgentRunning {
+		m.error = "Cannot switch sessions while the agent is running"
+		return nil
+	}
+	if id == m.session.Metadata.ID {
+		return nil
+	}
+
+	// Keep the conversation so far so it can be resumed too
+	m.saveSession()
+
+	session, err := m.sessionManager.Switch(m.session, id)
+	if err != nil {
+		m.error = fmt.Sprintf("Failed to switch sessions: %v", err)
+		return nil
+	}
+	m.error = ""
+	m.session = session
+	if auditLog, ok := m.ctx.Value("audit_log").(*agent.AuditLog); ok {
+		auditLog.SetSessionID(session.Metadata.ID)
+	}
+
+	m.openCheckpoints(session.Metadata.ID)
+
+	// Print the history of the session below what is already in the scrollback
+	m.messages = m.agentState.GetMessages()
+	m.streamingMessage = nil
+	m.lastRenderedIdx = len(m.messages) - 1
+	m.updateViewportContent()
+
+	notice := fmt.Sprintf("↺ Resumed session %s: %s", session.Metadata.ID, session.Metadata.Title)
+	cmds := []tea.Cmd{tea.Println(m.styles.HelpValue.Render(notice))}
+	if m.useHybridMode {
+		for _, msg := range m.messages {
+			cmds = append(cmds, tea.Println(m.messageView.Render(msg, m.width)))
+		}
+	}
+	if m.statusMessage == "" {
+		m.statusMessage = notice
+	}
+	return tea.Sequence(cmds...)
+}
+
+// saveSession saves the current session so it can be resumed later. Sessions
+// without messages are not saved.
+func (m *ChatModel) saveSession() {
+	if m.sessionManager == nil || m.session == nil || len(m.session.State.GetMessages()) == 0 {
+		return
+	}
+	if err := m.sessionManager.Save(m.session); err != nil {
+		m.statusMessage = fmt.Sprintf("Failed to save the session: %v", err)
+	}
+}
+
+// modelPickerItems lists models by provider and name, with their context
+// window and cost
+func modelPickerItems(models []codingagent.ModelConfig, currentID string) []PickerItem {
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].Provider != models[j].Provider {
+			return models[i].Provider < models[j].Provider
+		}
+		return models[i].Name < models[j].Name
+	})
+
+	items := make([]PickerItem, 0, len(models))
+	for _, model := range models {
+		var details []string
+		if model.ContextWindow > 0 {
+			details = append(details, formatTokenCount(model.ContextWindow)+" context")
+		}
+		if model.InputCostPer1M > 0 || model.OutputCostPer1M > 0 {
+			details = append(details, fmt.Sprintf("$%.2f/$%.2f per 1M tokens", model.InputCostPer1M, model.OutputCostPer1M))
+		}
+		if model.SupportsVision {
+			details = append(details, "images")
+		}
+		items = append(items, PickerItem{
+			Value:   model.ID,
+			Label:   model.Name,
+			Detail:  strings.Join(details, " · "),
+			Group:   model.Provider,
+			Current: model.ID == currentID,
+		})
+	}
+	return items
+}
+
+// sessionPickerItems lists sessions, most recently updated first
+func sessionPickerItems(sessions []codingagent.SessionMetadata, currentID string) []PickerItem {
+	items := make([]PickerItem, 0, len(sessions))
+	for _, meta := range sessions {
+		detail := meta.UpdatedAt.Format("2006-01-02 15:04") + " · " + meta.ID
+		if meta.ParentID != "" {
+			detail += " · fork of " + meta.ParentID
+		}
+		items = append(items, PickerItem{
+			Value:   meta.ID,
+			Label:   meta.Title,
+			Detail:  detail,
+			Current: meta.ID == currentID,
+		})
+	}
+	return items
+}
+
+// themePickerItems lists the themes
+func themePickerItems(current string) []PickerItem {
+	var items []PickerItem
+	for _, name := range ThemeNames() {
+		items = append(items, PickerItem{Value: name, Label: name, Current: name == current})
+	}
+	return items
+}
+
+// describeMessages summarizes a conversation with a line per prompt and reply
+func describeMessages(messages []agent.AgentMessage) string {
+	var lines []string
+	for _, msg := range messages {
+		switch message := msg.Message.(type) {
+		case ai.UserMessage:
+			if text := contentText(message.Content); text != "" {
+				lines = append(lines, "You: "+text)
+			}
+		case ai.AssistantMessage:
+			text := contentText(message.Content)
+			for _, content := range message.Content {
+				if call, ok := content.(ai.ToolCall); ok && text == "" {
+					text = fmt.Sprintf("[%s]", call.Name)
+				}
+			}
+			if text != "" {
+				lines = append(lines, "Assistant: "+text)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "No messages."
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contentText returns the text of contents on one line
+func contentText(contents []ai.Content) string {
+	var parts []string
+	for _, content := range contents {
+		if text, ok := content.(ai.TextContent); ok && strings.TrimSpace(text.Text) != "" {
+			parts = append(parts, strings.Join(strings.Fields(text.Text), " "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatTokenCount shortens a token count, e.g. 200000 to "200K"
+func formatTokenCount(n int) string {
+	switch {
+	case n >= 1_000_000 && n%100_000 == 0:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1_000_000), ".0") + "M"
+	case n >= 1000:
+		return fmt.Sprintf("%dK", n/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+)
+
+// rewindOption is a choice in the second step of the rewind picker
+type rewindOption struct {
+	label string
+	mode  codingagent.RewindMode
+	fork  bool
+}
+
+// rewindOptions are the ways a rewind can be applied
+var rewindOptions = []rewindOption{
+	{label: "Restore code and conversation", mode: codingagent.RewindBoth},
+	{label: "Restore conversation", mode: codingagent.RewindConversation},
+	{label: "Restore code", mode: codingagent.RewindCode},
+	{label: "Restore code and branch the conversation (keeps current history)", mode: codingagent.RewindBoth, fork: true},
+}
+
+// RewindPickerModel lets the user pick a message to rewind to and what to restore
+type RewindPickerModel struct {
+	styles        *Styles
+	points        []codingagent.RewindPoint // Newest first
+	selectedIndex int
+	optionIndex   int
+	choosingMode  bool
+	width         int
+	height        int
+	visible       bool
+}
+
+// NewRewindPicker creates a new rewind picker
+func NewRewindPicker(styles *Styles) *RewindPickerModel {
+	return &RewindPickerModel{styles: styles}
+}
+
+// Show displays the picker for the given rewind points (oldest first)
+func (m *RewindPickerModel) Show(points []codingagent.RewindPoint) {
+	m.points = make([]codingagent.RewindPoint, len(points))
+	for i, point := range points {
+		m.points[len(points)-1-i] = point
+	}
+	m.selectedIndex = 0
+	m.optionIndex = 0
+	m.choosingMode = false
+	m.visible = true
+}
+
+// Hide hides the picker
+func (m *RewindPickerModel) Hide() {
+	m.visible = false
+	m.points = nil
+}
+
+// IsVisible returns whether the picker is visible
+func (m *RewindPickerModel) IsVisible() bool {
+	return m.visible
+}
+
+// SetSize sets the picker size
+func (m *RewindPickerModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+// Update handles messages
+func (m *RewindPickerModel) Update(msg tea.Msg) (*RewindPickerModel, tea.Cmd) {
+	if !m.visible {
+		return m, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.choosingMode && m.optionIndex > 0 {
+			m.optionIndex--
+		} else if !m.choosingMode && m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+	case "down", "j":
+		if m.choosingMode && m.optionIndex < len(rewindOptions)-1 {
+			m.optionIndex++
+		} else if !m.choosingMode && m.selectedIndex < len(m.points)-1 {
+			m.selectedIndex++
+		}
+	case "enter":
+		if !m.choosingMode {
+			m.choosingMode = true
+			m.optionIndex = 0
+			return m, nil
+		}
+		point := m.points[m.selectedIndex]
+		option := rewindOptions[m.optionIndex]
+		m.Hide()
+		return m, func() tea.Msg {
+			return RewindMsg{MessageID: point.MessageID, Mode: option.mode, Fork: option.fork}
+		}
+	case "esc":
+		if m.choosingMode {
+			m.choosingMode = false
+			return m, nil
+		}
+		m.Hide()
+	}
+
+	return m, nil
+}
+
+// View renders the picker
+func (m *RewindPickerModel) View() string {
+	if !m.visible {
+		return ""
+	}
+
+	dialogWidth := 80
+	if m.width > 0 && m.width < dialogWidth+4 {
+		dialogWidth = m.width - 4
+	}
+
+	var content strings.Builder
+
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(m.styles.Theme.Primary).
+		Render("Rewind")
+	content.WriteString(title)
+	content.WriteString("\n\n")
+
+	muted := lipgloss.NewStyle().Foreground(m.styles.Theme.Muted)
+
+	if len(m.points) == 0 {
+		content.WriteString(muted.Render("No messages to rewind to."))
+		content.WriteString("\n\n")
+		content.WriteString(muted.Render("Esc to close"))
+		return m.frame(content.String(), dialogWidth)
+	}
+
+	if m.choosingMode {
+		point := m.points[m.selectedIndex]
+		content.WriteString(lipgloss.NewStyle().Foreground(m.styles.Theme.Foreground).Render(
+			"Rewind to before: " + truncateLine(point.Prompt, dialogWidth-24)))
+		content.WriteString("\n")
+		content.WriteString(muted.Render(describeFiles(point.Files)))
+		content.WriteString("\n\n")
+
+		for i, option := range rewindOptions {
+			content.WriteString(m.renderItem(i, option.label, i == m.optionIndex))
+			content.WriteString("\n")
+		}
+		content.WriteString("\n")
+		content.WriteString(muted.Render("Enter to confirm · Esc to go back"))
+		return m.frame(content.String(), dialogWidth)
+	}
+
+	content.WriteString(muted.Render("Restore the code and/or conversation to before a message:"))
+	content.WriteString("\n\n")
+
+	// Show a window of messages around the selection
+	const maxVisible = 10
+	start := 0
+	if m.selectedIndex >= maxVisible {
+		start = m.selectedIndex - maxVisible + 1
+	}
+	end := start + maxVisible
+	if end > len(m.points) {
+		end = len(m.points)
+	}
+
+	for i := start; i < end; i++ {
+		point := m.points[i]
+		label := fmt.Sprintf("%s  %s", truncateLine(point.Prompt, dialogWidth-32), muted.Render(describeFiles(point.Files)))
+		content.WriteString(m.renderItem(i, label, i == m.selectedIndex))
+		content.WriteString("\n")
+	}
+	content.WriteString("\n")
+	content.WriteString(muted.Render("↑/↓ to select · Enter to choose · Esc to cancel"))
+
+	return m.frame(content.String(), dialogWidth)
+}
+
+// renderItem renders a selectable line
+func (m *RewindPickerModel) renderItem(index int, label string, selected bool) string {
+	prefix := "  "
+	style := lipgloss.NewStyle().Foreground(m.styles.Theme.Foreground)
+	if selected {
+		prefix = lipgloss.NewStyle().Foreground(m.styles.Theme.Primary).Render("❯ ")
+		style = lipgloss.NewStyle().Foreground(m.styles.Theme.Primary).Bold(true)
+	}
+	number := lipgloss.NewStyle().Foreground(m.styles.Theme.Muted).Render(fmt.Sprintf("%d. ", index+1))
+	return prefix + number + style.Render(label)
+}
+
+// frame draws the border and centers the dialog
+func (m *RewindPickerModel) frame(content string, width int) string {
+	dialog := lipgloss.NewStyle().
+		BorderStyle(m.styles.Border).
+		BorderForeground(m.styles.Theme.Primary).
+		Padding(1, 2).
+		Width(width).
+		Render(content)
+
+	if m.height > 0 {
+		topPadding := (m.height - lipgloss.Height(dialog)) / 2
+		if topPadding > 0 {
+			dialog = strings.Repeat("\n", topPadding) + dialog
+		}
+	}
+
+	return dialog
+}
+
+// describeFiles summarizes the files a rewind would restore
+func describeFiles(files []string) string {
+	switch len(files) {
+	case 0:
+		return "no file changes"
+	case 1:
+		return "restores " + filepath.Base(files[0])
+	default:
+		return fmt.Sprintf("restores %d files", len(files))
+	}
+}
+
+// truncateLine returns the first line of text, shortened to width
+func truncateLine(text string, width int) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " …"
+	}
+	runes := []rune(text)
+	if width > 1 && len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return text
+}
+
+// RewindMsg is sent when the user picks a rewind point
+type RewindMsg struct {
+	MessageID string
+	Mode      codingagent.RewindMode
+	Fork      bool
+}
@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/statusline"
+)
+
+// StatusLineMsg carries a freshly rendered status line
+type StatusLineMsg struct {
+	Line string
+	Err  error
+}
+
+// statusLineTickMsg refreshes the status line on a timer
+type statusLineTickMsg struct{}
+
+// SetStatusLine configures the status line shown above the footer
+func (m *ChatModel) SetStatusLine(config statusline.Config) {
+	m.statusLineConfig = config
+}
+
+// refreshStatusLine renders the status line in the background, unless a
+// refresh is already running
+func (m *ChatModel) refreshStatusLine() tea.Cmd {
+	if m.statusLineBusy {
+		return nil
+	}
+	m.statusLineBusy = true
+
+	info := statusline.Collect(m.agentState, m.priceModel)
+	info.WorkingDir = m.workingDir
+	if m.shellCwd != "" {
+		info.WorkingDir = m.shellCwd
+	}
+	if m.session != nil {
+		info.Session = statusline.SessionInfo{ID: m.session.Metadata.ID, Title: m.session.Metadata.Title}
+	}
+
+	config := m.statusLineConfig
+	dir := info.WorkingDir
+	ctx := m.ctx
+	return func() tea.Msg {
+		info.Git = statusline.GitStatus(ctx, dir)
+		info.WorkingDir = shortenHome(info.WorkingDir)
+		line, err := statusline.Render(ctx, config, info, dir)
+		return StatusLineMsg{Line: line, Err: err}
+	}
+}
+
+// statusLineTick schedules the next timed refresh
+func (m *ChatModel) statusLineTick() tea.Cmd {
+	return tea.Tick(m.statusLineConfig.RefreshInterval(), func(time.Time) tea.Msg {
+		return statusLineTickMsg{}
+	})
+}
+
+// priceModel returns the model with the given ID from the registry
+func (m *ChatModel) priceModel(id string) (ai.Model, bool) {
+	if m.models == nil {
+		return ai.Model{}, false
+	}
+	model, err := m.models.ToAIModel(id)
+	return model, err == nil
+}
+
+// refreshesStatusLine reports whether an agent event changes what the status line shows
+func refreshesStatusLine(event agent.AgentEvent) bool {
+	switch event.(type) {
+	case agent.AgentEndEvent, agent.TurnEndEvent, agent.ToolExecutionEndEvent, agent.ErrorEvent:
+		return true
+	}
+	return false
+}
+
+// shortenHome replaces the home directory at the start of path with ~
+func shortenHome(path string) string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" && strings.HasPrefix(path, home) {
+		return "~" + strings.TrimPrefix(path, home)
+	}
+	return path
+}
+
+// renderStatusLine renders the status line to fit the width
+func (m *ChatModel) renderStatusLine() string {
+	if m.statusLineErr != "" {
+		return m.styles.Warning.Render(truncateLine("Status line: "+m.statusLineErr, m.width-2))
+	}
+	if m.statusLine == "" {
+		return ""
+	}
+	return m.styles.HelpValue.Render(truncateLine(m.statusLine, m.width-2))
+}
+
+// applyStatusLine shows a rendered status line
+func (m *ChatModel) applyStatusLine(msg StatusLineMsg) {
+	m.statusLineBusy = false
+	if msg.Err != nil {
+		m.statusLineErr = msg.Err.Error()
+		return
+	}
+	m.statusLineErr = ""
+	m.statusLine = msg.Line
+}
@@ -21,6 +21,23 @@ type Theme struct {
 	AIMessage     lipgloss.Color
 	ToolMessage   lipgloss.Color
 	ThinkingColor lipgloss.Color
+
+	// Code colors
+	CodeBackground lipgloss.Color
+	SyntaxKeyword  lipgloss.Color
+	SyntaxString   lipgloss.Color
+	SyntaxNumber   lipgloss.Color
+	SyntaxComment  lipgloss.Color
+
+	// Diff colors
+	DiffAdded             lipgloss.Color
+	DiffRemoved           lipgloss.Color
+	DiffAddedBackground   lipgloss.Color // Behind the words that changed
+	DiffRemovedBackground lipgloss.Color
+
+	// BorderStyle is the shape of dialog and editor borders: rounded, normal,
+	// thick, double or hidden
+	BorderStyle string
 }
 
 // DarkTheme returns the default dark theme (Claude Code style)
@@ -41,6 +58,19 @@ func DarkTheme() Theme {
 		AIMessage:     lipgloss.Color("#A78BFA"), // Light purple
 		ToolMessage:   lipgloss.Color("#34D399"), // Emerald green
 		ThinkingColor: lipgloss.Color("#9CA3AF"), // Light gray
+
+		CodeBackground: lipgloss.Color("#1A1F2E"), // Slightly lighter than the background
+		SyntaxKeyword:  lipgloss.Color("#C084FC"), // Violet
+		SyntaxString:   lipgloss.Color("#86EFAC"), // Light green
+		SyntaxNumber:   lipgloss.Color("#FDBA74"), // Light orange
+		SyntaxComment:  lipgloss.Color("#6B7280"), // Medium gray
+
+		DiffAdded:             lipgloss.Color("#4ADE80"), // Green
+		DiffRemoved:           lipgloss.Color("#F87171"), // Light red
+		DiffAddedBackground:   lipgloss.Color("#14532D"), // Dark green
+		DiffRemovedBackground: lipgloss.Color("#7F1D1D"), // Dark red
+
+		BorderStyle: "rounded",
 	}
 }
 
@@ -62,6 +92,19 @@ func LightTheme() Theme {
 		AIMessage:     lipgloss.Color("#7C3AED"), // Purple
 		ToolMessage:   lipgloss.Color("#10B981"), // Green
 		ThinkingColor: lipgloss.Color("#9CA3AF"), // Light gray
+
+		CodeBackground: lipgloss.Color("#F3F4F6"), // Very light gray
+		SyntaxKeyword:  lipgloss.Color("#7C3AED"), // Purple
+		SyntaxString:   lipgloss.Color("#047857"), // Dark green
+		SyntaxNumber:   lipgloss.Color("#C2410C"), // Dark orange
+		SyntaxComment:  lipgloss.Color("#9CA3AF"), // Light gray
+
+		DiffAdded:             lipgloss.Color("#15803D"), // Dark green
+		DiffRemoved:           lipgloss.Color("#B91C1C"), // Dark red
+		DiffAddedBackground:   lipgloss.Color("#BBF7D0"), // Light green
+		DiffRemovedBackground: lipgloss.Color("#FECACA"), // Light red
+
+		BorderStyle: "rounded",
 	}
 }
 
@@ -103,7 +146,25 @@ type Styles struct {
 	ToolOutput     lipgloss.Style
 	Markdown       lipgloss.Style
 
+	// Markdown styles
+	Heading     lipgloss.Style
+	Code        lipgloss.Style // Text in code blocks
+	CodeKeyword lipgloss.Style
+	CodeString  lipgloss.Style
+	CodeNumber  lipgloss.Style
+	CodeComment lipgloss.Style
+
+	// Diff styles
+	DiffAdded       lipgloss.Style
+	DiffRemoved     lipgloss.Style
+	DiffAddedWord   lipgloss.Style // Words that changed within a line
+	DiffRemovedWord lipgloss.Style
+	DiffContext     lipgloss.Style
+	DiffLineNumber  lipgloss.Style
+	DiffFold        lipgloss.Style // Marks unchanged lines left out
+
 	// Border styles
+	Border       lipgloss.Border // Shape of dialog and editor borders
 	BorderNormal lipgloss.Style
 	BorderActive lipgloss.Style
 }
@@ -204,14 +265,14 @@ func NewStyles(theme Theme) *Styles {
 	// Component styles
 	s.CodeBlock = lipgloss.NewStyle().
 		Foreground(theme.Secondary).
-		Background(lipgloss.Color("#1A1F2E")).
+		Background(theme.CodeBackground).
 		Padding(1, 2).
 		MarginTop(1).
 		MarginBottom(1)
 
 	s.InlineCode = lipgloss.NewStyle().
 		Foreground(theme.Secondary).
-		Background(lipgloss.Color("#1A1F2E")).
+		Background(theme.CodeBackground).
 		Padding(0, 1)
 
 	s.Quote = lipgloss.NewStyle().
@@ -246,26 +307,100 @@ func NewStyles(theme Theme) *Styles {
 	s.Markdown = lipgloss.NewStyle().
 		Foreground(theme.Foreground)
 
+	// Markdown styles
+	s.Heading = lipgloss.NewStyle().
+		Foreground(theme.Primary).
+		Bold(true)
+
+	s.Code = lipgloss.NewStyle().
+		Foreground(theme.Foreground).
+		Background(theme.CodeBackground)
+
+	s.CodeKeyword = s.Code.Foreground(theme.SyntaxKeyword)
+	s.CodeString = s.Code.Foreground(theme.SyntaxString)
+	s.CodeNumber = s.Code.Foreground(theme.SyntaxNumber)
+	s.CodeComment = s.Code.Foreground(theme.SyntaxComment).Italic(true)
+
+	// Diff styles
+	s.DiffAdded = lipgloss.NewStyle().
+		Foreground(theme.DiffAdded)
+
+	s.DiffRemoved = lipgloss.NewStyle().
+		Foreground(theme.DiffRemoved)
+
+	s.DiffAddedWord = s.DiffAdded.Background(theme.DiffAddedBackground)
+	s.DiffRemovedWord = s.DiffRemoved.Background(theme.DiffRemovedBackground)
+
+	s.DiffContext = lipgloss.NewStyle().
+		Foreground(theme.Foreground)
+
+	s.DiffLineNumber = lipgloss.NewStyle().
+		Foreground(theme.Muted)
+
+	s.DiffFold = lipgloss.NewStyle().
+		Foreground(theme.Muted).
+		Italic(true)
+
 	// Border styles
+	s.Border = borderShape(theme.BorderStyle)
+
 	s.BorderNormal = lipgloss.NewStyle().
-		BorderStyle(lipgloss.RoundedBorder()).
+		BorderStyle(s.Border).
 		BorderForeground(theme.Border)
 
 	s.BorderActive = lipgloss.NewStyle().
-		BorderStyle(lipgloss.RoundedBorder()).
+		BorderStyle(s.Border).
 		BorderForeground(theme.Primary)
 
 	return s
 }
 
-// GetTheme returns a theme by name
+// borderShapes are the border shapes themes can use
+var borderShapes = map[string]lipgloss.Border{
+	"rounded": lipgloss.RoundedBorder(),
+	"normal":  lipgloss.NormalBorder(),
+	"thick":   lipgloss.ThickBorder(),
+	"double":  lipgloss.DoubleBorder(),
+	"hidden":  lipgloss.HiddenBorder(),
+}
+
+// borderShape returns the border with the given shape name, rounded if unknown
+func borderShape(name string) lipgloss.Border {
+	if border, ok := borderShapes[name]; ok {
+		return border
+	}
+	return lipgloss.RoundedBorder()
+}
+
+// ThemeNames returns the names of the built-in themes followed by those of
+// the loaded theme files
+func ThemeNames() []string {
+	return append([]string{"dark", "light"}, userThemeNames()...)
+}
+
+// GetTheme returns a theme by name: dark, light, a theme loaded from a theme
+// file, or auto for dark or light, whichever suits the terminal background.
+// Unknown names get the dark theme.
 func GetTheme(name string) Theme {
 	switch name {
 	case "light":
 		return LightTheme()
 	case "dark":
 		return DarkTheme()
-	default:
+	case "auto":
+		return autoTheme()
+	}
+	if theme, err := resolveTheme(name); err == nil {
+		return theme
+	}
+	return DarkTheme()
+}
+
+// autoTheme asks the terminal for its background color and returns the
+// matching built-in theme. Terminals that don't answer get the dark theme.
+func autoTheme() Theme {
+	if lipgloss.HasDarkBackground() {
 		return DarkTheme()
 	}
+	return LightTheme()
 }
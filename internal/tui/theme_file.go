@@ -0,0 +1,338 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+)
+
+// Theme files live in ~/.cc-mono/themes and are YAML or JSON:
+//
+//	name: solarized
+//	base: light
+//	colors:
+//	  primary: "#268BD2"
+//	  muted: "245"
+//	syntax:
+//	  keyword: "#859900"
+//	diff:
+//	  added_background: "#EEE8D5"
+//	border:
+//	  style: double
+//
+// A theme starts from its base theme (dark unless set), which is dark, light
+// or another theme file, and overrides the colors it lists. Colors are
+// hex (#RGB or #RRGGBB) or ANSI 256 color numbers; hex colors must be quoted
+// in YAML.
+
+// themeColors maps the color keys of theme files to the colors of Theme
+var themeColors = map[string]func(t *Theme) *lipgloss.Color{
+	"colors.primary":          func(t *Theme) *lipgloss.Color { return &t.Primary },
+	"colors.secondary":        func(t *Theme) *lipgloss.Color { return &t.Secondary },
+	"colors.accent":           func(t *Theme) *lipgloss.Color { return &t.Accent },
+	"colors.background":       func(t *Theme) *lipgloss.Color { return &t.Background },
+	"colors.foreground":       func(t *Theme) *lipgloss.Color { return &t.Foreground },
+	"colors.muted":            func(t *Theme) *lipgloss.Color { return &t.Muted },
+	"colors.success":          func(t *Theme) *lipgloss.Color { return &t.Success },
+	"colors.warning":          func(t *Theme) *lipgloss.Color { return &t.Warning },
+	"colors.error":            func(t *Theme) *lipgloss.Color { return &t.Error },
+	"colors.user_message":     func(t *Theme) *lipgloss.Color { return &t.UserMessage },
+	"colors.ai_message":       func(t *Theme) *lipgloss.Color { return &t.AIMessage },
+	"colors.tool_message":     func(t *Theme) *lipgloss.Color { return &t.ToolMessage },
+	"colors.thinking":         func(t *Theme) *lipgloss.Color { return &t.ThinkingColor },
+	"syntax.background":       func(t *Theme) *lipgloss.Color { return &t.CodeBackground },
+	"syntax.keyword":          func(t *Theme) *lipgloss.Color { return &t.SyntaxKeyword },
+	"syntax.string":           func(t *Theme) *lipgloss.Color { return &t.SyntaxString },
+	"syntax.number":           func(t *Theme) *lipgloss.Color { return &t.SyntaxNumber },
+	"syntax.comment":          func(t *Theme) *lipgloss.Color { return &t.SyntaxComment },
+	"diff.added":              func(t *Theme) *lipgloss.Color { return &t.DiffAdded },
+	"diff.removed":            func(t *Theme) *lipgloss.Color { return &t.DiffRemoved },
+	"diff.added_background":   func(t *Theme) *lipgloss.Color { return &t.DiffAddedBackground },
+	"diff.removed_background": func(t *Theme) *lipgloss.Color { return &t.DiffRemovedBackground },
+	"border.color":            func(t *Theme) *lipgloss.Color { return &t.Border },
+}
+
+// ThemeKeys returns every key a theme file can set
+func ThemeKeys() []string {
+	keys := []string{"name", "base", "border.style"}
+	for key := range themeColors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ThemeFile is a theme loaded from a theme file
+type ThemeFile struct {
+	Path   string
+	Name   string            // The file name without extension unless set
+	Base   string            // Theme the file overrides, dark unless set
+	Values map[string]string // Key -> value of the colors and border style set
+}
+
+// ThemeIssue is a problem found in a theme file
+type ThemeIssue struct {
+	Path    string
+	Key     string // Empty for problems with the whole file
+	Message string
+}
+
+// String formats the issue as path: key: message
+func (i ThemeIssue) String() string {
+	if i.Key == "" {
+		return fmt.Sprintf("%s: %s", i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Key, i.Message)
+}
+
+// hexColor matches #RGB and #RRGGBB colors
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validColor reports whether value is a hex or ANSI 256 color
+func validColor(value string) bool {
+	if hexColor.MatchString(value) {
+		return true
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// builtinTheme reports whether name is taken by a built-in theme
+func builtinTheme(name string) bool {
+	return name == "dark" || name == "light" || name == "auto"
+}
+
+// isThemeFile reports whether path has a theme file extension
+func isThemeFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// LoadThemeFile loads a theme file. Unknown keys and invalid values are
+// reported as issues and left out of the theme; the error is for files that
+// can't be read or parsed.
+func LoadThemeFile(path string) (*ThemeFile, []ThemeIssue, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	config := codingagent.NewConfig(codingagent.NewLogger(codingagent.LoggerConfig{Level: codingagent.LogLevelError, Output: io.Discard}))
+	if err := config.LoadFile(path); err != nil {
+		return nil, nil, err
+	}
+
+	file := &ThemeFile{
+		Path:   path,
+		Name:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Values: make(map[string]string),
+	}
+	var issues []ThemeIssue
+	issue := func(key, format string, args ...any) {
+		issues = append(issues, ThemeIssue{Path: path, Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range config.Keys() {
+		value := strings.TrimSpace(config.GetString(key))
+		switch {
+		case key == "name":
+			if value != "" {
+				file.Name = value
+			}
+		case key == "base":
+			file.Base = value
+		case key == "border.style":
+			if _, ok := borderShapes[value]; !ok {
+				issue(key, "unknown border style %q (rounded, normal, thick, double or hidden)", value)
+				continue
+			}
+			file.Values[key] = value
+		case themeColors[key] != nil:
+			if value == "" {
+				issue(key, "missing color (hex colors must be quoted in YAML)")
+				continue
+			}
+			if !validColor(value) {
+				issue(key, "invalid color %q (use #RRGGBB, #RGB or 0-255)", value)
+				continue
+			}
+			file.Values[key] = value
+		default:
+			issue(key, "unknown key")
+		}
+	}
+
+	if builtinTheme(file.Name) {
+		issue("name", "%q is a built-in theme", file.Name)
+	}
+	return file, issues, nil
+}
+
+// apply sets the colors and border style of the file on theme
+func (f *ThemeFile) apply(theme *Theme) {
+	theme.Name = f.Name
+	for key, value := range f.Values {
+		if color := themeColors[key]; color != nil {
+			*color(theme) = lipgloss.Color(value)
+		}
+	}
+	if style, ok := f.Values["border.style"]; ok {
+		theme.BorderStyle = style
+	}
+}
+
+// userThemes holds the theme files loaded by LoadThemes, by name. The TUI
+// reads them while the watcher reloads them.
+var userThemes = struct {
+	sync.RWMutex
+	files map[string]*ThemeFile
+}{files: make(map[string]*ThemeFile)}
+
+// LoadThemes loads the theme files in dir, replacing those loaded before, and
+// returns the problems found in them. Files that fail to parse, or take the
+// name of a built-in or an earlier theme, are skipped. A missing dir loads no
+// themes.
+func LoadThemes(dir string) ([]ThemeIssue, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read themes directory: %w", err)
+	}
+
+	files := make(map[string]*ThemeFile)
+	var issues []ThemeIssue
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !isThemeFile(path) {
+			continue
+		}
+		file, fileIssues, err := LoadThemeFile(path)
+		if err != nil {
+			issues = append(issues, ThemeIssue{Path: path, Message: err.Error()})
+			continue
+		}
+		issues = append(issues, fileIssues...)
+		if builtinTheme(file.Name) {
+			continue
+		}
+		if other, ok := files[file.Name]; ok {
+			issues = append(issues, ThemeIssue{Path: path, Key: "name", Message: fmt.Sprintf("theme %q is already defined in %s", file.Name, other.Path)})
+			continue
+		}
+		files[file.Name] = file
+	}
+
+	for _, name := range sortedThemeNames(files) {
+		if _, err := resolveThemeIn(files, name, nil); err != nil {
+			issues = append(issues, ThemeIssue{Path: files[name].Path, Key: "base", Message: err.Error()})
+		}
+	}
+
+	userThemes.Lock()
+	userThemes.files = files
+	userThemes.Unlock()
+	return issues, nil
+}
+
+// ValidateThemeFile checks a theme file, resolving its base among the
+// built-in themes and those loaded by LoadThemes
+func ValidateThemeFile(path string) ([]ThemeIssue, error) {
+	file, issues, err := LoadThemeFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	userThemes.RLock()
+	files := make(map[string]*ThemeFile, len(userThemes.files)+1)
+	for name, loaded := range userThemes.files {
+		files[name] = loaded
+	}
+	userThemes.RUnlock()
+	files[file.Name] = file
+
+	if _, err := resolveThemeIn(files, file.Name, nil); err != nil && !builtinTheme(file.Name) {
+		issues = append(issues, ThemeIssue{Path: path, Key: "base", Message: err.Error()})
+	}
+	return issues, nil
+}
+
+// WatchThemes reloads the themes in dir when a theme file in it is written,
+// created, renamed or removed and then calls onChange. The returned function
+// stops watching.
+func WatchThemes(dir string, onChange func(issues []ThemeIssue)) (func(), error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return func() {}, nil
+	}
+
+	return codingagent.WatchDirs([]string{dir}, isThemeFile, func(string) {
+		issues, err := LoadThemes(dir)
+		if err != nil {
+			issues = append(issues, ThemeIssue{Path: dir, Message: err.Error()})
+		}
+		onChange(issues)
+	}, nil)
+}
+
+// userThemeNames returns the names of the loaded theme files, sorted
+func userThemeNames() []string {
+	userThemes.RLock()
+	defer userThemes.RUnlock()
+	return sortedThemeNames(userThemes.files)
+}
+
+// sortedThemeNames returns the names of files, sorted
+func sortedThemeNames(files map[string]*ThemeFile) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveTheme builds the loaded theme with the given name
+func resolveTheme(name string) (Theme, error) {
+	userThemes.RLock()
+	defer userThemes.RUnlock()
+	return resolveThemeIn(userThemes.files, name, nil)
+}
+
+// resolveThemeIn builds the theme with the given name from its chain of base
+// themes. seen holds the themes that inherit from it, to catch cycles.
+func resolveThemeIn(files map[string]*ThemeFile, name string, seen []string) (Theme, error) {
+	switch name {
+	case "dark":
+		return DarkTheme(), nil
+	case "light":
+		return LightTheme(), nil
+	}
+
+	file, ok := files[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	if slices.Contains(seen, name) {
+		return Theme{}, fmt.Errorf("themes inherit from each other: %s", strings.Join(append(seen, name), " -> "))
+	}
+
+	base := file.Base
+	if base == "" {
+		base = "dark"
+	}
+	theme, err := resolveThemeIn(files, base, append(seen, name))
+	if err != nil {
+		return Theme{}, err
+	}
+	file.apply(&theme)
+	return theme, nil
+}
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTheme(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadThemes(t *testing.T) {
+	t.Cleanup(func() { LoadThemes(t.TempDir()) })
+
+	dir := t.TempDir()
+	writeTheme(t, dir, "paper.yaml", `
+base: light
+colors:
+  primary: "#112233"
+  muted: "245"
+syntax:
+  keyword: "#abc"
+diff:
+  added_background: "#00FF00"
+border:
+  style: double
+  color: "#445566"
+`)
+	writeTheme(t, dir, "ink.json", `{"name": "ink-dark", "base": "paper", "colors": {"primary": "#000000"}}`)
+	writeTheme(t, dir, "notes.txt", "ignored")
+
+	issues, err := LoadThemes(dir)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+	assert.Equal(t, []string{"dark", "light", "ink-dark", "paper"}, ThemeNames())
+
+	paper := GetTheme("paper")
+	assert.Equal(t, "paper", paper.Name)
+	assert.Equal(t, lipgloss.Color("#112233"), paper.Primary)
+	assert.Equal(t, lipgloss.Color("245"), paper.Muted)
+	assert.Equal(t, lipgloss.Color("#abc"), paper.SyntaxKeyword)
+	assert.Equal(t, lipgloss.Color("#00FF00"), paper.DiffAddedBackground)
+	assert.Equal(t, lipgloss.Color("#445566"), paper.Border)
+	assert.Equal(t, "double", paper.BorderStyle)
+	assert.Equal(t, LightTheme().Foreground, paper.Foreground)
+	assert.Equal(t, lipgloss.DoubleBorder(), NewStyles(paper).Border)
+
+	// Inherits from paper, which inherits from light
+	ink := GetTheme("ink-dark")
+	assert.Equal(t, "ink-dark", ink.Name)
+	assert.Equal(t, lipgloss.Color("#000000"), ink.Primary)
+	assert.Equal(t, lipgloss.Color("245"), ink.Muted)
+	assert.Equal(t, LightTheme().Error, ink.Error)
+
+	assert.Equal(t, DarkTheme(), GetTheme("missing"))
+}
+
+func TestLoadThemesIssues(t *testing.T) {
+	t.Cleanup(func() { LoadThemes(t.TempDir()) })
+
+	dir := t.TempDir()
+	bad := writeTheme(t, dir, "bad.yaml", `
+colors:
+  primary: "purple"
+  backgroud: "#000000"
+  muted:
+border:
+  style: dotted
+spinner: dots
+`)
+	loopA := writeTheme(t, dir, "a.yaml", "base: b\n")
+	writeTheme(t, dir, "b.yaml", "base: a\n")
+	orphan := writeTheme(t, dir, "orphan.yaml", "base: nowhere\n")
+	light := writeTheme(t, dir, "mine.yaml", "name: light\n")
+	broken := writeTheme(t, dir, "broken.json", "{")
+
+	issues, err := LoadThemes(dir)
+	require.NoError(t, err)
+
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	assert.ElementsMatch(t, []string{
+		bad + `: border.style: unknown border style "dotted" (rounded, normal, thick, double or hidden)`,
+		bad + `: colors.backgroud: unknown key`,
+		bad + `: colors.muted: missing color (hex colors must be quoted in YAML)`,
+		bad + `: colors.primary: invalid color "purple" (use #RRGGBB, #RGB or 0-255)`,
+		bad + `: spinner: unknown key`,
+		loopA + `: base: themes inherit from each other: a -> b -> a`,
+		filepath.Join(dir, "b.yaml") + `: base: themes inherit from each other: b -> a -> b`,
+		orphan + `: base: unknown theme "nowhere"`,
+		light + `: name: "light" is a built-in theme`,
+		broken + `: failed to load config file: unexpected end of JSON input`,
+	}, got)
+
+	// Valid values of a file with issues still apply
+	assert.Equal(t, DarkTheme().Primary, GetTheme("bad").Primary)
+	assert.Equal(t, "bad", GetTheme("bad").Name)
+	assert.Equal(t, "light", GetTheme("light").Name)
+}
+
+func TestValidateThemeFile(t *testing.T) {
+	t.Cleanup(func() { LoadThemes(t.TempDir()) })
+
+	dir := t.TempDir()
+	writeTheme(t, dir, "base.yaml", "colors:\n  primary: \"#123456\"\n")
+	_, err := LoadThemes(dir)
+	require.NoError(t, err)
+
+	other := t.TempDir()
+	good := writeTheme(t, other, "good.yaml", "base: base\nsyntax:\n  comment: \"8\"\n")
+	issues, err := ValidateThemeFile(good)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	orphan := writeTheme(t, other, "orphan.yaml", "base: nope\nextra: 1\n")
+	issues, err = ValidateThemeFile(orphan)
+	require.NoError(t, err)
+	assert.Equal(t, []ThemeIssue{
+		{Path: orphan, Key: "extra", Message: "unknown key"},
+		{Path: orphan, Key: "base", Message: `unknown theme "nope"`},
+	}, issues)
+
+	_, err = ValidateThemeFile(filepath.Join(other, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestWatchThemes(t *testing.T) {
+	t.Cleanup(func() { LoadThemes(t.TempDir()) })
+
+	dir := t.TempDir()
+	writeTheme(t, dir, "live.yaml", "colors:\n  primary: \"#111111\"\n")
+	_, err := LoadThemes(dir)
+	require.NoError(t, err)
+
+	changed := make(chan []ThemeIssue, 10)
+	stop, err := WatchThemes(dir, func(issues []ThemeIssue) { changed <- issues })
+	require.NoError(t, err)
+	defer stop()
+
+	writeTheme(t, dir, "live.yaml", "colors:\n  primary: \"#222222\"\n")
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("theme change not noticed")
+	}
+	// The file may be reloaded between its truncation and write
+	assert.Eventually(t, func() bool {
+		return GetTheme("live").Primary == lipgloss.Color("#222222")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Themes added after startup are picked up
+	writeTheme(t, dir, "added.yaml", "colors:\n  primary: \"#333333\"\n")
+	assert.Eventually(t, func() bool {
+		return GetTheme("added").Primary == lipgloss.Color("#333333")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// So are saves that rename a new copy over the file
+	writeTheme(t, dir, "live.tmp", "colors:\n  primary: \"#444444\"\n")
+	require.NoError(t, os.Rename(filepath.Join(dir, "live.tmp"), filepath.Join(dir, "live.yaml")))
+	assert.Eventually(t, func() bool {
+		return GetTheme("live").Primary == lipgloss.Color("#444444")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,1123 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// VimMode is the mode of the editor's vim keybindings
+type VimMode string
+
+const (
+	VimInsert     VimMode = "insert"
+	VimNormal     VimMode = "normal"
+	VimVisual     VimMode = "visual"
+	VimVisualLine VimMode = "visual line"
+)
+
+// vimRegister is the content of a register. Linewise text holds whole lines
+// without the last newline.
+type vimRegister struct {
+	text     string
+	linewise bool
+}
+
+// vimSnapshot is a state of the draft to undo to
+type vimSnapshot struct {
+	text string
+	pos  int
+}
+
+// vimBuffer is the draft as vim commands see it: its text and the offset of
+// the cursor in it
+type vimBuffer struct {
+	text []rune
+	pos  int
+}
+
+// maxVimUndo bounds the undo history
+const maxVimUndo = 100
+
+// vimState holds the vim keybindings of the editor: the mode, the keys of an
+// incomplete command, the registers and the undo history
+type vimState struct {
+	mode      VimMode
+	pending   []string
+	anchor    int // Where the visual selection started
+	registers map[rune]vimRegister
+	undo      []vimSnapshot
+}
+
+// newVimState returns vim keybindings starting in insert mode
+func newVimState() *vimState {
+	return &vimState{mode: VimInsert, registers: make(map[rune]vimRegister)}
+}
+
+// reset starts over in insert mode for a new draft, keeping the registers
+func (v *vimState) reset() {
+	v.mode = VimInsert
+	v.pending = nil
+	v.undo = nil
+}
+
+// vimKeyName names a key the way vim commands spell it; arrows and the like
+// become their motions. It returns "" for keys vim mode ignores.
+func vimKeyName(msg tea.KeyMsg) string {
+	switch msg.Type {
+	case tea.KeyRunes:
+		if len(msg.Runes) == 1 && !msg.Paste {
+			return string(msg.Runes)
+		}
+	case tea.KeySpace:
+		return " "
+	case tea.KeyEsc:
+		return "esc"
+	case tea.KeyLeft, tea.KeyBackspace:
+		return "h"
+	case tea.KeyRight:
+		return "l"
+	case tea.KeyUp:
+		return "k"
+	case tea.KeyDown:
+		return "j"
+	case tea.KeyHome:
+		return "0"
+	case tea.KeyEnd:
+		return "$"
+	}
+	return ""
+}
+
+// vimCommand is a parsed normal or visual mode command, e.g. "a2d3w to
+// delete six words into register a
+type vimCommand struct {
+	register rune   // 0 for the unnamed register
+	count    int    // Count before and after the operator multiplied, 0 if none
+	op       string // d, c or y; empty for motions and other commands
+	motion   string // Motion, text object or command
+}
+
+// Results of parsing the keys typed so far
+const (
+	vimIncomplete = iota
+	vimComplete
+	vimInvalid
+)
+
+// vimMotions are the motions taking no argument
+var vimMotions = map[string]bool{
+	"h": true, "j": true, "k": true, "l": true, " ": true,
+	"w": true, "b": true, "e": true, "W": true, "B": true, "E": true,
+	"0": true, "^": true, "$": true, "G": true, "gg": true,
+}
+
+// vimNormalCommands are the normal mode commands that are not motions
+var vimNormalCommands = map[string]bool{
+	"x": true, "X": true, "p": true, "P": true, "u": true, "i": true, "a": true,
+	"I": true, "A": true, "o": true, "O": true, "~": true, "J": true, "D": true,
+	"C": true, "Y": true, "s": true, "S": true, "v": true, "V": true,
+}
+
+// vimVisualCommands are the visual mode commands that act on the selection
+var vimVisualCommands = map[string]bool{
+	"d": true, "x": true, "y": true, "c": true, "s": true, "o": true, "~": true,
+	"J": true, "v": true, "V": true, "D": true, "X": true, "Y": true,
+}
+
+// parseVimCommand parses keys as ["x][count][operator[count]](motion|text object|command)
+func parseVimCommand(keys []string, visual bool) (vimCommand, int) {
+	var cmd vimCommand
+	i := 0
+	if i < len(keys) && keys[i] == `"` {
+		if i+1 >= len(keys) {
+			return cmd, vimIncomplete
+		}
+		reg := []rune(keys[i+1])[0]
+		if !isVimRegister(reg) {
+			return cmd, vimInvalid
+		}
+		cmd.register = reg
+		i += 2
+	}
+
+	count, i := parseVimCount(keys, i)
+	if i >= len(keys) {
+		return cmd, vimIncomplete
+	}
+	cmd.count = count
+
+	key := keys[i]
+	switch {
+	case visual && vimVisualCommands[key]:
+		cmd.motion = key
+		return cmd, vimComplete
+	case !visual && (key == "d" || key == "c" || key == "y"):
+		cmd.op = key
+		i++
+		count, i = parseVimCount(keys, i)
+		if count > 0 {
+			cmd.count = max(cmd.count, 1) * count
+		}
+		if i >= len(keys) {
+			return cmd, vimIncomplete
+		}
+		if keys[i] == cmd.op {
+			cmd.motion = "_" // dd, cc and yy act on lines
+			return cmd, exactVimKeys(keys, i+1)
+		}
+	case !visual && vimNormalCommands[key]:
+		cmd.motion = key
+		return cmd, exactVimKeys(keys, i+1)
+	case !visual && key == "r":
+		if i+1 >= len(keys) {
+			return cmd, vimIncomplete
+		}
+		cmd.motion = "r" + keys[i+1]
+		return cmd, exactVimKeys(keys, i+2)
+	}
+
+	motion, status := parseVimMotion(keys[i:], cmd.op != "" || visual)
+	cmd.motion = motion
+	return cmd, status
+}
+
+// parseVimMotion parses a motion, or a text object when objects is set
+func parseVimMotion(keys []string, objects bool) (string, int) {
+	key := keys[0]
+	switch {
+	case vimMotions[key]:
+		return key, exactVimKeys(keys, 1)
+	case key == "g":
+		if len(keys) < 2 {
+			return "", vimIncomplete
+		}
+		if keys[1] != "g" {
+			return "", vimInvalid
+		}
+		return "gg", exactVimKeys(keys, 2)
+	case key == "f" || key == "F" || key == "t" || key == "T":
+		if len(keys) < 2 {
+			return "", vimIncomplete
+		}
+		return key + keys[1], exactVimKeys(keys, 2)
+	case objects && (key == "i" || key == "a"):
+		if len(keys) < 2 {
+			return "", vimIncomplete
+		}
+		if _, ok := vimTextObjects[keys[1]]; !ok {
+			return "", vimInvalid
+		}
+		return key + keys[1], exactVimKeys(keys, 2)
+	}
+	return "", vimInvalid
+}
+
+// exactVimKeys reports a command complete if it used all n keys
+func exactVimKeys(keys []string, n int) int {
+	if len(keys) == n {
+		return vimComplete
+	}
+	return vimInvalid
+}
+
+// parseVimCount parses a count starting at keys[i]
+func parseVimCount(keys []string, i int) (int, int) {
+	count := 0
+	for i < len(keys) && len(keys[i]) == 1 && keys[i][0] >= '0' && keys[i][0] <= '9' {
+		if keys[i] == "0" && count == 0 {
+			break // 0 is a motion
+		}
+		count = min(count*10+int(keys[i][0]-'0'), 10000)
+		i++
+	}
+	return count, i
+}
+
+// isVimRegister reports whether r names a register: the unnamed one, a-z
+// (A-Z to append), 0 for the last yank or _ to discard
+func isVimRegister(r rune) bool {
+	return r == '"' || r == '0' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// feed handles a key in normal or visual mode
+func (v *vimState) feed(buf *vimBuffer, key string) {
+	if key == "esc" {
+		if len(v.pending) == 0 && v.mode != VimNormal {
+			v.mode = VimNormal
+		}
+		v.pending = nil
+		buf.pos = clampVimCursor(buf.text, buf.pos)
+		return
+	}
+
+	v.pending = append(v.pending, key)
+	visual := v.mode == VimVisual || v.mode == VimVisualLine
+	cmd, status := parseVimCommand(v.pending, visual)
+	switch status {
+	case vimIncomplete:
+		return
+	case vimInvalid:
+		v.pending = nil
+		return
+	}
+	v.pending = nil
+
+	if visual {
+		v.runVisual(buf, cmd)
+	} else {
+		v.runNormal(buf, cmd)
+	}
+	if v.mode == VimNormal {
+		buf.pos = clampVimCursor(buf.text, buf.pos)
+	}
+}
+
+// runNormal runs a normal mode command
+func (v *vimState) runNormal(buf *vimBuffer, cmd vimCommand) {
+	count := max(cmd.count, 1)
+	text := buf.text
+	pos := buf.pos
+
+	if cmd.op != "" {
+		start, end, linewise, ok := v.operatorRange(buf, cmd)
+		if ok {
+			v.operate(buf, cmd.op, cmd.register, start, end, linewise)
+		}
+		return
+	}
+
+	switch cmd.motion {
+	case "i":
+		v.insert(buf, pos)
+	case "a":
+		if pos < vimLineEnd(text, pos) {
+			pos++
+		}
+		v.insert(buf, pos)
+	case "I":
+		v.insert(buf, vimFirstNonBlank(text, pos))
+	case "A":
+		v.insert(buf, vimLineEnd(text, pos))
+	case "o":
+		v.save(buf)
+		end := vimLineEnd(text, pos)
+		buf.text = vimSplice(text, end, end, []rune("\n"))
+		buf.pos = end + 1
+		v.mode = VimInsert
+	case "O":
+		v.save(buf)
+		start := vimLineStart(text, pos)
+		buf.text = vimSplice(text, start, start, []rune("\n"))
+		buf.pos = start
+		v.mode = VimInsert
+	case "x", "s":
+		end := min(pos+count, vimLineEnd(text, pos))
+		op := "d"
+		if cmd.motion == "s" {
+			op = "c"
+		}
+		if end > pos || op == "c" {
+			v.operate(buf, op, cmd.register, pos, end, false)
+		}
+	case "X":
+		if start := max(pos-count, vimLineStart(text, pos)); start < pos {
+			v.operate(buf, "d", cmd.register, start, pos, false)
+		}
+	case "D", "C":
+		end := vimLineEnd(text, pos)
+		if count > 1 {
+			end = vimLineEnd(text, vimLineOffset(text, vimLineIndex(text, pos)+count-1))
+		}
+		v.operate(buf, strings.ToLower(cmd.motion), cmd.register, pos, end, false)
+	case "Y":
+		start, end := vimLines(text, pos, vimLineOffset(text, vimLineIndex(text, pos)+count-1))
+		v.operate(buf, "y", cmd.register, start, end, true)
+	case "S":
+		start, end := vimLines(text, pos, vimLineOffset(text, vimLineIndex(text, pos)+count-1))
+		v.operate(buf, "c", cmd.register, start, end, true)
+	case "p", "P":
+		v.put(buf, cmd.register, cmd.motion == "P", count)
+	case "u":
+		for i := 0; i < count && len(v.undo) > 0; i++ {
+			snapshot := v.undo[len(v.undo)-1]
+			v.undo = v.undo[:len(v.undo)-1]
+			buf.text = []rune(snapshot.text)
+			buf.pos = snapshot.pos
+		}
+	case "~":
+		end := min(pos+count, vimLineEnd(text, pos))
+		if end > pos {
+			v.save(buf)
+			buf.text = vimSplice(text, pos, end, toggleCase(text[pos:end]))
+			buf.pos = end
+		}
+	case "J":
+		v.join(buf, pos, max(count, 2)-1)
+	case "v", "V":
+		v.mode = VimVisual
+		if cmd.motion == "V" {
+			v.mode = VimVisualLine
+		}
+		v.anchor = pos
+	default:
+		if strings.HasPrefix(cmd.motion, "r") {
+			v.replace(buf, []rune(cmd.motion)[1], count)
+			return
+		}
+		if target, _, _, ok := vimMotion(text, pos, cmd.motion, cmd.count); ok {
+			buf.pos = target
+		}
+	}
+}
+
+// runVisual runs a visual mode command: a motion extends the selection, a
+// text object selects itself, and the rest act on the selection
+func (v *vimState) runVisual(buf *vimBuffer, cmd vimCommand) {
+	if len(cmd.motion) == 2 && (cmd.motion[0] == 'i' || cmd.motion[0] == 'a') {
+		if start, end, ok := vimTextObject(buf.text, buf.pos, cmd.motion); ok && end > start {
+			v.anchor = start
+			buf.pos = end - 1
+		}
+		return
+	}
+
+	start, end := min(v.anchor, buf.pos), max(v.anchor, buf.pos)+1
+	linewise := v.mode == VimVisualLine
+	if linewise {
+		start, end = vimLines(buf.text, start, end-1)
+	}
+	end = min(end, len(buf.text))
+
+	switch cmd.motion {
+	case "o":
+		v.anchor, buf.pos = buf.pos, v.anchor
+	case "v", "V":
+		mode := VimVisual
+		if cmd.motion == "V" {
+			mode = VimVisualLine
+		}
+		if v.mode == mode {
+			v.mode = VimNormal
+		} else {
+			v.mode = mode
+		}
+	case "d", "x", "D", "X":
+		v.mode = VimNormal
+		v.operate(buf, "d", cmd.register, start, end, linewise || cmd.motion == "D" || cmd.motion == "X")
+	case "y", "Y":
+		v.mode = VimNormal
+		v.operate(buf, "y", cmd.register, start, end, linewise || cmd.motion == "Y")
+		buf.pos = start
+	case "c", "s":
+		v.mode = VimNormal
+		v.operate(buf, "c", cmd.register, start, end, linewise)
+	case "~":
+		v.mode = VimNormal
+		v.save(buf)
+		buf.text = vimSplice(buf.text, start, end, toggleCase(buf.text[start:end]))
+		buf.pos = start
+	case "J":
+		v.mode = VimNormal
+		lines := max(vimLineIndex(buf.text, max(end-1, start))-vimLineIndex(buf.text, start), 1)
+		v.join(buf, start, lines)
+	default:
+		if target, _, _, ok := vimMotion(buf.text, buf.pos, cmd.motion, cmd.count); ok {
+			buf.pos = min(target, max(len(buf.text)-1, 0))
+		}
+	}
+}
+
+// operatorRange returns the text an operator acts on
+func (v *vimState) operatorRange(buf *vimBuffer, cmd vimCommand) (int, int, bool, bool) {
+	text, pos := buf.text, buf.pos
+	count := max(cmd.count, 1)
+
+	switch {
+	case cmd.motion == "_":
+		start, end := vimLines(text, pos, vimLineOffset(text, vimLineIndex(text, pos)+count-1))
+		return start, end, true, true
+	case len(cmd.motion) == 2 && (cmd.motion[0] == 'i' || cmd.motion[0] == 'a'):
+		start, end, ok := vimTextObject(text, pos, cmd.motion)
+		return start, end, false, ok
+	}
+
+	motion := cmd.motion
+	// cw changes to the end of the word, like ce
+	if cmd.op == "c" && pos < len(text) && !unicode.IsSpace(text[pos]) {
+		switch motion {
+		case "w":
+			motion = "e"
+		case "W":
+			motion = "E"
+		}
+	}
+
+	target, linewise, inclusive, ok := vimMotion(text, pos, motion, cmd.count)
+	if !ok {
+		return 0, 0, false, false
+	}
+	if linewise {
+		start, end := vimLines(text, pos, target)
+		return start, end, true, true
+	}
+
+	start, end := min(pos, target), max(pos, target)
+	if inclusive {
+		end = min(end+1, len(text))
+	}
+	// dw at the last word of a line stops at the end of the line
+	if (motion == "w" || motion == "W") && end > start {
+		if newline := vimLineEnd(text, start); newline < end && newline > start {
+			end = newline
+		}
+	}
+	return start, end, false, true
+}
+
+// operate deletes, changes or yanks text[start:end]
+func (v *vimState) operate(buf *vimBuffer, op string, register rune, start, end int, linewise bool) {
+	text := buf.text
+	reg := vimRegister{text: string(text[start:end]), linewise: linewise}
+	if linewise {
+		reg.text = strings.TrimSuffix(reg.text, "\n")
+	}
+	v.store(register, reg, op == "y")
+
+	switch op {
+	case "y":
+		if !linewise {
+			buf.pos = start
+		}
+	case "d":
+		v.save(buf)
+		if linewise && end == len(text) && start > 0 {
+			start-- // Deleting the last lines takes the newline before them
+		}
+		buf.text = vimSplice(text, start, end, nil)
+		buf.pos = start
+		if linewise {
+			buf.pos = vimFirstNonBlank(buf.text, start)
+		}
+	case "c":
+		v.save(buf)
+		if linewise && end > start && text[end-1] == '\n' {
+			end-- // Keep an empty line to type in
+		}
+		buf.text = vimSplice(text, start, end, nil)
+		v.mode = VimInsert
+		buf.pos = start
+	}
+}
+
+// put pastes a register after the cursor, or before it
+func (v *vimState) put(buf *vimBuffer, register rune, before bool, count int) {
+	reg, ok := v.register(register)
+	if !ok || reg.text == "" {
+		return
+	}
+	v.save(buf)
+	text, pos := buf.text, buf.pos
+
+	if reg.linewise {
+		content := []rune(strings.TrimSuffix(strings.Repeat(reg.text+"\n", count), "\n"))
+		if before {
+			at := vimLineStart(text, pos)
+			buf.text = vimSplice(text, at, at, append(content, '\n'))
+			buf.pos = vimFirstNonBlank(buf.text, at)
+		} else {
+			at := vimLineEnd(text, pos)
+			buf.text = vimSplice(text, at, at, append([]rune("\n"), content...))
+			buf.pos = vimFirstNonBlank(buf.text, at+1)
+		}
+		return
+	}
+
+	content := []rune(strings.Repeat(reg.text, count))
+	at := pos
+	if !before && pos < vimLineEnd(text, pos) {
+		at++
+	}
+	buf.text = vimSplice(text, at, at, content)
+	buf.pos = at + len(content) - 1
+}
+
+// replace replaces count characters under the cursor with r
+func (v *vimState) replace(buf *vimBuffer, r rune, count int) {
+	text, pos := buf.text, buf.pos
+	if pos+count > vimLineEnd(text, pos) {
+		return
+	}
+	v.save(buf)
+	buf.text = vimSplice(text, pos, pos+count, []rune(strings.Repeat(string(r), count)))
+	buf.pos = pos + count - 1
+}
+
+// join joins the line at pos with the lines below it, separated by a space
+func (v *vimState) join(buf *vimBuffer, pos, lines int) {
+	saved := false
+	for i := 0; i < lines; i++ {
+		text := buf.text
+		newline := vimLineEnd(text, pos)
+		if newline >= len(text) {
+			return
+		}
+		if !saved {
+			v.save(buf)
+			saved = true
+		}
+		next := newline + 1
+		for next < len(text) && (text[next] == ' ' || text[next] == '\t') {
+			next++
+		}
+		sep := []rune(" ")
+		if next >= len(text) || text[next] == '\n' || text[next] == ')' || newline == vimLineStart(text, newline) {
+			sep = nil
+		}
+		buf.text = vimSplice(text, newline, next, sep)
+		buf.pos = newline
+	}
+}
+
+// insert switches to insert mode with the cursor at pos
+func (v *vimState) insert(buf *vimBuffer, pos int) {
+	buf.pos = pos
+	v.save(buf)
+	v.mode = VimInsert
+}
+
+// save remembers the draft for undo
+func (v *vimState) save(buf *vimBuffer) {
+	snapshot := vimSnapshot{text: string(buf.text), pos: buf.pos}
+	if n := len(v.undo); n > 0 && v.undo[n-1] == snapshot {
+		return
+	}
+	v.undo = append(v.undo, snapshot)
+	if len(v.undo) > maxVimUndo {
+		v.undo = v.undo[1:]
+	}
+}
+
+// store saves deleted or yanked text in the unnamed register and the one
+// the command named. Upper case registers append to their lower case one.
+func (v *vimState) store(register rune, reg vimRegister, yank bool) {
+	switch {
+	case register == '_':
+		return
+	case register >= 'A' && register <= 'Z':
+		name := unicode.ToLower(register)
+		if old, ok := v.registers[name]; ok && old.text != "" {
+			sep := ""
+			if old.linewise || reg.linewise {
+				sep = "\n"
+			}
+			reg = vimRegister{text: old.text + sep + reg.text, linewise: old.linewise || reg.linewise}
+		}
+		v.registers[name] = reg
+	case register >= 'a' && register <= 'z':
+		v.registers[register] = reg
+	}
+	v.registers['"'] = reg
+	if yank {
+		v.registers['0'] = reg
+	}
+}
+
+// register returns the content of a register, the unnamed one for 0
+func (v *vimState) register(register rune) (vimRegister, bool) {
+	switch {
+	case register == 0:
+		register = '"'
+	case register >= 'A' && register <= 'Z':
+		register = unicode.ToLower(register)
+	}
+	reg, ok := v.registers[register]
+	return reg, ok
+}
+
+// vimMotion returns where a motion moves the cursor. Linewise motions cover
+// whole lines when an operator uses them; inclusive ones take the character
+// they end on. count is 0 when none was typed.
+func vimMotion(text []rune, pos int, motion string, count int) (int, bool, bool, bool) {
+	n := max(count, 1)
+	switch motion {
+	case "h":
+		return max(pos-n, vimLineStart(text, pos)), false, false, true
+	case "l", " ":
+		return min(pos+n, vimLineEnd(text, pos)), false, false, true
+	case "j", "k":
+		line := vimLineIndex(text, pos)
+		target := line + n
+		if motion == "k" {
+			target = line - n
+		}
+		if target < 0 || target > vimLineIndex(text, len(text)) {
+			return pos, true, false, false
+		}
+		column := pos - vimLineStart(text, pos)
+		start := vimLineOffset(text, target)
+		return min(start+column, vimLineEnd(text, start)), true, false, true
+	case "w", "W", "b", "B", "e", "E":
+		big := motion == "W" || motion == "B" || motion == "E"
+		target := pos
+		for i := 0; i < n; i++ {
+			switch strings.ToLower(motion) {
+			case "w":
+				target = vimNextWord(text, target, big)
+			case "b":
+				target = vimPrevWord(text, target, big)
+			case "e":
+				target = vimWordEnd(text, target, big)
+			}
+		}
+		return target, false, motion == "e" || motion == "E", true
+	case "0":
+		return vimLineStart(text, pos), false, false, true
+	case "^":
+		return vimFirstNonBlank(text, pos), false, false, true
+	case "$":
+		line := vimLineOffset(text, vimLineIndex(text, pos)+n-1)
+		end := vimLineEnd(text, line)
+		if end > vimLineStart(text, line) {
+			end--
+		}
+		return end, false, true, true
+	case "gg", "G":
+		line := 0
+		if motion == "G" {
+			line = vimLineIndex(text, len(text))
+		}
+		if count > 0 {
+			line = count - 1
+		}
+		return vimFirstNonBlank(text, vimLineOffset(text, line)), true, false, true
+	}
+
+	if len([]rune(motion)) == 2 {
+		kind, char := motion[0], []rune(motion)[1]
+		start, end := vimLineStart(text, pos), vimLineEnd(text, pos)
+		target := pos
+		for i := 0; i < n; i++ {
+			found := -1
+			switch kind {
+			case 'f', 't':
+				from := target + 1
+				if kind == 't' && i == 0 {
+					from = target + 2 // t from just before a match moves on
+				}
+				for j := from; j < end; j++ {
+					if text[j] == char {
+						found = j
+						break
+					}
+				}
+			case 'F', 'T':
+				from := target - 1
+				if kind == 'T' && i == 0 {
+					from = target - 2
+				}
+				for j := from; j >= start; j-- {
+					if text[j] == char {
+						found = j
+						break
+					}
+				}
+			}
+			if found < 0 {
+				return pos, false, false, false
+			}
+			target = found
+		}
+		switch kind {
+		case 't':
+			target--
+		case 'T':
+			target++
+		}
+		return target, false, kind == 'f' || kind == 't', true
+	}
+	return pos, false, false, false
+}
+
+// vimTextObjects maps the text object keys to the delimiters they select
+// between; words have none
+var vimTextObjects = map[string][2]rune{
+	"w": {}, "W": {},
+	`"`: {'"', '"'}, "'": {'\'', '\''}, "`": {'`', '`'},
+	"(": {'(', ')'}, ")": {'(', ')'}, "b": {'(', ')'},
+	"[": {'[', ']'}, "]": {'[', ']'},
+	"{": {'{', '}'}, "}": {'{', '}'}, "B": {'{', '}'},
+	"<": {'<', '>'}, ">": {'<', '>'},
+}
+
+// vimTextObject returns the range of a text object around pos, e.g. "iw" or "a("
+func vimTextObject(text []rune, pos int, object string) (int, int, bool) {
+	if len(text) == 0 {
+		return 0, 0, false
+	}
+	pos = min(pos, len(text)-1)
+	around := object[0] == 'a'
+	delims := vimTextObjects[object[1:]]
+
+	switch {
+	case object[1:] == "w" || object[1:] == "W":
+		big := object[1:] == "W"
+		class := vimCharClass(text[pos], big)
+		start, end := pos, pos+1
+		for start > 0 && text[start-1] != '\n' && vimCharClass(text[start-1], big) == class {
+			start--
+		}
+		for end < len(text) && text[end] != '\n' && vimCharClass(text[end], big) == class {
+			end++
+		}
+		if around && class != 0 {
+			// Take the spaces after the word, or else those before it
+			spaceEnd := end
+			for spaceEnd < len(text) && (text[spaceEnd] == ' ' || text[spaceEnd] == '\t') {
+				spaceEnd++
+			}
+			if spaceEnd > end {
+				end = spaceEnd
+			} else {
+				for start > 0 && (text[start-1] == ' ' || text[start-1] == '\t') {
+					start--
+				}
+			}
+		}
+		return start, end, true
+
+	case delims[0] == delims[1]:
+		// Quotes pair up within the line
+		lineStart, lineEnd := vimLineStart(text, pos), vimLineEnd(text, pos)
+		var quotes []int
+		for i := lineStart; i < lineEnd; i++ {
+			if text[i] == delims[0] && (i == lineStart || text[i-1] != '\\') {
+				quotes = append(quotes, i)
+			}
+		}
+		for i := 0; i+1 < len(quotes); i += 2 {
+			open, close := quotes[i], quotes[i+1]
+			if pos <= close {
+				if around {
+					return open, close + 1, true
+				}
+				return open + 1, close, true
+			}
+		}
+		return 0, 0, false
+
+	default:
+		open, close := -1, -1
+		depth := 0
+		for i := pos; i >= 0; i-- {
+			if text[i] == delims[1] && i != pos {
+				depth++
+			} else if text[i] == delims[0] {
+				if depth == 0 {
+					open = i
+					break
+				}
+				depth--
+			}
+		}
+		if open < 0 {
+			return 0, 0, false
+		}
+		depth = 0
+		for i := open + 1; i < len(text); i++ {
+			if text[i] == delims[0] {
+				depth++
+			} else if text[i] == delims[1] {
+				if depth == 0 {
+					close = i
+					break
+				}
+				depth--
+			}
+		}
+		if close < 0 {
+			return 0, 0, false
+		}
+		if around {
+			return open, close + 1, true
+		}
+		return open + 1, close, true
+	}
+}
+
+// vimCharClass classifies a character for word motions: 0 for blanks, 1 for
+// word characters and 2 for punctuation. For WORDs everything else is 1.
+func vimCharClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+// vimNextWord returns the start of the next word
+func vimNextWord(text []rune, pos int, big bool) int {
+	if pos >= len(text) {
+		return pos
+	}
+	i := pos
+	if class := vimCharClass(text[i], big); class != 0 {
+		for i < len(text) && vimCharClass(text[i], big) == class {
+			i++
+		}
+	}
+	for i < len(text) && vimCharClass(text[i], big) == 0 {
+		i++
+	}
+	return i
+}
+
+// vimPrevWord returns the start of the word before pos
+func vimPrevWord(text []rune, pos int, big bool) int {
+	i := pos - 1
+	for i > 0 && vimCharClass(text[i], big) == 0 {
+		i--
+	}
+	if i <= 0 {
+		return 0
+	}
+	class := vimCharClass(text[i], big)
+	for i > 0 && vimCharClass(text[i-1], big) == class {
+		i--
+	}
+	return i
+}
+
+// vimWordEnd returns the end of the word after pos
+func vimWordEnd(text []rune, pos int, big bool) int {
+	i := pos + 1
+	for i < len(text) && vimCharClass(text[i], big) == 0 {
+		i++
+	}
+	if i >= len(text) {
+		return max(len(text)-1, 0)
+	}
+	class := vimCharClass(text[i], big)
+	for i+1 < len(text) && vimCharClass(text[i+1], big) == class {
+		i++
+	}
+	return i
+}
+
+// vimLineStart returns the offset of the start of the line at pos
+func vimLineStart(text []rune, pos int) int {
+	pos = min(pos, len(text))
+	for pos > 0 && text[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// vimLineEnd returns the offset of the newline ending the line at pos, or
+// the length of text on the last line
+func vimLineEnd(text []rune, pos int) int {
+	for pos < len(text) && text[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// vimFirstNonBlank returns the offset of the first non-blank of the line at pos
+func vimFirstNonBlank(text []rune, pos int) int {
+	i := vimLineStart(text, pos)
+	end := vimLineEnd(text, i)
+	for i < end && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// vimLineIndex returns the number of the line at pos, counting from 0
+func vimLineIndex(text []rune, pos int) int {
+	line := 0
+	for _, r := range text[:max(min(pos, len(text)), 0)] {
+		if r == '\n' {
+			line++
+		}
+	}
+	return line
+}
+
+// vimLineOffset returns the offset of the start of a line, the last line if
+// there are fewer
+func vimLineOffset(text []rune, line int) int {
+	pos := 0
+	for i := 0; i < line; i++ {
+		end := vimLineEnd(text, pos)
+		if end >= len(text) {
+			break
+		}
+		pos = end + 1
+	}
+	return pos
+}
+
+// vimLines returns the range of the lines from the one at a to the one at b,
+// with the newline after the last of them
+func vimLines(text []rune, a, b int) (int, int) {
+	start := vimLineStart(text, min(a, b))
+	end := vimLineEnd(text, max(a, b))
+	if end < len(text) {
+		end++
+	}
+	return start, end
+}
+
+// clampVimCursor keeps the cursor on a character, as normal mode does
+func clampVimCursor(text []rune, pos int) int {
+	pos = max(0, min(pos, len(text)))
+	if end := vimLineEnd(text, pos); pos >= end && end > vimLineStart(text, pos) {
+		return end - 1
+	}
+	return pos
+}
+
+// vimSplice replaces text[start:end] with content
+func vimSplice(text []rune, start, end int, content []rune) []rune {
+	result := make([]rune, 0, len(text)-(end-start)+len(content))
+	result = append(result, text[:start]...)
+	result = append(result, content...)
+	return append(result, text[end:]...)
+}
+
+// toggleCase swaps the case of letters
+func toggleCase(text []rune) []rune {
+	result := make([]rune, len(text))
+	for i, r := range text {
+		if unicode.IsUpper(r) {
+			result[i] = unicode.ToLower(r)
+		} else {
+			result[i] = unicode.ToUpper(r)
+		}
+	}
+	return result
+}
+
+// vimStatus describes the mode and the keys of a pending command, e.g.
+// "-- NORMAL -- d2"
+func (v *vimState) status() string {
+	status := "-- " + strings.ToUpper(string(v.mode)) + " --"
+	if len(v.pending) > 0 {
+		status += " " + strings.Join(v.pending, "")
+	}
+	return status
+}
+
+// SetVimMode turns the vim keybindings on or off. They start in insert mode.
+func (e *Editor) SetVimMode(enabled bool) {
+	switch {
+	case enabled && e.vim == nil:
+		e.vim = newVimState()
+	case !enabled:
+		e.vim = nil
+	}
+}
+
+// VimMode returns the mode of the vim keybindings, empty when they are off
+func (e *Editor) VimMode() VimMode {
+	if e.vim == nil {
+		return ""
+	}
+	return e.vim.mode
+}
+
+// VimStatus describes the vim mode for the footer, e.g. "-- NORMAL --",
+// with the keys of an incomplete command. It is empty when vim is off.
+func (e *Editor) VimStatus() string {
+	if e.vim == nil {
+		return ""
+	}
+	return e.vim.status()
+}
+
+// updateVim handles a key with the vim keybindings. It reports false for
+// keys the editor handles as usual: every key in insert mode but Esc, and
+// Enter to send.
+func (e *Editor) updateVim(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if e.vim.mode == VimInsert {
+		if msg.Type != tea.KeyEsc {
+			return nil, false
+		}
+		// Leaving insert mode steps back onto the last typed character
+		e.vim.mode = VimNormal
+		buf := e.vimBuffer()
+		if buf.pos > vimLineStart(buf.text, buf.pos) {
+			buf.pos--
+		}
+		e.moveCursor(buf.text, clampVimCursor(buf.text, buf.pos))
+		return nil, true
+	}
+	if msg.Type == tea.KeyEnter {
+		e.vim.mode = VimNormal
+		e.vim.pending = nil
+		return nil, false
+	}
+
+	key := vimKeyName(msg)
+	if key == "" {
+		return nil, true
+	}
+
+	// k and j browse the history like the arrow keys
+	if e.vim.mode == VimNormal && len(e.vim.pending) == 0 {
+		browsed := false
+		switch key {
+		case "k":
+			browsed = e.historyUp()
+		case "j":
+			browsed = e.historyDown()
+		}
+		if browsed {
+			e.fitHeight()
+			buf := e.vimBuffer()
+			e.moveCursor(buf.text, clampVimCursor(buf.text, buf.pos))
+			return nil, true
+		}
+	}
+
+	buf := e.vimBuffer()
+	before := string(buf.text)
+	e.vim.feed(&buf, key)
+	if string(buf.text) != before {
+		e.textarea.SetValue(string(buf.text))
+		e.fitHeight()
+		buf.text = []rune(e.textarea.Value())
+	}
+	e.moveCursor(buf.text, min(buf.pos, len(buf.text)))
+	return nil, true
+}
+
+// vimBuffer returns the draft and the offset of the cursor in it
+func (e *Editor) vimBuffer() vimBuffer {
+	text := []rune(e.textarea.Value())
+	pos := vimLineOffset(text, e.textarea.Line())
+	info := e.textarea.LineInfo()
+	return vimBuffer{text: text, pos: min(pos+info.StartColumn+info.ColumnOffset, len(text))}
+}
+
+// moveCursor moves the cursor of the textarea to the offset pos of text,
+// which is its value
+func (e *Editor) moveCursor(text []rune, pos int) {
+	row := vimLineIndex(text, pos)
+	for e.textarea.Line() > row {
+		e.textarea.CursorUp()
+	}
+	for i := 0; e.textarea.Line() < row && i < len(text); i++ {
+		e.textarea.CursorDown()
+	}
+	e.textarea.SetCursor(pos - vimLineStart(text, pos))
+}
+
+// fitHeight grows the textarea with its content, up to the maximum height
+func (e *Editor) fitHeight() {
+	lines := strings.Count(e.textarea.Value(), "\n") + 1
+	e.textarea.SetHeight(max(e.minHeight, min(lines, e.maxHeight)))
+}
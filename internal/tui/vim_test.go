@@ -0,0 +1,290 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVimEditor returns a focused editor in normal mode holding text, with
+// the cursor at the start
+func newVimEditor(t *testing.T, text string) *Editor {
+	t.Helper()
+	editor := NewEditor(NewStyles(GetTheme("dark")), "> ", nil)
+	editor.SetWidth(80)
+	editor.SetHeight(1, 20)
+	editor.Focus()
+	editor.SetVimMode(true)
+	editor.SetValue(text)
+	press(editor, "<esc>gg0")
+	require.Equal(t, VimNormal, editor.VimMode())
+	return editor
+}
+
+// press sends keys to the editor; <esc> and <enter> name those keys
+func press(editor *Editor, keys string) tea.Cmd {
+	var cmd tea.Cmd
+	for keys != "" {
+		var msg tea.KeyMsg
+		switch {
+		case strings.HasPrefix(keys, "<esc>"):
+			msg, keys = tea.KeyMsg{Type: tea.KeyEsc}, keys[5:]
+		case strings.HasPrefix(keys, "<enter>"):
+			msg, keys = tea.KeyMsg{Type: tea.KeyEnter}, keys[7:]
+		default:
+			r := []rune(keys)[0]
+			msg, keys = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}, keys[len(string(r)):]
+			if r == ' ' {
+				msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{r}}
+			}
+		}
+		editor, cmd = editor.Update(msg)
+	}
+	return cmd
+}
+
+// cursor returns the offset of the cursor in the draft
+func cursor(editor *Editor) int {
+	return editor.vimBuffer().pos
+}
+
+func TestVimMotions(t *testing.T) {
+	editor := newVimEditor(t, "foo.bar baz\n  qux quux\nend")
+
+	steps := []struct {
+		keys string
+		pos  int
+	}{
+		{"w", 3},  // .
+		{"w", 4},  // bar
+		{"W", 8},  // baz
+		{"e", 10}, // end of baz
+		{"w", 14}, // qux on the next line
+		{"b", 8},
+		{"2w", 18},
+		{"0", 12},
+		{"^", 14},
+		{"$", 21},
+		{"j", 25}, // end is shorter
+		{"gg", 0},
+		{"G", 23},
+		{"k", 12},
+		{"2G", 14},
+		{"fu", 15},
+		{"Fq", 14},
+		{"tx", 15},
+		{"l", 16},
+		{"h", 15},
+	}
+	for _, step := range steps {
+		press(editor, step.keys)
+		assert.Equal(t, step.pos, cursor(editor), "after %s", step.keys)
+	}
+}
+
+func TestVimOperators(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		keys string
+		want string
+		pos  int
+		mode VimMode
+	}{
+		{name: "dw", text: "one two three", keys: "dw", want: "two three"},
+		{name: "d2w", text: "one two three", keys: "d2w", want: "three"},
+		{name: "2dw", text: "one two three", keys: "2dw", want: "three"},
+		{name: "dw at end of line", text: "one\ntwo", keys: "ldw", want: "o\ntwo", pos: 0},
+		{name: "de", text: "one two", keys: "de", want: " two"},
+		{name: "d$", text: "one two\nx", keys: "wd$", want: "one \nx", pos: 3},
+		{name: "D", text: "one two", keys: "wD", want: "one ", pos: 3},
+		{name: "dd", text: "a\nb\nc", keys: "jdd", want: "a\nc", pos: 2},
+		{name: "dd last line", text: "a\nb\nc", keys: "Gdd", want: "a\nb", pos: 2},
+		{name: "2dd", text: "a\nb\nc", keys: "2dd", want: "c"},
+		{name: "dj", text: "a\nb\nc", keys: "dj", want: "c"},
+		{name: "dG", text: "a\nb\nc", keys: "jdG", want: "a", pos: 0},
+		{name: "x", text: "abc", keys: "x", want: "bc"},
+		{name: "3x", text: "abcd", keys: "3x", want: "d"},
+		{name: "X", text: "abc", keys: "$X", want: "ac", pos: 1},
+		{name: "dt", text: "call(a, b)", keys: "dt(", want: "(a, b)"},
+		{name: "df", text: "call(a, b)", keys: "df,", want: " b)"},
+		{name: "cw", text: "one two", keys: "cwuno<esc>", want: "uno two", pos: 2},
+		{name: "cc", text: "a\n  bc\nd", keys: "jccx<esc>", want: "a\nx\nd", pos: 2},
+		{name: "C", text: "one two", keys: "wCthree<esc>", want: "one three", pos: 8},
+		{name: "s", text: "abc", keys: "sX<esc>", want: "Xbc"},
+		{name: "diw", text: "foo bar baz", keys: "wdiw", want: "foo  baz", pos: 4},
+		{name: "daw", text: "foo bar baz", keys: "wdaw", want: "foo baz", pos: 4},
+		{name: "ciw", text: "foo bar", keys: "ciwqux<esc>", want: "qux bar", pos: 2},
+		{name: `di"`, text: `say "hi there" now`, keys: `fhdi"`, want: `say "" now`, pos: 5},
+		{name: `da"`, text: `say "hi" now`, keys: `fhda"`, want: `say  now`, pos: 4},
+		{name: "di(", text: "f(a, (b), c)", keys: "fbdi(", want: "f(a, (), c)", pos: 6},
+		{name: "da(", text: "f(a, (b), c)", keys: "fada(", want: "f", pos: 0},
+		{name: "ci{ across lines", text: "if {\n  x\n}", keys: "jci{<esc>", want: "if {}", pos: 3},
+		{name: "r", text: "abc", keys: "lrX", want: "aXc", pos: 1},
+		{name: "~", text: "abC", keys: "3~", want: "ABc", pos: 2},
+		{name: "J", text: "one\n  two", keys: "J", want: "one two", pos: 3},
+		{name: "o", text: "a\nc", keys: "ob<esc>", want: "a\nb\nc", pos: 2},
+		{name: "O", text: "b", keys: "Oa<esc>", want: "a\nb", pos: 0},
+		{name: "A", text: "ab", keys: "Ac<esc>", want: "abc", pos: 2},
+		{name: "I", text: "  b", keys: "$Ia<esc>", want: "  ab", pos: 2},
+		{name: "yy p", text: "a\nb", keys: "yyp", want: "a\na\nb", pos: 2},
+		{name: "yw P", text: "ab cd", keys: "ywwP", want: "ab ab cd", pos: 5},
+		{name: "dd P", text: "a\nb", keys: "ddP", want: "a\nb", pos: 0},
+		{name: "x p swaps", text: "ab", keys: "xp", want: "ba", pos: 1},
+		{name: "3p", text: "a", keys: "yl3p", want: "aaaa", pos: 3},
+		{name: "u", text: "one two", keys: "dwdwu", want: "two", pos: 0},
+		{name: "u insert", text: "one", keys: "A two<esc>u", want: "one", pos: 2},
+		{name: "invalid command is dropped", text: "abc", keys: "dzx", want: "bc"},
+		{name: "esc cancels", text: "abc", keys: "d<esc>x", want: "bc"},
+		{name: "insert mode", text: "abc", keys: "i", want: "abc", mode: VimInsert},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			editor := newVimEditor(t, tt.text)
+			press(editor, tt.keys)
+			assert.Equal(t, tt.want, editor.Value())
+			assert.Equal(t, tt.pos, cursor(editor))
+			mode := tt.mode
+			if mode == "" {
+				mode = VimNormal
+			}
+			assert.Equal(t, mode, editor.VimMode())
+		})
+	}
+}
+
+func TestVimRegisters(t *testing.T) {
+	editor := newVimEditor(t, "one two three")
+
+	press(editor, `"ayw`)
+	assert.Equal(t, "one ", editor.vim.registers['a'].text)
+	press(editor, `w"Ayw`)
+	assert.Equal(t, "one two ", editor.vim.registers['a'].text)
+
+	// Deleting keeps the yank in 0 but replaces the unnamed register
+	press(editor, `w"_dw`)
+	assert.Equal(t, "one two ", editor.Value())
+	press(editor, "0dw")
+	assert.Equal(t, "two ", editor.Value())
+	assert.Equal(t, "one ", editor.vim.registers['"'].text)
+	assert.Equal(t, "one two ", editor.vim.registers['0'].text)
+
+	press(editor, `$"ap`)
+	assert.Equal(t, "two one two ", editor.Value())
+	press(editor, `"0P`)
+	assert.Equal(t, "two one twoone two  ", editor.Value())
+
+	// Registers outlive the draft
+	editor.Reset()
+	press(editor, `<esc>"ap`)
+	assert.Equal(t, "one two ", editor.Value())
+}
+
+func TestVimVisual(t *testing.T) {
+	editor := newVimEditor(t, "one two three")
+	press(editor, "wve")
+	assert.Equal(t, VimVisual, editor.VimMode())
+	assert.Equal(t, "-- VISUAL --", editor.VimStatus())
+	press(editor, "d")
+	assert.Equal(t, "one  three", editor.Value())
+	assert.Equal(t, VimNormal, editor.VimMode())
+
+	editor = newVimEditor(t, "one two three")
+	press(editor, "wvhy")
+	assert.Equal(t, " t", editor.vim.registers['"'].text)
+	assert.Equal(t, 3, cursor(editor))
+
+	editor = newVimEditor(t, "a (b c) d")
+	press(editor, "fbvi(c")
+	assert.Equal(t, "a () d", editor.Value())
+	assert.Equal(t, VimInsert, editor.VimMode())
+
+	editor = newVimEditor(t, "a\nb\nc\nd")
+	press(editor, "jVjd")
+	assert.Equal(t, "a\nd", editor.Value())
+
+	editor = newVimEditor(t, "ab cd")
+	press(editor, "vlo~")
+	assert.Equal(t, "AB cd", editor.Value())
+
+	editor = newVimEditor(t, "ab")
+	press(editor, "vV")
+	assert.Equal(t, VimVisualLine, editor.VimMode())
+	press(editor, "<esc>")
+	assert.Equal(t, VimNormal, editor.VimMode())
+}
+
+func TestVimVisualOnEmptyDraft(t *testing.T) {
+	for _, keys := range []string{"VJ", "vJ", "Vd", "v~", "Vy"} {
+		editor := newVimEditor(t, "")
+		press(editor, keys)
+		assert.Equal(t, "", editor.Value(), keys)
+		assert.Equal(t, VimNormal, editor.VimMode(), keys)
+	}
+}
+
+func FuzzVim(f *testing.F) {
+	f.Add("", "VJ")
+	f.Add("one two\nthree", "wvjd")
+	f.Add("a (b c) d", "fbvi(c<esc>u")
+	f.Add("a\nb\nc", "jVjJ3x\"ayyG\"ap")
+	f.Fuzz(func(t *testing.T, text, keys string) {
+		if !utf8.ValidString(text) || !utf8.ValidString(keys) || utf8.RuneCountInString(keys) > 64 {
+			t.Skip()
+		}
+		editor := newVimEditor(t, text)
+		press(editor, keys)
+		if pos := cursor(editor); pos < 0 || pos > len([]rune(editor.Value())) {
+			t.Fatalf("cursor %d outside the draft %q", pos, editor.Value())
+		}
+	})
+}
+
+func TestVimPendingStatus(t *testing.T) {
+	editor := newVimEditor(t, "abc")
+	assert.Equal(t, "-- NORMAL --", editor.VimStatus())
+	press(editor, `"a2d`)
+	assert.Equal(t, `-- NORMAL -- "a2d`, editor.VimStatus())
+	press(editor, "<esc>i")
+	assert.Equal(t, "-- INSERT --", editor.VimStatus())
+
+	editor.SetVimMode(false)
+	assert.Equal(t, "", editor.VimStatus())
+	press(editor, "x")
+	assert.Equal(t, "xabc", editor.Value())
+}
+
+func TestVimHistory(t *testing.T) {
+	editor := newVimEditor(t, "")
+	editor.AddToHistory("first")
+	editor.AddToHistory("second\nlines")
+
+	press(editor, "<esc>k")
+	assert.Equal(t, "second\nlines", editor.Value())
+	// In multi-line drafts k moves between lines
+	press(editor, "k")
+	assert.Equal(t, "second\nlines", editor.Value())
+	assert.Equal(t, 4, cursor(editor))
+
+	editor = newVimEditor(t, "draft")
+	editor.AddToHistory("first")
+	press(editor, "k")
+	assert.Equal(t, "first", editor.Value())
+	assert.Equal(t, 4, cursor(editor))
+	press(editor, "j")
+	assert.Equal(t, "draft", editor.Value())
+}
+
+func TestVimSubmit(t *testing.T) {
+	editor := newVimEditor(t, "hello")
+	cmd := press(editor, "<enter>")
+	require.NotNil(t, cmd)
+	assert.Equal(t, EditorSubmitMsg{Content: "hello"}, cmd())
+
+	editor.Reset()
+	assert.Equal(t, VimInsert, editor.VimMode())
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"sync"
 
 	"github.com/myersguo/cc-mono/pkg/ai"
 )
@@ -9,8 +10,22 @@ import (
 // Agent represents an AI agent that can interact with LLMs and execute tools
 type Agent struct {
 	state    *AgentState
+	mu       sync.RWMutex // guards provider, which commands swap while the loop runs
 	provider ai.Provider
 	eventBus *EventBus
+	hooks    LoopHooks
+}
+
+// LoopHooks intercepts the agent loop. Hooks run synchronously on the loop goroutine.
+type LoopHooks interface {
+	// OnPromptSubmit runs before prompts are added to the history.
+	// It returns the prompts to use, or an error to reject them.
+	OnPromptSubmit(ctx context.Context, prompts []AgentMessage) ([]AgentMessage, error)
+
+	// OnStop runs when the agent is about to stop. A non-empty result is added as a
+	// user message and the loop continues. stopHookActive is true when the loop is
+	// already continuing because of an earlier OnStop result.
+	OnStop(ctx context.Context, messages []AgentMessage, stopHookActive bool) (string, error)
 }
 
 // NewAgent creates a new agent
@@ -34,14 +49,34 @@ func (a *Agent) GetState() *AgentState {
 
 // GetProvider returns the provider
 func (a *Agent) GetProvider() ai.Provider {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.provider
 }
 
+// SetProvider replaces the provider used for the next turn, e.g. when switching
+// to a model from another provider
+func (a *Agent) SetProvider(provider ai.Provider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.provider = provider
+}
+
 // GetEventBus returns the event bus
 func (a *Agent) GetEventBus() *EventBus {
 	return a.eventBus
 }
 
+// SetLoopHooks installs hooks that intercept the agent loop (nil to remove)
+func (a *Agent) SetLoopHooks(hooks LoopHooks) {
+	a.hooks = hooks
+}
+
+// GetLoopHooks returns the installed loop hooks, if any
+func (a *Agent) GetLoopHooks() LoopHooks {
+	return a.hooks
+}
+
 // SetSystemPrompt updates the system prompt
 func (a *Agent) SetSystemPrompt(prompt string) {
 	a.state.SetSystemPrompt(prompt)
@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit decisions
+const (
+	AuditDecisionAllowed = "allowed"
+	AuditDecisionDenied  = "denied"
+)
+
+// Sources of a permission decision
+const (
+	DecidedByRule      = "rule"      // Matched an allow/deny pattern
+	DecidedByUser      = "user"      // Answered in the permission dialog
+	DecidedByAuto      = "auto"      // Auto-approved by the frontend (e.g. safe risk level)
+	DecidedByTimeout   = "timeout"   // Nobody answered in time
+	DecidedByCancelled = "cancelled" // The context was cancelled while waiting
+	DecidedByNone      = "none"      // No permission manager was configured
+	DecidedByPrepare   = "prepare"   // Blocked before the permission check, e.g. by a hook
+	DecidedByError     = "error"     // Failed before a decision, e.g. an unknown tool
+)
+
+// redactedValue replaces secrets in audit entries
+const redactedValue = "[REDACTED]"
+
+// AuditEntry represents a single permission decision and tool execution
+type AuditEntry struct {
+	Timestamp   time.Time      `json:"timestamp"`
+	SessionID   string         `json:"session_id,omitempty"`
+	ToolCallID  string         `json:"tool_call_id"`
+	ToolName    string         `json:"tool_name"`
+	Resource    string         `json:"resource,omitempty"`
+	Params      map[string]any `json:"params,omitempty"` // Redacted tool parameters
+	RiskLevel   string         `json:"risk_level,omitempty"`
+	RequestID   string         `json:"request_id,omitempty"`
+	Decision    string         `json:"decision"`   // "allowed" or "denied"
+	DecidedBy   string         `json:"decided_by"` // One of the DecidedBy constants
+	MatchedRule string         `json:"matched_rule,omitempty"`
+	Executed    bool           `json:"executed"`
+	IsError     bool           `json:"is_error,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	ExitCode    *int           `json:"exit_code,omitempty"`
+	DurationMs  int64          `json:"duration_ms,omitempty"`
+}
+
+// AuditFilter selects entries when querying the audit log
+type AuditFilter struct {
+	SessionID string
+	ToolName  string
+	Since     time.Time // Zero means no lower bound
+	Until     time.Time // Zero means no upper bound
+}
+
+// Match reports whether an entry satisfies the filter
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	if f.SessionID != "" && entry.SessionID != f.SessionID {
+		return false
+	}
+	if f.ToolName != "" && !strings.EqualFold(entry.ToolName, f.ToolName) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only JSONL log of permission decisions and tool executions
+type AuditLog struct {
+	mu        sync.Mutex
+	path      string
+	sessionID string
+}
+
+// NewAuditLog creates an audit log writing to path, tagging entries with sessionID
+func NewAuditLog(path, sessionID string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	return &AuditLog{
+		path:      path,
+		sessionID: sessionID,
+	}, nil
+}
+
+// AuditLogPath returns the per-project audit log path under the global config dir.
+// The log lives outside the project so the agent cannot rewrite its own history.
+func AuditLogPath(globalConfigDir, projectDir string) string {
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		absDir = projectDir
+	}
+
+	hash := sha256.Sum256([]byte(absDir))
+	name := fmt.Sprintf("%s-%s.jsonl", filepath.Base(absDir), hex.EncodeToString(hash[:4]))
+	return filepath.Join(globalConfigDir, "audit", name)
+}
+
+// Path returns the log file path
+func (a *AuditLog) Path() string {
+	return a.path
+}
+
+// SessionID returns the session ID attached to new entries
+func (a *AuditLog) SessionID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sessionID
+}
+
+// SetSessionID changes the session ID attached to new entries
+func (a *AuditLog) SetSessionID(sessionID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessionID = sessionID
+}
+
+// Record appends an entry to the log
+func (a *AuditLog) Record(entry AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.SessionID == "" {
+		entry.SessionID = a.sessionID
+	}
+	entry.Params = RedactParams(entry.Params)
+	entry.Resource = redactString(entry.Resource)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns all entries matching the filter, oldest first
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	return QueryAuditLog(a.path, filter)
+}
+
+// QueryAuditLog reads the log at path and returns entries matching the filter
+func QueryAuditLog(path string, filter AuditFilter) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry on line %d: %w", lineNum, err)
+		}
+
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// sensitiveKeyPattern matches parameter names that usually hold secrets
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key|^auth$|authorization|credential|private[_-]?key)`)
+
+// secretValuePattern pairs a secret-matching expression with its replacement
+type secretValuePattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// secretValuePatterns match secrets embedded in free-form strings such as commands
+var secretValuePatterns = []secretValuePattern{
+	// KEY=value assignments for secret-looking variable names
+	{regexp.MustCompile(`(?i)\b([A-Z0-9_]*(?:PASSWORD|PASSWD|SECRET|TOKEN|API_KEY|APIKEY|ACCESS_KEY)[A-Z0-9_]*)=("[^"]*"|'[^']*'|\S+)`), "${1}=" + redactedValue},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic|token)\s+)[^\s'"]+`), "${1}" + redactedValue},
+	// Credentials in URLs
+	{regexp.MustCompile(`(://[^:/\s]+:)[^@/\s]+(@)`), "${1}" + redactedValue + "${2}"},
+	// Well-known token formats
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`), redactedValue},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`), redactedValue},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), redactedValue},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`), redactedValue},
+}
+
+// RedactParams returns a copy of params with secrets replaced
+func RedactParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+
+	redacted := make(map[string]any, len(params))
+	for key, value := range params {
+		if sensitiveKeyPattern.MatchString(key) {
+			redacted[key] = redactedValue
+			continue
+		}
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+// redactValue redacts secrets inside nested values
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return redactString(v)
+	case map[string]any:
+		return RedactParams(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// redactString replaces secret-looking substrings in s
+func redactString(s string) string {
+	for _, p := range secretValuePatterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
@@ -35,6 +35,9 @@ const (
 
 	// Prompt events
 	EventTypePromptAdded AgentEventType = "prompt_added"
+
+	// Sub-agent events
+	EventTypeSubAgent AgentEventType = "subagent"
 )
 
 // AgentEvent is the interface that all agent events implement
@@ -258,3 +261,24 @@ func NewPromptAddedEvent(message AgentMessage) PromptAddedEvent {
 		Message: message,
 	}
 }
+
+// SubAgentEvent wraps an event from a sub-agent started by a parent tool call
+type SubAgentEvent struct {
+	Type             AgentEventType `json:"type"`
+	ParentToolCallID string         `json:"parent_tool_call_id"`
+	AgentType        string         `json:"agent_type"`
+	Event            AgentEvent     `json:"event"`
+}
+
+func (e SubAgentEvent) EventType() AgentEventType { return e.Type }
+func (e SubAgentEvent) isAgentEvent()             {}
+
+// NewSubAgentEvent creates a new sub-agent event
+func NewSubAgentEvent(parentToolCallID, agentType string, event AgentEvent) SubAgentEvent {
+	return SubAgentEvent{
+		Type:             EventTypeSubAgent,
+		ParentToolCallID: parentToolCallID,
+		AgentType:        agentType,
+		Event:            event,
+	}
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -19,6 +20,10 @@ type AgentLoopConfig struct {
 	CompactionRatio  float64 // Trigger compaction at this ratio (default: 0.8)
 }
 
+// maxStopContinuations bounds how often the loop hooks' OnStop can keep one
+// run going, so a hook that always objects can't loop until MaxTurns
+const maxStopContinuations = 3
+
 // AgentLoop is the main agent loop that processes messages and tool calls
 func AgentLoop(
 	ctx context.Context,
@@ -35,6 +40,16 @@ func AgentLoop(
 
 	agent := agentContext.Agent
 	state := agent.state
+	hooks := agent.GetLoopHooks()
+
+	// Let hooks rewrite or reject the prompts
+	if hooks != nil && len(prompts) > 0 {
+		var err error
+		prompts, err = hooks.OnPromptSubmit(ctx, prompts)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Add initial prompts to message history
 	for _, prompt := range prompts {
@@ -43,6 +58,8 @@ func AgentLoop(
 	}
 
 	turnCount := 0
+	stopHookActive := false
+	stopContinuations := 0
 
 	// Outer loop: process follow-up messages
 	for {
@@ -71,7 +88,7 @@ func AgentLoop(
 		options := BuildStreamOptions(state)
 
 		// Call LLM
-		stream := agent.provider.Stream(ctx, state.GetModel(), aiContext, options)
+		stream := agent.GetProvider().Stream(ctx, state.GetModel(), aiContext, options)
 
 		// Process the stream
 		assistantMessage, toolResults, err := processStream(
@@ -125,6 +142,23 @@ func AgentLoop(
 			shouldContinue = true
 		}
 
+		// Give stop hooks a chance to keep the agent going, a few times at most
+		if !shouldContinue && hooks != nil && stopContinuations < maxStopContinuations {
+			reason, err := hooks.OnStop(ctx, state.GetMessages(), stopHookActive)
+			if err != nil {
+				eventBus.Publish(NewErrorEvent(err, "stop hook"))
+			} else if reason != "" {
+				state.AddMessage(NewAgentMessage(
+					ai.NewUserTextMessage(reason),
+					fmt.Sprintf("hook-%d", time.Now().UnixNano()),
+					time.Now().UnixMilli(),
+				))
+				stopHookActive = true
+				stopContinuations++
+				shouldContinue = true
+			}
+		}
+
 		if !shouldContinue {
 			// No more work to do
 			break
@@ -320,10 +354,51 @@ func executeToolCall(
 	state *AgentState,
 	eventBus *EventBus,
 ) (ai.ToolResultMessage, error) {
+	// Audit entry for this call, recorded once the outcome is known
+	auditLog, _ := ctx.Value("audit_log").(*AuditLog)
+	entry := AuditEntry{
+		ToolCallID: toolCall.ID,
+		ToolName:   toolCall.Name,
+		Decision:   AuditDecisionAllowed,
+		DecidedBy:  DecidedByNone,
+	}
+	recordAudit := func() {
+		if auditLog != nil {
+			entry.Timestamp = time.Now()
+			entry.Resource = extractResource(toolCall)
+			entry.Params = toolCall.Params
+			_ = auditLog.Record(entry)
+		}
+	}
+
 	// Find the tool
 	agentTool, found := findToolByName(state, toolCall.Name)
 	if !found {
-		return ai.ToolResultMessage{}, fmt.Errorf("tool not found: %s", toolCall.Name)
+		err := fmt.Errorf("tool not found: %s", toolCall.Name)
+		entry.Decision = AuditDecisionDenied
+		entry.DecidedBy = DecidedByError
+		entry.Error = err.Error()
+		recordAudit()
+		return ai.ToolResultMessage{}, err
+	}
+
+	// Let the tool rewrite or block the call, so the permission check, the
+	// audit log and the tool all see the same params
+	if agentTool.Prepare != nil {
+		params, err := agentTool.Prepare(ctx, toolCall.ID, toolCall.Params)
+		if err != nil {
+			entry.Decision = AuditDecisionDenied
+			entry.DecidedBy = DecidedByPrepare
+			entry.Error = err.Error()
+			recordAudit()
+			return ai.ToolResultMessage{}, err
+		}
+		if params != nil {
+			toolCall.Params = params
+		}
+	}
+	if agentTool.Finish != nil {
+		defer agentTool.Finish(toolCall.ID)
 	}
 
 	// Check if permission manager is available in context
@@ -334,19 +409,27 @@ func executeToolCall(
 
 		// Create permission request
 		req := &PermissionRequest{
-			ToolName: toolCall.Name,
-			Action:   "execute",
-			Resource: extractResource(toolCall),
-			Params:   toolCall.Params,
+			ToolName:  toolCall.Name,
+			Action:    "execute",
+			Resource:  extractResource(toolCall),
+			Params:    toolCall.Params,
+			Sandboxed: agentTool.Sandboxed,
+			Networked: agentTool.Networked,
 		}
 		req.RiskLevel = AnalyzeRiskLevel(req)
 		req.Description = describeToolCall(toolCall)
+		entry.RiskLevel = req.RiskLevel
 
 		// Check permission
 		allowed, needAsk, err := pm.CheckPermission(req)
 		if err != nil {
+			entry.Decision = AuditDecisionDenied
+			entry.DecidedBy = DecidedByError
+			entry.Error = err.Error()
+			recordAudit()
 			return ai.ToolResultMessage{}, fmt.Errorf("permission check failed: %w", err)
 		}
+		entry.MatchedRule = req.MatchedRule
 
 		if needAsk {
 			// Emit permission request event
@@ -354,15 +437,29 @@ func executeToolCall(
 
 			// Request permission from user with context
 			resp, err := pm.RequestPermissionWithContext(ctx, req)
+			entry.RequestID = req.RequestID
+			if resp != nil {
+				entry.DecidedBy = resp.DecidedBy
+			}
 			if err != nil {
+				entry.Decision = AuditDecisionDenied
+				entry.Error = err.Error()
+				recordAudit()
 				return ai.ToolResultMessage{}, fmt.Errorf("permission request failed: %w", err)
 			}
 
 			if !resp.Allowed {
+				entry.Decision = AuditDecisionDenied
+				recordAudit()
 				return ai.ToolResultMessage{}, fmt.Errorf("permission denied by user")
 			}
 		} else if !allowed {
+			entry.Decision = AuditDecisionDenied
+			entry.DecidedBy = DecidedByRule
+			recordAudit()
 			return ai.ToolResultMessage{}, fmt.Errorf("permission denied by policy")
+		} else {
+			entry.DecidedBy = DecidedByRule
 		}
 	}
 
@@ -375,8 +472,19 @@ func executeToolCall(
 	}
 
 	// Execute the tool
+	startTime := time.Now()
 	result, err := agentTool.Execute(ctx, toolCall.ID, toolCall.Params, onUpdate)
 
+	// Record the outcome
+	entry.Executed = true
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	entry.IsError = err != nil || result.IsError
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	entry.ExitCode = extractExitCode(result)
+	recordAudit()
+
 	// Emit tool execution end event
 	eventBus.Publish(NewToolExecutionEndEvent(toolCall.ID, toolCall.Name, result, err != nil))
 
@@ -396,6 +504,23 @@ func executeToolCall(
 	return toolResultMsg, nil
 }
 
+// extractExitCode extracts a process exit code from tool result details, if any
+func extractExitCode(result AgentToolResult) *int {
+	details, ok := result.Details.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	switch code := details["exit_code"].(type) {
+	case int:
+		return &code
+	case float64:
+		exitCode := int(code)
+		return &exitCode
+	}
+	return nil
+}
+
 // findToolByName finds a tool by name in the agent state
 func findToolByName(state *AgentState, name string) (AgentTool, bool) {
 	tools := state.GetTools()
@@ -409,38 +534,107 @@ func findToolByName(state *AgentState, name string) (AgentTool, bool) {
 
 // extractResource extracts the resource identifier from a tool call
 func extractResource(toolCall ai.ToolCall) string {
-	switch toolCall.Name {
-	case "Read", "Write", "Edit":
+	switch strings.ToLower(toolCall.Name) {
+	case "read", "write", "edit", "multi_edit", "lsp_definition", "lsp_references", "lsp_hover":
 		if path, ok := toolCall.Params["file_path"].(string); ok {
 			return path
 		}
-	case "Bash":
+	case "bash":
 		if cmd, ok := toolCall.Params["command"].(string); ok {
 			return cmd
 		}
+	case "web_fetch":
+		if url, ok := toolCall.Params["url"].(string); ok {
+			return url
+		}
+	case "web_search":
+		if query, ok := toolCall.Params["query"].(string); ok {
+			return query
+		}
+	case "repo_map":
+		if path, ok := toolCall.Params["path"].(string); ok {
+			return path
+		}
+	case "apply_patch":
+		if patch, ok := toolCall.Params["patch"].(string); ok {
+			return strings.Join(patchPaths(patch), ", ")
+		}
 	}
 	return ""
 }
 
 // describeToolCall creates a human-readable description of a tool call
 func describeToolCall(toolCall ai.ToolCall) string {
-	switch toolCall.Name {
-	case "Read":
+	switch strings.ToLower(toolCall.Name) {
+	case "read":
 		if path, ok := toolCall.Params["file_path"].(string); ok {
 			return fmt.Sprintf("Read file: %s", path)
 		}
-	case "Write":
+	case "write":
 		if path, ok := toolCall.Params["file_path"].(string); ok {
 			return fmt.Sprintf("Write file: %s", path)
 		}
-	case "Edit":
+	case "edit", "multi_edit":
 		if path, ok := toolCall.Params["file_path"].(string); ok {
 			return fmt.Sprintf("Edit file: %s", path)
 		}
-	case "Bash":
+	case "lsp_definition", "lsp_references", "lsp_hover":
+		if path, ok := toolCall.Params["file_path"].(string); ok {
+			return fmt.Sprintf("Look up symbol in: %s", path)
+		}
+	case "web_fetch":
+		if url, ok := toolCall.Params["url"].(string); ok {
+			return fmt.Sprintf("Fetch URL: %s", url)
+		}
+	case "web_search":
+		if query, ok := toolCall.Params["query"].(string); ok {
+			return fmt.Sprintf("Search the web for: %s", query)
+		}
+	case "repo_map":
+		if path, ok := toolCall.Params["path"].(string); ok && path != "" {
+			return fmt.Sprintf("Map symbols in: %s", path)
+		}
+		return "Map repository symbols"
+	case "apply_patch":
+		if patch, ok := toolCall.Params["patch"].(string); ok {
+			return fmt.Sprintf("Apply patch to: %s", strings.Join(patchPaths(patch), ", "))
+		}
+	case "bash":
 		if cmd, ok := toolCall.Params["command"].(string); ok {
+			if background, _ := toolCall.Params["run_in_background"].(bool); background {
+				return fmt.Sprintf("Execute command in background: %s", cmd)
+			}
 			return fmt.Sprintf("Execute command: %s", cmd)
 		}
 	}
 	return fmt.Sprintf("Execute tool: %s", toolCall.Name)
 }
+
+// patchPaths lists the files named in the headers of a patch
+func patchPaths(patch string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path != "" && path != "/dev/null" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, line := range strings.Split(patch, "\n") {
+		line = strings.TrimRight(line, "\r")
+		for _, prefix := range []string{"*** Add File: ", "*** Update File: ", "*** Delete File: ", "*** Move to: "} {
+			if strings.HasPrefix(line, prefix) {
+				add(strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+			}
+		}
+		for _, prefix := range []string{"--- a/", "+++ b/", "--- ", "+++ "} {
+			if strings.HasPrefix(line, prefix) {
+				path, _, _ := strings.Cut(strings.TrimPrefix(line, prefix), "\t")
+				add(strings.TrimSpace(path))
+				break
+			}
+		}
+	}
+	return paths
+}
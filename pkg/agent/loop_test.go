@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// scriptedProvider replies with canned assistant messages, one per Stream call
+type scriptedProvider struct {
+	mu       sync.Mutex
+	replies  []ai.AssistantMessage
+	contexts []ai.Context
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+func (p *scriptedProvider) Stream(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.StreamOptions) *ai.AssistantMessageEventStream {
+	p.mu.Lock()
+	p.contexts = append(p.contexts, aiContext)
+	reply := ai.NewAssistantMessage([]ai.Content{ai.NewTextContent("done")}, "test", "scripted", model.ID, ai.Usage{}, ai.StopReasonEndTurn)
+	if len(p.replies) > 0 {
+		reply = p.replies[0]
+		p.replies = p.replies[1:]
+	}
+	p.mu.Unlock()
+
+	stream := ai.NewAssistantMessageEventStream(ctx)
+	go stream.SendResult(reply)
+	return stream
+}
+
+func (p *scriptedProvider) StreamSimple(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.SimpleStreamOptions) *ai.AssistantMessageEventStream {
+	return p.Stream(ctx, model, aiContext, nil)
+}
+
+func (p *scriptedProvider) ValidateModel(model ai.Model) error { return nil }
+
+func (p *scriptedProvider) GetDefaultModel() ai.Model { return ai.Model{ID: "scripted"} }
+
+// testLoopHooks records calls and returns canned results
+type testLoopHooks struct {
+	promptErr   error
+	stopReasons []string
+	stopActive  []bool
+}
+
+func (h *testLoopHooks) OnPromptSubmit(ctx context.Context, prompts []AgentMessage) ([]AgentMessage, error) {
+	if h.promptErr != nil {
+		return nil, h.promptErr
+	}
+	return append(prompts, NewAgentMessage(ai.NewUserTextMessage("hook context"), "hook", 0)), nil
+}
+
+func (h *testLoopHooks) OnStop(ctx context.Context, messages []AgentMessage, stopHookActive bool) (string, error) {
+	h.stopActive = append(h.stopActive, stopHookActive)
+	if len(h.stopReasons) == 0 {
+		return "", nil
+	}
+	reason := h.stopReasons[0]
+	h.stopReasons = h.stopReasons[1:]
+	return reason, nil
+}
+
+func TestAgentLoopHooks(t *testing.T) {
+	prompt := NewAgentMessage(ai.NewUserTextMessage("Hello"), "1", 0)
+
+	t.Run("PromptSubmitRewritesPrompts", func(t *testing.T) {
+		provider := &scriptedProvider{}
+		agent := NewAgent(provider, "", ai.Model{ID: "scripted"}, nil)
+		agent.SetLoopHooks(&testLoopHooks{})
+
+		if err := agent.Run(context.Background(), []AgentMessage{prompt}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(provider.contexts) != 1 || len(provider.contexts[0].Messages) != 2 {
+			t.Fatalf("Expected the hook message to be sent to the model, got %+v", provider.contexts)
+		}
+	})
+
+	t.Run("PromptSubmitRejectsPrompts", func(t *testing.T) {
+		provider := &scriptedProvider{}
+		agent := NewAgent(provider, "", ai.Model{ID: "scripted"}, nil)
+		agent.SetLoopHooks(&testLoopHooks{promptErr: errors.New("blocked")})
+
+		if err := agent.Run(context.Background(), []AgentMessage{prompt}); err == nil {
+			t.Fatal("Expected error")
+		}
+
+		if len(provider.contexts) != 0 {
+			t.Errorf("Expected no model calls, got %d", len(provider.contexts))
+		}
+		if len(agent.GetState().GetMessages()) != 0 {
+			t.Errorf("Expected no messages in history, got %d", len(agent.GetState().GetMessages()))
+		}
+	})
+
+	t.Run("StopContinues", func(t *testing.T) {
+		provider := &scriptedProvider{}
+		hooks := &testLoopHooks{stopReasons: []string{"Run the tests too"}}
+		agent := NewAgent(provider, "", ai.Model{ID: "scripted"}, nil)
+		agent.SetLoopHooks(hooks)
+
+		if err := agent.Run(context.Background(), []AgentMessage{prompt}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(provider.contexts) != 2 {
+			t.Fatalf("Expected 2 model calls, got %d", len(provider.contexts))
+		}
+		if len(hooks.stopActive) != 2 || hooks.stopActive[0] || !hooks.stopActive[1] {
+			t.Errorf("Expected stop_hook_active [false true], got %v", hooks.stopActive)
+		}
+
+		// The reason is sent to the model as a user message
+		messages := provider.contexts[1].Messages
+		last, ok := messages[len(messages)-1].(ai.UserMessage)
+		if !ok || last.Content[0].(ai.TextContent).Text != "Run the tests too" {
+			t.Errorf("Expected the stop reason as the last message, got %+v", messages[len(messages)-1])
+		}
+	})
+	t.Run("StopContinuationsAreCapped", func(t *testing.T) {
+		provider := &scriptedProvider{}
+		hooks := &testLoopHooks{stopReasons: []string{"1", "2", "3", "4", "5"}}
+		agent := NewAgent(provider, "", ai.Model{ID: "scripted"}, nil)
+		agent.SetLoopHooks(hooks)
+
+		if err := agent.Run(context.Background(), []AgentMessage{prompt}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(provider.contexts) != maxStopContinuations+1 {
+			t.Errorf("Expected %d model calls, got %d", maxStopContinuations+1, len(provider.contexts))
+		}
+		if len(hooks.stopActive) != maxStopContinuations {
+			t.Errorf("Expected OnStop to run %d times, got %d", maxStopContinuations, len(hooks.stopActive))
+		}
+	})
+}
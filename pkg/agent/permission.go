@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,21 +18,25 @@ import (
 // PermissionRequest represents a request for permission
 type PermissionRequest struct {
 	ToolName    string         `json:"tool_name"`
-	Action      string         `json:"action"`       // e.g., "read", "write", "execute"
-	Resource    string         `json:"resource"`     // e.g., file path, command
-	Params      map[string]any `json:"params"`       // Tool parameters
-	RiskLevel   string         `json:"risk_level"`   // "safe", "medium", "dangerous"
-	Description string         `json:"description"`  // Human-readable description
-	RequestID   string         `json:"request_id"`   // Unique request ID
+	Action      string         `json:"action"`      // e.g., "read", "write", "execute"
+	Resource    string         `json:"resource"`    // e.g., file path, command
+	Params      map[string]any `json:"params"`      // Tool parameters
+	RiskLevel   string         `json:"risk_level"`  // "safe", "medium", "dangerous"
+	Description string         `json:"description"` // Human-readable description
+	RequestID   string         `json:"request_id"`  // Unique request ID
 	Timestamp   int64          `json:"timestamp"`
+	MatchedRule string         `json:"matched_rule,omitempty"` // Allow/deny pattern that decided the request
+	Sandboxed   bool           `json:"sandboxed,omitempty"`    // The tool runs inside an OS sandbox
+	Networked   bool           `json:"networked,omitempty"`    // The sandbox keeps network access
 }
 
 // PermissionResponse represents a user's response to a permission request
 type PermissionResponse struct {
 	RequestID string `json:"request_id"`
 	Allowed   bool   `json:"allowed"`
-	Remember  bool   `json:"remember"` // Whether to remember this choice
-	Scope     string `json:"scope"`    // "project" or "global"
+	Remember  bool   `json:"remember"`             // Whether to remember this choice
+	Scope     string `json:"scope"`                // "project" or "global"
+	DecidedBy string `json:"decided_by,omitempty"` // "user", "auto", "timeout" or "cancelled"
 	Timestamp int64  `json:"timestamp"`
 }
 
@@ -46,15 +52,21 @@ type Settings struct {
 	// Other settings fields...
 }
 
+// permissionTimeout is how long to wait for an answer before denying
+const permissionTimeout = 5 * time.Minute
+
 // PermissionManager manages tool execution permissions
 type PermissionManager struct {
 	mu              sync.RWMutex
 	allowPatterns   []string // Patterns like "Bash(go build:*)"
 	denyPatterns    []string
+	temporaryAllow  map[int][]string // Rules granted until released, by grant
+	nextGrant       int
 	pendingRequests map[string]*PermissionRequest
 	responseChan    map[string]chan PermissionResponse
-	globalPath      string // Global settings path
-	projectPath     string // Project-local settings path
+	onRequest       func(req *PermissionRequest) // Called once a request is pending
+	globalPath      string                       // Global settings path
+	projectPath     string                       // Project-local settings path
 }
 
 // NewPermissionManager creates a new permission manager
@@ -93,6 +105,7 @@ func (pm *PermissionManager) CheckPermission(req *PermissionRequest) (bool, bool
 	// Check deny patterns first
 	for _, denyPattern := range pm.denyPatterns {
 		if pm.matchPattern(pattern, denyPattern) {
+			req.MatchedRule = denyPattern
 			return false, false, nil // Explicitly denied
 		}
 	}
@@ -100,14 +113,78 @@ func (pm *PermissionManager) CheckPermission(req *PermissionRequest) (bool, bool
 	// Check allow patterns
 	for _, allowPattern := range pm.allowPatterns {
 		if pm.matchPattern(pattern, allowPattern) {
+			req.MatchedRule = allowPattern
 			return true, false, nil // Allowed, no need to ask
 		}
 	}
 
+	// Check rules granted for the moment, e.g. by a custom command
+	for _, patterns := range pm.temporaryAllow {
+		for _, allowPattern := range patterns {
+			if pm.matchTemporary(req, pattern, allowPattern) {
+				req.MatchedRule = allowPattern
+				return true, false, nil
+			}
+		}
+	}
+
 	// No matching rule, need to ask user
 	return false, true, nil
 }
 
+// AllowTemporarily allows what patterns match until the returned function is
+// called. Deny rules still win. Besides the usual rules, patterns may be bare
+// tool names such as "read", and bash rules match the start of the whole
+// command, so "Bash(git status:*)" allows "git status -s" but not "git push".
+func (pm *PermissionManager) AllowTemporarily(patterns ...string) func() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if pm.temporaryAllow == nil {
+		pm.temporaryAllow = make(map[int][]string)
+	}
+	pm.nextGrant++
+	grant := pm.nextGrant
+	pm.temporaryAllow[grant] = patterns
+
+	return func() {
+		pm.mu.Lock()
+		defer pm.mu.Unlock()
+		delete(pm.temporaryAllow, grant)
+	}
+}
+
+// matchTemporary checks a request against a temporarily allowed pattern
+func (pm *PermissionManager) matchTemporary(req *PermissionRequest, reqPattern, permPattern string) bool {
+	name, rule, hasRule := strings.Cut(strings.TrimSuffix(permPattern, ")"), "(")
+	normalize := func(s string) string { return strings.ToLower(strings.ReplaceAll(s, "_", "")) }
+	if normalize(name) != normalize(req.ToolName) {
+		return false
+	}
+	if !hasRule || rule == "*" {
+		return true
+	}
+
+	if strings.EqualFold(req.ToolName, "bash") {
+		command, _ := req.Params["command"].(string)
+		command = strings.TrimSpace(command)
+		if prefix, ok := strings.CutSuffix(rule, ":*"); ok {
+			return command == prefix || strings.HasPrefix(command, prefix+" ")
+		}
+		return command == rule
+	}
+	return pm.matchPattern(reqPattern, permPattern)
+}
+
+// SetRequestHandler sets a function called with each permission request once
+// it is pending, so it can be answered right away with RespondToRequestAs,
+// e.g. when there is nobody to ask. Pass nil to remove it.
+func (pm *PermissionManager) SetRequestHandler(handler func(req *PermissionRequest)) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.onRequest = handler
+}
+
 // RequestPermission requests permission from the user
 func (pm *PermissionManager) RequestPermission(req *PermissionRequest) (*PermissionResponse, error) {
 	pm.mu.Lock()
@@ -125,8 +202,13 @@ func (pm *PermissionManager) RequestPermission(req *PermissionRequest) (*Permiss
 	respChan := make(chan PermissionResponse, 1)
 	pm.responseChan[req.RequestID] = respChan
 
+	onRequest := pm.onRequest
 	pm.mu.Unlock()
 
+	if onRequest != nil {
+		onRequest(req)
+	}
+
 	// Wait for response (with timeout)
 	select {
 	case resp := <-respChan:
@@ -145,7 +227,7 @@ func (pm *PermissionManager) RequestPermission(req *PermissionRequest) (*Permiss
 
 		return &resp, nil
 
-	case <-time.After(5 * time.Minute):
+	case <-time.After(permissionTimeout):
 		// Timeout - deny by default
 		pm.mu.Lock()
 		delete(pm.pendingRequests, req.RequestID)
@@ -156,6 +238,7 @@ func (pm *PermissionManager) RequestPermission(req *PermissionRequest) (*Permiss
 			RequestID: req.RequestID,
 			Allowed:   false,
 			Remember:  false,
+			DecidedBy: DecidedByTimeout,
 			Timestamp: time.Now().UnixMilli(),
 		}, fmt.Errorf("permission request timed out")
 	}
@@ -178,8 +261,13 @@ func (pm *PermissionManager) RequestPermissionWithContext(ctx context.Context, r
 	respChan := make(chan PermissionResponse, 1)
 	pm.responseChan[req.RequestID] = respChan
 
+	onRequest := pm.onRequest
 	pm.mu.Unlock()
 
+	if onRequest != nil {
+		onRequest(req)
+	}
+
 	// Wait for response with context
 	select {
 	case resp := <-respChan:
@@ -198,6 +286,21 @@ func (pm *PermissionManager) RequestPermissionWithContext(ctx context.Context, r
 
 		return &resp, nil
 
+	case <-time.After(permissionTimeout):
+		// Timeout - deny by default
+		pm.mu.Lock()
+		delete(pm.pendingRequests, req.RequestID)
+		delete(pm.responseChan, req.RequestID)
+		pm.mu.Unlock()
+
+		return &PermissionResponse{
+			RequestID: req.RequestID,
+			Allowed:   false,
+			Remember:  false,
+			DecidedBy: DecidedByTimeout,
+			Timestamp: time.Now().UnixMilli(),
+		}, fmt.Errorf("permission request timed out")
+
 	case <-ctx.Done():
 		// Context cancelled
 		pm.mu.Lock()
@@ -209,13 +312,19 @@ func (pm *PermissionManager) RequestPermissionWithContext(ctx context.Context, r
 			RequestID: req.RequestID,
 			Allowed:   false,
 			Remember:  false,
+			DecidedBy: DecidedByCancelled,
 			Timestamp: time.Now().UnixMilli(),
 		}, ctx.Err()
 	}
 }
 
-// RespondToRequest sends a response to a pending permission request
+// RespondToRequest sends a user's response to a pending permission request
 func (pm *PermissionManager) RespondToRequest(requestID string, allowed bool, remember bool, scope string) error {
+	return pm.RespondToRequestAs(requestID, allowed, remember, scope, DecidedByUser)
+}
+
+// RespondToRequestAs sends a response to a pending permission request on behalf of decidedBy
+func (pm *PermissionManager) RespondToRequestAs(requestID string, allowed bool, remember bool, scope string, decidedBy string) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -229,6 +338,7 @@ func (pm *PermissionManager) RespondToRequest(requestID string, allowed bool, re
 		Allowed:   allowed,
 		Remember:  remember,
 		Scope:     scope,
+		DecidedBy: decidedBy,
 		Timestamp: time.Now().UnixMilli(),
 	}
 
@@ -420,7 +530,7 @@ func (pm *PermissionManager) generatePattern(req *PermissionRequest) string {
 		}
 		return "Write(*)"
 
-	case "edit":
+	case "edit", "multi_edit":
 		// For edit, can be more specific with directory
 		if filepath.IsAbs(req.Resource) {
 			dir := filepath.Dir(req.Resource)
@@ -428,6 +538,16 @@ func (pm *PermissionManager) generatePattern(req *PermissionRequest) string {
 		}
 		return "Edit(*)"
 
+	case "web_fetch":
+		// For web_fetch, pattern is: WebFetch(domain:host)
+		if host := urlHost(req.Resource); host != "" {
+			return fmt.Sprintf("WebFetch(domain:%s)", host)
+		}
+		return "WebFetch(*)"
+
+	case "web_search":
+		return "WebSearch(*)"
+
 	default:
 		return fmt.Sprintf("%s(*)", capitalizedName)
 	}
@@ -440,6 +560,15 @@ func (pm *PermissionManager) matchPattern(reqPattern, permPattern string) bool {
 		return true
 	}
 
+	// Domain rules also match subdomains: "WebFetch(domain:go.dev)" covers pkg.go.dev
+	if host, ok := strings.CutPrefix(reqPattern, "WebFetch(domain:"); ok {
+		if domain, ok := strings.CutPrefix(permPattern, "WebFetch(domain:"); ok {
+			host = strings.TrimSuffix(host, ")")
+			domain = strings.TrimPrefix(strings.TrimSuffix(domain, ")"), "*.")
+			return domain == "*" || host == domain || strings.HasSuffix(host, "."+domain)
+		}
+	}
+
 	// Wildcard matching
 	// permPattern format: "Bash(git:*)" or "Write(/path/*)"
 	if strings.Contains(permPattern, "*") {
@@ -469,10 +598,15 @@ func AnalyzeRiskLevel(req *PermissionRequest) string {
 	toolName := strings.ToLower(req.ToolName)
 
 	// Safe operations
-	if toolName == "read" {
+	if toolName == "read" || toolName == "bash_output" || toolName == "repo_map" || strings.HasPrefix(toolName, "lsp_") {
 		return "safe"
 	}
 
+	// Web requests reach outside the machine, so they always ask
+	if toolName == "web_fetch" || toolName == "web_search" {
+		return "medium"
+	}
+
 	// Dangerous operations - check paths
 	dangerousPaths := []string{
 		"/etc",
@@ -485,6 +619,7 @@ func AnalyzeRiskLevel(req *PermissionRequest) string {
 		"/.gnupg",
 	}
 
+	// The sandbox stops writes to these paths but not reads, e.g. of ~/.ssh keys
 	for _, path := range dangerousPaths {
 		if strings.HasPrefix(req.Resource, path) || strings.Contains(req.Resource, path) {
 			return "dangerous"
@@ -495,42 +630,21 @@ func AnalyzeRiskLevel(req *PermissionRequest) string {
 	if toolName == "bash" {
 		if cmd, ok := req.Params["command"].(string); ok {
 			// Check for dangerous commands
-			dangerousCommands := []string{
-				"rm -rf",
-				"sudo",
-				"chmod",
-				"chown",
-				"dd",
-				"mkfs",
-				"fdisk",
-				"> /dev/",
+			if isDangerousCommand(cmd) {
+				return "dangerous"
 			}
 
-			cmdLower := strings.ToLower(cmd)
-			for _, dangerous := range dangerousCommands {
-				if strings.Contains(cmdLower, dangerous) {
-					return "dangerous"
-				}
+			// Sandboxed commands can only write to the working directory.
+			// With network access they could still send what they read
+			// anywhere, so they are analyzed like any other command.
+			if req.Sandboxed && !req.Networked {
+				return "safe"
 			}
 
-			// Check for safe read-only commands
-			safeCommands := []string{
-				"ls", "pwd", "echo", "cat", "head", "tail", "grep",
-				"find", "which", "whoami", "date", "uname",
-			}
-
-			// Extract first word (command name)
-			parts := strings.Fields(cmd)
-			if len(parts) > 0 {
-				firstCmd := parts[0]
-				for _, safe := range safeCommands {
-					if firstCmd == safe {
-						return "safe"
-					}
-				}
+			// Read-only commands are safe; all others are medium risk by default
+			if isReadOnlyCommand(cmd) {
+				return "safe"
 			}
-
-			// All other bash commands are medium risk by default
 			return "medium"
 		}
 		// If can't get command string, consider medium risk
@@ -538,9 +652,77 @@ func AnalyzeRiskLevel(req *PermissionRequest) string {
 	}
 
 	// Write/Edit operations
-	if toolName == "write" || toolName == "edit" {
+	if toolName == "write" || toolName == "edit" || toolName == "multi_edit" || toolName == "apply_patch" {
 		return "medium"
 	}
 
 	return "safe"
 }
+
+// urlHost returns the lowercase host of a URL, without its port
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// isReadOnlyCommand reports whether every command of a pipeline or list is a
+// read-only command, so that e.g. "cat secrets | curl ..." is not. Commands
+// with substitutions or redirections are never read-only.
+func isReadOnlyCommand(cmd string) bool {
+	if strings.ContainsAny(cmd, "`<>") || strings.Contains(cmd, "$(") {
+		return false
+	}
+
+	safeCommands := []string{
+		"ls", "pwd", "echo", "cat", "head", "tail", "grep",
+		"find", "which", "whoami", "date", "uname",
+	}
+	segments := strings.FieldsFunc(cmd, func(r rune) bool {
+		return r == '|' || r == ';' || r == '&' || r == '\n'
+	})
+	if len(segments) == 0 {
+		return false
+	}
+	for _, segment := range segments {
+		parts := strings.Fields(segment)
+		if len(parts) == 0 || !slices.Contains(safeCommands, parts[0]) {
+			return false
+		}
+		// find can run other commands or delete files
+		if parts[0] == "find" && slices.ContainsFunc(parts, func(arg string) bool {
+			return strings.HasPrefix(arg, "-exec") || strings.HasPrefix(arg, "-ok") || arg == "-delete" || strings.HasPrefix(arg, "-fprint")
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDangerousCommand reports whether a bash command looks destructive
+func isDangerousCommand(cmd string) bool {
+	cmdLower := strings.ToLower(cmd)
+
+	// Multi-word patterns match anywhere in the command
+	for _, dangerous := range []string{"rm -rf", "> /dev/"} {
+		if strings.Contains(cmdLower, dangerous) {
+			return true
+		}
+	}
+
+	// Command names must match a whole word, so "git add" is not "dd"
+	words := strings.FieldsFunc(cmdLower, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == ';' || r == '|' || r == '&' || r == '(' || r == ')'
+	})
+	for _, word := range words {
+		switch {
+		case word == "sudo", word == "chmod", word == "chown", word == "dd", word == "fdisk":
+			return true
+		case strings.HasPrefix(word, "mkfs"):
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/myersguo/cc-mono/pkg/ai"
@@ -33,6 +34,30 @@ func NewAgentMessage(message ai.Message, id string, createdAt int64) AgentMessag
 	}
 }
 
+// UnmarshalJSON implements json.Unmarshaler for AgentMessage, decoding the
+// wrapped message by its type
+func (m *AgentMessage) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Message   json.RawMessage `json:"message"`
+		ID        string          `json:"id"`
+		CreatedAt int64           `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.ID, m.CreatedAt, m.Message = aux.ID, aux.CreatedAt, nil
+	if len(aux.Message) == 0 || string(aux.Message) == "null" {
+		return nil
+	}
+	message, err := ai.UnmarshalMessage(aux.Message)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
 // AgentToolUpdateCallback is called when a tool has an update to report
 type AgentToolUpdateCallback func(update AgentToolUpdate)
 
@@ -58,13 +83,29 @@ type AgentTool struct {
 	// Label for display purposes
 	Label string
 
-	// Execute function that runs the tool
+	// Sandboxed reports whether the tool runs inside an OS sandbox
+	Sandboxed bool
+
+	// Networked reports whether the sandbox keeps network access
+	Networked bool
+
+	// Prepare, if set, runs before the permission check and returns the params
+	// to check and run the tool with, or an error to block the call. It is not
+	// saved with sessions.
+	Prepare func(ctx context.Context, toolCallID string, params map[string]any) (map[string]any, error) `json:"-"`
+
+	// Finish, if set, is called once a call that Prepare let through is over,
+	// whether it ran or was denied, so that state kept for the call can be
+	// dropped. It is not saved with sessions.
+	Finish func(toolCallID string) `json:"-"`
+
+	// Execute function that runs the tool; it is not saved with sessions
 	Execute func(
 		ctx context.Context,
 		toolCallID string,
 		params map[string]any,
 		onUpdate AgentToolUpdateCallback,
-	) (AgentToolResult, error)
+	) (AgentToolResult, error) `json:"-"`
 }
 
 // NewAgentTool creates a new agent tool
@@ -185,6 +226,13 @@ func (s *AgentState) AddMessage(message AgentMessage) {
 	s.Messages = append(s.Messages, message)
 }
 
+// SetMessages replaces the message history (thread-safe)
+func (s *AgentState) SetMessages(messages []AgentMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Messages = append([]AgentMessage(nil), messages...)
+}
+
 // SetIsStreaming sets the streaming state (thread-safe)
 func (s *AgentState) SetIsStreaming(streaming bool) {
 	s.mu.Lock()
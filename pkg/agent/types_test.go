@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -237,3 +238,32 @@ func TestAgentTool(t *testing.T) {
 		t.Error("Expected result to not be an error")
 	}
 }
+
+func TestAgentStateJSON(t *testing.T) {
+	execute := func(ctx context.Context, id string, params map[string]any, onUpdate AgentToolUpdateCallback) (AgentToolResult, error) {
+		return AgentToolResult{}, nil
+	}
+	tools := []AgentTool{NewAgentTool(ai.NewTool("test_tool", "A test tool", map[string]any{"type": "object"}), "Test Tool", execute)}
+	state := NewAgentState("Test prompt", ai.Model{ID: "test-model"}, tools)
+	state.AddMessage(NewAgentMessage(ai.NewUserTextMessage("hello"), "user-1", 1))
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to marshal state with tools: %v", err)
+	}
+
+	var loaded AgentState
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Failed to unmarshal state: %v", err)
+	}
+	messages := loaded.GetMessages()
+	if len(messages) != 1 || messages[0].ID != "user-1" {
+		t.Fatalf("Expected message user-1, got %+v", messages)
+	}
+	if _, ok := messages[0].Message.(ai.UserMessage); !ok {
+		t.Errorf("Expected a user message, got %T", messages[0].Message)
+	}
+	if loaded.StreamMessage.Message != nil {
+		t.Errorf("Expected no stream message, got %T", loaded.StreamMessage.Message)
+	}
+}
@@ -0,0 +1,39 @@
+package agent
+
+import "github.com/myersguo/cc-mono/pkg/ai"
+
+// UsageTotals sums the usage of the assistant replies in a conversation
+type UsageTotals struct {
+	Replies      int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64  // USD
+	Last         ai.Usage // Usage of the last reply, whose request held the whole conversation
+}
+
+// SumUsage sums the usage and cost of the assistant replies in messages. Each
+// reply is priced with the model that wrote it: price looks that model up by
+// ID, and replies it doesn't know, or all replies if it is nil, are priced
+// with current.
+func SumUsage(messages []AgentMessage, current ai.Model, price func(modelID string) (ai.Model, bool)) UsageTotals {
+	var totals UsageTotals
+	for _, msg := range messages {
+		assistant, ok := msg.Message.(ai.AssistantMessage)
+		if !ok {
+			continue
+		}
+		totals.Replies++
+		totals.InputTokens += assistant.Usage.InputTokens
+		totals.OutputTokens += assistant.Usage.OutputTokens
+		totals.Last = assistant.Usage
+
+		model := current
+		if price != nil && assistant.Model != "" && assistant.Model != current.ID {
+			if m, ok := price(assistant.Model); ok {
+				model = m
+			}
+		}
+		totals.Cost += model.CalculateCost(assistant.Usage)
+	}
+	return totals
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+func TestSumUsage(t *testing.T) {
+	current := ai.Model{ID: "small", InputCostPer1M: 1, OutputCostPer1M: 2}
+	large := ai.Model{ID: "large", InputCostPer1M: 10, OutputCostPer1M: 20}
+	price := func(id string) (ai.Model, bool) {
+		if id == "large" {
+			return large, true
+		}
+		return ai.Model{}, false
+	}
+	reply := func(model string, input, output int) AgentMessage {
+		msg := ai.NewAssistantMessage(nil, "", "", model, ai.Usage{InputTokens: input, OutputTokens: output}, ai.StopReasonEndTurn)
+		return NewAgentMessage(msg, "", 0)
+	}
+	messages := []AgentMessage{
+		NewAgentMessage(ai.NewUserTextMessage("Hello"), "", 0),
+		reply("small", 1000000, 0),
+		reply("large", 1000000, 500000),
+		reply("unknown", 0, 1000000),
+	}
+
+	tests := []struct {
+		name  string
+		price func(string) (ai.Model, bool)
+		cost  float64
+	}{
+		{"PricedByModel", price, 1 + 10 + 10 + 2},
+		{"CurrentModelOnly", nil, 1 + 1 + 1 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals := SumUsage(messages, current, tt.price)
+			if totals.Replies != 3 || totals.InputTokens != 2000000 || totals.OutputTokens != 1500000 {
+				t.Errorf("Unexpected totals: %+v", totals)
+			}
+			if totals.Last.OutputTokens != 1000000 {
+				t.Errorf("Expected the last reply's usage, got %+v", totals.Last)
+			}
+			if math.Abs(totals.Cost-tt.cost) > 1e-9 {
+				t.Errorf("Expected cost %.2f, got %.2f", tt.cost, totals.Cost)
+			}
+		})
+	}
+
+	if totals := SumUsage(nil, current, price); totals != (UsageTotals{}) {
+		t.Errorf("Expected no usage, got %+v", totals)
+	}
+}
@@ -24,13 +24,30 @@ func convertContextToRequest(
 		})
 	}
 
-	// Convert context messages
+	// Convert context messages. Tool messages carry only text, so images that
+	// tools return follow them in a user message; it goes after the whole run
+	// of tool messages, which must directly follow the assistant's tool calls.
+	var toolImages []ContentPart
 	for _, msg := range context.Messages {
+		if len(toolImages) > 0 && msg.GetType() != ai.MessageTypeToolResult {
+			messages = append(messages, toolImagesMessage(toolImages))
+			toolImages = nil
+		}
 		converted, err := convertMessage(msg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert message: %w", err)
 		}
 		messages = append(messages, converted...)
+		if result, ok := msg.(ai.ToolResultMessage); ok {
+			for _, content := range result.Content {
+				if image, ok := content.(ai.ImageContent); ok {
+					toolImages = append(toolImages, convertImage(image))
+				}
+			}
+		}
+	}
+	if len(toolImages) > 0 {
+		messages = append(messages, toolImagesMessage(toolImages))
 	}
 
 	// Build request
@@ -115,17 +132,7 @@ func convertUserMessage(msg ai.UserMessage) ([]ChatMessage, error) {
 				Text: c.Text,
 			})
 		case ai.ImageContent:
-			url := c.Source.URL
-			if c.Source.Type == "base64" {
-				url = fmt.Sprintf("data:%s;base64,%s", c.Source.MediaType, c.Source.Data)
-			}
-			parts = append(parts, ContentPart{
-				Type: "image_url",
-				ImageURL: &ImageURL{
-					URL:    url,
-					Detail: "auto",
-				},
-			})
+			parts = append(parts, convertImage(c))
 		}
 	}
 
@@ -208,6 +215,30 @@ func convertToolResultMessage(msg ai.ToolResultMessage) ([]ChatMessage, error) {
 	}}, nil
 }
 
+// convertImage converts an image to an image_url part, inlining base64 data as a data URL
+func convertImage(img ai.ImageContent) ContentPart {
+	url := img.Source.URL
+	if img.Source.Type == "base64" {
+		url = fmt.Sprintf("data:%s;base64,%s", img.Source.MediaType, img.Source.Data)
+	}
+	return ContentPart{
+		Type: "image_url",
+		ImageURL: &ImageURL{
+			URL:    url,
+			Detail: "auto",
+		},
+	}
+}
+
+// toolImagesMessage returns a user message with the images that tools returned
+func toolImagesMessage(images []ContentPart) ChatMessage {
+	parts := append([]ContentPart{{Type: "text", Text: "Images returned by the tool calls above:"}}, images...)
+	return ChatMessage{
+		Role:    "user",
+		Content: parts,
+	}
+}
+
 // convertChunkToEvent converts OpenAI chunk to our event
 func convertChunkToEvent(chunk ChatCompletionChunk) ([]ai.AssistantMessageEvent, error) {
 	events := make([]ai.AssistantMessageEvent, 0)
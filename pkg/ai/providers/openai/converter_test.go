@@ -246,3 +246,52 @@ func TestConvertContextToRequest(t *testing.T) {
 		t.Errorf("Expected tool name 'read_file', got '%s'", req.Tools[0].Function.Name)
 	}
 }
+
+func TestConvertContextToRequest_Images(t *testing.T) {
+	model := ai.Model{ID: "gpt-4o", Provider: "openai", SupportsVision: true}
+	context := ai.NewContext("", []ai.Message{
+		ai.NewUserMessage([]ai.Content{
+			ai.NewTextContent("What changed in these screenshots?"),
+			ai.NewImageContentFromBase64("iVBORw0K", "image/png"),
+		}),
+		ai.NewAssistantMessage([]ai.Content{
+			ai.NewToolCall("call-1", "read", map[string]any{"file_path": "before.png"}),
+			ai.NewToolCall("call-2", "read", map[string]any{"file_path": "notes.txt"}),
+		}, "openai", "openai", "gpt-4o", ai.Usage{}, ai.StopReasonToolUse),
+		ai.NewToolResultMessage("call-1", "read", []ai.Content{
+			ai.NewTextContent("Image: before.png (8 bytes)"),
+			ai.NewImageContentFromBase64("/9j/4AAQ", "image/jpeg"),
+		}, false),
+		ai.NewToolResultMessage("call-2", "read", []ai.Content{ai.NewTextContent("notes")}, false),
+	})
+
+	req, err := convertContextToRequest(model, context, nil)
+	if err != nil {
+		t.Fatalf("Failed to convert context: %v", err)
+	}
+
+	var roles []string
+	for _, msg := range req.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if len(roles) != 5 || roles[2] != "tool" || roles[3] != "tool" || roles[4] != "user" {
+		t.Fatalf("Expected user, assistant, tool, tool, user messages, got %v", roles)
+	}
+
+	parts, ok := req.Messages[0].Content.([]ContentPart)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Expected 2 content parts, got %v", req.Messages[0].Content)
+	}
+	if parts[1].ImageURL == nil || parts[1].ImageURL.URL != "data:image/png;base64,iVBORw0K" {
+		t.Errorf("Expected image as data URL, got %+v", parts[1].ImageURL)
+	}
+
+	// The tool's image follows the tool messages
+	parts, ok = req.Messages[4].Content.([]ContentPart)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Expected 2 content parts, got %v", req.Messages[4].Content)
+	}
+	if parts[1].ImageURL == nil || parts[1].ImageURL.URL != "data:image/jpeg;base64,/9j/4AAQ" {
+		t.Errorf("Expected tool image as data URL, got %+v", parts[1].ImageURL)
+	}
+}
@@ -0,0 +1,81 @@
+package codingagent
+
+import (
+	"fmt"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+)
+
+// Branch continues the conversation of session in a new branch session. The
+// branch takes over the live agent state and session keeps a copy of its history.
+func (sm *SessionManager) Branch(session *Session, title string) (*Session, error) {
+	if title == "" {
+		title = fmt.Sprintf("%s (fork)", session.Metadata.Title)
+	}
+
+	branch, err := sm.Fork(session.Metadata.ID, len(session.State.GetMessages()), title)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	branch.State.SetThinkingLevel(session.State.GetThinkingLevel())
+	branch.State, session.State = session.State, branch.State
+	sm.currentSession = branch
+	sm.reset()
+
+	return branch, nil
+}
+
+// Clear starts a new, empty session on the live agent state of session.
+// session keeps a copy of its history.
+func (sm *SessionManager) Clear(session *Session, title string) *Session {
+	live := session.State
+
+	sm.mu.Lock()
+	session.State = copyState(live)
+	sm.mu.Unlock()
+
+	live.SetMessages([]agent.AgentMessage{})
+	sm.reset()
+	return sm.NewSession(title, live)
+}
+
+// Switch continues the session with the given ID on the live agent state of
+// session, which keeps a copy of its history. The live state keeps its model
+// and tools and takes the messages and thinking level of the other session.
+func (sm *SessionManager) Switch(session *Session, id string) (*Session, error) {
+	if id == session.Metadata.ID {
+		return session, nil
+	}
+	target, err := sm.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if target.State == nil {
+		return nil, fmt.Errorf("session %s has no state", id)
+	}
+	live := session.State
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session.State = copyState(live)
+	live.SetMessages(target.State.GetMessages())
+	live.SetThinkingLevel(target.State.GetThinkingLevel())
+	target.State = live
+	sm.currentSession = target
+	sm.reset()
+
+	return target, nil
+}
+
+// copyState returns a detached copy of state
+func copyState(state *agent.AgentState) *agent.AgentState {
+	copied := agent.NewAgentState(state.GetSystemPrompt(), state.GetModel(), state.GetTools())
+	copied.SetThinkingLevel(state.GetThinkingLevel())
+	copied.SetMessages(state.GetMessages())
+	return copied
+}
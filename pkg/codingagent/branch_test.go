@@ -0,0 +1,118 @@
+package codingagent
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBranchSession(t *testing.T) (*SessionManager, *Session) {
+	t.Helper()
+
+	sm, err := NewSessionManager(filepath.Join(t.TempDir(), "sessions"))
+	require.NoError(t, err)
+
+	state := agent.NewAgentState("system", ai.Model{}, []agent.AgentTool{})
+	state.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("hello"), "user-1", 0))
+	return sm, sm.NewSession("Test Session", state)
+}
+
+func TestSessionManager_Branch(t *testing.T) {
+	sm, session := newBranchSession(t)
+	live := session.State
+
+	branch, err := sm.Branch(session, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Session (fork)", branch.Metadata.Title)
+	assert.Equal(t, session.Metadata.ID, branch.Metadata.ParentID)
+	assert.Equal(t, 1, branch.Metadata.BranchPoint)
+	assert.Same(t, branch, sm.GetCurrent())
+
+	// The branch continues on the live state; the original keeps its history
+	assert.Same(t, live, branch.State)
+	live.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("more"), "user-2", 0))
+	assert.Len(t, session.State.GetMessages(), 1)
+	assert.Len(t, branch.State.GetMessages(), 2)
+
+	sessions, err := sm.List()
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+}
+
+func TestSessionManager_Clear(t *testing.T) {
+	sm, session := newBranchSession(t)
+	live := session.State
+
+	cleared := sm.Clear(session, "New Session")
+	assert.NotEqual(t, session.Metadata.ID, cleared.Metadata.ID)
+	assert.Same(t, live, cleared.State)
+	assert.Empty(t, live.GetMessages())
+	assert.Len(t, session.State.GetMessages(), 1)
+	assert.Same(t, cleared, sm.GetCurrent())
+}
+
+func TestSessionManager_Switch(t *testing.T) {
+	sm, session := newBranchSession(t)
+	live := session.State
+
+	// A saved session from an earlier run
+	saved := agent.NewAgentState("other system", ai.Model{ID: "other"}, nil)
+	saved.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("earlier"), "user-a", 0))
+	saved.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("question"), "user-b", 0))
+	earlier := &Session{Metadata: SessionMetadata{ID: "earlier", Title: "Earlier"}, State: saved}
+	require.NoError(t, sm.Save(earlier))
+
+	reopened, err := NewSessionManager(sm.sessionsDir)
+	require.NoError(t, err)
+	reopened.SetCurrent(session)
+
+	switched, err := reopened.Switch(session, "earlier")
+	require.NoError(t, err)
+	assert.Equal(t, "Earlier", switched.Metadata.Title)
+	assert.Same(t, live, switched.State)
+	assert.Same(t, switched, reopened.GetCurrent())
+	assert.Len(t, live.GetMessages(), 2)
+	assert.Equal(t, "system", live.GetSystemPrompt(), "the live state keeps its configuration")
+	assert.Len(t, session.State.GetMessages(), 1, "the previous session keeps its history")
+
+	_, err = reopened.Switch(switched, "missing")
+	assert.Error(t, err)
+}
+
+func TestSessionManager_OnReset(t *testing.T) {
+	sm, session := newBranchSession(t)
+	resets := 0
+	sm.OnReset(func() { resets++ })
+
+	saved := &Session{Metadata: SessionMetadata{ID: "saved", Title: "Saved"}, State: copyState(session.State)}
+	require.NoError(t, sm.Save(saved))
+
+	branch, err := sm.Branch(session, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, resets)
+
+	cleared := sm.Clear(branch, "New Session")
+	assert.Equal(t, 2, resets)
+
+	switched, err := sm.Switch(cleared, "saved")
+	require.NoError(t, err)
+	assert.Equal(t, 3, resets)
+
+	// Switching to the current session changes nothing
+	_, err = sm.Switch(switched, "saved")
+	require.NoError(t, err)
+	assert.Equal(t, 3, resets)
+
+	_, err = sm.Rewind(switched, nil, "user-1", RewindConversation, false)
+	require.NoError(t, err)
+	assert.Equal(t, 4, resets)
+
+	// A failed rewind leaves the conversation as it was
+	_, err = sm.Rewind(switched, nil, "missing", RewindConversation, false)
+	assert.Error(t, err)
+	assert.Equal(t, 4, resets)
+}
@@ -0,0 +1,347 @@
+// Package checkpoint snapshots files before the agent changes them so edits can be undone.
+//
+// A Store holds one checkpoint per user message. Tools call Snapshot (or the
+// context helper) before modifying a file; the first snapshot of a path within a
+// checkpoint records its prior content. Restore walks the checkpoints back to a
+// message and puts every touched file back the way it was.
+package checkpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// contextKey is the context value key tools use to find the store
+const contextKey = "checkpoint_store"
+
+// indexFile lists the checkpoints in a store directory
+const indexFile = "index.json"
+
+// Checkpoint records the files changed while handling one user message
+type Checkpoint struct {
+	MessageID string      `json:"message_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Files     []FileState `json:"files"`
+}
+
+// FileState is the content of a file before its first change in a checkpoint
+type FileState struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Blob    string      `json:"blob,omitempty"` // SHA-256 of the content, stored under blobs/
+	Mode    fs.FileMode `json:"mode,omitempty"`
+}
+
+// Store keeps the checkpoints of a session on disk
+type Store struct {
+	mu          sync.Mutex
+	dir         string
+	checkpoints []*Checkpoint
+}
+
+// Dir returns the checkpoint directory for a session
+func Dir(globalConfigDir, sessionID string) string {
+	return filepath.Join(globalConfigDir, "checkpoints", sessionID)
+}
+
+// Open opens the store in dir, loading existing checkpoints
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs"), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	store := &Store{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read checkpoint index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &store.checkpoints); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint index: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Dir returns the directory the store lives in
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Begin starts the checkpoint for a user message. Later snapshots belong to it.
+func (s *Store) Begin(messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current := s.current(); current != nil && current.MessageID == messageID {
+		return nil
+	}
+
+	s.checkpoints = append(s.checkpoints, &Checkpoint{
+		MessageID: messageID,
+		CreatedAt: time.Now(),
+	})
+	return s.saveLocked()
+}
+
+// Snapshot records the current content of path in the current checkpoint, unless it
+// was already recorded there. It does nothing before the first Begin.
+func (s *Store) Snapshot(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.current()
+	if current == nil {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	for _, file := range current.Files {
+		if file.Path == absPath {
+			return nil
+		}
+	}
+
+	state := FileState{Path: absPath}
+	info, err := os.Stat(absPath)
+	switch {
+	case os.IsNotExist(err):
+		// Restoring removes the file
+	case err != nil:
+		return fmt.Errorf("failed to stat %s: %w", absPath, err)
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory", absPath)
+	default:
+		data, err := os.ReadFile(absPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", absPath, err)
+		}
+		blob, err := s.writeBlob(data)
+		if err != nil {
+			return err
+		}
+		state.Existed = true
+		state.Blob = blob
+		state.Mode = info.Mode().Perm()
+	}
+
+	current.Files = append(current.Files, state)
+	return s.saveLocked()
+}
+
+// List returns the checkpoints, oldest first
+func (s *Store) List() []Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints := make([]Checkpoint, len(s.checkpoints))
+	for i, checkpoint := range s.checkpoints {
+		checkpoints[i] = *checkpoint
+	}
+	return checkpoints
+}
+
+// Get returns the checkpoint for a user message
+func (s *Store) Get(messageID string) (Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.indexOf(messageID); i >= 0 {
+		return *s.checkpoints[i], true
+	}
+	return Checkpoint{}, false
+}
+
+// Restore puts every file changed since messageID's checkpoint began back to its
+// earlier content and drops the undone checkpoints. It returns the restored paths.
+func (s *Store) Restore(messageID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := s.indexOf(messageID)
+	if start < 0 {
+		return nil, fmt.Errorf("no checkpoint for message %s", messageID)
+	}
+
+	// Walk back from the newest checkpoint so the oldest snapshot of each path wins
+	restored := make(map[string]FileState)
+	for i := len(s.checkpoints) - 1; i >= start; i-- {
+		for _, file := range s.checkpoints[i].Files {
+			restored[file.Path] = file
+		}
+	}
+
+	paths := make([]string, 0, len(restored))
+	for path, file := range restored {
+		if err := s.restoreFile(file); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	s.checkpoints = s.checkpoints[:start]
+	if err := s.saveLocked(); err != nil {
+		return paths, err
+	}
+
+	return paths, nil
+}
+
+// Fork copies the checkpoints before messageID into a new store in dir, so a
+// branched session can still rewind past the branch point. With a messageID
+// that has no checkpoint, such as "", every checkpoint is copied.
+func (s *Store) Fork(dir, messageID string) (*Store, error) {
+	s.mu.Lock()
+	end := s.indexOf(messageID)
+	if end < 0 {
+		end = len(s.checkpoints)
+	}
+	checkpoints := make([]*Checkpoint, end)
+	for i, checkpoint := range s.checkpoints[:end] {
+		copied := *checkpoint
+		copied.Files = append([]FileState(nil), checkpoint.Files...)
+		checkpoints[i] = &copied
+	}
+	s.mu.Unlock()
+
+	forked, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, checkpoint := range checkpoints {
+		for _, file := range checkpoint.Files {
+			if !file.Existed {
+				continue
+			}
+			data, err := os.ReadFile(s.blobPath(file.Blob))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read checkpoint blob: %w", err)
+			}
+			if _, err := forked.writeBlob(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	forked.mu.Lock()
+	defer forked.mu.Unlock()
+	forked.checkpoints = checkpoints
+	if err := forked.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	return forked, nil
+}
+
+// WithStore returns a context that makes Snapshot record into store
+func WithStore(ctx context.Context, store *Store) context.Context {
+	if store == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey, store)
+}
+
+// FromContext returns the store attached to ctx, if any
+func FromContext(ctx context.Context) *Store {
+	store, _ := ctx.Value(contextKey).(*Store)
+	return store
+}
+
+// Snapshot records path in the store attached to ctx. It does nothing without a store.
+func Snapshot(ctx context.Context, path string) error {
+	if store := FromContext(ctx); store != nil {
+		return store.Snapshot(path)
+	}
+	return nil
+}
+
+// current returns the newest checkpoint
+func (s *Store) current() *Checkpoint {
+	if len(s.checkpoints) == 0 {
+		return nil
+	}
+	return s.checkpoints[len(s.checkpoints)-1]
+}
+
+// indexOf returns the position of messageID's checkpoint, or -1
+func (s *Store) indexOf(messageID string) int {
+	for i, checkpoint := range s.checkpoints {
+		if checkpoint.MessageID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// restoreFile writes back (or removes) a snapshotted file
+func (s *Store) restoreFile(file FileState) error {
+	if !file.Existed {
+		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", file.Path, err)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(s.blobPath(file.Blob))
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint of %s: %w", file.Path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+	}
+
+	mode := file.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.WriteFile(file.Path, data, mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", file.Path, err)
+	}
+	return nil
+}
+
+// writeBlob stores data by content hash and returns the hash
+func (s *Store) writeBlob(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	blob := hex.EncodeToString(sum[:])
+
+	path := s.blobPath(blob)
+	if _, err := os.Stat(path); err == nil {
+		return blob, nil
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write checkpoint blob: %w", err)
+	}
+	return blob, nil
+}
+
+// blobPath returns the path of a blob
+func (s *Store) blobPath(blob string) string {
+	return filepath.Join(s.dir, "blobs", blob)
+}
+
+// saveLocked writes the index (caller must hold the lock)
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, indexFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package checkpoint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestStore(t *testing.T) {
+	t.Run("SnapshotBeforeBegin", func(t *testing.T) {
+		store, err := Open(t.TempDir())
+		require.NoError(t, err)
+
+		require.NoError(t, store.Snapshot(filepath.Join(t.TempDir(), "a.txt")))
+		assert.Empty(t, store.List())
+	})
+
+	t.Run("RestoreAcrossCheckpoints", func(t *testing.T) {
+		workDir := t.TempDir()
+		store, err := Open(t.TempDir())
+		require.NoError(t, err)
+
+		mainFile := filepath.Join(workDir, "main.go")
+		newFile := filepath.Join(workDir, "pkg", "new.go")
+		writeFile(t, mainFile, "v1")
+
+		// First message edits main.go twice; only the first snapshot counts
+		require.NoError(t, store.Begin("msg-1"))
+		require.NoError(t, store.Snapshot(mainFile))
+		writeFile(t, mainFile, "v2")
+		require.NoError(t, store.Snapshot(mainFile))
+		writeFile(t, mainFile, "v3")
+
+		// Second message edits main.go again and creates a new file
+		require.NoError(t, store.Begin("msg-2"))
+		require.NoError(t, store.Snapshot(mainFile))
+		writeFile(t, mainFile, "v4")
+		require.NoError(t, store.Snapshot(newFile))
+		require.NoError(t, os.MkdirAll(filepath.Dir(newFile), 0755))
+		writeFile(t, newFile, "new")
+
+		checkpoints := store.List()
+		require.Len(t, checkpoints, 2)
+		assert.Len(t, checkpoints[0].Files, 1)
+		assert.Len(t, checkpoints[1].Files, 2)
+
+		// Rewinding the second message keeps the first message's changes
+		restored, err := store.Restore("msg-2")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{mainFile, newFile}, restored)
+		assert.Equal(t, "v3", readFile(t, mainFile))
+		assert.NoFileExists(t, newFile)
+		assert.Len(t, store.List(), 1)
+
+		// Rewinding the first message goes back to the original content
+		_, err = store.Restore("msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", readFile(t, mainFile))
+		assert.Empty(t, store.List())
+	})
+
+	t.Run("UnknownMessage", func(t *testing.T) {
+		store, err := Open(t.TempDir())
+		require.NoError(t, err)
+
+		_, err = store.Restore("missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("PersistsAcrossOpen", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(t.TempDir(), "a.txt")
+		writeFile(t, file, "before")
+
+		store, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, store.Begin("msg-1"))
+		require.NoError(t, store.Snapshot(file))
+		writeFile(t, file, "after")
+
+		reopened, err := Open(dir)
+		require.NoError(t, err)
+		_, err = reopened.Restore("msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, "before", readFile(t, file))
+	})
+
+	t.Run("Fork", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "a.txt")
+		writeFile(t, file, "v1")
+
+		store, err := Open(t.TempDir())
+		require.NoError(t, err)
+		require.NoError(t, store.Begin("msg-1"))
+		require.NoError(t, store.Snapshot(file))
+		writeFile(t, file, "v2")
+		require.NoError(t, store.Begin("msg-2"))
+		require.NoError(t, store.Snapshot(file))
+		writeFile(t, file, "v3")
+
+		forked, err := store.Fork(t.TempDir(), "msg-2")
+		require.NoError(t, err)
+		require.Len(t, forked.List(), 1)
+
+		// The fork can rewind past the branch point on its own blobs
+		_, err = forked.Restore("msg-1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", readFile(t, file))
+
+		// The original store is untouched
+		assert.Len(t, store.List(), 2)
+	})
+}
+
+func TestContext(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	writeFile(t, file, "content")
+
+	// Without a store, snapshots are a no-op
+	require.NoError(t, Snapshot(context.Background(), file))
+
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, store.Begin("msg-1"))
+
+	ctx := WithStore(context.Background(), store)
+	assert.Same(t, store, FromContext(ctx))
+	require.NoError(t, Snapshot(ctx, file))
+
+	cp, ok := store.Get("msg-1")
+	require.True(t, ok)
+	require.Len(t, cp.Files, 1)
+	assert.True(t, cp.Files[0].Existed)
+}
@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/compaction"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
+	"github.com/myersguo/cc-mono/pkg/shared"
+)
+
+// Env is what the built-in commands act on
+type Env struct {
+	Agent    *agent.Agent
+	Sessions *codingagent.SessionManager
+	Models   *codingagent.ModelRegistry
+
+	// WorkingDir is where custom commands run shell commands and find files
+	WorkingDir string
+
+	// Sandbox runs the shell commands of custom commands when set, as it
+	// runs the commands of the bash tool
+	Sandbox *sandbox.Sandbox
+
+	// ResolveModel returns a model and a provider to run it. Without it /model
+	// only switches between models of the current provider.
+	ResolveModel func(id string) (ai.Model, ai.Provider, error)
+}
+
+// RegisterBuiltins adds /help, /clear, /model, /compact, /cost, /sessions,
+// /fork and /export
+func (r *Registry) RegisterBuiltins(env Env) error {
+	builtins := []shared.Command{
+		{
+			Name:        "help",
+			Description: "List commands, or show how to use one",
+			Usage:       "[command]",
+			Run:         r.help,
+		},
+		{
+			Name:        "clear",
+			Description: "Clear the conversation and start a new session",
+			Run:         env.clear,
+		},
+		{
+			Name:        "model",
+			Description: "Show the available models, or switch to another one",
+			Usage:       "[model-id]",
+			Run:         env.model,
+		},
+		{
+			Name:        "compact",
+			Description: "Summarize older messages to free up context",
+			Run:         env.compact,
+		},
+		{
+			Name:        "cost",
+			Description: "Show the tokens used and the cost of this session",
+			Run:         env.cost,
+		},
+		{
+			Name:        "sessions",
+			Description: "List saved sessions and the sessions of this run",
+			Run:         env.sessions,
+		},
+		{
+			Name:        "fork",
+			Description: "Continue the conversation in a new branch session",
+			Usage:       "[title]",
+			Run:         env.fork,
+		},
+		{
+			Name:        "export",
+			Description: "Export the session to an HTML file",
+			Usage:       "[path]",
+			Run:         env.export,
+		},
+	}
+
+	for _, cmd := range builtins {
+		if err := r.Register(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// help lists the commands or describes one
+func (r *Registry) help(ctx context.Context, args []string) (shared.CommandResult, error) {
+	if len(args) > 0 {
+		name := strings.TrimPrefix(args[0], "/")
+		cmd, ok := r.Get(name)
+		if !ok {
+			return shared.CommandResult{}, fmt.Errorf("unknown command /%s", name)
+		}
+		return shared.CommandResult{Output: fmt.Sprintf("%s\n  %s", Synopsis(cmd), cmd.Description)}, nil
+	}
+
+	cmds := r.List()
+	width := 0
+	for _, cmd := range cmds {
+		width = max(width, len(Synopsis(cmd)))
+	}
+
+	var b strings.Builder
+	b.WriteString("Commands:")
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "\n  %-*s  %s", width, Synopsis(cmd), cmd.Description)
+	}
+	return shared.CommandResult{Output: b.String()}, nil
+}
+
+// Synopsis returns the command name with its usage, e.g. "/model [model-id]"
+func Synopsis(cmd shared.Command) string {
+	if cmd.Usage == "" {
+		return "/" + cmd.Name
+	}
+	return "/" + cmd.Name + " " + cmd.Usage
+}
+
+// idle returns an error if the agent is running
+func (env Env) idle(action string) error {
+	if env.Agent.GetState().GetIsStreaming() {
+		return fmt.Errorf("cannot %s while the agent is running", action)
+	}
+	return nil
+}
+
+// current returns the current session
+func (env Env) current() (*codingagent.Session, error) {
+	if env.Sessions == nil || env.Sessions.GetCurrent() == nil {
+		return nil, fmt.Errorf("no active session")
+	}
+	return env.Sessions.GetCurrent(), nil
+}
+
+func (env Env) clear(ctx context.Context, args []string) (shared.CommandResult, error) {
+	if err := env.idle("clear the conversation"); err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	session, err := env.current()
+	if err != nil {
+		env.Agent.GetState().SetMessages([]agent.AgentMessage{})
+		return shared.CommandResult{Output: "Cleared the conversation."}, nil
+	}
+
+	cleared := env.Sessions.Clear(session, fmt.Sprintf("Chat %s", time.Now().Format("2006-01-02 15:04")))
+	return shared.CommandResult{Output: fmt.Sprintf("Cleared the conversation. Started session %s.", cleared.Metadata.ID)}, nil
+}
+
+func (env Env) model(ctx context.Context, args []string) (shared.CommandResult, error) {
+	current := env.Agent.GetState().GetModel()
+
+	if len(args) == 0 {
+		if env.Models == nil {
+			return shared.CommandResult{Output: fmt.Sprintf("Model: %s (%s)", current.Name, current.ID)}, nil
+		}
+		var b strings.Builder
+		b.WriteString("Models:")
+		for _, m := range env.Models.List() {
+			marker := " "
+			if m.ID == current.ID {
+				marker = "*"
+			}
+			fmt.Fprintf(&b, "\n%s %-32s %s (%s)", marker, m.ID, m.Name, m.Provider)
+		}
+		return shared.CommandResult{Output: b.String()}, nil
+	}
+
+	if err := env.idle("switch models"); err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	model, err := env.switchModel(args[0])
+	if err != nil {
+		return shared.CommandResult{}, fmt.Errorf("failed to switch model: %w", err)
+	}
+	return shared.CommandResult{Output: fmt.Sprintf("Switched to %s (%s).", model.Name, model.ID)}, nil
+}
+
+// switchModel makes the agent use the model with the given ID
+func (env Env) switchModel(id string) (ai.Model, error) {
+	current := env.Agent.GetState().GetModel()
+
+	var (
+		model    ai.Model
+		provider ai.Provider
+		err      error
+	)
+	switch {
+	case env.ResolveModel != nil:
+		model, provider, err = env.ResolveModel(id)
+	case env.Models != nil:
+		model, err = env.Models.ToAIModel(id)
+		if err == nil && model.Provider != current.Provider {
+			err = fmt.Errorf("model %s uses provider %s; restart with --model to switch providers", model.ID, model.Provider)
+		}
+	default:
+		err = fmt.Errorf("no model registry")
+	}
+	if err != nil {
+		return ai.Model{}, err
+	}
+
+	env.Agent.SetModel(model)
+	if provider != nil {
+		env.Agent.SetProvider(provider)
+	}
+	return model, nil
+}
+
+func (env Env) compact(ctx context.Context, args []string) (shared.CommandResult, error) {
+	if err := env.idle("compact the conversation"); err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	state := env.Agent.GetState()
+	model := state.GetModel()
+	messages := state.GetMessages()
+
+	compactor := compaction.NewCompactor(env.Agent.GetProvider(), model, compaction.Config{ContextWindow: model.ContextWindow})
+	compacted, err := compactor.Compact(ctx, messages)
+	if err != nil {
+		return shared.CommandResult{}, fmt.Errorf("failed to compact: %w", err)
+	}
+	if len(compacted) == len(messages) {
+		return shared.CommandResult{Output: "Nothing to compact."}, nil
+	}
+
+	state.SetMessages(compacted)
+	return shared.CommandResult{Output: fmt.Sprintf("Compacted %d messages into %d.", len(messages), len(compacted))}, nil
+}
+
+func (env Env) cost(ctx context.Context, args []string) (shared.CommandResult, error) {
+	state := env.Agent.GetState()
+
+	// Price each reply with the model that wrote it
+	var price func(modelID string) (ai.Model, bool)
+	if env.Models != nil {
+		price = func(modelID string) (ai.Model, bool) {
+			model, err := env.Models.ToAIModel(modelID)
+			return model, err == nil
+		}
+	}
+	usage := agent.SumUsage(state.GetMessages(), state.GetModel(), price)
+
+	return shared.CommandResult{Output: fmt.Sprintf(
+		"Replies:       %d\nInput tokens:  %d\nOutput tokens: %d\nCost:          $%.4f",
+		usage.Replies, usage.InputTokens, usage.OutputTokens, usage.Cost,
+	)}, nil
+}
+
+func (env Env) sessions(ctx context.Context, args []string) (shared.CommandResult, error) {
+	if env.Sessions == nil {
+		return shared.CommandResult{}, fmt.Errorf("sessions are not available")
+	}
+
+	list, err := env.Sessions.List()
+	if err != nil {
+		return shared.CommandResult{}, err
+	}
+	if len(list) == 0 {
+		return shared.CommandResult{Output: "No sessions."}, nil
+	}
+
+	currentID := ""
+	if session := env.Sessions.GetCurrent(); session != nil {
+		currentID = session.Metadata.ID
+	}
+
+	var b strings.Builder
+	b.WriteString("Sessions:")
+	for _, meta := range list {
+		marker := " "
+		if meta.ID == currentID {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "\n%s %s  %s  %s", marker, meta.ID, meta.UpdatedAt.Format("2006-01-02 15:04"), meta.Title)
+		if meta.ParentID != "" {
+			fmt.Fprintf(&b, " (fork of %s)", meta.ParentID)
+		}
+	}
+	return shared.CommandResult{Output: b.String()}, nil
+}
+
+func (env Env) fork(ctx context.Context, args []string) (shared.CommandResult, error) {
+	if err := env.idle("fork the session"); err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	session, err := env.current()
+	if err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	branch, err := env.Sessions.Branch(session, strings.Join(args, " "))
+	if err != nil {
+		return shared.CommandResult{}, fmt.Errorf("failed to fork session: %w", err)
+	}
+	return shared.CommandResult{Output: fmt.Sprintf("Forked session %s into %s.", session.Metadata.ID, branch.Metadata.ID)}, nil
+}
+
+func (env Env) export(ctx context.Context, args []string) (shared.CommandResult, error) {
+	session, err := env.current()
+	if err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	path := session.Metadata.ID + ".html"
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if err := env.Sessions.Export(session.Metadata.ID, path); err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return shared.CommandResult{Output: fmt.Sprintf("Exported the session to %s.", path)}, nil
+}
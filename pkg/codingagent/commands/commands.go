@@ -0,0 +1,196 @@
+// Package commands implements the slash commands typed in the chat, such as
+// /help, /model and /compact. The same registry serves the TUI and RPC mode.
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/myersguo/cc-mono/pkg/shared"
+)
+
+// namePattern matches valid command names
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_:-]*$`)
+
+// Registry holds the available commands by name
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]shared.Command
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]shared.Command)}
+}
+
+// Register adds a command. Names must be unique.
+func (r *Registry) Register(cmd shared.Command) error {
+	if !namePattern.MatchString(cmd.Name) {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.commands[cmd.Name]; exists {
+		return fmt.Errorf("command /%s is already registered", cmd.Name)
+	}
+	r.commands[cmd.Name] = cmd
+	return nil
+}
+
+// Get returns the command with the given name
+func (r *Registry) Get(name string) (shared.Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// List returns all commands sorted by name
+func (r *Registry) List() []shared.Command {
+	return r.Complete("")
+}
+
+// Complete returns the commands whose names start with prefix, sorted by name
+func (r *Registry) Complete(prefix string) []shared.Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []shared.Command
+	for name, cmd := range r.commands {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, cmd)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	return matches
+}
+
+// Execute parses a command line and runs the command
+func (r *Registry) Execute(ctx context.Context, line string) (shared.CommandResult, error) {
+	name, rest, err := Split(line)
+	if err != nil {
+		return shared.CommandResult{}, err
+	}
+
+	cmd, ok := r.Get(name)
+	if !ok {
+		return shared.CommandResult{}, fmt.Errorf("unknown command /%s (type /help for a list)", name)
+	}
+	if cmd.Run == nil {
+		return shared.CommandResult{}, fmt.Errorf("/%s is not available here", name)
+	}
+
+	var args []string
+	if cmd.RawArgs {
+		if rest != "" {
+			args = []string{rest}
+		}
+	} else if args, err = splitWords(rest); err != nil {
+		return shared.CommandResult{}, err
+	}
+	return cmd.Run(ctx, args)
+}
+
+// IsCommand reports whether input is a command line rather than a prompt.
+// Text such as "/usr/bin is missing" is a prompt.
+func IsCommand(input string) bool {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return false
+	}
+	name, _, _ := strings.Cut(input[1:], " ")
+	name, _, _ = strings.Cut(name, "\n")
+	return namePattern.MatchString(name)
+}
+
+// Parse splits a command line into the command name and its arguments.
+// Arguments are separated by whitespace; single or double quotes group
+// words and a backslash escapes the next character.
+func Parse(line string) (string, []string, error) {
+	name, rest, err := Split(line)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args, err := splitWords(rest)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, args, nil
+}
+
+// Split splits a command line into the command name and the text after it,
+// with surrounding whitespace removed
+func Split(line string) (string, string, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		return "", "", fmt.Errorf("commands start with /")
+	}
+
+	name, rest := line[1:], ""
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid command %q", line)
+	}
+	return name, strings.TrimSpace(rest), nil
+}
+
+// splitWords splits s like a shell would, without expansions
+func splitWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		word.WriteRune('\\')
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
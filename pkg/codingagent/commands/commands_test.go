@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/shared"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		args []string
+	}{
+		{"/help", "help", []string{}},
+		{"  /model gpt-4o  ", "model", []string{"gpt-4o"}},
+		{`/fork "my branch" two`, "fork", []string{"my branch", "two"}},
+		{`/export 'a b.html'`, "export", []string{"a b.html"}},
+		{`/say it\'s`, "say", []string{"it's"}},
+		{`/x ""`, "x", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			name, args, err := Parse(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, name)
+			if len(tt.args) == 0 {
+				assert.Empty(t, args)
+			} else {
+				assert.Equal(t, tt.args, args)
+			}
+		})
+	}
+
+	_, _, err := Parse(`/fork "unterminated`)
+	assert.ErrorContains(t, err, "unterminated")
+	_, _, err = Parse("hello")
+	assert.Error(t, err)
+}
+
+func TestSplit(t *testing.T) {
+	name, rest, err := Split("  /explain why doesn't this  work ")
+	require.NoError(t, err)
+	assert.Equal(t, "explain", name)
+	assert.Equal(t, "why doesn't this  work", rest)
+
+	name, rest, err = Split("/review\nthe diff")
+	require.NoError(t, err)
+	assert.Equal(t, "review", name)
+	assert.Equal(t, "the diff", rest)
+
+	_, _, err = Split("/a.b")
+	assert.Error(t, err)
+}
+
+func TestIsCommand(t *testing.T) {
+	assert.True(t, IsCommand("/help"))
+	assert.True(t, IsCommand(" /model gpt-4o"))
+	assert.True(t, IsCommand("/team:deploy now"))
+	assert.False(t, IsCommand("/usr/bin is missing"))
+	assert.False(t, IsCommand("hello /help"))
+	assert.False(t, IsCommand("/"))
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	echo := shared.Command{
+		Name:        "echo",
+		Description: "Echo the arguments",
+		Run: func(ctx context.Context, args []string) (shared.CommandResult, error) {
+			return shared.CommandResult{Output: args[0]}, nil
+		},
+	}
+	require.NoError(t, r.Register(echo))
+	require.NoError(t, r.Register(shared.Command{Name: "exit"}))
+	assert.ErrorContains(t, r.Register(echo), "already registered")
+	assert.Error(t, r.Register(shared.Command{Name: "bad name"}))
+
+	var names []string
+	for _, cmd := range r.Complete("e") {
+		names = append(names, cmd.Name)
+	}
+	assert.Equal(t, []string{"echo", "exit"}, names)
+	assert.Len(t, r.Complete("ec"), 1)
+
+	result, err := r.Execute(context.Background(), `/echo "hello world"`)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", result.Output)
+
+	_, err = r.Execute(context.Background(), "/missing")
+	assert.ErrorContains(t, err, "unknown command /missing")
+	_, err = r.Execute(context.Background(), "/exit")
+	assert.ErrorContains(t, err, "not available")
+}
+
+// newEnv creates an agent with a short conversation in a session
+func newEnv(t *testing.T) (*Registry, Env) {
+	t.Helper()
+
+	models := codingagent.NewModelRegistry()
+	models.Register(codingagent.ModelConfig{ID: "small", Name: "Small", Provider: "openai", InputCostPer1M: 1, OutputCostPer1M: 2})
+	models.Register(codingagent.ModelConfig{ID: "large", Name: "Large", Provider: "openai"})
+	models.Register(codingagent.ModelConfig{ID: "other", Name: "Other", Provider: "anthropic"})
+	model, err := models.ToAIModel("small")
+	require.NoError(t, err)
+
+	agentInst := agent.NewAgent(nil, "system", model, nil)
+	state := agentInst.GetState()
+	state.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("hello"), "user-1", 0))
+	reply := ai.NewAssistantMessage([]ai.Content{ai.NewTextContent("hi")}, "", "", "small",
+		ai.Usage{InputTokens: 1000000, OutputTokens: 500000}, ai.StopReasonEndTurn)
+	state.AddMessage(agent.NewAgentMessage(reply, "reply-1", 0))
+
+	sessions, err := codingagent.NewSessionManager(filepath.Join(t.TempDir(), "sessions"))
+	require.NoError(t, err)
+	sessions.NewSession("Test", state)
+
+	env := Env{Agent: agentInst, Sessions: sessions, Models: models}
+	r := NewRegistry()
+	require.NoError(t, r.RegisterBuiltins(env))
+	return r, env
+}
+
+func run(t *testing.T, r *Registry, line string) string {
+	t.Helper()
+	result, err := r.Execute(context.Background(), line)
+	require.NoError(t, err)
+	return result.Output
+}
+
+func TestBuiltins(t *testing.T) {
+	t.Run("Help", func(t *testing.T) {
+		r, _ := newEnv(t)
+		output := run(t, r, "/help")
+		assert.Contains(t, output, "/model [model-id]")
+		assert.Contains(t, output, "/compact")
+		assert.Contains(t, run(t, r, "/help /fork"), "Continue the conversation")
+	})
+
+	t.Run("Model", func(t *testing.T) {
+		r, env := newEnv(t)
+		assert.Contains(t, run(t, r, "/model"), "* small")
+
+		assert.Contains(t, run(t, r, "/model large"), "Switched to Large")
+		assert.Equal(t, "large", env.Agent.GetState().GetModel().ID)
+
+		_, err := r.Execute(context.Background(), "/model other")
+		assert.ErrorContains(t, err, "restart with --model")
+	})
+
+	t.Run("Cost", func(t *testing.T) {
+		r, _ := newEnv(t)
+		output := run(t, r, "/cost")
+		assert.Contains(t, output, "Input tokens:  1000000")
+		assert.Contains(t, output, "$2.0000")
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		r, _ := newEnv(t)
+		assert.Equal(t, "Nothing to compact.", run(t, r, "/compact"))
+	})
+
+	t.Run("ForkAndSessions", func(t *testing.T) {
+		r, env := newEnv(t)
+		original := env.Sessions.GetCurrent()
+
+		assert.Contains(t, run(t, r, `/fork "try another way"`), "Forked session "+original.Metadata.ID)
+		current := env.Sessions.GetCurrent()
+		assert.Equal(t, "try another way", current.Metadata.Title)
+		assert.Len(t, env.Agent.GetState().GetMessages(), 2)
+
+		output := run(t, r, "/sessions")
+		assert.Contains(t, output, "* "+current.Metadata.ID)
+		assert.Contains(t, output, "(fork of "+original.Metadata.ID+")")
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		r, env := newEnv(t)
+		original := env.Sessions.GetCurrent()
+
+		assert.Contains(t, run(t, r, "/clear"), "Cleared the conversation")
+		assert.Empty(t, env.Agent.GetState().GetMessages())
+		assert.NotEqual(t, original.Metadata.ID, env.Sessions.GetCurrent().Metadata.ID)
+		assert.Len(t, original.State.GetMessages(), 2)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		r, _ := newEnv(t)
+		path := filepath.Join(t.TempDir(), "chat.html")
+		assert.Contains(t, run(t, r, "/export "+path), path)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "hello")
+	})
+
+	t.Run("RefusesWhileRunning", func(t *testing.T) {
+		r, env := newEnv(t)
+		env.Agent.GetState().SetIsStreaming(true)
+		_, err := r.Execute(context.Background(), "/clear")
+		assert.ErrorContains(t, err, "while the agent is running")
+	})
+}
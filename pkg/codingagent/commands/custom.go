@@ -0,0 +1,481 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/frontmatter"
+	"github.com/myersguo/cc-mono/pkg/shared"
+)
+
+// shellTimeout bounds each !`command` of a custom command
+const shellTimeout = 30 * time.Second
+
+// maxInlineFileSize is the largest file an @path reference inlines
+const maxInlineFileSize = 256 * 1024
+
+var (
+	// placeholderPattern matches $ARGUMENTS and $1 through $9
+	placeholderPattern = regexp.MustCompile(`\$(ARGUMENTS|[1-9])`)
+
+	// directivePattern matches !`command` or a placeholder; placeholders inside
+	// a command are left to the shell
+	directivePattern = regexp.MustCompile("!`([^`\n]+)`|" + placeholderPattern.String())
+
+	// filePattern matches @path at the start of a line or after whitespace
+	filePattern = regexp.MustCompile(`(?:^|\s)@([^\s` + "`" + `]+)`)
+)
+
+// CustomCommand is a slash command defined by a markdown prompt file
+type CustomCommand struct {
+	Name         string
+	Description  string
+	ArgumentHint string   // Usage shown in completions, e.g. "<file> [focus]"
+	AllowedTools []string // Permission rules granted while the prompt runs
+	Model        string   // Model ID to answer with; empty means the current model
+	Body         string   // Prompt template
+	Path         string   // File the command was loaded from
+}
+
+// customHeader is the front matter of a custom command file
+type customHeader struct {
+	Description  string `yaml:"description"`
+	ArgumentHint string `yaml:"argument-hint"`
+	AllowedTools any    `yaml:"allowed-tools"` // "read, Bash(git diff:*)" or a list
+	Model        string `yaml:"model"`
+}
+
+// Dirs returns the directories custom commands are loaded from, lowest precedence first
+func Dirs(globalConfigDir, projectDir string) []string {
+	return []string{
+		filepath.Join(globalConfigDir, "commands"),
+		filepath.Join(projectDir, ".cc-mono", "commands"),
+	}
+}
+
+// LoadCustomCommands loads the *.md files in dirs. A file in a subdirectory is
+// namespaced by it, so frontend/test.md becomes /frontend:test. Commands from
+// later directories replace earlier ones with the same name. Missing
+// directories are ignored.
+func LoadCustomCommands(dirs ...string) ([]CustomCommand, error) {
+	byName := make(map[string]CustomCommand)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name := strings.ReplaceAll(strings.TrimSuffix(rel, ".md"), string(filepath.Separator), ":")
+
+			def, err := LoadCustomCommand(path, name)
+			if err != nil {
+				return err
+			}
+			byName[def.Name] = def
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load commands from %s: %w", dir, err)
+		}
+	}
+
+	defs := make([]CustomCommand, 0, len(byName))
+	for _, def := range byName {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+
+	return defs, nil
+}
+
+// LoadCustomCommand loads a command from a markdown file. The front matter sets
+// description, argument-hint, allowed-tools and model; the body is the prompt.
+// The description defaults to the first line of the prompt.
+func LoadCustomCommand(path, name string) (CustomCommand, error) {
+	if !namePattern.MatchString(name) {
+		return CustomCommand{}, fmt.Errorf("invalid command %s: %q is not a valid command name", path, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CustomCommand{}, fmt.Errorf("failed to read command %s: %w", path, err)
+	}
+
+	var h customHeader
+	body, err := frontmatter.Parse(data, &h)
+	if err != nil {
+		return CustomCommand{}, fmt.Errorf("invalid command %s: %w", path, err)
+	}
+
+	allowedTools, err := parseAllowedTools(h.AllowedTools)
+	if err != nil {
+		return CustomCommand{}, fmt.Errorf("invalid command %s: %w", path, err)
+	}
+
+	def := CustomCommand{
+		Name:         name,
+		Description:  strings.TrimSpace(h.Description),
+		ArgumentHint: strings.TrimSpace(h.ArgumentHint),
+		AllowedTools: allowedTools,
+		Model:        strings.TrimSpace(h.Model),
+		Body:         strings.TrimSpace(body),
+		Path:         path,
+	}
+	if def.Body == "" {
+		return CustomCommand{}, fmt.Errorf("invalid command %s: prompt is empty", path)
+	}
+	if def.Description == "" {
+		line, _, _ := strings.Cut(def.Body, "\n")
+		def.Description = truncate(strings.TrimLeft(line, "# "), 80)
+	}
+
+	return def, nil
+}
+
+// parseAllowedTools accepts a comma-separated string or a list of rules
+func parseAllowedTools(value any) ([]string, error) {
+	var rules []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		rules = strings.Split(v, ",")
+	case []any:
+		for _, item := range v {
+			rule, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("allowed-tools must be a list of rules")
+			}
+			rules = append(rules, rule)
+		}
+	default:
+		return nil, fmt.Errorf("allowed-tools must be a list of rules")
+	}
+
+	var allowed []string
+	for _, rule := range rules {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			allowed = append(allowed, rule)
+		}
+	}
+	return allowed, nil
+}
+
+// RegisterCustom adds custom commands. Commands whose names are taken are
+// skipped and reported in the returned error.
+func (r *Registry) RegisterCustom(env Env, defs []CustomCommand) error {
+	var errs []error
+	for _, def := range defs {
+		if err := r.Register(env.customCommand(def)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", def.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// customCommand turns a custom command into a command that sends its expanded
+// prompt, with its allowed tools and model in effect until the agent answers
+func (env Env) customCommand(def CustomCommand) shared.Command {
+	return shared.Command{
+		Name:        def.Name,
+		Description: def.Description,
+		Usage:       def.ArgumentHint,
+		RawArgs:     true,
+		Run: func(ctx context.Context, args []string) (shared.CommandResult, error) {
+			if err := env.idle("run /" + def.Name); err != nil {
+				return shared.CommandResult{}, err
+			}
+
+			var undo []func()
+			done := func() {
+				for i := len(undo) - 1; i >= 0; i-- {
+					undo[i]()
+				}
+			}
+
+			// The allowed tools also cover the !`command` blocks. A project's
+			// commands come with the checkout, so they can't allow bash
+			// commands on their own: those still ask the user.
+			allowedTools := def.AllowedTools
+			if env.isProjectCommand(def) {
+				allowedTools = slices.DeleteFunc(slices.Clone(allowedTools), isBashRule)
+			}
+			if pm, ok := ctx.Value("permission_manager").(*agent.PermissionManager); ok && len(allowedTools) > 0 {
+				undo = append(undo, pm.AllowTemporarily(allowedTools...))
+			}
+
+			var raw string
+			if len(args) > 0 {
+				raw = args[0]
+			}
+			prompt, err := env.expand(ctx, def, raw)
+			if err != nil {
+				done()
+				return shared.CommandResult{}, err
+			}
+
+			if def.Model != "" {
+				model, provider := env.Agent.GetState().GetModel(), env.Agent.GetProvider()
+				if _, err := env.switchModel(def.Model); err != nil {
+					done()
+					return shared.CommandResult{}, fmt.Errorf("failed to switch to model %s: %w", def.Model, err)
+				}
+				undo = append(undo, func() {
+					env.Agent.SetModel(model)
+					env.Agent.SetProvider(provider)
+				})
+			}
+
+			return shared.CommandResult{Prompt: prompt, Done: done}, nil
+		},
+	}
+}
+
+// isProjectCommand reports whether a command was loaded from the project's
+// .cc-mono directory rather than from the user's own configuration
+func (env Env) isProjectCommand(def CustomCommand) bool {
+	if def.Path == "" || env.WorkingDir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Join(env.WorkingDir, ".cc-mono"), def.Path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isBashRule reports whether a permission rule allows bash commands
+func isBashRule(rule string) bool {
+	tool, _, _ := strings.Cut(rule, "(")
+	return strings.EqualFold(strings.TrimSpace(tool), "bash")
+}
+
+// expand fills in the arguments of a custom command, the output of its shell
+// commands and the files it references. Only the template is scanned for
+// !`command` and @path, so arguments can't run commands or read files.
+func (env Env) expand(ctx context.Context, def CustomCommand, raw string) (string, error) {
+	// Quotes group words for $1..$9; unbalanced ones don't stop the command
+	args, err := splitWords(raw)
+	if err != nil {
+		args = strings.Fields(raw)
+	}
+	fill := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+			if match == "$ARGUMENTS" {
+				return raw
+			}
+			n, _ := strconv.Atoi(match[1:])
+			if n > len(args) {
+				return ""
+			}
+			return args[n-1]
+		})
+	}
+
+	files, err := env.inlineFiles(def.Body, fill)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		b        strings.Builder
+		last     int
+		filled   bool
+		shellErr error
+	)
+	for _, loc := range directivePattern.FindAllStringSubmatchIndex(def.Body, -1) {
+		b.WriteString(def.Body[last:loc[0]])
+		last = loc[1]
+		if loc[2] < 0 {
+			b.WriteString(fill(def.Body[loc[0]:loc[1]]))
+			filled = true
+			continue
+		}
+		var output string
+		if output, shellErr = env.runShell(ctx, def.Name, def.Body[loc[2]:loc[3]], raw, args); shellErr != nil {
+			return "", shellErr
+		}
+		b.WriteString(output)
+	}
+	b.WriteString(def.Body[last:])
+
+	prompt := b.String()
+	if !filled && raw != "" {
+		prompt += "\n\nArguments: " + raw
+	}
+	return prompt + files, nil
+}
+
+// inlineFiles returns the contents of the existing files referenced as @path in
+// the template, to append to the prompt. Placeholders in a reference, as in
+// @$1, are filled in first. References to missing files are left as text.
+func (env Env) inlineFiles(template string, fill func(string) string) (string, error) {
+	var (
+		b    strings.Builder
+		seen = make(map[string]bool)
+	)
+	for _, match := range filePattern.FindAllStringSubmatch(template, -1) {
+		ref := strings.TrimRight(fill(match[1]), ".,;:!?)'\"")
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(env.WorkingDir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.Size() > maxInlineFileSize {
+			return "", fmt.Errorf("@%s is too large to include (%d KB, limit %d KB)", ref, info.Size()/1024, maxInlineFileSize/1024)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read @%s: %w", ref, err)
+		}
+		fmt.Fprintf(&b, "\n\nContents of %s:\n```\n%s\n```", ref, strings.TrimRight(string(data), "\n"))
+	}
+	return b.String(), nil
+}
+
+// runShell runs a !`command` block once the permission rules allow it, asking
+// the user when no rule decides. It runs in the sandbox when there is one, and
+// is audited like a bash tool call. The arguments reach the command as $1..
+// and $ARGUMENTS shell variables rather than as part of its text.
+func (env Env) runShell(ctx context.Context, name, command, raw string, args []string) (string, error) {
+	auditLog, _ := ctx.Value("audit_log").(*agent.AuditLog)
+	entry := agent.AuditEntry{
+		ToolCallID: "/" + name,
+		ToolName:   "bash",
+		Resource:   command,
+		Params:     map[string]any{"command": command},
+		Decision:   agent.AuditDecisionAllowed,
+		DecidedBy:  agent.DecidedByNone,
+	}
+	recordAudit := func() {
+		if auditLog != nil {
+			entry.Timestamp = time.Now()
+			_ = auditLog.Record(entry)
+		}
+	}
+
+	if pm, ok := ctx.Value("permission_manager").(*agent.PermissionManager); ok {
+		req := &agent.PermissionRequest{
+			ToolName:  "bash",
+			Action:    "execute",
+			Resource:  command,
+			Params:    map[string]any{"command": command},
+			Sandboxed: env.Sandbox != nil,
+			Networked: env.Sandbox != nil && env.Sandbox.AllowsNetwork(),
+		}
+		req.RiskLevel = agent.AnalyzeRiskLevel(req)
+		req.Description = fmt.Sprintf("/%s runs: %s", name, command)
+		entry.RiskLevel = req.RiskLevel
+
+		allowed, needAsk, err := pm.CheckPermission(req)
+		if err != nil {
+			entry.Decision = agent.AuditDecisionDenied
+			entry.Error = err.Error()
+			recordAudit()
+			return "", fmt.Errorf("permission check failed: %w", err)
+		}
+		entry.MatchedRule = req.MatchedRule
+		entry.DecidedBy = agent.DecidedByRule
+
+		if needAsk {
+			env.Agent.GetEventBus().Publish(agent.NewPermissionRequestEvent(req))
+			resp, err := pm.RequestPermissionWithContext(ctx, req)
+			entry.RequestID = req.RequestID
+			if resp != nil {
+				entry.DecidedBy = resp.DecidedBy
+			}
+			if err != nil {
+				entry.Decision = agent.AuditDecisionDenied
+				entry.Error = err.Error()
+				recordAudit()
+				return "", fmt.Errorf("permission request failed: %w", err)
+			}
+			allowed = resp.Allowed
+		}
+		if !allowed {
+			entry.Decision = agent.AuditDecisionDenied
+			recordAudit()
+			return "", fmt.Errorf("permission denied to run %q", command)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shellTimeout)
+	defer cancel()
+
+	bashArgs := append([]string{"-c", command, "/" + name}, args...)
+	var cmd *exec.Cmd
+	if env.Sandbox != nil {
+		cmd = env.Sandbox.Command(ctx, "bash", bashArgs...)
+	} else {
+		cmd = exec.CommandContext(ctx, "bash", bashArgs...)
+	}
+	cmd.Dir = env.WorkingDir
+	cmd.Env = append(os.Environ(), "ARGUMENTS="+raw)
+
+	startTime := time.Now()
+	output, err := cmd.CombinedOutput()
+	result := strings.TrimRight(string(output), "\n")
+
+	entry.Executed = true
+	entry.DurationMs = time.Since(startTime).Milliseconds()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode := exitErr.ExitCode()
+		entry.ExitCode = &exitCode
+		entry.IsError = true
+	case err != nil:
+		entry.IsError = true
+		entry.Error = err.Error()
+	default:
+		exitCode := 0
+		entry.ExitCode = &exitCode
+	}
+	recordAudit()
+
+	// A failing command is still useful context, e.g. a failing test run
+	if exitErr != nil && ctx.Err() == nil {
+		return fmt.Sprintf("%s\n(exit status %d)", result, exitErr.ExitCode()), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run %q: %w", command, err)
+	}
+	return result, nil
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
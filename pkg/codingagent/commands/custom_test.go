@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestLoadCustomCommands(t *testing.T) {
+	global, project := t.TempDir(), t.TempDir()
+	dirs := Dirs(global, project)
+
+	writeFile(t, filepath.Join(dirs[0], "explain.md"), "# Explain this stack trace\n\n$ARGUMENTS")
+	writeFile(t, filepath.Join(dirs[0], "tests.md"), "Global version")
+	writeFile(t, filepath.Join(dirs[1], "tests.md"), `---
+description: Write table tests
+argument-hint: <function>
+allowed-tools: read, Bash(go test:*)
+model: small
+---
+Write table tests for $1.
+`)
+	writeFile(t, filepath.Join(dirs[1], "frontend", "review.md"), "Review the component")
+	writeFile(t, filepath.Join(dirs[1], "notes.txt"), "not a command")
+
+	defs, err := LoadCustomCommands(dirs...)
+	require.NoError(t, err)
+	require.Len(t, defs, 3)
+
+	assert.Equal(t, "explain", defs[0].Name)
+	assert.Equal(t, "Explain this stack trace", defs[0].Description)
+
+	assert.Equal(t, "frontend:review", defs[1].Name)
+
+	tests := defs[2]
+	assert.Equal(t, "tests", tests.Name)
+	assert.Equal(t, "Write table tests", tests.Description)
+	assert.Equal(t, "<function>", tests.ArgumentHint)
+	assert.Equal(t, []string{"read", "Bash(go test:*)"}, tests.AllowedTools)
+	assert.Equal(t, "small", tests.Model)
+	assert.Equal(t, "Write table tests for $1.", tests.Body)
+
+	// Missing directories are ignored
+	defs, err = LoadCustomCommands(filepath.Join(global, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, defs)
+
+	writeFile(t, filepath.Join(dirs[1], "empty.md"), "---\ndescription: nothing\n---\n")
+	_, err = LoadCustomCommands(dirs...)
+	assert.ErrorContains(t, err, "prompt is empty")
+}
+
+// newCustomEnv creates an agent and a permission manager with the given rules
+func newCustomEnv(t *testing.T, allow, deny []string) (*Registry, Env, context.Context) {
+	t.Helper()
+	_, env := newEnv(t)
+	env.WorkingDir = t.TempDir()
+
+	global := t.TempDir()
+	settings, err := json.Marshal(agent.Settings{Permissions: &agent.PermissionSettings{Allow: allow, Deny: deny}})
+	require.NoError(t, err)
+	writeFile(t, filepath.Join(global, "settings.json"), string(settings))
+	pm, err := agent.NewPermissionManager(global, t.TempDir())
+	require.NoError(t, err)
+
+	return NewRegistry(), env, context.WithValue(context.Background(), "permission_manager", pm)
+}
+
+func TestCustomCommand(t *testing.T) {
+	t.Run("Arguments", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "explain", Body: "Explain $ARGUMENTS, starting with $1.$4"},
+			{Name: "plain", Body: "Summarize"},
+		}))
+
+		result, err := r.Execute(ctx, `/explain "the panic" in main`)
+		require.NoError(t, err)
+		assert.Equal(t, `Explain "the panic" in main, starting with the panic.`, result.Prompt)
+
+		// $ARGUMENTS is the text as typed, even with an unbalanced quote
+		result, err = r.Execute(ctx, "/explain why doesn't this work")
+		require.NoError(t, err)
+		assert.Equal(t, "Explain why doesn't this work, starting with why.work", result.Prompt)
+
+		result, err = r.Execute(ctx, "/plain README.md")
+		require.NoError(t, err)
+		assert.Equal(t, "Summarize\n\nArguments: README.md", result.Prompt)
+	})
+
+	t.Run("Files", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		writeFile(t, filepath.Join(env.WorkingDir, "main.go"), "package main\n")
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{{Name: "review", Body: "Review @$1, not @missing.go."}}))
+
+		result, err := r.Execute(ctx, "/review main.go")
+		require.NoError(t, err)
+		assert.Equal(t, "Review @main.go, not @missing.go.\n\nContents of main.go:\n```\npackage main\n```", result.Prompt)
+	})
+
+	t.Run("Shell", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, []string{"Bash(echo:*)"}, nil)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{{Name: "status", Body: "Status: !`echo clean`\nFail: !`echo oops; exit 3`"}}))
+
+		result, err := r.Execute(ctx, "/status")
+		require.NoError(t, err)
+		assert.Equal(t, "Status: clean\nFail: oops\n(exit status 3)", result.Prompt)
+	})
+
+	t.Run("ShellArguments", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, []string{"Bash(echo:*)"}, nil)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{{Name: "show", Body: "Show: !`echo \"$1|$ARGUMENTS\"`"}}))
+
+		result, err := r.Execute(ctx, "/show a;touch pwned b")
+		require.NoError(t, err)
+		assert.Equal(t, "Show: a;touch|a;touch pwned b\n\nArguments: a;touch pwned b", result.Prompt)
+		assert.NoFileExists(t, filepath.Join(env.WorkingDir, "pwned"))
+	})
+
+	t.Run("ArgumentsNotExpanded", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		writeFile(t, filepath.Join(env.WorkingDir, "secret.txt"), "token\n")
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{{Name: "ask", Body: "Answer: $ARGUMENTS"}}))
+
+		result, err := r.Execute(ctx, "/ask !`touch pwned` and @secret.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "Answer: !`touch pwned` and @secret.txt", result.Prompt)
+		assert.NoFileExists(t, filepath.Join(env.WorkingDir, "pwned"))
+	})
+
+	t.Run("ShellDenied", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, []string{"Bash(rm:*)"})
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "clean", Body: "!`rm -rf build`", AllowedTools: []string{"Bash(rm:*)"}},
+		}))
+
+		_, err := r.Execute(ctx, "/clean")
+		assert.ErrorContains(t, err, "permission denied")
+	})
+
+	t.Run("AllowedTools", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "diff", Body: "Diff: !`echo one two`", AllowedTools: []string{"Bash(echo one:*)", "read"}},
+		}))
+
+		result, err := r.Execute(ctx, "/diff")
+		require.NoError(t, err)
+		assert.Equal(t, "Diff: one two", result.Prompt)
+
+		// The tools stay allowed until the prompt is answered
+		pm := ctx.Value("permission_manager").(*agent.PermissionManager)
+		allowed, _, err := pm.CheckPermission(&agent.PermissionRequest{ToolName: "read", Resource: "/tmp/a"})
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		require.NotNil(t, result.Done)
+		result.Done()
+		allowed, _, err = pm.CheckPermission(&agent.PermissionRequest{ToolName: "read", Resource: "/tmp/a"})
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("ShellAudited", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, []string{"Bash(echo:*)"}, []string{"Bash(rm:*)"})
+		auditLog, err := agent.NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"), "session-1")
+		require.NoError(t, err)
+		ctx = context.WithValue(ctx, "audit_log", auditLog)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "status", Body: "!`echo clean`"},
+			{Name: "clean", Body: "!`rm -rf build`"},
+		}))
+
+		_, err = r.Execute(ctx, "/status")
+		require.NoError(t, err)
+		_, err = r.Execute(ctx, "/clean")
+		require.Error(t, err)
+
+		entries, err := auditLog.Query(agent.AuditFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "/status", entries[0].ToolCallID)
+		assert.Equal(t, "echo clean", entries[0].Resource)
+		assert.Equal(t, agent.AuditDecisionAllowed, entries[0].Decision)
+		assert.True(t, entries[0].Executed)
+		require.NotNil(t, entries[0].ExitCode)
+		assert.Equal(t, 0, *entries[0].ExitCode)
+		assert.Equal(t, agent.AuditDecisionDenied, entries[1].Decision)
+		assert.Equal(t, agent.DecidedByRule, entries[1].DecidedBy)
+		assert.False(t, entries[1].Executed)
+	})
+
+	t.Run("ProjectCannotAllowBash", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		path := filepath.Join(env.WorkingDir, ".cc-mono", "commands", "setup.md")
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "setup", Body: "!`echo installed`", AllowedTools: []string{"Bash(echo:*)", "read"}, Path: path},
+		}))
+
+		// The command asks the user instead of running on its own rule
+		pm := ctx.Value("permission_manager").(*agent.PermissionManager)
+		var asked []string
+		pm.SetRequestHandler(func(req *agent.PermissionRequest) {
+			asked = append(asked, req.Resource)
+			_ = pm.RespondToRequest(req.RequestID, false, false, "")
+		})
+		_, err := r.Execute(ctx, "/setup")
+		assert.ErrorContains(t, err, "permission denied")
+		assert.Equal(t, []string{"echo installed"}, asked)
+
+		// Its other rules still apply
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "look", Body: "Look", AllowedTools: []string{"Bash(echo:*)", "read"}, Path: path},
+		}))
+		result, err := r.Execute(ctx, "/look")
+		require.NoError(t, err)
+		defer result.Done()
+		allowed, _, err := pm.CheckPermission(&agent.PermissionRequest{ToolName: "read", Resource: "/tmp/a"})
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		allowed, _, err = pm.CheckPermission(&agent.PermissionRequest{ToolName: "bash", Resource: "echo hi", Params: map[string]any{"command": "echo hi"}})
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("Model", func(t *testing.T) {
+		r, env, ctx := newCustomEnv(t, nil, nil)
+		require.NoError(t, r.RegisterCustom(env, []CustomCommand{
+			{Name: "big", Body: "Think hard", Model: "large"},
+			{Name: "bad", Body: "Think", Model: "missing"},
+		}))
+
+		result, err := r.Execute(ctx, "/big")
+		require.NoError(t, err)
+		assert.Equal(t, "large", env.Agent.GetState().GetModel().ID)
+		result.Done()
+		assert.Equal(t, "small", env.Agent.GetState().GetModel().ID)
+
+		_, err = r.Execute(ctx, "/bad")
+		assert.ErrorContains(t, err, "failed to switch to model missing")
+	})
+
+	t.Run("NameTaken", func(t *testing.T) {
+		r, env, _ := newCustomEnv(t, nil, nil)
+		require.NoError(t, r.RegisterBuiltins(env))
+		err := r.RegisterCustom(env, []CustomCommand{{Name: "help", Body: "Help me", Path: "help.md"}})
+		assert.ErrorContains(t, err, "help.md")
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,10 +17,10 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	k       *koanf.Koanf
-	files   []string
-	watcher *fsnotify.Watcher
-	logger  *slog.Logger
+	k      *koanf.Koanf
+	files  []string
+	stop   func() // Stops watching the files
+	logger *slog.Logger
 }
 
 // NewConfig creates a new configuration instance
@@ -62,7 +63,9 @@ func (c *Config) LoadFile(path string) error {
 		return fmt.Errorf("failed to load config file: %w", err)
 	}
 
-	c.files = append(c.files, path)
+	if path = filepath.Clean(path); !slices.Contains(c.files, path) {
+		c.files = append(c.files, path)
+	}
 	c.logger.Info("Loaded config file", "path", path)
 
 	return nil
@@ -100,6 +103,11 @@ func (c *Config) GetStringSlice(key string) []string {
 	return c.k.Strings(key)
 }
 
+// Keys returns the flattened keys of all loaded values, e.g. "server.port"
+func (c *Config) Keys() []string {
+	return c.k.Keys()
+}
+
 // Set sets a configuration value
 func (c *Config) Set(key string, value interface{}) error {
 	return c.k.Set(key, value)
@@ -115,63 +123,97 @@ func (c *Config) UnmarshalAll(target interface{}) error {
 	return c.k.Unmarshal("", target)
 }
 
-// Watch starts watching configuration files for changes
+// Watch starts watching configuration files for changes. A file is reloaded
+// when it is written, or replaced by an editor that renames a new copy over it.
 func (c *Config) Watch(onChange func()) error {
 	if len(c.files) == 0 {
 		return fmt.Errorf("no files to watch")
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	var dirs []string
+	for _, path := range c.files {
+		if dir := filepath.Dir(path); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	files := slices.Clone(c.files)
+
+	stop, err := WatchDirs(dirs, func(path string) bool {
+		return slices.Contains(files, filepath.Clean(path))
+	}, func(path string) {
+		if _, err := os.Stat(path); err != nil {
+			return // Removed, or renamed away until the new copy is created
+		}
+		c.logger.Info("Config file changed", "path", path)
+		if err := c.LoadFile(path); err != nil {
+			c.logger.Error("Failed to reload config file", "path", path, "error", err)
+		} else {
+			onChange()
+		}
+	}, func(err error) {
+		c.logger.Error("Config watcher error", "error", err)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
+		return err
 	}
+	c.stop = stop
+	return nil
+}
 
-	c.watcher = watcher
+// Close closes the configuration watcher
+func (c *Config) Close() error {
+	if c.stop != nil {
+		c.stop()
+		c.stop = nil
+	}
+	return nil
+}
 
-	// Add all config files to watcher
-	for _, path := range c.files {
-		if err := watcher.Add(path); err != nil {
-			c.logger.Warn("Failed to watch config file", "path", path, "error", err)
+// WatchDirs calls onChange with the path of each file in dirs that is written,
+// created, renamed or removed and that match accepts, and onError with watcher
+// errors (nil to ignore them). Watching the directories rather than the files
+// picks up new files, and files that editors save by renaming a new copy over
+// them. The returned function stops watching.
+func WatchDirs(dirs []string, match func(path string) bool, onChange func(path string), onError func(err error)) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
 		}
 	}
 
-	// Start watching in a goroutine
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for {
 			select {
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
-
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					c.logger.Info("Config file changed", "path", event.Name)
-					// Reload the file
-					if err := c.LoadFile(event.Name); err != nil {
-						c.logger.Error("Failed to reload config file", "path", event.Name, "error", err)
-					} else {
-						onChange()
-					}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 && match(event.Name) {
+					onChange(event.Name)
 				}
 
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
-				c.logger.Error("Config watcher error", "error", err)
+				if onError != nil {
+					onError(err)
+				}
 			}
 		}
 	}()
 
-	return nil
-}
-
-// Close closes the configuration watcher
-func (c *Config) Close() error {
-	if c.watcher != nil {
-		return c.watcher.Close()
-	}
-	return nil
+	return func() {
+		watcher.Close()
+		<-done
+	}, nil
 }
 
 // mergeDeep deeply merges src into dest
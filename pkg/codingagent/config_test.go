@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestConfig(t *testing.T) {
@@ -40,6 +41,11 @@ func TestConfig(t *testing.T) {
 		if config.GetInt("server.port") != 8080 {
 			t.Errorf("Expected server.port=8080, got %d", config.GetInt("server.port"))
 		}
+
+		keys := config.Keys()
+		if len(keys) != 3 || keys[0] != "app.name" || keys[2] != "server.port" {
+			t.Errorf("Expected keys [app.name app.version server.port], got %v", keys)
+		}
 	})
 
 	t.Run("LoadNonexistentFile", func(t *testing.T) {
@@ -98,4 +104,43 @@ func TestConfig(t *testing.T) {
 			t.Errorf("Expected port=5432, got %d", dbConfig.Port)
 		}
 	})
+
+	t.Run("WatchRenamedFile", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		configPath := filepath.Join(tmpDir, "config.json")
+		if err := os.WriteFile(configPath, []byte(`{"app": {"name": "old"}}`), 0644); err != nil {
+			t.Fatalf("Failed to create config file: %v", err)
+		}
+
+		config := NewConfig(logger)
+		if err := config.LoadFile(configPath); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		changed := make(chan struct{}, 10)
+		if err := config.Watch(func() { changed <- struct{}{} }); err != nil {
+			t.Fatalf("Failed to watch config: %v", err)
+		}
+		defer config.Close()
+
+		// Editors often save by writing a new copy and renaming it over the file.
+		tmpPath := filepath.Join(tmpDir, "config.json.tmp")
+		if err := os.WriteFile(tmpPath, []byte(`{"app": {"name": "new"}}`), 0644); err != nil {
+			t.Fatalf("Failed to write config copy: %v", err)
+		}
+		if err := os.Rename(tmpPath, configPath); err != nil {
+			t.Fatalf("Failed to rename config copy: %v", err)
+		}
+
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected a change notification")
+		}
+		if config.GetString("app.name") != "new" {
+			t.Errorf("Expected app.name='new', got '%s'", config.GetString("app.name"))
+		}
+		if len(config.files) != 1 {
+			t.Errorf("Expected the file to be tracked once, got %v", config.files)
+		}
+	})
 }
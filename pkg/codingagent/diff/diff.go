@@ -0,0 +1,443 @@
+// Package diff compares texts line by line and reads and writes the changes
+// as unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+// maxCells bounds the table the line diff builds. Larger inputs are diffed as
+// one block that replaces everything between the common prefix and suffix.
+const maxCells = 4_000_000
+
+// Op says what happened to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line is one line of a diff. Old and New are its 1-based line numbers in
+// the old and new text, 0 where the line is absent.
+type Line struct {
+	Op   Op
+	Text string
+	Old  int
+	New  int
+}
+
+// Hunk is a run of changes with the unchanged lines around them
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Lines compares two texts line by line
+func Lines(oldText, newText string) []Line {
+	a, b := split(oldText), split(newText)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	oldNo, newNo := 0, 0
+	add := func(op Op, text string) {
+		line := Line{Op: op, Text: text}
+		if op != Insert {
+			oldNo++
+			line.Old = oldNo
+		}
+		if op != Delete {
+			newNo++
+			line.New = newNo
+		}
+		lines = append(lines, line)
+	}
+
+	for _, text := range a[:prefix] {
+		add(Equal, text)
+	}
+	for _, op := range lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(op.op, op.text)
+	}
+	for _, text := range a[len(a)-suffix:] {
+		add(Equal, text)
+	}
+	return lines
+}
+
+type edit struct {
+	op   Op
+	text string
+}
+
+// lcs returns the edits that turn a into b, keeping their longest common
+// subsequence. Deletions come before the insertions that replace them.
+func lcs(a, b []string) []edit {
+	n, m := len(a), len(b)
+	edits := make([]edit, 0, n+m)
+	if n*m == 0 || n*m > maxCells {
+		for _, text := range a {
+			edits = append(edits, edit{Delete, text})
+		}
+		for _, text := range b {
+			edits = append(edits, edit{Insert, text})
+		}
+		return edits
+	}
+
+	// table[i][j] is the length of the LCS of a[i:] and b[j:]. It cannot
+	// exceed min(n, m), which maxCells keeps below 2000.
+	width := m + 1
+	table := make([]uint16, (n+1)*width)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				table[i*width+j] = table[(i+1)*width+j+1] + 1
+			case table[(i+1)*width+j] >= table[i*width+j+1]:
+				table[i*width+j] = table[(i+1)*width+j]
+			default:
+				table[i*width+j] = table[i*width+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{Equal, a[i]})
+			i++
+			j++
+		case table[(i+1)*width+j] >= table[i*width+j+1]:
+			edits = append(edits, edit{Delete, a[i]})
+			i++
+		default:
+			edits = append(edits, edit{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		edits = append(edits, edit{Delete, a[i]})
+	}
+	for ; j < m; j++ {
+		edits = append(edits, edit{Insert, b[j]})
+	}
+	return edits
+}
+
+// split breaks text into lines, dropping the final newline
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Hunks groups the changed lines into hunks with up to context unchanged
+// lines around them. Changes closer than twice the context share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := max(i-context, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].Op == Equal {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = next
+		}
+
+		hunks = append(hunks, newHunk(lines[start:end], lines[:start]))
+		i = end
+	}
+	return hunks
+}
+
+// newHunk builds the hunk of lines, which follow before
+func newHunk(lines, before []Line) Hunk {
+	h := Hunk{Lines: lines}
+	oldBefore, newBefore := 0, 0
+	for _, line := range before {
+		if line.Op != Insert {
+			oldBefore++
+		}
+		if line.Op != Delete {
+			newBefore++
+		}
+	}
+	for _, line := range lines {
+		if line.Op != Insert {
+			h.OldLines++
+		}
+		if line.Op != Delete {
+			h.NewLines++
+		}
+	}
+	// An empty side starts at the line the change follows
+	h.OldStart, h.NewStart = oldBefore, newBefore
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+	return h
+}
+
+// Stats counts the added and removed lines
+func Stats(lines []Line) (added, removed int) {
+	for _, line := range lines {
+		switch line.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified returns the unified diff between two versions of the file name, or
+// "" when they are the same
+func Unified(oldText, newText, name string) string {
+	hunks := Hunks(Lines(oldText, newText), DefaultContext)
+	if len(hunks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n", name, name) + Format(hunks)
+}
+
+// Format writes hunks in unified diff format, without file headers
+func Format(hunks []Hunk) string {
+	var sb strings.Builder
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, line := range h.Lines {
+			switch line.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(line.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// hunkRange formats one side of a hunk header; the count is left out when it is 1
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Parse reads the hunks of a unified diff, skipping file headers and any
+// other text between hunks
+func Parse(text string) []Hunk {
+	var hunks []Hunk
+	var current *Hunk
+	oldLeft, newLeft, oldNo, newNo := 0, 0, 0, 0
+
+	for _, raw := range strings.Split(text, "\n") {
+		if current != nil && (oldLeft > 0 || newLeft > 0) {
+			line := Line{}
+			switch {
+			case strings.HasPrefix(raw, "\\"): // \ No newline at end of file
+				continue
+			case strings.HasPrefix(raw, "-") && oldLeft > 0:
+				line.Op = Delete
+			case strings.HasPrefix(raw, "+") && newLeft > 0:
+				line.Op = Insert
+			case (strings.HasPrefix(raw, " ") || raw == "") && oldLeft > 0 && newLeft > 0:
+				line.Op = Equal
+			default:
+				current = nil
+				continue
+			}
+			if raw != "" {
+				line.Text = raw[1:]
+			}
+			if line.Op != Insert {
+				oldNo++
+				oldLeft--
+				line.Old = oldNo
+			}
+			if line.Op != Delete {
+				newNo++
+				newLeft--
+				line.New = newNo
+			}
+			current.Lines = append(current.Lines, line)
+			continue
+		}
+
+		h, ok := parseHeader(raw)
+		if !ok {
+			current = nil
+			continue
+		}
+		hunks = append(hunks, h)
+		current = &hunks[len(hunks)-1]
+		oldLeft, newLeft = h.OldLines, h.NewLines
+		oldNo, newNo = h.OldStart-1, h.NewStart-1
+		if h.OldLines == 0 {
+			oldNo = h.OldStart
+		}
+		if h.NewLines == 0 {
+			newNo = h.NewStart
+		}
+	}
+	return hunks
+}
+
+// parseHeader reads a "@@ -a,b +c,d @@" hunk header
+func parseHeader(line string) (Hunk, bool) {
+	rest, ok := strings.CutPrefix(line, "@@ -")
+	if !ok {
+		return Hunk{}, false
+	}
+	ranges, _, ok := strings.Cut(rest, " @@")
+	if !ok {
+		return Hunk{}, false
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " +")
+	if !ok {
+		return Hunk{}, false
+	}
+	var h Hunk
+	if h.OldStart, h.OldLines, ok = parseRange(oldRange); !ok {
+		return Hunk{}, false
+	}
+	if h.NewStart, h.NewLines, ok = parseRange(newRange); !ok {
+		return Hunk{}, false
+	}
+	return h, true
+}
+
+// parseRange reads "start,count" or "start"
+func parseRange(s string) (int, int, bool) {
+	startText, countText, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, false
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
+
+// Span is a piece of a changed line; Changed marks the words that differ
+// from the other version of the line
+type Span struct {
+	Text    string
+	Changed bool
+}
+
+// maxWordCells bounds the table of a word diff; longer lines are marked
+// changed as a whole
+const maxWordCells = 250_000
+
+// Words compares two versions of a line word by word
+func Words(oldLine, newLine string) (oldSpans, newSpans []Span) {
+	a, b := tokenize(oldLine), tokenize(newLine)
+	if len(a)*len(b) > maxWordCells {
+		return []Span{{Text: oldLine, Changed: true}}, []Span{{Text: newLine, Changed: true}}
+	}
+
+	appendSpan := func(spans []Span, text string, changed bool) []Span {
+		if n := len(spans); n > 0 && spans[n-1].Changed == changed {
+			spans[n-1].Text += text
+			return spans
+		}
+		return append(spans, Span{Text: text, Changed: changed})
+	}
+	for _, e := range lcs(a, b) {
+		switch e.op {
+		case Equal:
+			oldSpans = appendSpan(oldSpans, e.text, false)
+			newSpans = appendSpan(newSpans, e.text, false)
+		case Delete:
+			oldSpans = appendSpan(oldSpans, e.text, true)
+		case Insert:
+			newSpans = appendSpan(newSpans, e.text, true)
+		}
+	}
+	return oldSpans, newSpans
+}
+
+// tokenize splits a line into words, runs of spaces and single symbols
+func tokenize(line string) []string {
+	var tokens []string
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		j := i + size
+		if class := runeClass(r); class != 0 {
+			for j < len(line) {
+				next, nextSize := utf8.DecodeRuneInString(line[j:])
+				if runeClass(next) != class {
+					break
+				}
+				j += nextSize
+			}
+		}
+		tokens = append(tokens, line[i:j])
+		i = j
+	}
+	return tokens
+}
+
+// runeClass groups runes that belong to one token: 1 for word characters,
+// 2 for spaces and 0 for symbols, which stand alone
+func runeClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	case unicode.IsSpace(r):
+		return 2
+	default:
+		return 0
+	}
+}
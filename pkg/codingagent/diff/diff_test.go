@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numbered(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestLines(t *testing.T) {
+	lines := Lines("a\nb\nc\n", "a\nB\nc\nd\n")
+	assert.Equal(t, []Line{
+		{Op: Equal, Text: "a", Old: 1, New: 1},
+		{Op: Delete, Text: "b", Old: 2},
+		{Op: Insert, Text: "B", New: 2},
+		{Op: Equal, Text: "c", Old: 3, New: 3},
+		{Op: Insert, Text: "d", New: 4},
+	}, lines)
+
+	added, removed := Stats(lines)
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+
+	assert.Empty(t, Hunks(Lines("same\n", "same\n"), DefaultContext))
+}
+
+func TestUnified(t *testing.T) {
+	oldText := numbered(1, 20)
+	newText := strings.Replace(strings.Replace(oldText, "line 3\n", "line three\n", 1), "line 18\n", "line 18\nline 18.5\n", 1)
+
+	assert.Equal(t, `--- f.txt
++++ f.txt
+@@ -1,6 +1,6 @@
+ line 1
+ line 2
+-line 3
++line three
+ line 4
+ line 5
+ line 6
+@@ -16,5 +16,6 @@
+ line 16
+ line 17
+ line 18
++line 18.5
+ line 19
+ line 20
+`, Unified(oldText, newText, "f.txt"))
+
+	// Nearby changes share a hunk
+	newText = strings.Replace(strings.Replace(oldText, "line 5\n", "", 1), "line 10\n", "line ten\n", 1)
+	require.Len(t, Hunks(Lines(oldText, newText), DefaultContext), 1)
+
+	assert.Equal(t, "--- new\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n", Unified("", "x\ny\n", "new"))
+	assert.Equal(t, "", Unified("same", "same", "f"))
+}
+
+func TestParse(t *testing.T) {
+	oldText := numbered(1, 30)
+	newText := strings.Replace(strings.Replace(oldText, "line 2\n", "", 1), "line 25\n", "line 25\nextra\n", 1)
+	hunks := Hunks(Lines(oldText, newText), DefaultContext)
+
+	parsed := Parse("diff --git a/f b/f\nindex 1..2\n" + Unified(oldText, newText, "f") + "trailing text\n")
+	assert.Equal(t, hunks, parsed)
+
+	// Hunk headers without counts
+	parsed = Parse("@@ -3 +3 @@\n-old\n+new\n")
+	require.Len(t, parsed, 1)
+	assert.Equal(t, []Line{{Op: Delete, Text: "old", Old: 3}, {Op: Insert, Text: "new", New: 3}}, parsed[0].Lines)
+
+	assert.Empty(t, Parse("no diff here"))
+}
+
+func TestWords(t *testing.T) {
+	oldSpans, newSpans := Words("return total + 1", "return total * 2")
+	assert.Equal(t, []Span{{Text: "return total ", Changed: false}, {Text: "+", Changed: true}, {Text: " ", Changed: false}, {Text: "1", Changed: true}}, oldSpans)
+	assert.Equal(t, []Span{{Text: "return total ", Changed: false}, {Text: "*", Changed: true}, {Text: " ", Changed: false}, {Text: "2", Changed: true}}, newSpans)
+
+	oldSpans, newSpans = Words("", "added")
+	assert.Empty(t, oldSpans)
+	assert.Equal(t, []Span{{Text: "added", Changed: true}}, newSpans)
+}
@@ -22,6 +22,7 @@ type MockExtension struct {
 	modifyResult         bool
 	returnError          bool
 	registeredToolsCount int
+	commands             []shared.Command
 }
 
 func NewMockExtension(name string) *MockExtension {
@@ -98,6 +99,10 @@ func (m *MockExtension) RegisterTools() []agent.AgentTool {
 	return tools
 }
 
+func (m *MockExtension) RegisterCommands() []shared.Command {
+	return m.commands
+}
+
 // Test Loader
 
 func TestLoader_LoadExtension(t *testing.T) {
@@ -308,6 +313,24 @@ func TestRunner_GetRegisteredTools(t *testing.T) {
 	assert.Len(t, tools, 5) // 2 + 3
 }
 
+func TestRunner_GetRegisteredCommands(t *testing.T) {
+	loader := NewLoader()
+
+	ext1 := NewMockExtension("ext1")
+	ext1.commands = []shared.Command{{Name: "deploy", Description: "Deploy the app"}}
+
+	ext2 := NewMockExtension("ext2")
+
+	loader.LoadExtension(ext1, nil)
+	loader.LoadExtension(ext2, nil)
+
+	runner := NewRunner(loader)
+
+	commands := runner.GetRegisteredCommands()
+	require.Len(t, commands, 1)
+	assert.Equal(t, "deploy", commands[0].Name)
+}
+
 func TestRunner_SetEnabled(t *testing.T) {
 	loader := NewLoader()
 	ext := NewMockExtension("test-ext")
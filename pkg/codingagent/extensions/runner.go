@@ -7,6 +7,7 @@ import (
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/shared"
 )
 
 // Runner executes extension hooks during agent execution
@@ -165,6 +166,18 @@ func (r *Runner) GetRegisteredTools() []agent.AgentTool {
 	return tools
 }
 
+// GetRegisteredCommands collects all slash commands from loaded extensions
+func (r *Runner) GetRegisteredCommands() []shared.Command {
+	extensions := r.loader.ListExtensions()
+	var commands []shared.Command
+
+	for _, ext := range extensions {
+		commands = append(commands, ext.RegisterCommands()...)
+	}
+
+	return commands
+}
+
 // WrapTool wraps a tool to intercept its execution
 func (r *Runner) WrapTool(tool agent.AgentTool) agent.AgentTool {
 	originalExecute := tool.Execute
@@ -204,11 +217,9 @@ func (r *Runner) WrapTool(tool agent.AgentTool) agent.AgentTool {
 		return result, nil
 	}
 
-	return agent.AgentTool{
-		Tool:    tool.Tool,
-		Label:   tool.Label,
-		Execute: wrappedExecute,
-	}
+	wrapped := tool
+	wrapped.Execute = wrappedExecute
+	return wrapped
 }
 
 // WrapAllTools wraps all tools with extension hooks
@@ -0,0 +1,51 @@
+// Package frontmatter splits markdown files into YAML front matter and body.
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const delimiter = "---"
+
+// Parse decodes the YAML front matter of content into out and returns the
+// markdown body. Content without front matter is returned as the body and out
+// is left untouched.
+func Parse(content []byte, out any) (string, error) {
+	// Strip a UTF-8 byte order mark
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	if !strings.HasPrefix(text, delimiter+"\n") {
+		return text, nil
+	}
+	rest := text[len(delimiter)+1:]
+
+	// Find the closing delimiter on its own line
+	var header, body string
+	switch {
+	case strings.HasPrefix(rest, delimiter+"\n") || rest == delimiter:
+		body = strings.TrimPrefix(rest, delimiter)
+	default:
+		end := strings.Index(rest, "\n"+delimiter+"\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n"+delimiter) {
+				return "", fmt.Errorf("front matter is not closed with %s", delimiter)
+			}
+			end = len(rest) - len(delimiter) - 1
+		}
+		header = rest[:end]
+		body = rest[end+len(delimiter)+1:]
+	}
+
+	if strings.TrimSpace(header) != "" {
+		if err := yaml.Unmarshal([]byte(header), out); err != nil {
+			return "", fmt.Errorf("failed to parse front matter: %w", err)
+		}
+	}
+
+	return strings.TrimPrefix(body, "\n"), nil
+}
@@ -0,0 +1,59 @@
+package frontmatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type header struct {
+	Name  string   `yaml:"name"`
+	Tools []string `yaml:"tools"`
+}
+
+func TestParse(t *testing.T) {
+	t.Run("WithFrontMatter", func(t *testing.T) {
+		var h header
+		body, err := Parse([]byte("---\nname: reviewer\ntools: [read, bash]\n---\nReview the code.\n"), &h)
+		require.NoError(t, err)
+		assert.Equal(t, "reviewer", h.Name)
+		assert.Equal(t, []string{"read", "bash"}, h.Tools)
+		assert.Equal(t, "Review the code.\n", body)
+	})
+
+	t.Run("WithoutFrontMatter", func(t *testing.T) {
+		var h header
+		body, err := Parse([]byte("Just a body\n"), &h)
+		require.NoError(t, err)
+		assert.Empty(t, h.Name)
+		assert.Equal(t, "Just a body\n", body)
+	})
+
+	t.Run("CRLFAndEmptyBody", func(t *testing.T) {
+		var h header
+		body, err := Parse([]byte("---\r\nname: x\r\n---"), &h)
+		require.NoError(t, err)
+		assert.Equal(t, "x", h.Name)
+		assert.Empty(t, body)
+	})
+
+	t.Run("EmptyHeader", func(t *testing.T) {
+		var h header
+		body, err := Parse([]byte("---\n---\nbody"), &h)
+		require.NoError(t, err)
+		assert.Equal(t, "body", body)
+	})
+
+	t.Run("Unclosed", func(t *testing.T) {
+		var h header
+		_, err := Parse([]byte("---\nname: x\nbody"), &h)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidYAML", func(t *testing.T) {
+		var h header
+		_, err := Parse([]byte("---\nname: [\n---\nbody"), &h)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,204 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// WrapTool wraps a tool to run PreToolUse and PostToolUse hooks around its execution.
+// PreToolUse hooks run in the tool's Prepare step, before the permission check, so
+// params they rewrite are checked like any others. Tools without matching hooks
+// are returned unchanged.
+func (m *Manager) WrapTool(tool agent.AgentTool) agent.AgentTool {
+	toolName := tool.Tool.Name
+	if !m.HasHooks(PreToolUse, toolName) && !m.HasHooks(PostToolUse, toolName) {
+		return tool
+	}
+
+	originalPrepare := tool.Prepare
+	originalExecute := tool.Execute
+	originalFinish := tool.Finish
+
+	// Context added by PreToolUse hooks, by tool call ID, until the call is over
+	var (
+		mu         sync.Mutex
+		preContext = make(map[string][]string)
+	)
+
+	wrappedPrepare := func(ctx context.Context, toolCallID string, params map[string]any) (map[string]any, error) {
+		if originalPrepare != nil {
+			var err error
+			if params, err = originalPrepare(ctx, toolCallID, params); err != nil {
+				return nil, err
+			}
+		}
+
+		// Pre-execution hooks may block the call or rewrite its params
+		pre := m.Run(ctx, Input{
+			HookEventName: PreToolUse,
+			ToolName:      toolName,
+			ToolCallID:    toolCallID,
+			ToolInput:     params,
+		})
+		if pre.Blocked {
+			return nil, fmt.Errorf("tool call blocked by hook: %s", pre.Reason)
+		}
+		if pre.ToolInput != nil {
+			params = pre.ToolInput
+		}
+		if len(pre.AdditionalContext) > 0 {
+			mu.Lock()
+			preContext[toolCallID] = pre.AdditionalContext
+			mu.Unlock()
+		}
+		return params, nil
+	}
+
+	wrappedExecute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		mu.Lock()
+		extra := preContext[toolCallID]
+		delete(preContext, toolCallID)
+		mu.Unlock()
+
+		// Execute original tool
+		result, err := originalExecute(ctx, toolCallID, params, onUpdate)
+		if err != nil {
+			return result, err
+		}
+
+		// Post-execution hooks may add feedback for the model
+		post := m.Run(ctx, Input{
+			HookEventName: PostToolUse,
+			ToolName:      toolName,
+			ToolCallID:    toolCallID,
+			ToolInput:     params,
+			ToolResponse: &ToolResponse{
+				Content: contentText(result.Content),
+				IsError: result.IsError,
+				Details: result.Details,
+			},
+		})
+
+		for _, text := range append(extra, post.AdditionalContext...) {
+			result.Content = append(result.Content, ai.NewTextContent(text))
+		}
+		if post.Blocked {
+			result.Content = append(result.Content, ai.NewTextContent(fmt.Sprintf("Hook feedback: %s", post.Reason)))
+		}
+
+		return result, nil
+	}
+
+	// Calls that were denied after Prepare never run, so drop their context here
+	wrappedFinish := func(toolCallID string) {
+		mu.Lock()
+		delete(preContext, toolCallID)
+		mu.Unlock()
+
+		if originalFinish != nil {
+			originalFinish(toolCallID)
+		}
+	}
+
+	wrapped := tool
+	wrapped.Prepare = wrappedPrepare
+	wrapped.Execute = wrappedExecute
+	wrapped.Finish = wrappedFinish
+	return wrapped
+}
+
+// WrapAllTools wraps all tools with PreToolUse and PostToolUse hooks
+func (m *Manager) WrapAllTools(tools []agent.AgentTool) []agent.AgentTool {
+	wrapped := make([]agent.AgentTool, len(tools))
+	for i, tool := range tools {
+		wrapped[i] = m.WrapTool(tool)
+	}
+	return wrapped
+}
+
+// OnPromptSubmit runs UserPromptSubmit hooks. Blocking rejects the prompts; extra
+// context is appended to the last prompt. It implements agent.LoopHooks.
+func (m *Manager) OnPromptSubmit(ctx context.Context, prompts []agent.AgentMessage) ([]agent.AgentMessage, error) {
+	if !m.HasHooks(UserPromptSubmit, "") {
+		return prompts, nil
+	}
+
+	var texts []string
+	for _, prompt := range prompts {
+		if msg, ok := prompt.Message.(ai.UserMessage); ok {
+			if text := contentText(msg.Content); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+
+	result := m.Run(ctx, Input{
+		HookEventName: UserPromptSubmit,
+		Prompt:        strings.Join(texts, "\n"),
+	})
+	if result.Blocked {
+		return nil, fmt.Errorf("prompt blocked by hook: %s", result.Reason)
+	}
+	if len(result.AdditionalContext) == 0 {
+		return prompts, nil
+	}
+
+	// Attach the context to the last user prompt
+	updated := make([]agent.AgentMessage, len(prompts))
+	copy(updated, prompts)
+	for i := len(updated) - 1; i >= 0; i-- {
+		msg, ok := updated[i].Message.(ai.UserMessage)
+		if !ok {
+			continue
+		}
+		content := make([]ai.Content, len(msg.Content), len(msg.Content)+len(result.AdditionalContext))
+		copy(content, msg.Content)
+		for _, extra := range result.AdditionalContext {
+			content = append(content, ai.NewTextContent(extra))
+		}
+		msg.Content = content
+		updated[i].Message = msg
+		break
+	}
+
+	return updated, nil
+}
+
+// OnStop runs Stop hooks. When one blocks, its reason is returned so the agent
+// keeps going with it as the next instruction. It implements agent.LoopHooks.
+func (m *Manager) OnStop(ctx context.Context, messages []agent.AgentMessage, stopHookActive bool) (string, error) {
+	if !m.HasHooks(Stop, "") {
+		return "", nil
+	}
+
+	result := m.Run(ctx, Input{
+		HookEventName:  Stop,
+		StopHookActive: stopHookActive,
+	})
+	if result.Blocked {
+		return result.Reason, nil
+	}
+
+	return "", nil
+}
+
+// contentText joins the text parts of content
+func contentText(content []ai.Content) string {
+	var parts []string
+	for _, c := range content {
+		if text, ok := c.(ai.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,266 @@
+// Package hooks runs user-configured shell commands at points in the agent lifecycle.
+//
+// Hooks are declared in settings.json under "hooks", keyed by event name:
+//
+//	{"hooks": {"PreToolUse": [{"matcher": "bash|write", "hooks": [{"type": "command", "command": "./check.sh"}]}]}}
+//
+// Each command receives a JSON Input on stdin. Exit code 0 succeeds, and stdout may
+// carry a JSON Output. Exit code 2 blocks the action with stderr as the reason. Any
+// other exit code is reported and otherwise ignored.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hook events
+const (
+	PreToolUse       = "PreToolUse"
+	PostToolUse      = "PostToolUse"
+	UserPromptSubmit = "UserPromptSubmit"
+	Stop             = "Stop"
+)
+
+// DefaultTimeout is used for commands that don't set a timeout
+const DefaultTimeout = 60 * time.Second
+
+// blockExitCode is the exit code a command uses to block the action
+const blockExitCode = 2
+
+// Command is a shell command run for an event
+type Command struct {
+	Type    string `koanf:"type" json:"type"`       // Only "command" is supported
+	Command string `koanf:"command" json:"command"` // Run with sh -c in the working directory
+	Timeout int    `koanf:"timeout" json:"timeout"` // Seconds (default: 60)
+}
+
+// Matcher selects the tools a group of commands applies to
+type Matcher struct {
+	Matcher string    `koanf:"matcher" json:"matcher"` // Tool name regex; empty or "*" matches every tool
+	Hooks   []Command `koanf:"hooks" json:"hooks"`
+}
+
+// Config maps event names to their matchers
+type Config map[string][]Matcher
+
+// ToolResponse describes a tool result in PostToolUse input
+type ToolResponse struct {
+	Content string `json:"content"`
+	IsError bool   `json:"is_error"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Input is the JSON payload written to a command's stdin
+type Input struct {
+	SessionID      string         `json:"session_id,omitempty"`
+	HookEventName  string         `json:"hook_event_name"`
+	Cwd            string         `json:"cwd"`
+	ToolName       string         `json:"tool_name,omitempty"`
+	ToolCallID     string         `json:"tool_call_id,omitempty"`
+	ToolInput      map[string]any `json:"tool_input,omitempty"`
+	ToolResponse   *ToolResponse  `json:"tool_response,omitempty"`
+	Prompt         string         `json:"prompt,omitempty"`
+	StopHookActive bool           `json:"stop_hook_active,omitempty"`
+}
+
+// Output is the optional JSON a command prints on stdout
+type Output struct {
+	Decision          string         `json:"decision,omitempty"`           // "block" to block the action
+	Reason            string         `json:"reason,omitempty"`             // Fed back to the model when blocking
+	ToolInput         map[string]any `json:"tool_input,omitempty"`         // PreToolUse: replacement tool params
+	AdditionalContext string         `json:"additional_context,omitempty"` // Extra context for the model
+}
+
+// Result is the combined outcome of the commands run for an event
+type Result struct {
+	Blocked           bool
+	Reason            string
+	ToolInput         map[string]any // Set when a PreToolUse command rewrote the params
+	AdditionalContext []string
+}
+
+// Manager runs the configured hooks
+type Manager struct {
+	config     Config
+	workingDir string
+	logger     *slog.Logger
+
+	mu        sync.RWMutex
+	sessionID string
+}
+
+// NewManager creates a hook manager for commands run in workingDir
+func NewManager(config Config, workingDir string, logger *slog.Logger) *Manager {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{
+		config:     config,
+		workingDir: workingDir,
+		logger:     logger,
+	}
+}
+
+// SetSessionID sets the session ID sent to commands
+func (m *Manager) SetSessionID(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionID = sessionID
+}
+
+// HasHooks reports whether any command is configured for event and tool name
+func (m *Manager) HasHooks(event, toolName string) bool {
+	return len(m.commands(event, toolName)) > 0
+}
+
+// Run runs the commands configured for input.HookEventName in order.
+// A rewritten tool input is passed on to later commands, and the first block stops the run.
+func (m *Manager) Run(ctx context.Context, input Input) *Result {
+	m.mu.RLock()
+	input.SessionID = m.sessionID
+	m.mu.RUnlock()
+	input.Cwd = m.workingDir
+
+	result := &Result{}
+	for _, command := range m.commands(input.HookEventName, input.ToolName) {
+		output, err := m.runCommand(ctx, command, input)
+		if err != nil {
+			m.logger.Warn("hook command failed",
+				"event", input.HookEventName,
+				"command", command.Command,
+				"error", err)
+			continue
+		}
+		if output == nil {
+			continue
+		}
+
+		if output.AdditionalContext != "" {
+			result.AdditionalContext = append(result.AdditionalContext, output.AdditionalContext)
+		}
+		if output.ToolInput != nil && input.HookEventName == PreToolUse {
+			input.ToolInput = output.ToolInput
+			result.ToolInput = output.ToolInput
+		}
+		if isBlock(output.Decision) {
+			result.Blocked = true
+			result.Reason = output.Reason
+			if result.Reason == "" {
+				result.Reason = fmt.Sprintf("blocked by %s hook", input.HookEventName)
+			}
+			return result
+		}
+	}
+
+	return result
+}
+
+// commands returns the commands configured for event that match toolName
+func (m *Manager) commands(event, toolName string) []Command {
+	var commands []Command
+	for _, matcher := range m.config[event] {
+		if !matchesTool(matcher.Matcher, toolName) {
+			continue
+		}
+		for _, command := range matcher.Hooks {
+			if (command.Type == "" || command.Type == "command") && command.Command != "" {
+				commands = append(commands, command)
+			}
+		}
+	}
+	return commands
+}
+
+// matchesTool reports whether pattern matches the whole tool name, ignoring case.
+// Events without a tool (toolName == "") match every pattern.
+func matchesTool(pattern, toolName string) bool {
+	if pattern == "" || pattern == "*" || toolName == "" {
+		return true
+	}
+
+	re, err := regexp.Compile("(?i)^(?:" + pattern + ")$")
+	if err != nil {
+		return strings.EqualFold(pattern, toolName)
+	}
+	return re.MatchString(toolName)
+}
+
+// isBlock reports whether a decision blocks the action
+func isBlock(decision string) bool {
+	switch strings.ToLower(decision) {
+	case "block", "deny":
+		return true
+	}
+	return false
+}
+
+// runCommand runs a single command and interprets its exit code and output.
+// It returns a nil output when the command succeeded without saying anything.
+func (m *Manager) runCommand(ctx context.Context, command Command, input Input) (*Output, error) {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode hook input: %w", err)
+	}
+
+	timeout := DefaultTimeout
+	if command.Timeout > 0 {
+		timeout = time.Duration(command.Timeout) * time.Second
+	}
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command.Command)
+	cmd.Dir = m.workingDir
+	cmd.Env = append(os.Environ(), "CC_PROJECT_DIR="+m.workingDir)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // Don't wait on background children holding the pipes
+
+	err = cmd.Run()
+	if cmdCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %v", timeout)
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		if exitErr.ExitCode() == blockExitCode {
+			return &Output{Decision: "block", Reason: strings.TrimSpace(stderr.String())}, nil
+		}
+		return nil, fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+	}
+
+	text := strings.TrimSpace(stdout.String())
+	if text == "" {
+		return nil, nil
+	}
+
+	// JSON output controls the action; plain text is only used as prompt context
+	if strings.HasPrefix(text, "{") {
+		var output Output
+		if err := json.Unmarshal([]byte(text), &output); err != nil {
+			return nil, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		return &output, nil
+	}
+	if input.HookEventName == UserPromptSubmit {
+		return &Output{AdditionalContext: text}, nil
+	}
+
+	return nil, nil
+}
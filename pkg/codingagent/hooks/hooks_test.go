@@ -0,0 +1,219 @@
+package hooks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+func newTestManager(t *testing.T, config Config) *Manager {
+	t.Helper()
+	return NewManager(config, t.TempDir(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func commandHook(matcher, command string) []Matcher {
+	return []Matcher{{Matcher: matcher, Hooks: []Command{{Type: "command", Command: command}}}}
+}
+
+func echoTool() agent.AgentTool {
+	tool := ai.NewTool("bash", "Echo", map[string]any{})
+	return agent.NewAgentTool(tool, "Bash", func(ctx context.Context, id string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		command, _ := params["command"].(string)
+		return agent.AgentToolResult{Content: []ai.Content{ai.NewTextContent("ran: " + command)}}, nil
+	})
+}
+
+func resultText(result agent.AgentToolResult) []string {
+	var texts []string
+	for _, c := range result.Content {
+		texts = append(texts, c.(ai.TextContent).Text)
+	}
+	return texts
+}
+
+func TestMatchesTool(t *testing.T) {
+	assert.True(t, matchesTool("", "bash"))
+	assert.True(t, matchesTool("*", "bash"))
+	assert.True(t, matchesTool("bash|write", "write"))
+	assert.True(t, matchesTool("Bash", "bash"))
+	assert.False(t, matchesTool("bash", "bash_output"))
+	assert.False(t, matchesTool("write", "read"))
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ReceivesInput", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("bash", "cat > input.json")})
+		m.SetSessionID("session-1")
+
+		result := m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash", ToolInput: map[string]any{"command": "ls"}})
+		assert.False(t, result.Blocked)
+
+		data, err := os.ReadFile(filepath.Join(m.workingDir, "input.json"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"session_id":"session-1"`)
+		assert.Contains(t, string(data), `"tool_input":{"command":"ls"}`)
+		assert.Contains(t, string(data), `"cwd":"`+m.workingDir+`"`)
+	})
+
+	t.Run("ExitCodeTwoBlocks", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("", "echo 'no rm allowed' >&2; exit 2")})
+
+		result := m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash"})
+		assert.True(t, result.Blocked)
+		assert.Equal(t, "no rm allowed", result.Reason)
+	})
+
+	t.Run("OtherExitCodesAreIgnored", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("", "exit 1")})
+
+		result := m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash"})
+		assert.False(t, result.Blocked)
+	})
+
+	t.Run("UnmatchedToolIsSkipped", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("write", "exit 2")})
+
+		assert.False(t, m.HasHooks(PreToolUse, "bash"))
+		assert.False(t, m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash"}).Blocked)
+	})
+
+	t.Run("JSONOutput", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("", `echo '{"tool_input": {"command": "ls -a"}, "additional_context": "note"}'`)})
+
+		result := m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash"})
+		assert.False(t, result.Blocked)
+		assert.Equal(t, map[string]any{"command": "ls -a"}, result.ToolInput)
+		assert.Equal(t, []string{"note"}, result.AdditionalContext)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: []Matcher{{Hooks: []Command{{Command: "sleep 5; exit 2", Timeout: 1}}}}})
+
+		result := m.Run(ctx, Input{HookEventName: PreToolUse, ToolName: "bash"})
+		assert.False(t, result.Blocked)
+	})
+}
+
+func TestWrapTool(t *testing.T) {
+	ctx := context.Background()
+	params := map[string]any{"command": "rm -rf build"}
+
+	t.Run("NoHooks", func(t *testing.T) {
+		m := newTestManager(t, Config{})
+		tool := m.WrapTool(echoTool())
+
+		result, err := tool.Execute(ctx, "call-1", params, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ran: rm -rf build"}, resultText(result))
+	})
+
+	t.Run("PreToolUseBlocks", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("bash", `echo '{"decision": "block", "reason": "use make clean"}'`)})
+		tool := m.WrapTool(echoTool())
+
+		_, err := tool.Prepare(ctx, "call-1", params)
+		assert.ErrorContains(t, err, "use make clean")
+	})
+
+	t.Run("PreToolUseRewritesParams", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("bash", `echo '{"tool_input": {"command": "make clean"}}'`)})
+		tool := m.WrapTool(echoTool())
+
+		// The rewrite happens before the permission check, in Prepare
+		prepared, err := tool.Prepare(ctx, "call-1", params)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"command": "make clean"}, prepared)
+
+		result, err := tool.Execute(ctx, "call-1", prepared, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ran: make clean"}, resultText(result))
+	})
+
+	t.Run("PreToolUseContext", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("bash", `echo '{"additional_context": "Deletes are logged"}'`)})
+		tool := m.WrapTool(echoTool())
+
+		prepared, err := tool.Prepare(ctx, "call-1", params)
+		require.NoError(t, err)
+		result, err := tool.Execute(ctx, "call-1", prepared, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ran: rm -rf build", "Deletes are logged"}, resultText(result))
+	})
+
+	t.Run("PreToolUseContextDroppedOnFinish", func(t *testing.T) {
+		m := newTestManager(t, Config{PreToolUse: commandHook("bash", `echo '{"additional_context": "Deletes are logged"}'`)})
+		tool := m.WrapTool(echoTool())
+
+		// A call denied after Prepare is finished without running
+		_, err := tool.Prepare(ctx, "call-1", params)
+		require.NoError(t, err)
+		tool.Finish("call-1")
+
+		result, err := tool.Execute(ctx, "call-1", params, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ran: rm -rf build"}, resultText(result))
+	})
+
+	t.Run("PostToolUseFeedback", func(t *testing.T) {
+		m := newTestManager(t, Config{
+			PostToolUse: commandHook("bash", `grep -q '"content":"ran: rm -rf build"' && echo 'check the build dir' >&2 && exit 2`),
+		})
+		tool := m.WrapTool(echoTool())
+
+		result, err := tool.Execute(ctx, "call-1", params, nil)
+		require.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Equal(t, []string{"ran: rm -rf build", "Hook feedback: check the build dir"}, resultText(result))
+	})
+}
+
+func TestLoopHooks(t *testing.T) {
+	ctx := context.Background()
+	prompts := []agent.AgentMessage{agent.NewAgentMessage(ai.NewUserTextMessage("deploy it"), "1", 0)}
+
+	t.Run("PromptSubmitContext", func(t *testing.T) {
+		m := newTestManager(t, Config{UserPromptSubmit: commandHook("", "echo 'Current branch: main'")})
+
+		updated, err := m.OnPromptSubmit(ctx, prompts)
+		require.NoError(t, err)
+		require.Len(t, updated, 1)
+
+		msg := updated[0].Message.(ai.UserMessage)
+		require.Len(t, msg.Content, 2)
+		assert.Equal(t, "Current branch: main", msg.Content[1].(ai.TextContent).Text)
+
+		// The original prompt is left untouched
+		assert.Len(t, prompts[0].Message.(ai.UserMessage).Content, 1)
+	})
+
+	t.Run("PromptSubmitBlocks", func(t *testing.T) {
+		m := newTestManager(t, Config{UserPromptSubmit: commandHook("", `grep -q deploy && echo 'deploys are frozen' >&2 && exit 2`)})
+
+		_, err := m.OnPromptSubmit(ctx, prompts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "deploys are frozen")
+	})
+
+	t.Run("StopBlocks", func(t *testing.T) {
+		m := newTestManager(t, Config{Stop: commandHook("", `grep -q '"stop_hook_active":true' || { echo 'run the tests' >&2; exit 2; }`)})
+
+		reason, err := m.OnStop(ctx, nil, false)
+		require.NoError(t, err)
+		assert.Equal(t, "run the tests", reason)
+
+		reason, err = m.OnStop(ctx, nil, true)
+		require.NoError(t, err)
+		assert.Empty(t, reason)
+	})
+}
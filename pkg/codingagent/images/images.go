@@ -0,0 +1,205 @@
+// Package images prepares images for the model: it loads them, shrinks them to
+// sizes every provider accepts and checks that the model can see them.
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Decode GIFs
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Limits every provider accepts. Base64 grows data by a third, so MaxBytes
+// keeps the encoded image under 5 MB.
+const (
+	MaxBytes     = 3750 * 1024 // Bytes of an image before encoding
+	MaxDimension = 2000        // Pixels of the longer side
+)
+
+// maxPixels bounds the images decoded to be scaled down. A small file can
+// claim huge dimensions, and decoding allocates 4 bytes per pixel up front.
+const maxPixels = 50_000_000
+
+// jpegQuality is used when an image has to be re-encoded to fit
+const jpegQuality = 85
+
+// mediaTypes maps image extensions to the media types the providers accept
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// MediaType returns the media type of an image path, or "" if the path is not
+// an image the providers accept
+func MediaType(path string) string {
+	return mediaTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+// Load reads an image file and prepares it for the model
+func Load(path string) (ai.ImageContent, error) {
+	mediaType := MediaType(path)
+	if mediaType == "" {
+		return ai.ImageContent{}, fmt.Errorf("%s is not a PNG, JPEG, GIF or WebP image", filepath.Base(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ai.ImageContent{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	return FromBytes(data, mediaType)
+}
+
+// FromBase64 prepares base64-encoded image data for the model
+func FromBase64(data, mediaType string) (ai.ImageContent, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return ai.ImageContent{}, fmt.Errorf("invalid base64 image data: %w", err)
+	}
+	return FromBytes(raw, mediaType)
+}
+
+// FromBytes prepares image data for the model. Images larger than MaxDimension
+// or MaxBytes are scaled down; the media type is sniffed when empty.
+func FromBytes(data []byte, mediaType string) (ai.ImageContent, error) {
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return ai.ImageContent{}, fmt.Errorf("not an image (%s)", mediaType)
+	}
+
+	data, mediaType, err := fit(data, mediaType)
+	if err != nil {
+		return ai.ImageContent{}, err
+	}
+	return ai.NewImageContentFromBase64(base64.StdEncoding.EncodeToString(data), mediaType), nil
+}
+
+// fit returns the image unchanged if it is within the limits, or else scaled
+// down and re-encoded until it is
+func fit(data []byte, mediaType string) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// Formats without a decoder, such as WebP, are sent as they are
+		if len(data) <= MaxBytes {
+			return data, mediaType, nil
+		}
+		return nil, "", fmt.Errorf("image is too large (%d KB, limit %d KB) and can't be scaled down", len(data)/1024, MaxBytes/1024)
+	}
+	if len(data) <= MaxBytes && max(config.Width, config.Height) <= MaxDimension {
+		return data, mediaType, nil
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, "", fmt.Errorf("image is too large to scale down (%dx%d pixels, limit %d megapixels)", config.Width, config.Height, maxPixels/1_000_000)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	longest := min(max(config.Width, config.Height), MaxDimension)
+	for longest >= 16 {
+		scaled := scale(img, longest)
+
+		var buf bytes.Buffer
+		outType := "image/jpeg"
+		if mediaType == "image/png" {
+			// Screenshots stay sharp as PNG when that fits
+			outType = "image/png"
+			err = png.Encode(&buf, scaled)
+			if err == nil && buf.Len() > MaxBytes {
+				buf.Reset()
+				outType = "image/jpeg"
+				err = jpeg.Encode(&buf, opaque(scaled), &jpeg.Options{Quality: jpegQuality})
+			}
+		} else {
+			err = jpeg.Encode(&buf, opaque(scaled), &jpeg.Options{Quality: jpegQuality})
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		if buf.Len() <= MaxBytes {
+			return buf.Bytes(), outType, nil
+		}
+		longest = longest * 3 / 4
+	}
+	return nil, "", fmt.Errorf("image is too large to send")
+}
+
+// scale returns img scaled so that its longer side is longest pixels, by
+// averaging the source pixels that fall into each target pixel
+func scale(img image.Image, longest int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if max(w, h) <= longest {
+		return img
+	}
+	dw, dh := w*longest/max(w, h), h*longest/max(w, h)
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// opaque returns img drawn over white, since JPEG has no transparency
+func opaque(img image.Image) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// Count returns the number of images in contents
+func Count(contents []ai.Content) int {
+	n := 0
+	for _, content := range contents {
+		if _, ok := content.(ai.ImageContent); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// CheckModel returns an error if contents hold images the model can't see
+func CheckModel(model ai.Model, contents []ai.Content) error {
+	if n := Count(contents); n > 0 && !model.SupportsVision {
+		return fmt.Errorf("model %s does not support images (%d attached); switch to a model with vision support", model.ID, n)
+	}
+	return nil
+}
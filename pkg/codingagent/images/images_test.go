@@ -0,0 +1,124 @@
+package images
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodePNG returns a w x h PNG, filled with noise when noisy so it compresses badly
+func encodePNG(t *testing.T, w, h int, noisy bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewSource(1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255}
+			if noisy {
+				c = color.RGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), B: uint8(r.Intn(256)), A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// decode returns the decoded data of an image content
+func decode(t *testing.T, content ai.ImageContent) (image.Config, string, []byte) {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(content.Source.Data)
+	require.NoError(t, err)
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	return config, format, data
+}
+
+func TestFromBytes(t *testing.T) {
+	t.Run("SmallImagesAreKept", func(t *testing.T) {
+		data := encodePNG(t, 40, 20, false)
+		content, err := FromBytes(data, "")
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", content.Source.MediaType)
+		_, _, got := decode(t, content)
+		assert.Equal(t, data, got)
+	})
+
+	t.Run("LargeImagesAreScaledDown", func(t *testing.T) {
+		content, err := FromBytes(encodePNG(t, 3000, 1500, false), "image/png")
+		require.NoError(t, err)
+		config, format, _ := decode(t, content)
+		assert.Equal(t, "png", format)
+		assert.Equal(t, MaxDimension, config.Width)
+		assert.Equal(t, MaxDimension/2, config.Height)
+	})
+
+	t.Run("HeavyImagesBecomeJPEG", func(t *testing.T) {
+		data := encodePNG(t, 1800, 1200, true)
+		require.Greater(t, len(data), MaxBytes)
+		content, err := FromBytes(data, "image/png")
+		require.NoError(t, err)
+		config, format, got := decode(t, content)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, "image/jpeg", content.Source.MediaType)
+		assert.LessOrEqual(t, len(got), MaxBytes)
+		assert.Equal(t, 1800, config.Width)
+	})
+
+	t.Run("HugeDimensionsAreRejected", func(t *testing.T) {
+		// Claim 100000 x 100000 pixels in the header of a tiny PNG
+		data := encodePNG(t, 1, 1, false)
+		binary.BigEndian.PutUint32(data[16:], 100000)
+		binary.BigEndian.PutUint32(data[20:], 100000)
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+		_, err := FromBytes(data, "image/png")
+		assert.ErrorContains(t, err, "too large to scale down")
+	})
+
+	t.Run("UnknownFormats", func(t *testing.T) {
+		content, err := FromBytes([]byte("RIFF....WEBP"), "image/webp")
+		require.NoError(t, err)
+		assert.Equal(t, "image/webp", content.Source.MediaType)
+
+		_, err = FromBytes(bytes.Repeat([]byte{1}, MaxBytes+1), "image/webp")
+		assert.ErrorContains(t, err, "can't be scaled down")
+
+		_, err = FromBytes([]byte("hello"), "")
+		assert.ErrorContains(t, err, "not an image")
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shot.PNG")
+	require.NoError(t, os.WriteFile(path, encodePNG(t, 10, 10, false), 0644))
+
+	content, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", content.Source.MediaType)
+
+	_, err = Load(filepath.Join(dir, "logo.svg"))
+	assert.ErrorContains(t, err, "not a PNG")
+	assert.Empty(t, MediaType("notes.txt"))
+}
+
+func TestCheckModel(t *testing.T) {
+	contents := []ai.Content{ai.NewTextContent("what is this?"), ai.NewImageContentFromBase64("", "image/png")}
+	assert.NoError(t, CheckModel(ai.Model{ID: "gpt-4o", SupportsVision: true}, contents))
+	assert.ErrorContains(t, CheckModel(ai.Model{ID: "deepseek-chat"}, contents), "deepseek-chat does not support images")
+	assert.NoError(t, CheckModel(ai.Model{ID: "deepseek-chat"}, contents[:1]))
+}
@@ -0,0 +1,337 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned for requests to a server that has exited
+var ErrClosed = errors.New("language server closed")
+
+// document is a file opened in a language server
+type document struct {
+	version int
+	text    string
+}
+
+// Client talks to one language server over stdio
+type Client struct {
+	name    string
+	rootDir string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	nextID      int
+	pending     map[int]chan *message
+	docs        map[string]*document
+	diagnostics map[string][]Diagnostic
+	published   map[string]int // Number of diagnostic reports per URI
+	changed     chan struct{}  // Closed and replaced when diagnostics arrive
+	done        chan struct{}  // Closed when the server's output ends
+}
+
+// StartClient starts a language server in rootDir and initializes it
+func StartClient(ctx context.Context, name string, config ServerConfig, rootDir string) (*Client, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Dir = rootDir
+	cmd.Stderr = io.Discard
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s stdin: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s stdout: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	c := &Client{
+		name:        name,
+		rootDir:     rootDir,
+		cmd:         cmd,
+		stdin:       stdin,
+		pending:     make(map[int]chan *message),
+		docs:        make(map[string]*document),
+		diagnostics: make(map[string][]Diagnostic),
+		published:   make(map[string]int),
+		changed:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(stdout))
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// initialize performs the initialize handshake
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := PathToURI(c.rootDir)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]any{
+			{"uri": rootURI, "name": filepath.Base(c.rootDir)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"dynamicRegistration": false},
+				"publishDiagnostics": map[string]any{"relatedInformation": false},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]any{"linkSupport": false},
+				"references":         map[string]any{},
+			},
+			"workspace": map[string]any{
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}
+
+	if err := c.Call(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+	return c.Notify("initialized", map[string]any{})
+}
+
+// Call sends a request and decodes the result into result, if not nil
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(fmt.Sprintf("%d", id))
+	if err := c.send(&message{ID: &rawID, Method: method, Params: mustMarshal(params)}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s failed: %w", method, resp.Error)
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("failed to parse %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification
+func (c *Client) Notify(method string, params any) error {
+	return c.send(&message{Method: method, Params: mustMarshal(params)})
+}
+
+// send writes a message to the server
+func (c *Client) send(msg *message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := writeMessage(c.stdin, msg); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// readLoop dispatches responses, diagnostics and server requests until the server exits
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			var id int
+			if err := json.Unmarshal(*msg.ID, &id); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+
+		case msg.ID != nil:
+			c.answer(msg)
+
+		case msg.Method == "textDocument/publishDiagnostics":
+			var params struct {
+				URI         string       `json:"uri"`
+				Diagnostics []Diagnostic `json:"diagnostics"`
+			}
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			c.diagnostics[params.URI] = params.Diagnostics
+			c.published[params.URI]++
+			close(c.changed)
+			c.changed = make(chan struct{})
+			c.mu.Unlock()
+		}
+	}
+}
+
+// answer replies to a request from the server. The client has no settings and
+// accepts registrations and progress tokens without acting on them.
+func (c *Client) answer(req *message) {
+	var result any
+	if req.Method == "workspace/configuration" {
+		var params struct {
+			Items []any `json:"items"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		result = make([]any, len(params.Items))
+	}
+	resp := &message{ID: req.ID, Result: json.RawMessage("null")}
+	if result != nil {
+		resp.Result = mustMarshal(result)
+	}
+	_ = c.send(resp)
+}
+
+// Sync opens a document or sends its new content, and reports whether anything was sent
+func (c *Client) Sync(path, languageID, text string) (bool, error) {
+	uri := PathToURI(path)
+
+	c.mu.Lock()
+	doc, ok := c.docs[uri]
+	if ok && doc.text == text {
+		c.mu.Unlock()
+		return false, nil
+	}
+	if !ok {
+		doc = &document{}
+		c.docs[uri] = doc
+	}
+	doc.version++
+	doc.text = text
+	version := doc.version
+	c.mu.Unlock()
+
+	if !ok {
+		return true, c.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri":        uri,
+				"languageId": languageID,
+				"version":    version,
+				"text":       text,
+			},
+		})
+	}
+	return true, c.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]any{{"text": text}},
+	})
+}
+
+// IsOpen reports whether the file was synced to the server
+func (c *Client) IsOpen(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.docs[PathToURI(path)]
+	return ok
+}
+
+// Diagnostics returns the latest diagnostics for a file and how many reports were received for it
+func (c *Client) Diagnostics(path string) ([]Diagnostic, int) {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diagnostics[uri], c.published[uri]
+}
+
+// WaitForDiagnostics waits until more than after reports were received for a
+// file, then until no new report arrives for settle, and returns the latest
+// diagnostics. It gives up after timeout and returns what it has.
+func (c *Client) WaitForDiagnostics(ctx context.Context, path string, after int, timeout, settle time.Duration) []Diagnostic {
+	uri := PathToURI(path)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		c.mu.Lock()
+		diagnostics, count, changed := c.diagnostics[uri], c.published[uri], c.changed
+		c.mu.Unlock()
+
+		wait := deadline.C
+		var quiet <-chan time.Time
+		if count > after {
+			quiet = time.After(settle)
+		}
+
+		select {
+		case <-changed:
+			continue
+		case <-quiet:
+			return diagnostics
+		case <-wait:
+		case <-c.done:
+		case <-ctx.Done():
+		}
+		return diagnostics
+	}
+}
+
+// Close shuts the server down, killing it if it doesn't exit in time
+func (c *Client) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Call(ctx, "shutdown", nil, nil); err == nil {
+		_ = c.Notify("exit", nil)
+	}
+	_ = c.stdin.Close()
+
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		_ = c.cmd.Process.Kill()
+	}
+	_ = c.cmd.Wait()
+}
+
+// mustMarshal encodes params, which are always plain maps and structs. Nil is left out.
+func mustMarshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("lsp: failed to encode %T: %v", v, err))
+	}
+	return data
+}
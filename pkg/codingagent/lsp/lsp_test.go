@@ -0,0 +1,277 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServerEnv makes the test binary act as a fake language server
+const fakeServerEnv = "CC_FAKE_LSP"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		runFakeServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer is a tiny language server for ".fake" files. Every line
+// containing ERROR gets an error diagnostic; definitions are "func <name>"
+// lines; references are all occurrences of the word; hover shows the word.
+func runFakeServer() {
+	r := bufio.NewReader(os.Stdin)
+	docs := make(map[string]string)
+
+	reply := func(msg *message, result any) {
+		_ = writeMessage(os.Stdout, &message{ID: msg.ID, Result: mustMarshal(result)})
+	}
+	publish := func(uri string) {
+		var diagnostics []Diagnostic
+		for i, line := range strings.Split(docs[uri], "\n") {
+			if col := strings.Index(line, "ERROR"); col >= 0 {
+				diagnostics = append(diagnostics, Diagnostic{
+					Range:    Range{Start: Position{Line: i, Character: toUTF16(line, col)}, End: Position{Line: i, Character: toUTF16(line, col+5)}},
+					Severity: SeverityError,
+					Source:   "fake",
+					Message:  "unexpected ERROR in " + strings.TrimSpace(line),
+				})
+			}
+		}
+		_ = writeMessage(os.Stdout, &message{Method: "textDocument/publishDiagnostics", Params: mustMarshal(map[string]any{
+			"uri":         uri,
+			"diagnostics": append([]Diagnostic{}, diagnostics...),
+		})})
+	}
+	wordAt := func(params json.RawMessage) (string, string) {
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Position Position `json:"position"`
+		}
+		_ = json.Unmarshal(params, &p)
+		line := strings.Split(docs[p.TextDocument.URI], "\n")[p.Position.Line]
+		offset := fromUTF16(line, p.Position.Character)
+		for _, loc := range regexp.MustCompile(`\w+`).FindAllStringIndex(line, -1) {
+			if loc[0] <= offset && offset < loc[1] {
+				return p.TextDocument.URI, line[loc[0]:loc[1]]
+			}
+		}
+		return p.TextDocument.URI, ""
+	}
+
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+
+		switch msg.Method {
+		case "initialize":
+			reply(msg, map[string]any{"capabilities": map[string]any{}})
+		case "initialized":
+			// Servers may ask the client for settings at any time
+			id := json.RawMessage(`"config-1"`)
+			_ = writeMessage(os.Stdout, &message{ID: &id, Method: "workspace/configuration", Params: mustMarshal(map[string]any{"items": []any{map[string]any{}}})})
+		case "textDocument/didOpen":
+			var p struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+			}
+			_ = json.Unmarshal(msg.Params, &p)
+			docs[p.TextDocument.URI] = p.TextDocument.Text
+			publish(p.TextDocument.URI)
+		case "textDocument/didChange":
+			var p struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+			}
+			_ = json.Unmarshal(msg.Params, &p)
+			docs[p.TextDocument.URI] = p.ContentChanges[len(p.ContentChanges)-1].Text
+			publish(p.TextDocument.URI)
+		case "textDocument/definition":
+			uri, word := wordAt(msg.Params)
+			var locations []Location
+			for i, line := range strings.Split(docs[uri], "\n") {
+				if col := strings.Index(line, "func "+word); col >= 0 {
+					locations = append(locations, Location{URI: uri, Range: Range{Start: Position{Line: i, Character: toUTF16(line, col+5)}}})
+				}
+			}
+			reply(msg, locations)
+		case "textDocument/references":
+			uri, word := wordAt(msg.Params)
+			var locations []Location
+			for i, line := range strings.Split(docs[uri], "\n") {
+				for _, loc := range regexp.MustCompile(`\b`+word+`\b`).FindAllStringIndex(line, -1) {
+					locations = append(locations, Location{URI: uri, Range: Range{Start: Position{Line: i, Character: toUTF16(line, loc[0])}}})
+				}
+			}
+			reply(msg, locations)
+		case "textDocument/hover":
+			_, word := wordAt(msg.Params)
+			reply(msg, map[string]any{"contents": map[string]any{"kind": "markdown", "value": "**" + word + "**"}})
+		case "shutdown":
+			reply(msg, nil)
+		case "exit":
+			return
+		}
+	}
+}
+
+// toUTF16 converts a byte offset in line to a UTF-16 offset
+func toUTF16(line string, offset int) int {
+	return utf16Offset(line, utf8.RuneCountInString(line[:offset]))
+}
+
+// fromUTF16 converts a UTF-16 offset in line to a byte offset
+func fromUTF16(line string, offset int) int {
+	return len(string([]rune(line)[:runeColumn(line, offset)]))
+}
+
+// newFakeManager returns a manager that serves .fake files with the fake server
+func newFakeManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	t.Setenv(fakeServerEnv, "1")
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	manager := NewManager(Config{
+		Enabled: true,
+		Servers: map[string]ServerConfig{"fake": {Command: executable, Extensions: []string{".fake"}}},
+	}, dir)
+	manager.diagnosticsSettle = 50 * time.Millisecond
+	t.Cleanup(manager.Close)
+	return manager
+}
+
+func TestDiagnosticsAfterEdit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.fake")
+	require.NoError(t, os.WriteFile(path, []byte("func main\nERROR already here\n"), 0644))
+
+	manager := newFakeManager(t, dir)
+	wrapped := manager.WrapAllTools([]agent.AgentTool{
+		tools.CreateEditTool(dir),
+		tools.CreateReadTool(dir),
+		tools.CreateWriteTool(dir),
+		tools.CreateApplyPatchTool(dir),
+	})
+	edit, write, applyPatch := wrapped[0], wrapped[2], wrapped[3]
+
+	// Problems the file had before the first edit are not new
+	result, err := edit.Execute(context.Background(), "call-1", map[string]any{
+		"file_path":  "main.fake",
+		"old_string": "func main",
+		"new_string": "func main()",
+	}, nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	assert.Len(t, result.Content, 1)
+
+	// Edits report only new problems
+	result, err = edit.Execute(context.Background(), "call-2", map[string]any{
+		"file_path":  "main.fake",
+		"old_string": "func main()",
+		"new_string": "func main()\nreturn ERROR",
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	text := result.Content[1].(ai.TextContent).Text
+	assert.Contains(t, text, "New diagnostics in main.fake")
+	assert.Contains(t, text, "2:8 error: unexpected ERROR in return ERROR")
+	assert.NotContains(t, text, "already here")
+
+	// Clean edits add nothing
+	result, err = edit.Execute(context.Background(), "call-3", map[string]any{
+		"file_path":  "main.fake",
+		"old_string": "return ERROR",
+		"new_string": "return nil",
+	}, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Content, 1)
+
+	// Every problem in a new file is new
+	result, err = write.Execute(context.Background(), "call-4", map[string]any{
+		"file_path": "new.fake",
+		"content":   "ERROR from the start\n",
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	text = result.Content[1].(ai.TextContent).Text
+	assert.Contains(t, text, "New diagnostics in new.fake")
+	assert.Contains(t, text, "1:1 error: unexpected ERROR in ERROR from the start")
+
+	// The files of a patch are only known afterwards, so their problems
+	// aren't called new
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.fake"), []byte("ERROR old\n"), 0644))
+	result, err = applyPatch.Execute(context.Background(), "call-5", map[string]any{
+		"patch": "*** Begin Patch\n*** Update File: other.fake\n@@\n ERROR old\n+func other\n*** End Patch\n",
+	}, nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+	require.Len(t, result.Content, 2)
+	text = result.Content[1].(ai.TextContent).Text
+	assert.Contains(t, text, "Diagnostics in other.fake after this change (some may predate it)")
+	assert.Contains(t, text, "1:1 error: unexpected ERROR in ERROR old")
+
+	// Other tools are untouched, and unknown file types are ignored
+	assert.Equal(t, "read", wrapped[1].Tool.Name)
+	_, _, err = manager.Diagnose(context.Background(), "notes.txt")
+	assert.Error(t, err)
+	assert.Error(t, manager.Baseline(context.Background(), "notes.txt"))
+}
+
+func TestNavigationTools(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.fake")
+	require.NoError(t, os.WriteFile(path, []byte("func helper\nfunc main\n  helper\n  héllo helper\n"), 0644))
+
+	manager := newFakeManager(t, dir)
+	ctx := context.Background()
+
+	result, err := CreateDefinitionTool(manager).Execute(ctx, "call-1", map[string]any{"file_path": "main.fake", "line": float64(3), "column": float64(4)}, nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+	assert.Equal(t, "main.fake:1:6: func helper", result.Content[0].(ai.TextContent).Text)
+
+	// Columns are characters, not UTF-16 units or bytes
+	result, err = CreateReferencesTool(manager).Execute(ctx, "call-2", map[string]any{"file_path": path, "line": float64(4), "column": float64(9)}, nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+	assert.Equal(t, "main.fake:1:6: func helper\nmain.fake:3:3: helper\nmain.fake:4:9: héllo helper", result.Content[0].(ai.TextContent).Text)
+
+	result, err = CreateHoverTool(manager).Execute(ctx, "call-3", map[string]any{"file_path": "main.fake", "line": float64(2), "column": float64(7)}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "**main**", result.Content[0].(ai.TextContent).Text)
+
+	for _, params := range []map[string]any{
+		{"file_path": "main.fake", "line": float64(0), "column": float64(1)},
+		{"file_path": "main.fake", "line": float64(99), "column": float64(1)},
+		{"file_path": "notes.txt", "line": float64(1), "column": float64(1)},
+	} {
+		result, err := CreateHoverTool(manager).Execute(ctx, "call-4", params, nil)
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+	}
+}
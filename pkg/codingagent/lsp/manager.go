@@ -0,0 +1,428 @@
+// Package lsp runs language servers so the agent learns about errors it
+// introduces and can navigate code by symbol.
+//
+// A Manager starts one server per language the first time a file with one of
+// its extensions is touched, keeps edited files in sync with didOpen/didChange,
+// and collects the diagnostics the servers publish.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures the language servers
+type Config struct {
+	Enabled bool `koanf:"enabled" json:"enabled"`
+
+	// Servers by language name. They are added to the defaults, replacing a
+	// default with the same name; a server without a command is disabled.
+	Servers map[string]ServerConfig `koanf:"servers" json:"servers"`
+}
+
+// ServerConfig describes how to start a language server
+type ServerConfig struct {
+	Command    string   `koanf:"command" json:"command"`
+	Args       []string `koanf:"args" json:"args"`
+	Extensions []string `koanf:"extensions" json:"extensions"` // File extensions, e.g. ".go"
+}
+
+// DefaultServers returns the servers used when they are installed
+func DefaultServers() map[string]ServerConfig {
+	return map[string]ServerConfig{
+		"go": {
+			Command:    "gopls",
+			Extensions: []string{".go"},
+		},
+		"python": {
+			Command:    "pyright-langserver",
+			Args:       []string{"--stdio"},
+			Extensions: []string{".py"},
+		},
+		"typescript": {
+			Command:    "typescript-language-server",
+			Args:       []string{"--stdio"},
+			Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"},
+		},
+	}
+}
+
+// languageIDs maps file extensions to LSP language identifiers
+var languageIDs = map[string]string{
+	".go":  "go",
+	".py":  "python",
+	".ts":  "typescript",
+	".tsx": "typescriptreact",
+	".js":  "javascript",
+	".jsx": "javascriptreact",
+	".mjs": "javascript",
+	".cjs": "javascript",
+}
+
+// Manager starts language servers on demand and routes files to them
+type Manager struct {
+	rootDir string
+	servers map[string]ServerConfig
+
+	// How long to wait for diagnostics after a change, and for more to follow
+	diagnosticsTimeout time.Duration
+	diagnosticsSettle  time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*Client
+	failed  map[string]error // Servers that could not start are not retried
+}
+
+// NewManager creates a manager for the project in rootDir
+func NewManager(config Config, rootDir string) *Manager {
+	servers := DefaultServers()
+	for name, server := range config.Servers {
+		servers[name] = server
+	}
+
+	return &Manager{
+		rootDir:            rootDir,
+		servers:            servers,
+		diagnosticsTimeout: 3 * time.Second,
+		diagnosticsSettle:  300 * time.Millisecond,
+		clients:            make(map[string]*Client),
+		failed:             make(map[string]error),
+	}
+}
+
+// serverFor finds the server that handles a file
+func (m *Manager) serverFor(path string) (string, ServerConfig, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		server := m.servers[name]
+		if server.Command == "" {
+			continue
+		}
+		for _, e := range server.Extensions {
+			if strings.EqualFold(e, ext) {
+				return name, server, true
+			}
+		}
+	}
+	return "", ServerConfig{}, false
+}
+
+// client returns the running server for a file, starting it if needed
+func (m *Manager) client(ctx context.Context, path string) (*Client, error) {
+	name, server, ok := m.serverFor(path)
+	if !ok {
+		return nil, fmt.Errorf("no language server configured for %s files", filepath.Ext(path))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if client, ok := m.clients[name]; ok {
+		select {
+		case <-client.done:
+			// The server died; start a new one
+			delete(m.clients, name)
+		default:
+			return client, nil
+		}
+	}
+	if err, ok := m.failed[name]; ok {
+		return nil, err
+	}
+
+	if _, err := exec.LookPath(server.Command); err != nil {
+		m.failed[name] = fmt.Errorf("language server %s is not installed: %w", server.Command, err)
+		return nil, m.failed[name]
+	}
+	client, err := StartClient(ctx, name, server, m.rootDir)
+	if err != nil {
+		m.failed[name] = err
+		return nil, err
+	}
+	m.clients[name] = client
+	return client, nil
+}
+
+// open syncs the file's content on disk to its server and reports whether it changed
+func (m *Manager) open(ctx context.Context, path string) (*Client, bool, error) {
+	path = m.resolve(path)
+	client, err := m.client(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	changed, err := client.Sync(path, languageID(path), string(data))
+	if err != nil {
+		return nil, false, err
+	}
+	return client, changed, nil
+}
+
+// Baseline syncs a file its server doesn't know yet and waits for its
+// diagnostics, so that Diagnose after a change reports only the problems the
+// change adds. A file that doesn't exist yet is opened empty.
+func (m *Manager) Baseline(ctx context.Context, path string) error {
+	path = m.resolve(path)
+	client, err := m.client(ctx, path)
+	if err != nil {
+		return err
+	}
+	if client.IsOpen(path) {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	_, count := client.Diagnostics(path)
+	if _, err := client.Sync(path, languageID(path), string(data)); err != nil {
+		return err
+	}
+	// An empty file has nothing to compare with
+	if len(data) > 0 {
+		client.WaitForDiagnostics(ctx, path, count, m.diagnosticsTimeout, m.diagnosticsSettle)
+	}
+	return nil
+}
+
+// Diagnose syncs a changed file and returns the errors and warnings its
+// server reports that were not reported before the change. If the server
+// didn't know the file before, as without a Baseline, it returns all of them
+// and reports false.
+func (m *Manager) Diagnose(ctx context.Context, path string) ([]Diagnostic, bool, error) {
+	path = m.resolve(path)
+	client, err := m.client(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	known := client.IsOpen(path)
+	before, count := client.Diagnostics(path)
+	_, changed, err := m.open(ctx, path)
+	if err != nil || !changed {
+		return nil, known, err
+	}
+	after := client.WaitForDiagnostics(ctx, path, count, m.diagnosticsTimeout, m.diagnosticsSettle)
+
+	return newProblems(before, after), known, nil
+}
+
+// Definition returns where the symbol at a zero-based line and rune column is defined
+func (m *Manager) Definition(ctx context.Context, path string, line, column int) ([]Location, error) {
+	var raw json.RawMessage
+	if err := m.positionRequest(ctx, "textDocument/definition", path, line, column, nil, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// References returns the uses of the symbol at a zero-based line and rune column, including its declaration
+func (m *Manager) References(ctx context.Context, path string, line, column int) ([]Location, error) {
+	var raw json.RawMessage
+	extra := map[string]any{"context": map[string]any{"includeDeclaration": true}}
+	if err := m.positionRequest(ctx, "textDocument/references", path, line, column, extra, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// Hover returns the documentation of the symbol at a zero-based line and rune column
+func (m *Manager) Hover(ctx context.Context, path string, line, column int) (string, error) {
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := m.positionRequest(ctx, "textDocument/hover", path, line, column, nil, &result); err != nil {
+		return "", err
+	}
+	return hoverText(result.Contents), nil
+}
+
+// positionRequest sends a request about a position in a file
+func (m *Manager) positionRequest(ctx context.Context, method, path string, line, column int, extra map[string]any, result any) error {
+	path = m.resolve(path)
+	client, _, err := m.open(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+	if line < 0 || line >= len(lines) {
+		return fmt.Errorf("line %d is outside the file (%d lines)", line+1, len(lines))
+	}
+
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": PathToURI(path)},
+		"position":     Position{Line: line, Character: utf16Offset(lines[line], column)},
+	}
+	for key, value := range extra {
+		params[key] = value
+	}
+	return client.Call(ctx, method, params, result)
+}
+
+// Close shuts down all servers
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*Client)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			client.Close()
+		}(client)
+	}
+	wg.Wait()
+}
+
+// resolve makes a path absolute relative to the project
+func (m *Manager) resolve(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.rootDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// relative returns path relative to the project when it is inside it
+func (m *Manager) relative(path string) string {
+	if rel, err := filepath.Rel(m.rootDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// languageID returns the LSP language identifier of a file
+func languageID(path string) string {
+	if id, ok := languageIDs[strings.ToLower(filepath.Ext(path))]; ok {
+		return id
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+// newProblems returns the errors and warnings in after that are not in before.
+// Diagnostics are compared without their position, which edits move around.
+func newProblems(before, after []Diagnostic) []Diagnostic {
+	seen := make(map[string]int)
+	for _, d := range before {
+		seen[problemKey(d)]++
+	}
+
+	var problems []Diagnostic
+	for _, d := range after {
+		if d.Severity > SeverityWarning {
+			continue
+		}
+		key := problemKey(d)
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		problems = append(problems, d)
+	}
+	return problems
+}
+
+// problemKey identifies a diagnostic regardless of where it is
+func problemKey(d Diagnostic) string {
+	return fmt.Sprintf("%d\x00%s\x00%s", d.Severity, d.Source, d.Message)
+}
+
+// parseLocations accepts a Location, a list of Locations or a list of LocationLinks
+func parseLocations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	type item struct {
+		URI                  string `json:"uri"`
+		Range                Range  `json:"range"`
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	var items []item
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var single item
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return nil, fmt.Errorf("failed to parse locations: %w", err)
+		}
+		items = []item{single}
+	}
+
+	locations := make([]Location, 0, len(items))
+	for _, it := range items {
+		if it.TargetURI != "" {
+			locations = append(locations, Location{URI: it.TargetURI, Range: it.TargetSelectionRange})
+		} else {
+			locations = append(locations, Location{URI: it.URI, Range: it.Range})
+		}
+	}
+	return locations, nil
+}
+
+// hoverText flattens MarkupContent, MarkedString and lists of them
+func hoverText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+
+	var markup struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &markup); err == nil && markup.Value != "" {
+		return strings.TrimSpace(markup.Value)
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		var parts []string
+		for _, item := range list {
+			if part := hoverText(item); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// readLines reads a file as lines
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return strings.Split(string(data), "\n"), nil
+}
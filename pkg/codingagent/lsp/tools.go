@@ -0,0 +1,259 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// maxLocations is the maximum number of locations listed in a tool result
+const maxLocations = 100
+
+// WrapTool appends new diagnostics for the changed files to the results of
+// write, edit, multi_edit and apply_patch. Files named by a file_path param get
+// a baseline before the change; the files of a patch are only known after it.
+// Other tools are returned unchanged.
+func (m *Manager) WrapTool(tool agent.AgentTool) agent.AgentTool {
+	switch tool.Tool.Name {
+	case "write", "edit", "multi_edit", "apply_patch":
+	default:
+		return tool
+	}
+
+	execute := tool.Execute
+	tool.Execute = func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		if path, ok := params["file_path"].(string); ok {
+			_ = m.Baseline(ctx, path)
+		}
+
+		result, err := execute(ctx, toolCallID, params, onUpdate)
+		if err != nil || result.IsError {
+			return result, err
+		}
+
+		for _, path := range changedPaths(params, result) {
+			problems, known, err := m.Diagnose(ctx, path)
+			if err != nil || len(problems) == 0 {
+				continue
+			}
+			result.Content = append(result.Content, ai.NewTextContent(formatDiagnostics(path, problems, known)))
+		}
+		return result, nil
+	}
+	return tool
+}
+
+// WrapAllTools wraps each tool with WrapTool
+func (m *Manager) WrapAllTools(tools []agent.AgentTool) []agent.AgentTool {
+	wrapped := make([]agent.AgentTool, len(tools))
+	for i, tool := range tools {
+		wrapped[i] = m.WrapTool(tool)
+	}
+	return wrapped
+}
+
+// changedPaths returns the files a successful write or edit left on disk
+func changedPaths(params map[string]any, result agent.AgentToolResult) []string {
+	if path, ok := params["file_path"].(string); ok {
+		return []string{path}
+	}
+
+	// apply_patch lists its files in the result details
+	details, _ := result.Details.(map[string]any)
+	files, _ := details["files"].([]map[string]any)
+	var paths []string
+	for _, file := range files {
+		if file["action"] == "delete" {
+			continue
+		}
+		if moveTo, _ := file["move_to"].(string); moveTo != "" {
+			paths = append(paths, moveTo)
+		} else if path, _ := file["path"].(string); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// formatDiagnostics lists diagnostics with one-based positions. Without known
+// diagnostics from before the change, they aren't called new.
+func formatDiagnostics(path string, diagnostics []Diagnostic, known bool) string {
+	var b strings.Builder
+	if known {
+		b.WriteString(fmt.Sprintf("New diagnostics in %s after this change:\n", path))
+	} else {
+		b.WriteString(fmt.Sprintf("Diagnostics in %s after this change (some may predate it):\n", path))
+	}
+	for _, d := range diagnostics {
+		b.WriteString(fmt.Sprintf("  %d:%d %s: %s", d.Range.Start.Line+1, d.Range.Start.Character+1, d.SeverityName(), d.Message))
+		if d.Source != "" {
+			b.WriteString(fmt.Sprintf(" (%s)", d.Source))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// positionParams is the input schema shared by the navigation tools
+func positionParams() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "Path to the file containing the symbol",
+			},
+			"line": map[string]any{
+				"type":        "number",
+				"description": "Line of the symbol (1-based)",
+			},
+			"column": map[string]any{
+				"type":        "number",
+				"description": "Column of any character of the symbol (1-based)",
+			},
+		},
+		"required": []string{"file_path", "line", "column"},
+	}
+}
+
+// parsePosition reads file_path, line and column, converting them to zero-based values
+func parsePosition(params map[string]any) (string, int, int, error) {
+	path, _ := params["file_path"].(string)
+	if path == "" {
+		return "", 0, 0, fmt.Errorf("file_path must be a non-empty string")
+	}
+	line, ok := params["line"].(float64)
+	if !ok || line < 1 {
+		return "", 0, 0, fmt.Errorf("line must be a positive number")
+	}
+	column, ok := params["column"].(float64)
+	if !ok || column < 1 {
+		return "", 0, 0, fmt.Errorf("column must be a positive number")
+	}
+	return path, int(line) - 1, int(column) - 1, nil
+}
+
+// CreateDefinitionTool creates the tool that finds where a symbol is defined
+func CreateDefinitionTool(manager *Manager) agent.AgentTool {
+	tool := ai.NewTool(
+		"lsp_definition",
+		"Find where the symbol at a position is defined, using the language server. More precise than searching for the name.",
+		positionParams(),
+	)
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		path, line, column, err := parsePosition(params)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		locations, err := manager.Definition(ctx, path, line, column)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(locations) == 0 {
+			return textResult("No definition found", 0), nil
+		}
+		return textResult(manager.formatLocations(locations), len(locations)), nil
+	}
+
+	return agent.NewAgentTool(tool, "Go to Definition", execute)
+}
+
+// CreateReferencesTool creates the tool that finds the uses of a symbol
+func CreateReferencesTool(manager *Manager) agent.AgentTool {
+	tool := ai.NewTool(
+		"lsp_references",
+		"Find all references to the symbol at a position, including its declaration, using the language server.",
+		positionParams(),
+	)
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		path, line, column, err := parsePosition(params)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		locations, err := manager.References(ctx, path, line, column)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(locations) == 0 {
+			return textResult("No references found", 0), nil
+		}
+		return textResult(manager.formatLocations(locations), len(locations)), nil
+	}
+
+	return agent.NewAgentTool(tool, "Find References", execute)
+}
+
+// CreateHoverTool creates the tool that shows the type and documentation of a symbol
+func CreateHoverTool(manager *Manager) agent.AgentTool {
+	tool := ai.NewTool(
+		"lsp_hover",
+		"Show the type signature and documentation of the symbol at a position, using the language server.",
+		positionParams(),
+	)
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		path, line, column, err := parsePosition(params)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		text, err := manager.Hover(ctx, path, line, column)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if text == "" {
+			text = "No information available"
+		}
+		return textResult(text, 0), nil
+	}
+
+	return agent.NewAgentTool(tool, "Hover", execute)
+}
+
+// formatLocations lists locations as path:line:column followed by the line's text
+func (m *Manager) formatLocations(locations []Location) string {
+	var b strings.Builder
+	files := make(map[string][]string)
+	for i, loc := range locations {
+		if i == maxLocations {
+			b.WriteString(fmt.Sprintf("... and %d more\n", len(locations)-maxLocations))
+			break
+		}
+
+		path := URIToPath(loc.URI)
+		lines, ok := files[path]
+		if !ok {
+			lines, _ = readLines(path)
+			files[path] = lines
+		}
+
+		line, column := loc.Range.Start.Line, loc.Range.Start.Character
+		text := ""
+		if line < len(lines) {
+			text = strings.TrimSpace(lines[line])
+			column = runeColumn(lines[line], column)
+		}
+		b.WriteString(fmt.Sprintf("%s:%d:%d: %s\n", m.relative(path), line+1, column+1, text))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// textResult creates a successful tool result
+func textResult(text string, count int) agent.AgentToolResult {
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent(text)},
+		Details: map[string]any{"count": count},
+	}
+}
+
+// errorResult creates an error tool result
+func errorResult(err error) agent.AgentToolResult {
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+		IsError: true,
+	}
+}
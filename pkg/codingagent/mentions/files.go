@@ -0,0 +1,232 @@
+package mentions
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxFiles bounds the number of files offered by the picker
+const maxFiles = 20000
+
+// skipDirs are directories left out when the project is not a git repository
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+	"__pycache__":  true,
+	"venv":         true,
+}
+
+// File is a project file or directory that can be mentioned
+type File struct {
+	Path    string // Relative to the project root, slash-separated; directories end in "/"
+	ModTime time.Time
+}
+
+// IsDir reports whether the file is a directory
+func (f File) IsDir() bool {
+	return strings.HasSuffix(f.Path, "/")
+}
+
+// ListFiles returns the files and directories under root, most recently
+// modified first. In a git repository, files ignored by .gitignore are left
+// out; elsewhere hidden and dependency directories are skipped.
+func ListFiles(ctx context.Context, root string) ([]File, error) {
+	paths, err := gitFiles(ctx, root)
+	if err != nil {
+		paths, err = walkFiles(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var files []File
+	dirs := make(map[string]time.Time)
+	for _, rel := range paths {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, File{Path: rel, ModTime: info.ModTime()})
+
+		// A directory is as recent as the newest file in it
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if info.ModTime().After(dirs[dir]) {
+				dirs[dir] = info.ModTime()
+			}
+		}
+	}
+	for dir, modTime := range dirs {
+		files = append(files, File{Path: dir + "/", ModTime: modTime})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].ModTime.After(files[j].ModTime)
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// gitFiles lists the tracked and untracked but not ignored files of a repository
+func gitFiles(ctx context.Context, root string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, rel := range bytes.Split(output, []byte{0}) {
+		// Files deleted from the worktree are still listed until committed
+		if len(rel) == 0 || seen[string(rel)] {
+			continue
+		}
+		seen[string(rel)] = true
+		paths = append(paths, string(rel))
+		if len(paths) >= maxFiles {
+			break
+		}
+	}
+	return paths, nil
+}
+
+// walkFiles lists the files under root, skipping hidden and dependency directories
+func walkFiles(ctx context.Context, root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != root && (strings.HasPrefix(name, ".") || skipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		if len(paths) >= maxFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// Match returns up to limit files whose paths contain the letters of query in
+// order, best matches first. Matches of the start of the file name, at the
+// start of words and in runs score higher; ties go to the most recent file,
+// so an empty query returns the most recent files.
+func Match(files []File, query string, limit int) []File {
+	type scored struct {
+		file  File
+		score int
+		index int
+	}
+
+	var matches []scored
+	for i, file := range files {
+		if score, ok := fuzzyScore(file.Path, query); ok {
+			matches = append(matches, scored{file: file, score: score, index: i})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].index < matches[j].index
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	result := make([]File, len(matches))
+	for i, m := range matches {
+		result[i] = m.file
+	}
+	return result
+}
+
+// fuzzyScore scores how well query matches target, and reports whether all of
+// its letters were found in order
+func fuzzyScore(target, query string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	t := []rune(strings.ToLower(target))
+	q := []rune(strings.ToLower(query))
+	base := len([]rune(target[:strings.LastIndex(strings.TrimSuffix(target, "/"), "/")+1]))
+
+	// Letters are matched greedily, from whichever first letter scores best
+	best, found := 0, false
+	for start := range t {
+		if t[start] != q[0] {
+			continue
+		}
+		score, qi, prev := 0, 0, -2
+		for ti := start; ti < len(t) && qi < len(q); ti++ {
+			if t[ti] != q[qi] {
+				continue
+			}
+			score++
+			if ti == prev+1 {
+				score += 5 // A run of matched letters
+			}
+			if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+				score += 3 // The start of a name or a word in it
+			}
+			if ti >= base {
+				score += 2 // The file name rather than its directory
+			}
+			prev = ti
+			qi++
+		}
+		if qi < len(q) {
+			break // Later starts can't match either
+		}
+		if !found || score > best {
+			best, found = score, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	score := best
+
+	// Typing the start of the file name, or all of it, is the strongest hint
+	name := strings.ToLower(path.Base(target))
+	query = strings.ToLower(query)
+	if strings.HasPrefix(name, query) {
+		score += 10
+		if strings.TrimSuffix(name, path.Ext(name)) == query {
+			score += 10
+		}
+	}
+	return score, true
+}
@@ -0,0 +1,213 @@
+// Package mentions finds the project files referenced as @path in a prompt and
+// attaches their contents to it.
+package mentions
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+)
+
+// Limits on what a mention attaches
+const (
+	maxTextBytes    = 256 * 1024 // Bytes of a text file
+	maxDirEntries   = 500        // Entries of a directory listing
+	binarySniffSize = 8000       // Bytes checked for NUL to detect binary files
+)
+
+// mentionPattern matches @path and @path#L10-40 at the start of the text or
+// after whitespace
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([^\s#]+)(?:#L(\d+)(?:-L?(\d+))?)?`)
+
+// attachmentPattern matches the opening tag of an attachment
+var attachmentPattern = regexp.MustCompile(`^<(file|directory) path="([^"]*)"(?: lines="([^"]*)")?>`)
+
+// Mention is a reference to a file, a range of its lines or a directory
+type Mention struct {
+	Path      string // As written, relative to the working directory or absolute
+	StartLine int    // First line to include, 0 for the whole file
+	EndLine   int    // Last line to include, 0 for the end of the file
+}
+
+// String returns the mention as it is written, without the @
+func (m Mention) String() string {
+	switch {
+	case m.StartLine == 0:
+		return m.Path
+	case m.EndLine == 0:
+		return fmt.Sprintf("%s#L%d", m.Path, m.StartLine)
+	default:
+		return fmt.Sprintf("%s#L%d-%d", m.Path, m.StartLine, m.EndLine)
+	}
+}
+
+// Parse returns the mentions in text, each once
+func Parse(text string) []Mention {
+	var mentions []Mention
+	seen := make(map[Mention]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		m := Mention{Path: strings.TrimRight(match[1], ".,;:!?)'\"")}
+		m.StartLine, _ = strconv.Atoi(match[2])
+		m.EndLine, _ = strconv.Atoi(match[3])
+		if m.EndLine == 0 && m.StartLine > 0 && match[3] == "" {
+			m.EndLine = m.StartLine
+		}
+		if m.Path == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		mentions = append(mentions, m)
+	}
+	return mentions
+}
+
+// Attach returns the content to send along with text for the files it
+// mentions: the text of files, listings of directories and images. Mentions
+// of paths that don't exist are not file references and are skipped; files
+// that can't be attached are reported in the error, after the rest.
+func Attach(workingDir, text string) ([]ai.Content, error) {
+	var (
+		contents []ai.Content
+		errs     []string
+	)
+	for _, m := range Parse(text) {
+		path := m.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		var content ai.Content
+		switch {
+		case info.IsDir():
+			content, err = attachDir(path, m)
+		case images.MediaType(path) != "":
+			content, err = images.Load(path)
+		default:
+			content, err = attachFile(path, m)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("@%s: %v", m, err))
+			continue
+		}
+		contents = append(contents, content)
+	}
+
+	if len(errs) > 0 {
+		return contents, fmt.Errorf("failed to attach %s", strings.Join(errs, "; "))
+	}
+	return contents, nil
+}
+
+// attachFile returns a file, or the mentioned lines of it, numbered like the read tool does
+func attachFile(path string, m Mention) (ai.Content, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReaderSize(file, 64*1024)
+	if head, _ := r.Peek(binarySniffSize); bytes.IndexByte(head, 0) >= 0 {
+		return nil, fmt.Errorf("binary file")
+	}
+
+	var b strings.Builder
+	lineNo, first, last := 0, 0, 0
+	truncated := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxTextBytes)
+	for scanner.Scan() {
+		lineNo++
+		if lineNo < m.StartLine {
+			continue
+		}
+		if m.EndLine > 0 && lineNo > m.EndLine {
+			break
+		}
+		if b.Len() > maxTextBytes {
+			truncated = true
+			break
+		}
+		if first == 0 {
+			first = lineNo
+		}
+		last = lineNo
+		fmt.Fprintf(&b, "%6d\t%s\n", lineNo, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if m.StartLine > 0 && first == 0 {
+		return nil, fmt.Errorf("the file has only %d lines", lineNo)
+	}
+
+	lines := ""
+	if m.StartLine > 0 || truncated {
+		lines = fmt.Sprintf(` lines="%d-%d"`, first, last)
+	}
+	body := b.String()
+	if truncated {
+		body += fmt.Sprintf("... (cut at %d KB; read the file for the rest)\n", maxTextBytes/1024)
+	}
+	return ai.NewTextContent(fmt.Sprintf("<file path=\"%s\"%s>\n%s</file>", m.Path, lines, body)), nil
+}
+
+// attachDir returns a listing of a directory, with subdirectories marked by a trailing /
+func attachDir(path string, m Mention) (ai.Content, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	more := ""
+	if len(names) > maxDirEntries {
+		more = fmt.Sprintf("... and %d more\n", len(names)-maxDirEntries)
+		names = names[:maxDirEntries]
+	}
+
+	listing := strings.Join(names, "\n")
+	if listing != "" {
+		listing += "\n"
+	}
+	dir := strings.TrimSuffix(m.Path, "/") + "/"
+	return ai.NewTextContent(fmt.Sprintf("<directory path=\"%s\">\n%s%s</directory>", dir, listing, more)), nil
+}
+
+// Describe returns a short label, such as "main.go#L10-40", for text that
+// Attach produced, and reports whether text is an attachment
+func Describe(text string) (string, bool) {
+	match := attachmentPattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", false
+	}
+	if match[3] != "" {
+		return fmt.Sprintf("%s#L%s", match[2], match[3]), true
+	}
+	return match[2], true
+}
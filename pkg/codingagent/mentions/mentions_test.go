@@ -0,0 +1,125 @@
+package mentions
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestParse(t *testing.T) {
+	mentions := Parse("Compare @main.go#L10-40 with @pkg/util.go#L7, see @docs/ and mail me@example.com.\n@main.go#L10-40")
+	assert.Equal(t, []Mention{
+		{Path: "main.go", StartLine: 10, EndLine: 40},
+		{Path: "pkg/util.go", StartLine: 7, EndLine: 7},
+		{Path: "docs/"},
+	}, mentions)
+	assert.Equal(t, "main.go#L10-40", mentions[0].String())
+	assert.Equal(t, "pkg/util.go#L7-7", mentions[1].String())
+	assert.Empty(t, Parse("no mentions here"))
+}
+
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n", now)
+	writeFile(t, filepath.Join(dir, "pkg", "util.go"), "package pkg\n", now)
+	writeFile(t, filepath.Join(dir, "shot.png"), "\x89PNG", now)
+	writeFile(t, filepath.Join(dir, "data.bin"), "a\x00b", now)
+
+	contents, err := Attach(dir, "Look at @main.go#L3-4 and @pkg, @shot.png, @data.bin and @nobody")
+	assert.ErrorContains(t, err, "@data.bin: binary file")
+	require.Len(t, contents, 3)
+
+	text := contents[0].(ai.TextContent).Text
+	assert.Equal(t, "<file path=\"main.go\" lines=\"3-4\">\n     3\tfunc main() {\n     4\t}\n</file>", text)
+	label, ok := Describe(text)
+	assert.True(t, ok)
+	assert.Equal(t, "main.go#L3-4", label)
+
+	listing := contents[1].(ai.TextContent).Text
+	assert.Equal(t, "<directory path=\"pkg/\">\nutil.go\n</directory>", listing)
+	label, _ = Describe(listing)
+	assert.Equal(t, "pkg/", label)
+
+	image := contents[2].(ai.ImageContent)
+	assert.Equal(t, "image/png", image.Source.MediaType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x89PNG")), image.Source.Data)
+
+	contents, err = Attach(dir, "@main.go")
+	require.NoError(t, err)
+	assert.Contains(t, contents[0].(ai.TextContent).Text, "<file path=\"main.go\">\n     1\tpackage main\n")
+
+	_, err = Attach(dir, "@main.go#L90")
+	assert.ErrorContains(t, err, "only 4 lines")
+
+	_, ok = Describe("plain text")
+	assert.False(t, ok)
+}
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	writeFile(t, filepath.Join(dir, "README.md"), "readme", old)
+	writeFile(t, filepath.Join(dir, "pkg", "util.go"), "package pkg", time.Now())
+	writeFile(t, filepath.Join(dir, "node_modules", "lib.js"), "", time.Now())
+	writeFile(t, filepath.Join(dir, ".hidden", "secret"), "", time.Now())
+
+	paths := func(files []File) []string {
+		var result []string
+		for _, f := range files {
+			result = append(result, f.Path)
+		}
+		return result
+	}
+
+	// Without git, hidden and dependency directories are skipped
+	files, err := ListFiles(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/", "pkg/util.go", "README.md"}, paths(files))
+	assert.True(t, files[0].IsDir())
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	require.NoError(t, exec.Command("git", "-C", dir, "init", "-q").Run())
+	writeFile(t, filepath.Join(dir, ".gitignore"), "pkg/\n", old)
+	files, err = ListFiles(context.Background(), dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{".gitignore", "README.md", ".hidden/", ".hidden/secret", "node_modules/", "node_modules/lib.js"}, paths(files))
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Now()
+	files := []File{
+		{Path: "internal/tui/editor.go", ModTime: now},
+		{Path: "docs/editors.md", ModTime: now.Add(-time.Minute)},
+		{Path: "pkg/codingagent/tools/edit.go", ModTime: now.Add(-time.Hour)},
+		{Path: "README.md", ModTime: now.Add(-2 * time.Hour)},
+	}
+
+	var names []string
+	for _, f := range Match(files, "edit", 10) {
+		names = append(names, f.Path)
+	}
+	assert.Equal(t, []string{"pkg/codingagent/tools/edit.go", "internal/tui/editor.go", "docs/editors.md"}, names)
+
+	assert.Equal(t, "README.md", Match(files, "rdme", 10)[0].Path)
+	assert.Empty(t, Match(files, "xyz", 10))
+	assert.Len(t, Match(files, "", 2), 2)
+	assert.Equal(t, "internal/tui/editor.go", Match(files, "", 2)[0].Path)
+}
@@ -0,0 +1,244 @@
+package repomap
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSignature caps the length of a rendered signature
+const maxSignature = 160
+
+// languages maps file extensions to the language used to parse them
+var languages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".ts":   "javascript",
+	".tsx":  "javascript",
+	".rs":   "rust",
+	".java": "java",
+	".kt":   "kotlin",
+	".cs":   "csharp",
+	".rb":   "ruby",
+}
+
+// languageOf returns the language of a file, or "" if it isn't mapped
+func languageOf(path string) string {
+	if strings.HasSuffix(path, "_test.go") {
+		return ""
+	}
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// parseFile extracts the top-level symbols and referenced identifiers of a file
+func parseFile(path string, src []byte) ([]Symbol, []string) {
+	if languageOf(path) == "go" {
+		if symbols, refs, err := parseGo(path, src); err == nil {
+			return symbols, refs
+		}
+	}
+	return scanDefinitions(languageOf(path), src), identifiers(src)
+}
+
+// parseGo extracts functions, methods and types with go/ast
+func parseGo(path string, src []byte) ([]Symbol, []string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var symbols []Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			var buf bytes.Buffer
+			_ = printer.Fprint(&buf, fset, &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type})
+			symbols = append(symbols, Symbol{
+				Name:      d.Name.Name,
+				Kind:      kind,
+				Line:      fset.Position(d.Pos()).Line,
+				Signature: clip(joinLines(buf.String())),
+			})
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				line := sourceLine(src, fset.Position(ts.Pos()).Offset)
+				line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "{"))
+				symbols = append(symbols, Symbol{
+					Name:      ts.Name.Name,
+					Kind:      "type",
+					Line:      fset.Position(ts.Pos()).Line,
+					Signature: clip("type " + strings.TrimPrefix(line, "type ")),
+				})
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name != "_" {
+			seen[ident.Name] = true
+		}
+		return true
+	})
+	return symbols, sortedKeys(seen), nil
+}
+
+// definitionPattern matches a definition line and captures its name
+type definitionPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// definitionPatterns are the definitions recognized in languages without a
+// Go parser, and in Go files that don't parse. Methods only count inside a
+// top-level block that holds them, such as a Python class or a Rust impl.
+var definitionPatterns = map[string][]definitionPattern{
+	"go": {
+		{"method", regexp.MustCompile(`^func\s+\([^)]*\)\s*(\w+)`)},
+		{"func", regexp.MustCompile(`^func\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^type\s+(\w+)`)},
+	},
+	"python": {
+		{"class", regexp.MustCompile(`^class\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`)},
+		{"method", regexp.MustCompile(`^\s+(?:async\s+)?def\s+(\w+)`)},
+	},
+	"javascript": {
+		{"class", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^(?:export\s+)?const\s+(\w+)\s*=\s*(?:async\s+)?(?:\([^)]*\)|\w+)\s*(?::\s*[^=]+)?=>`)},
+	},
+	"rust": {
+		{"func", regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"\w+"\s+)?fn\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|union|type)\s+(\w+)`)},
+		// One level deep, so functions nested in a method body don't count
+		{"method", regexp.MustCompile(`^(?:    |\t)(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"\w+"\s+)?fn\s+(\w+)`)},
+	},
+	"java": {
+		{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|final|abstract|sealed|non-sealed|strictfp)\s+)*(?:class|interface|@interface|enum|record)\s+(\w+)`)},
+		{"method", regexp.MustCompile(`^\s+(?:(?:public|protected|private|static|final|abstract|synchronized|native|default|strictfp)\s+)+(?:<[^>]*>\s+)?[\w<>\[\],.? ]*?\b(\w+)\s*\(`)},
+	},
+	"kotlin": {
+		{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|abstract|open|final|sealed|data|enum|annotation|inner|value|fun)\s+)*(?:class|interface|object)\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^(?:(?:public|private|internal|inline|suspend|operator|infix|tailrec|external)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.<>?]+\.)?(\w+)\s*\(`)},
+		{"method", regexp.MustCompile(`^(?:    |\t)(?:(?:public|private|protected|internal|open|final|abstract|override|inline|suspend|operator|infix|tailrec|external)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.<>?]+\.)?(\w+)\s*\(`)},
+	},
+	"csharp": {
+		{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|sealed|abstract|partial|readonly|ref|unsafe|new|file)\s+)*(?:class|interface|struct|enum|record(?:\s+class|\s+struct)?)\s+(\w+)`)},
+		{"method", regexp.MustCompile(`^\s+(?:(?:public|private|protected|internal|static|virtual|override|abstract|sealed|async|extern|unsafe|new|partial)\s+)+[\w<>\[\],.?() ]*?\b(\w+)\s*(?:<[^>]*>)?\s*\(`)},
+	},
+	"ruby": {
+		{"class", regexp.MustCompile(`^\s*(?:class|module)\s+([A-Z]\w*)`)},
+		{"method", regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!]?)`)},
+	},
+}
+
+// blockPatterns match the top-level lines that open a block holding methods
+var blockPatterns = map[string]*regexp.Regexp{
+	"python": regexp.MustCompile(`^class\s`),
+	"kotlin": regexp.MustCompile(`^(?:(?:public|private|protected|internal|abstract|open|final|sealed|data|enum|annotation|value|fun)\s+)*(?:class|interface|object)\s`),
+	"rust":   regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?(?:impl|trait)\b`),
+}
+
+// scanDefinitions finds definitions line by line
+func scanDefinitions(language string, src []byte) []Symbol {
+	var symbols []Symbol
+	block, hasBlocks := blockPatterns[language]
+	inBlock := false
+	for i, line := range strings.Split(string(src), "\n") {
+		if hasBlocks && strings.TrimSpace(line) != "" && !isIndented(line) {
+			inBlock = block.MatchString(line)
+		}
+		for _, pattern := range definitionPatterns[language] {
+			match := pattern.re.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			if hasBlocks && pattern.kind == "method" && !inBlock {
+				break
+			}
+			signature := strings.TrimSpace(line)
+			signature = strings.TrimSpace(strings.TrimSuffix(signature, "{"))
+			symbols = append(symbols, Symbol{
+				Name:      match[1],
+				Kind:      pattern.kind,
+				Line:      i + 1,
+				Signature: clip(signature),
+			})
+			break
+		}
+	}
+	return symbols
+}
+
+// identifierPattern matches identifiers in most languages
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// identifiers returns the distinct identifiers in src
+func identifiers(src []byte) []string {
+	seen := make(map[string]bool)
+	for _, match := range identifierPattern.FindAll(src, -1) {
+		seen[string(match)] = true
+	}
+	return sortedKeys(seen)
+}
+
+// isIndented reports whether a line starts with whitespace
+func isIndented(line string) bool {
+	return line[0] == ' ' || line[0] == '\t'
+}
+
+// joinLines puts a declaration that spans several lines on one line
+func joinLines(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, "( ", "(")
+	return strings.ReplaceAll(s, ", )", ")")
+}
+
+// sourceLine returns the line of src that contains offset
+func sourceLine(src []byte, offset int) string {
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := bytes.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		return string(src[start:])
+	}
+	return string(src[start : offset+end])
+}
+
+// clip shortens a signature to maxSignature bytes
+func clip(s string) string {
+	if len(s) <= maxSignature {
+		return s
+	}
+	return strings.TrimSpace(s[:maxSignature-3]) + "..."
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,239 @@
+package repomap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PageRank parameters for the file reference graph
+const (
+	damping    = 0.85
+	iterations = 30
+)
+
+// RenderOptions selects what goes into a rendered map
+type RenderOptions struct {
+	MaxTokens int    // Token budget (default: DefaultMaxTokens)
+	Path      string // Only include files under this relative path
+}
+
+// Outline is a rendered map
+type Outline struct {
+	Text         string
+	Files        int // Files with symbols in the map
+	Symbols      int // Symbols shown
+	TotalSymbols int // Symbols that matched the path
+}
+
+// rankedSymbol is a symbol with its file and score
+type rankedSymbol struct {
+	Symbol
+	file  string
+	score float64
+}
+
+// Render refreshes the map and renders the highest ranked symbols that fit the budget
+func (m *Map) Render(ctx context.Context, options RenderOptions) (Outline, error) {
+	if err := m.Refresh(ctx); err != nil {
+		return Outline{}, err
+	}
+
+	m.mu.Lock()
+	ranked := rank(m.files)
+	m.mu.Unlock()
+
+	prefix := strings.Trim(strings.TrimPrefix(options.Path, "./"), "/")
+	if prefix == "." {
+		prefix = ""
+	}
+	if prefix != "" {
+		filtered := ranked[:0]
+		for _, s := range ranked {
+			if s.file == prefix || strings.HasPrefix(s.file, prefix+"/") {
+				filtered = append(filtered, s)
+			}
+		}
+		ranked = filtered
+	}
+
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	return render(ranked, maxTokens), nil
+}
+
+// rank scores every symbol. Files are ranked with PageRank over the graph of
+// which files reference names defined in which other files; a symbol scores the
+// rank of each other file that references it, shared among same-named definitions.
+func rank(files map[string]*fileInfo) []rankedSymbol {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	definedIn := make(map[string][]string)
+	for _, path := range paths {
+		for _, s := range files[path].Symbols {
+			if len(definedIn[s.Name]) == 0 || definedIn[s.Name][len(definedIn[s.Name])-1] != path {
+				definedIn[s.Name] = append(definedIn[s.Name], path)
+			}
+		}
+	}
+
+	// edges[f][g] weighs the names f uses that g defines
+	edges := make(map[string]map[string]float64)
+	referencedBy := make(map[string][]string)
+	for _, path := range paths {
+		for _, name := range files[path].Refs {
+			defs := definedIn[name]
+			for _, def := range defs {
+				if def == path {
+					continue
+				}
+				if edges[path] == nil {
+					edges[path] = make(map[string]float64)
+				}
+				edges[path][def] += nameWeight(name) / float64(len(defs))
+			}
+			if len(defs) > 0 {
+				referencedBy[name] = append(referencedBy[name], path)
+			}
+		}
+	}
+
+	fileRank := pageRank(paths, edges)
+
+	var ranked []rankedSymbol
+	for _, path := range paths {
+		for _, s := range files[path].Symbols {
+			// A small share of the file's own rank orders symbols nobody references
+			score := fileRank[path] * 0.01
+			for _, user := range referencedBy[s.Name] {
+				if user != path {
+					score += fileRank[user] * nameWeight(s.Name) / float64(len(definedIn[s.Name]))
+				}
+			}
+			ranked = append(ranked, rankedSymbol{Symbol: s, file: path, score: score})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		if ranked[i].file != ranked[j].file {
+			return ranked[i].file < ranked[j].file
+		}
+		return ranked[i].Line < ranked[j].Line
+	})
+	return ranked
+}
+
+// nameWeight favors specific names: a reference to NewSessionManager says more
+// about a file's dependencies than a reference to Name or String
+func nameWeight(name string) float64 {
+	if len(name) < 8 {
+		return 1
+	}
+	hasUpper, hasLower := false, false
+	for _, r := range name {
+		hasUpper = hasUpper || unicode.IsUpper(r)
+		hasLower = hasLower || unicode.IsLower(r)
+	}
+	if strings.Contains(name, "_") || (hasUpper && hasLower) {
+		return 10
+	}
+	return 1
+}
+
+// pageRank ranks files by the weighted reference graph
+func pageRank(paths []string, edges map[string]map[string]float64) map[string]float64 {
+	n := float64(len(paths))
+	rank := make(map[string]float64, len(paths))
+	for _, path := range paths {
+		rank[path] = 1 / n
+	}
+
+	outWeight := make(map[string]float64)
+	for from, targets := range edges {
+		for _, w := range targets {
+			outWeight[from] += w
+		}
+	}
+
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64, len(paths))
+		dangling := 0.0
+		for _, path := range paths {
+			if outWeight[path] == 0 {
+				dangling += rank[path]
+			}
+		}
+		for _, path := range paths {
+			next[path] = (1-damping)/n + damping*dangling/n
+		}
+		for from, targets := range edges {
+			for to, w := range targets {
+				next[to] += damping * rank[from] * w / outWeight[from]
+			}
+		}
+		rank = next
+	}
+	return rank
+}
+
+// estimateTokens approximates the tokens in s
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// render adds symbols in rank order while they fit the budget and prints them
+// grouped by file, in file and line order
+func render(ranked []rankedSymbol, maxTokens int) Outline {
+	chosen := make(map[string][]Symbol)
+	tokens := 0
+	shown := 0
+	for _, s := range ranked {
+		cost := estimateTokens("  " + s.Signature + "\n")
+		if _, ok := chosen[s.file]; !ok {
+			cost += estimateTokens(s.file + ":\n")
+		}
+		if tokens+cost > maxTokens {
+			break
+		}
+		tokens += cost
+		chosen[s.file] = append(chosen[s.file], s.Symbol)
+		shown++
+	}
+
+	files := make([]string, 0, len(chosen))
+	for file := range chosen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	for _, file := range files {
+		symbols := chosen[file]
+		sort.Slice(symbols, func(i, j int) bool { return symbols[i].Line < symbols[j].Line })
+		b.WriteString(file + ":\n")
+		for _, s := range symbols {
+			b.WriteString("  " + s.Signature + "\n")
+		}
+	}
+	if shown < len(ranked) {
+		b.WriteString(fmt.Sprintf("... (%d less referenced symbols not shown)\n", len(ranked)-shown))
+	}
+
+	return Outline{
+		Text:         strings.TrimSuffix(b.String(), "\n"),
+		Files:        len(files),
+		Symbols:      shown,
+		TotalSymbols: len(ranked),
+	}
+}
@@ -0,0 +1,217 @@
+// Package repomap builds an outline of a repository's top-level symbols so the
+// agent can learn the project structure without exploring file by file.
+//
+// Go files are parsed with go/ast; other languages are scanned line by line
+// for definitions. Symbols are ranked by how often other files reference them,
+// weighted by the rank of the referencing files, and the outline is cut to a
+// token budget. Parsed files are cached on disk and re-parsed only when their
+// modification time or size changes.
+package repomap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Config configures the repository map
+type Config struct {
+	// SystemPrompt adds the map to the system prompt at startup
+	SystemPrompt bool `koanf:"system_prompt" json:"system_prompt"`
+
+	// MaxTokens is the default size of the map (default: 1024)
+	MaxTokens int `koanf:"max_tokens" json:"max_tokens"`
+}
+
+// Limits on what is scanned
+const (
+	DefaultMaxTokens = 1024
+	maxFileBytes     = 512 * 1024
+	maxFiles         = 10000
+	cacheVersion     = 2 // Bump when parsing changes, to re-parse cached files
+)
+
+// skipDirs are directories that never contain project source
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+	"__pycache__":  true,
+	"venv":         true,
+}
+
+// Symbol is a top-level definition in a file
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"` // "func", "method", "type", "class", ...
+	Line      int    `json:"line"`
+	Signature string `json:"signature"`
+}
+
+// fileInfo is what the map knows about one file
+type fileInfo struct {
+	ModTime int64    `json:"mod_time"`
+	Size    int64    `json:"size"`
+	Symbols []Symbol `json:"symbols"`
+	Refs    []string `json:"refs"` // Distinct identifiers used in the file
+}
+
+// cacheFile is the on-disk form of the map
+type cacheFile struct {
+	Version int                  `json:"version"`
+	Root    string               `json:"root"`
+	Files   map[string]*fileInfo `json:"files"`
+}
+
+// Map is the repository map of a directory tree
+type Map struct {
+	rootDir   string
+	cachePath string
+
+	mu     sync.Mutex
+	files  map[string]*fileInfo // By slash-separated path relative to rootDir
+	loaded bool
+	parsed int // Files parsed by the last Refresh
+}
+
+// New creates the map of rootDir. Parsed files are cached in cacheDir; an
+// empty cacheDir keeps them in memory only.
+func New(rootDir, cacheDir string) *Map {
+	m := &Map{rootDir: rootDir, files: make(map[string]*fileInfo)}
+	if cacheDir != "" {
+		sum := sha256.Sum256([]byte(rootDir))
+		m.cachePath = filepath.Join(cacheDir, fmt.Sprintf("%x.json", sum[:8]))
+	}
+	return m
+}
+
+// Refresh re-parses files that changed since they were last parsed and drops
+// files that no longer exist
+func (m *Map) Refresh(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		m.loadCache()
+		m.loaded = true
+	}
+
+	seen := make(map[string]bool)
+	changed := false
+	m.parsed = 0
+
+	err := filepath.WalkDir(m.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == m.rootDir {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != m.rootDir && (strings.HasPrefix(name, ".") || skipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || languageOf(path) == "" {
+			return nil
+		}
+		if len(seen) >= maxFiles {
+			return filepath.SkipAll
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileBytes {
+			return nil
+		}
+		rel, err := filepath.Rel(m.rootDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		if cached, ok := m.files[rel]; ok && cached.ModTime == info.ModTime().UnixNano() && cached.Size == info.Size() {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		symbols, refs := parseFile(path, src)
+		m.files[rel] = &fileInfo{
+			ModTime: info.ModTime().UnixNano(),
+			Size:    info.Size(),
+			Symbols: symbols,
+			Refs:    refs,
+		}
+		m.parsed++
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", m.rootDir, err)
+	}
+
+	for rel := range m.files {
+		if !seen[rel] {
+			delete(m.files, rel)
+			changed = true
+		}
+	}
+
+	if changed {
+		m.saveCache()
+	}
+	return nil
+}
+
+// loadCache reads the on-disk cache; a missing or stale cache is ignored
+func (m *Map) loadCache() {
+	if m.cachePath == "" {
+		return
+	}
+	data, err := os.ReadFile(m.cachePath)
+	if err != nil {
+		return
+	}
+	var cache cacheFile
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Version != cacheVersion || cache.Root != m.rootDir {
+		return
+	}
+	if cache.Files != nil {
+		m.files = cache.Files
+	}
+}
+
+// saveCache writes the cache. The map works without it, so errors are ignored.
+func (m *Map) saveCache() {
+	if m.cachePath == "" {
+		return
+	}
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Root: m.rootDir, Files: m.files})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.cachePath), 0755); err != nil {
+		return
+	}
+	tmp := m.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, m.cachePath)
+}
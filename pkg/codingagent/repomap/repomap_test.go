@@ -0,0 +1,292 @@
+package repomap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+var testRepo = map[string]string{
+	"store/store.go": `package store
+
+// Store keeps records
+type Store struct {
+	records map[string]string
+}
+
+// NewStore creates a store
+func NewStore() *Store {
+	return &Store{records: map[string]string{}}
+}
+
+func (s *Store) Get(key string) (string, bool) {
+	value, ok := s.records[key]
+	return value, ok
+}
+
+func unusedHelper() {}
+`,
+	"api/server.go": `package api
+
+import "example.com/store"
+
+type Server struct {
+	store *store.Store
+}
+
+func NewServer() *Server {
+	return &Server{store: store.NewStore()}
+}
+
+func (s *Server) Lookup(key string) string {
+	value, _ := s.store.Get(key)
+	return value
+}
+`,
+	"cli/main.go": `package main
+
+func main() {
+	s := store.NewStore()
+	s.Get("a")
+}
+`,
+	"scripts/tool.py": `import os
+
+class Loader:
+    def load(self, path):
+        return open(path).read()
+
+def main():
+    Loader().load("x")
+`,
+	"web/app.ts": `export interface Options {
+  name: string
+}
+
+export function render(options: Options) {
+}
+
+export const start = async (port: number) => {
+}
+`,
+	"node_modules/dep/index.js": `function ignored() {}`,
+	".hidden/secret.go":         `package hidden; func Hidden() {}`,
+	"store/store_test.go":       `package store; func TestGet(t *testing.T) {}`,
+}
+
+func TestParseFile(t *testing.T) {
+	t.Run("Go", func(t *testing.T) {
+		symbols, refs := parseFile("store.go", []byte(testRepo["store/store.go"]))
+		require.Len(t, symbols, 4)
+		assert.Equal(t, Symbol{Name: "Store", Kind: "type", Line: 4, Signature: "type Store struct"}, symbols[0])
+		assert.Equal(t, "func NewStore() *Store", symbols[1].Signature)
+		assert.Equal(t, "method", symbols[2].Kind)
+		assert.Equal(t, "func (s *Store) Get(key string) (string, bool)", symbols[2].Signature)
+		assert.Contains(t, refs, "records")
+	})
+
+	t.Run("Python", func(t *testing.T) {
+		symbols, _ := parseFile("tool.py", []byte(testRepo["scripts/tool.py"]))
+		require.Len(t, symbols, 3)
+		assert.Equal(t, "class Loader:", symbols[0].Signature)
+		assert.Equal(t, "method", symbols[1].Kind)
+		assert.Equal(t, "load", symbols[1].Name)
+		assert.Equal(t, "func", symbols[2].Kind)
+	})
+
+	t.Run("TypeScript", func(t *testing.T) {
+		symbols, _ := parseFile("app.ts", []byte(testRepo["web/app.ts"]))
+		var names []string
+		for _, s := range symbols {
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, []string{"Options", "render", "start"}, names)
+	})
+
+	t.Run("InvalidGo", func(t *testing.T) {
+		symbols, _ := parseFile("broken.go", []byte("package x\n\ntype T struct{}\n\nfunc (t T) Get() {}\n\nfunc Broken( {\n"))
+		assert.Equal(t, []Symbol{
+			{Name: "T", Kind: "type", Line: 3, Signature: "type T struct{}"},
+			{Name: "Get", Kind: "method", Line: 5, Signature: "func (t T) Get() {}"},
+			{Name: "Broken", Kind: "func", Line: 7, Signature: "func Broken("},
+		}, symbols)
+	})
+
+	tests := []struct {
+		name string
+		path string
+		src  string
+		want []string // kind name
+	}{
+		{
+			name: "Rust",
+			path: "lib.rs",
+			src: `pub struct Parser {}
+
+impl Parser {
+    pub fn parse(&self) {
+        fn helper() {}
+    }
+}
+
+mod tests {
+    fn test_parse() {}
+}
+
+pub async fn run() {}
+`,
+			want: []string{"type Parser", "method parse", "func run"},
+		},
+		{
+			name: "Java",
+			path: "Main.java",
+			src: `public final class Main {
+    private static <T> List<T> load(String path) {
+        if (path == null) {
+        }
+    }
+}
+`,
+			want: []string{"class Main", "method load"},
+		},
+		{
+			name: "Kotlin",
+			path: "App.kt",
+			src: `data class User(val name: String)
+
+class Repo {
+    suspend fun find(id: Int): User {
+        fun local() {}
+    }
+}
+
+fun String.shout() = uppercase()
+`,
+			want: []string{"class User", "class Repo", "method find", "func shout"},
+		},
+		{
+			name: "CSharp",
+			path: "Service.cs",
+			src: `namespace App;
+
+public sealed class Service
+{
+    public async Task<int> RunAsync(string name)
+    {
+        if (name == null) { }
+    }
+}
+
+public record struct Point(int X, int Y);
+`,
+			want: []string{"class Service", "method RunAsync", "class Point"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbols, _ := parseFile(tt.path, []byte(tt.src))
+			var got []string
+			for _, s := range symbols {
+				got = append(got, s.Kind+" "+s.Name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, testRepo)
+	m := New(dir, "")
+
+	t.Run("RanksReferencedSymbolsFirst", func(t *testing.T) {
+		outline, err := m.Render(context.Background(), RenderOptions{MaxTokens: 4096})
+		require.NoError(t, err)
+
+		assert.Contains(t, outline.Text, "store/store.go:\n  type Store struct\n  func NewStore() *Store\n  func (s *Store) Get(key string) (string, bool)")
+		assert.Contains(t, outline.Text, "scripts/tool.py:\n  class Loader:")
+		assert.NotContains(t, outline.Text, "ignored")
+		assert.NotContains(t, outline.Text, "Hidden")
+		assert.NotContains(t, outline.Text, "TestGet")
+		assert.Equal(t, outline.TotalSymbols, outline.Symbols)
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		outline, err := m.Render(context.Background(), RenderOptions{MaxTokens: 20})
+		require.NoError(t, err)
+		assert.Less(t, outline.Symbols, outline.TotalSymbols)
+		// NewStore is used by two other files, more than anything else
+		assert.Contains(t, outline.Text, "NewStore")
+		assert.NotContains(t, outline.Text, "unusedHelper")
+		assert.Contains(t, outline.Text, "less referenced symbols not shown")
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		outline, err := m.Render(context.Background(), RenderOptions{Path: "./web"})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(outline.Text, "web/app.ts:"), outline.Text)
+		assert.Equal(t, 1, outline.Files)
+	})
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := t.TempDir()
+	writeFiles(t, dir, testRepo)
+
+	m := New(dir, cacheDir)
+	require.NoError(t, m.Refresh(context.Background()))
+	assert.Equal(t, 5, m.parsed)
+
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// A new map reuses the cache and only parses files that changed
+	path := filepath.Join(dir, "api/server.go")
+	require.NoError(t, os.WriteFile(path, []byte("package api\n\nfunc Renamed() {}\n"), 0644))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	require.NoError(t, os.Remove(filepath.Join(dir, "cli/main.go")))
+
+	m = New(dir, cacheDir)
+	outline, err := m.Render(context.Background(), RenderOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.parsed)
+	assert.Contains(t, outline.Text, "func Renamed()")
+	assert.NotContains(t, outline.Text, "NewServer")
+	assert.NotContains(t, outline.Text, "cli/main.go")
+}
+
+func TestRepoMapTool(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, testRepo)
+	tool := CreateRepoMapTool(New(dir, ""), Config{})
+
+	result, err := tool.Execute(context.Background(), "call-1", map[string]any{"path": "store"}, nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	text := result.Content[0].(ai.TextContent).Text
+	assert.True(t, strings.HasPrefix(text, "store/store.go:\n  type Store struct"), text)
+	assert.Equal(t, 4, result.Details.(map[string]any)["symbols"])
+
+	result, err = tool.Execute(context.Background(), "call-2", map[string]any{"path": "missing"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "No symbols found under missing", result.Content[0].(ai.TextContent).Text)
+}
@@ -0,0 +1,74 @@
+package repomap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// maxToolTokens caps the budget the model can ask for
+const maxToolTokens = 16384
+
+// CreateRepoMapTool creates the tool that renders the repository map
+func CreateRepoMapTool(m *Map, config Config) agent.AgentTool {
+	defaultTokens := config.MaxTokens
+	if defaultTokens <= 0 {
+		defaultTokens = DefaultMaxTokens
+	}
+
+	tool := ai.NewTool(
+		"repo_map",
+		"Show an outline of the repository: the top-level functions, types and methods of each file, "+
+			"most referenced first. Use it to learn the project structure before searching or reading files.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Optional: Only include files under this directory, relative to the working directory",
+				},
+				"max_tokens": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Optional: Size of the outline in tokens (default: %d, maximum: %d)", defaultTokens, maxToolTokens),
+				},
+			},
+		},
+	)
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		options := RenderOptions{MaxTokens: defaultTokens}
+		options.Path, _ = params["path"].(string)
+		if val, ok := params["max_tokens"].(float64); ok && val > 0 {
+			options.MaxTokens = min(int(val), maxToolTokens)
+		}
+
+		outline, err := m.Render(ctx, options)
+		if err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		text := outline.Text
+		if outline.TotalSymbols == 0 {
+			text = "No symbols found"
+			if options.Path != "" {
+				text += " under " + options.Path
+			}
+		}
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(text)},
+			Details: map[string]any{
+				"files":         outline.Files,
+				"symbols":       outline.Symbols,
+				"total_symbols": outline.TotalSymbols,
+			},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Repository Map", execute)
+}
@@ -0,0 +1,225 @@
+package codingagent
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+)
+
+// RewindMode selects what a rewind restores
+type RewindMode string
+
+const (
+	RewindCode         RewindMode = "code"         // Restore files only
+	RewindConversation RewindMode = "conversation" // Truncate the conversation only
+	RewindBoth         RewindMode = "both"         // Restore files and truncate the conversation
+)
+
+// ParseRewindMode parses a rewind mode, defaulting to RewindBoth
+func ParseRewindMode(mode string) (RewindMode, error) {
+	switch RewindMode(mode) {
+	case "", RewindBoth:
+		return RewindBoth, nil
+	case RewindCode, RewindConversation:
+		return RewindMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid rewind mode: %s (expected code, conversation or both)", mode)
+}
+
+// RewindPoint is a user message the session can be rewound to
+type RewindPoint struct {
+	MessageID string
+	Index     int    // Position in the message history
+	Prompt    string // Text of the user message
+	CreatedAt time.Time
+	Files     []string // Files changed while handling this message and later ones
+}
+
+// RewindResult describes a completed rewind
+type RewindResult struct {
+	Session       *Session          // Session to continue with (a new branch when forking)
+	Checkpoints   *checkpoint.Store // Checkpoint store of Session
+	RestoredFiles []string
+	Prompt        string // Text of the rewound user message, to edit and resend
+}
+
+// GetRewindPoints lists the user messages in state, oldest first
+func GetRewindPoints(state *agent.AgentState, store *checkpoint.Store) []RewindPoint {
+	var checkpoints []checkpoint.Checkpoint
+	if store != nil {
+		checkpoints = store.List()
+	}
+
+	var points []RewindPoint
+	for i, msg := range state.GetMessages() {
+		userMsg, ok := msg.Message.(ai.UserMessage)
+		if !ok {
+			continue
+		}
+
+		point := RewindPoint{
+			MessageID: msg.ID,
+			Index:     i,
+			Prompt:    userMessageText(userMsg),
+			CreatedAt: time.UnixMilli(msg.CreatedAt),
+		}
+
+		// Collect the files touched from this message's checkpoint onwards
+		seen := make(map[string]bool)
+		found := false
+		for _, cp := range checkpoints {
+			if cp.MessageID == msg.ID {
+				found = true
+			}
+			if !found {
+				continue
+			}
+			for _, file := range cp.Files {
+				if !seen[file.Path] {
+					seen[file.Path] = true
+					point.Files = append(point.Files, file.Path)
+				}
+			}
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
+
+// Rewind restores the code and/or conversation of session to just before the user
+// message messageID. With fork, the conversation is rewound in a new branch session
+// and the original is saved with its full history; otherwise the history is truncated.
+func (sm *SessionManager) Rewind(
+	session *Session,
+	store *checkpoint.Store,
+	messageID string,
+	mode RewindMode,
+	fork bool,
+) (*RewindResult, error) {
+	messages := session.State.GetMessages()
+	index := -1
+	for i, msg := range messages {
+		if msg.ID == messageID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("message %s not found in session", messageID)
+	}
+	userMsg, ok := messages[index].Message.(ai.UserMessage)
+	if !ok {
+		return nil, fmt.Errorf("message %s is not a user message", messageID)
+	}
+
+	result := &RewindResult{
+		Session:     session,
+		Checkpoints: store,
+		Prompt:      userMessageText(userMsg),
+	}
+
+	if (mode == RewindCode || mode == RewindBoth) && store == nil {
+		return nil, fmt.Errorf("checkpoints are not enabled for this session")
+	}
+
+	// restore restores files from the first checkpoint at or after the message
+	restore := func(store *checkpoint.Store) error {
+		for _, msg := range messages[index:] {
+			if _, ok := store.Get(msg.ID); !ok {
+				continue
+			}
+			restored, err := store.Restore(msg.ID)
+			result.RestoredFiles = restored
+			if err != nil {
+				return fmt.Errorf("failed to restore files: %w", err)
+			}
+			break
+		}
+		return nil
+	}
+
+	// Restore files first so a failure leaves the conversation untouched.
+	// A fork restores from the branch's copy of the checkpoints instead, so
+	// that the original session keeps all of its own.
+	if mode == RewindCode || (mode == RewindBoth && !fork) {
+		if err := restore(store); err != nil {
+			return result, err
+		}
+	}
+
+	if mode == RewindCode {
+		sm.reset()
+		return result, nil
+	}
+
+	if !fork {
+		session.State.SetMessages(messages[:index])
+		sm.reset()
+		return result, nil
+	}
+
+	// Keep the full history in the original session and continue in a branch
+	if err := sm.Save(session); err != nil {
+		return result, err
+	}
+	branch, err := sm.Fork(session.Metadata.ID, index, fmt.Sprintf("%s (rewind)", session.Metadata.Title))
+	if err != nil {
+		return result, fmt.Errorf("failed to fork session: %w", err)
+	}
+
+	if store != nil {
+		// To restore files the branch needs the checkpoints from the message
+		// on, which restoring then drops; otherwise those before it are enough
+		forkAt := messageID
+		if mode == RewindBoth {
+			forkAt = ""
+		}
+		branchDir := filepath.Join(filepath.Dir(store.Dir()), branch.Metadata.ID)
+		branchStore, err := store.Fork(branchDir, forkAt)
+		if err != nil {
+			return result, fmt.Errorf("failed to fork checkpoints: %w", err)
+		}
+		result.Checkpoints = branchStore
+		if mode == RewindBoth {
+			if err := restore(branchStore); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// The branch takes over the live agent state, and the original is reloaded from disk when needed
+	session.State.SetMessages(messages[:index])
+	branch.State = session.State
+
+	sm.mu.Lock()
+	delete(sm.cache, session.Metadata.ID)
+	sm.cache[branch.Metadata.ID] = branch
+	sm.currentSession = branch
+	sm.mu.Unlock()
+	sm.reset()
+
+	if err := sm.Save(branch); err != nil {
+		return result, err
+	}
+	result.Session = branch
+
+	return result, nil
+}
+
+// userMessageText joins the text parts of a user message
+func userMessageText(msg ai.UserMessage) string {
+	var parts []string
+	for _, content := range msg.Content {
+		if text, ok := content.(ai.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
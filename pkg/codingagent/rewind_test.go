@@ -0,0 +1,175 @@
+package codingagent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRewindSession creates a session with two prompts; the first rewrites file
+// from "v1" to "v2" and the second from "v2" to "v3"
+func newRewindSession(t *testing.T) (*SessionManager, *Session, *checkpoint.Store, string) {
+	t.Helper()
+
+	configDir := t.TempDir()
+	sm, err := NewSessionManager(filepath.Join(configDir, "sessions"))
+	require.NoError(t, err)
+
+	state := agent.NewAgentState("system", ai.Model{}, []agent.AgentTool{})
+	session := sm.NewSession("Test Session", state)
+
+	store, err := checkpoint.Open(checkpoint.Dir(configDir, session.Metadata.ID))
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(file, []byte("v1"), 0644))
+
+	for i, prompt := range []string{"first", "second"} {
+		id := []string{"user-1", "user-2"}[i]
+		state.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage(prompt), id, 0))
+		require.NoError(t, store.Begin(id))
+		require.NoError(t, store.Snapshot(file))
+		require.NoError(t, os.WriteFile(file, []byte([]string{"v2", "v3"}[i]), 0644))
+
+		reply := ai.NewAssistantMessage([]ai.Content{ai.NewTextContent("done")}, "", "", "", ai.Usage{}, ai.StopReasonEndTurn)
+		state.AddMessage(agent.NewAgentMessage(reply, id+"-reply", 0))
+	}
+
+	return sm, session, store, file
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestGetRewindPoints(t *testing.T) {
+	_, session, store, file := newRewindSession(t)
+
+	points := GetRewindPoints(session.State, store)
+	require.Len(t, points, 2)
+	assert.Equal(t, "user-1", points[0].MessageID)
+	assert.Equal(t, 0, points[0].Index)
+	assert.Equal(t, "first", points[0].Prompt)
+	assert.Equal(t, []string{file}, points[0].Files)
+	assert.Equal(t, 2, points[1].Index)
+}
+
+func TestSessionManager_Rewind(t *testing.T) {
+	t.Run("Both", func(t *testing.T) {
+		sm, session, store, file := newRewindSession(t)
+
+		result, err := sm.Rewind(session, store, "user-2", RewindBoth, false)
+		require.NoError(t, err)
+		assert.Same(t, session, result.Session)
+		assert.Equal(t, "second", result.Prompt)
+		assert.Equal(t, []string{file}, result.RestoredFiles)
+		assert.Equal(t, "v2", readString(t, file))
+		assert.Len(t, session.State.GetMessages(), 2)
+	})
+
+	t.Run("CodeOnly", func(t *testing.T) {
+		sm, session, store, file := newRewindSession(t)
+
+		_, err := sm.Rewind(session, store, "user-1", RewindCode, false)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", readString(t, file))
+		assert.Len(t, session.State.GetMessages(), 4)
+	})
+
+	t.Run("ConversationOnly", func(t *testing.T) {
+		sm, session, store, file := newRewindSession(t)
+
+		_, err := sm.Rewind(session, store, "user-1", RewindConversation, false)
+		require.NoError(t, err)
+		assert.Equal(t, "v3", readString(t, file))
+		assert.Empty(t, session.State.GetMessages())
+	})
+
+	t.Run("Fork", func(t *testing.T) {
+		sm, session, store, file := newRewindSession(t)
+		originalID := session.Metadata.ID
+
+		result, err := sm.Rewind(session, store, "user-2", RewindBoth, true)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", readString(t, file))
+
+		// The branch continues with the live state
+		branch := result.Session
+		assert.NotEqual(t, originalID, branch.Metadata.ID)
+		assert.Equal(t, originalID, branch.Metadata.ParentID)
+		assert.Equal(t, 2, branch.Metadata.BranchPoint)
+		assert.Same(t, session.State, branch.State)
+		assert.Len(t, branch.State.GetMessages(), 2)
+		assert.Same(t, branch, sm.GetCurrent())
+
+		// The original keeps its full history on disk
+		data, err := os.ReadFile(sm.getSessionPath(originalID))
+		require.NoError(t, err)
+		var saved struct {
+			State struct {
+				Messages []json.RawMessage `json:"messages"`
+			} `json:"state"`
+		}
+		require.NoError(t, json.Unmarshal(data, &saved))
+		assert.Len(t, saved.State.Messages, 4)
+
+		// The branch has its own checkpoints up to the branch point, and the
+		// original keeps all of its own
+		require.NotNil(t, result.Checkpoints)
+		assert.NotSame(t, store, result.Checkpoints)
+		assert.Len(t, result.Checkpoints.List(), 1)
+		assert.Len(t, store.List(), 2)
+		reopened, err := checkpoint.Open(store.Dir())
+		require.NoError(t, err)
+		assert.Len(t, reopened.List(), 2)
+
+		// So the original can still be rewound past the branch point
+		_, err = store.Restore("user-2")
+		require.NoError(t, err)
+		assert.Equal(t, "v2", readString(t, file))
+	})
+
+	t.Run("ForkConversationOnly", func(t *testing.T) {
+		sm, session, store, file := newRewindSession(t)
+
+		result, err := sm.Rewind(session, store, "user-2", RewindConversation, true)
+		require.NoError(t, err)
+		assert.Equal(t, "v3", readString(t, file))
+		assert.Empty(t, result.RestoredFiles)
+		assert.Len(t, result.Checkpoints.List(), 1)
+		assert.Len(t, store.List(), 2)
+	})
+
+	t.Run("UnknownMessage", func(t *testing.T) {
+		sm, session, store, _ := newRewindSession(t)
+
+		_, err := sm.Rewind(session, store, "missing", RewindBoth, false)
+		assert.Error(t, err)
+
+		_, err = sm.Rewind(session, store, "user-1-reply", RewindBoth, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseRewindMode(t *testing.T) {
+	mode, err := ParseRewindMode("")
+	require.NoError(t, err)
+	assert.Equal(t, RewindBoth, mode)
+
+	mode, err = ParseRewindMode("code")
+	require.NoError(t, err)
+	assert.Equal(t, RewindCode, mode)
+
+	_, err = ParseRewindMode("files")
+	assert.Error(t, err)
+}
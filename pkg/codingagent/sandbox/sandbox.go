@@ -0,0 +1,176 @@
+// Package sandbox runs shell commands with restricted filesystem and network access.
+//
+// On Linux, commands are started inside fresh user, mount and network namespaces and
+// re-executed through a small helper (the current binary invoked with HelperCommand)
+// that applies a landlock ruleset before exec'ing the real command. The working
+// directory and configured paths stay writable; the rest of the filesystem is read-only.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// HelperCommand is the hidden first argument that makes a binary act as the sandbox helper.
+// Programs using the sandbox must call RunHelper when they see it (see MaybeRunHelper).
+const HelperCommand = "__sandbox-exec"
+
+// ErrUnsupported is returned when the kernel cannot sandbox commands
+var ErrUnsupported = errors.New("sandbox not supported")
+
+// Config configures the sandbox
+type Config struct {
+	Enabled       bool     `koanf:"enabled" json:"enabled"`
+	AllowNetwork  bool     `koanf:"allow_network" json:"allow_network"`   // Keep the host network
+	WritablePaths []string `koanf:"writable_paths" json:"writable_paths"` // Writable in addition to the working dir
+}
+
+// Sandbox starts commands with restricted privileges
+type Sandbox struct {
+	config     Config
+	workingDir string
+	executable string
+}
+
+// New creates a sandbox for commands run in workingDir.
+// It returns an error wrapping ErrUnsupported when the kernel lacks the required features.
+func New(config Config, workingDir string) (*Sandbox, error) {
+	if err := Check(); err != nil {
+		return nil, err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate sandbox helper: %w", err)
+	}
+
+	absDir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	return &Sandbox{
+		config:     config,
+		workingDir: absDir,
+		executable: executable,
+	}, nil
+}
+
+// Check reports whether commands can be sandboxed on this system
+func Check() error {
+	return check()
+}
+
+// Command returns a command that runs name with args inside the sandbox
+func (s *Sandbox) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	helperArgs := []string{HelperCommand}
+	for _, path := range s.WritablePaths() {
+		helperArgs = append(helperArgs, "--rw", path)
+	}
+	if !s.config.AllowNetwork {
+		helperArgs = append(helperArgs, "--no-network")
+	}
+	helperArgs = append(helperArgs, "--", name)
+	helperArgs = append(helperArgs, args...)
+
+	cmd := exec.CommandContext(ctx, s.executable, helperArgs...)
+	cmd.Dir = s.workingDir
+	configureCommand(cmd, s.config)
+
+	return cmd
+}
+
+// WritablePaths returns the absolute paths commands may write to
+func (s *Sandbox) WritablePaths() []string {
+	paths := []string{s.workingDir}
+
+	home, _ := os.UserHomeDir()
+	for _, path := range s.config.WritablePaths {
+		if strings.HasPrefix(path, "~/") && home != "" {
+			path = filepath.Join(home, path[2:])
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.workingDir, path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+
+	return paths
+}
+
+// AllowsNetwork reports whether sandboxed commands keep network access
+func (s *Sandbox) AllowsNetwork() bool {
+	return s.config.AllowNetwork
+}
+
+// Describe returns a one-line summary of the restrictions, suitable for the model
+func (s *Sandbox) Describe() string {
+	network := "network access is blocked"
+	if s.config.AllowNetwork {
+		network = "network access is allowed"
+	}
+	return fmt.Sprintf("Commands run in a sandbox: only %s and a private /tmp are writable, %s.",
+		strings.Join(s.WritablePaths(), ", "), network)
+}
+
+// MaybeRunHelper runs the sandbox helper and never returns if args (usually os.Args)
+// invoke it. Call it first thing in main.
+func MaybeRunHelper(args []string) {
+	if len(args) < 2 || args[1] != HelperCommand {
+		return
+	}
+
+	err := RunHelper(args[2:])
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// RunHelper applies the sandbox to the current process and execs the command in args.
+// It only returns on error.
+func RunHelper(args []string) error {
+	opts, err := parseHelperArgs(args)
+	if err != nil {
+		return err
+	}
+	return runHelper(opts)
+}
+
+// helperOptions are the parsed helper arguments
+type helperOptions struct {
+	writable  []string
+	noNetwork bool
+	command   []string
+}
+
+// parseHelperArgs parses "[--rw PATH]... [--no-network] -- COMMAND [ARGS...]"
+func parseHelperArgs(args []string) (*helperOptions, error) {
+	opts := &helperOptions{}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--rw":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--rw requires a path")
+			}
+			opts.writable = append(opts.writable, args[i+1])
+			i++
+		case "--no-network":
+			opts.noNetwork = true
+		case "--":
+			opts.command = args[i+1:]
+			if len(opts.command) == 0 {
+				return nil, fmt.Errorf("no command given")
+			}
+			return opts, nil
+		default:
+			return nil, fmt.Errorf("unknown helper argument: %s", args[i])
+		}
+	}
+
+	return nil, fmt.Errorf("no command given")
+}
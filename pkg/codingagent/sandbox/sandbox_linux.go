@@ -0,0 +1,263 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock filesystem rights, grouped by the ABI version that introduced them
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+
+	landlockAccessV1 = landlockReadAccess |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+	// Rights that apply to regular files (rules on files may only use these)
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+)
+
+// deviceFiles stay writable so shells can redirect to them
+var deviceFiles = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/tty"}
+
+// check verifies landlock and unprivileged user namespaces are available
+func check() error {
+	abi, err := landlockABI()
+	if err != nil {
+		return fmt.Errorf("%w: landlock is unavailable: %v", ErrUnsupported, err)
+	}
+	if abi < 1 {
+		return fmt.Errorf("%w: landlock is disabled", ErrUnsupported)
+	}
+
+	// Start a no-op process in the namespaces the sandbox uses
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return fmt.Errorf("%w: cannot probe namespaces: %v", ErrUnsupported, err)
+	}
+	probe := exec.Command(truePath)
+	configureCommand(probe, Config{})
+	if err := probe.Run(); err != nil {
+		return fmt.Errorf("%w: unprivileged user namespaces are unavailable: %v", ErrUnsupported, err)
+	}
+
+	return nil
+}
+
+// configureCommand starts cmd in new user, mount and (optionally) network namespaces.
+// The helper keeps CAP_SYS_ADMIN and CAP_NET_ADMIN inside the namespace just long
+// enough to mount a private /tmp and bring up loopback, then drops them.
+func configureCommand(cmd *exec.Cmd, config Config) {
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS)
+	if !config.AllowNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  flags,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		AmbientCaps: []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN},
+	}
+}
+
+// runHelper sets up the namespaces, applies landlock and execs the command
+func runHelper(opts *helperOptions) error {
+	// Landlock and no_new_privs apply to the calling thread, which must be the one that execs
+	runtime.LockOSThread()
+
+	writable := append([]string{}, opts.writable...)
+
+	// Give the command its own /tmp instead of exposing the host's, unless that
+	// would hide a writable path
+	if !anyBelow(writable, "/tmp") {
+		if err := mountPrivateTmp(); err == nil {
+			writable = append(writable, "/tmp")
+		}
+	}
+
+	if opts.noNetwork {
+		// Loopback is down in a fresh network namespace; local servers still need it
+		_ = bringUpLoopback()
+	}
+
+	// Drop the capabilities granted for setup
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to drop capabilities: %w", err)
+	}
+
+	if err := restrictFilesystem(writable); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(opts.command[0])
+	if err != nil {
+		return fmt.Errorf("command not found: %w", err)
+	}
+
+	return unix.Exec(path, opts.command, os.Environ())
+}
+
+// anyBelow reports whether any of paths is dir or lies below it
+func anyBelow(paths []string, dir string) bool {
+	for _, path := range paths {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mountPrivateTmp mounts a fresh tmpfs over /tmp inside the mount namespace
+func mountPrivateTmp() error {
+	if err := unix.Mount("none", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+	return unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777")
+}
+
+// bringUpLoopback enables the lo interface in the new network namespace
+func bringUpLoopback() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	ifr.SetUint16(unix.IFF_UP | unix.IFF_LOOPBACK | unix.IFF_RUNNING)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// landlockABI returns the landlock ABI version supported by the kernel
+func landlockABI() (int, error) {
+	version, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(version), nil
+}
+
+// handledAccess returns the filesystem rights the kernel's landlock ABI understands
+func handledAccess(abi int) uint64 {
+	access := uint64(landlockAccessV1)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// restrictFilesystem makes everything read-only except the writable paths
+func restrictFilesystem(writable []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return fmt.Errorf("landlock is unavailable: %w", err)
+	}
+	handled := handledAccess(abi)
+
+	// Only access_fs is set, so pass the size of that field; this works on every ABI
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	rulesetFd, _, errno := unix.Syscall(
+		unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)),
+		unsafe.Sizeof(attr.Access_fs),
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to create landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(rulesetFd))
+
+	// Read and execute everywhere
+	if err := addPathRule(int(rulesetFd), "/", landlockReadAccess&handled); err != nil {
+		return err
+	}
+
+	// Full access below writable paths
+	for _, path := range writable {
+		if err := addPathRule(int(rulesetFd), path, handled); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, path := range deviceFiles {
+		_ = addPathRule(int(rulesetFd), path, handled&landlockFileAccess)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, rulesetFd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to apply landlock ruleset: %w", errno)
+	}
+
+	return nil
+}
+
+// addPathRule grants access below path
+func addPathRule(rulesetFd int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer unix.Close(fd)
+
+	// Rules on non-directories may only carry file rights
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	rule := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+	_, _, errno := unix.Syscall6(
+		unix.SYS_LANDLOCK_ADD_RULE,
+		uintptr(rulesetFd),
+		unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)),
+		0, 0, 0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to add landlock rule for %s: %w", path, errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// check always fails: the sandbox relies on Linux namespaces and landlock
+func check() error {
+	return fmt.Errorf("%w on %s", ErrUnsupported, runtime.GOOS)
+}
+
+// configureCommand is a no-op outside Linux
+func configureCommand(cmd *exec.Cmd, config Config) {}
+
+// runHelper always fails outside Linux
+func runHelper(opts *helperOptions) error {
+	return fmt.Errorf("%w on %s", ErrUnsupported, runtime.GOOS)
+}
@@ -0,0 +1,104 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the sandbox helper
+func TestMain(m *testing.M) {
+	MaybeRunHelper(os.Args)
+	os.Exit(m.Run())
+}
+
+// newTestSandbox creates a sandbox or skips when the kernel lacks support
+func newTestSandbox(t *testing.T, config Config) (*Sandbox, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	sb, err := New(config, dir)
+	if err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	return sb, dir
+}
+
+func TestSandbox_WritesConfinedToWorkingDir(t *testing.T) {
+	sb, dir := newTestSandbox(t, Config{Enabled: true})
+
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+
+	out, err := sb.Command(context.Background(), "bash", "-c", "echo inside > inside.txt && cat inside.txt").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, "inside\n", string(out))
+	assert.FileExists(t, filepath.Join(dir, "inside.txt"))
+
+	out, err = sb.Command(context.Background(), "bash", "-c", "echo nope > "+outside).CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "Permission denied")
+	assert.NoFileExists(t, outside)
+}
+
+func TestSandbox_ReadsOutsideWorkingDir(t *testing.T) {
+	sb, _ := newTestSandbox(t, Config{Enabled: true})
+
+	other := filepath.Join(t.TempDir(), "readable.txt")
+	require.NoError(t, os.WriteFile(other, []byte("hello"), 0644))
+
+	out, err := sb.Command(context.Background(), "cat", other).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, "hello", string(out))
+}
+
+func TestSandbox_WritablePaths(t *testing.T) {
+	extra := t.TempDir()
+	sb, dir := newTestSandbox(t, Config{Enabled: true, WritablePaths: []string{extra, "build"}})
+
+	assert.Equal(t, []string{dir, extra, filepath.Join(dir, "build")}, sb.WritablePaths())
+
+	out, err := sb.Command(context.Background(), "bash", "-c", "echo ok > "+filepath.Join(extra, "f.txt")).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.FileExists(t, filepath.Join(extra, "f.txt"))
+}
+
+func TestSandbox_Network(t *testing.T) {
+	sb, _ := newTestSandbox(t, Config{Enabled: true})
+
+	// Only loopback exists in the sandbox's network namespace
+	out, err := sb.Command(context.Background(), "cat", "/proc/net/dev").CombinedOutput()
+	require.NoError(t, err, string(out))
+	for _, line := range strings.Split(string(out), "\n")[2:] {
+		if name, _, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+			assert.Equal(t, "lo", name)
+		}
+	}
+	assert.Contains(t, sb.Describe(), "network access is blocked")
+
+	open, _ := newTestSandbox(t, Config{Enabled: true, AllowNetwork: true})
+	assert.Contains(t, open.Describe(), "network access is allowed")
+}
+
+func TestParseHelperArgs(t *testing.T) {
+	opts, err := parseHelperArgs([]string{"--rw", "/work", "--no-network", "--", "bash", "-c", "ls"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/work"}, opts.writable)
+	assert.True(t, opts.noNetwork)
+	assert.Equal(t, []string{"bash", "-c", "ls"}, opts.command)
+
+	_, err = parseHelperArgs([]string{"--rw"})
+	assert.Error(t, err)
+
+	_, err = parseHelperArgs([]string{"--rw", "/work"})
+	assert.Error(t, err)
+
+	_, err = parseHelperArgs([]string{"--bogus", "--", "ls"})
+	assert.Error(t, err)
+}
@@ -35,6 +35,9 @@ type SessionManager struct {
 	sessionsDir    string
 	currentSession *Session
 	cache          map[string]*Session
+
+	// onReset is called when the live conversation is replaced or rewound
+	onReset func()
 }
 
 // NewSessionManager creates a new session manager
@@ -168,6 +171,13 @@ func (sm *SessionManager) List() ([]SessionMetadata, error) {
 		metadataList = append(metadataList, session.Metadata)
 	}
 
+	// Include sessions of this process that haven't been saved yet
+	for id, session := range sm.cache {
+		if _, err := os.Stat(sm.getSessionPath(id)); os.IsNotExist(err) {
+			metadataList = append(metadataList, session.Metadata)
+		}
+	}
+
 	// Sort by updated time (most recent first)
 	sort.Slice(metadataList, func(i, j int) bool {
 		return metadataList[i].UpdatedAt.After(metadataList[j].UpdatedAt)
@@ -256,6 +266,20 @@ func (sm *SessionManager) SetCurrent(session *Session) {
 	sm.currentSession = session
 }
 
+// OnReset sets fn to be called whenever Clear, Switch, Branch or Rewind
+// replaces or rewinds the live conversation, so that state kept about it,
+// such as which files the agent has read, can be dropped. Set it before use.
+func (sm *SessionManager) OnReset(fn func()) {
+	sm.onReset = fn
+}
+
+// reset calls the OnReset callback if there is one
+func (sm *SessionManager) reset() {
+	if sm.onReset != nil {
+		sm.onReset()
+	}
+}
+
 // Export exports a session to HTML
 func (sm *SessionManager) Export(sessionID string, outputPath string) error {
 	session, err := sm.Load(sessionID)
@@ -0,0 +1,70 @@
+package codingagent
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/hooks"
+	"github.com/myersguo/cc-mono/pkg/codingagent/lsp"
+	"github.com/myersguo/cc-mono/pkg/codingagent/repomap"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
+	"github.com/myersguo/cc-mono/pkg/codingagent/statusline"
+	"github.com/myersguo/cc-mono/pkg/codingagent/web"
+)
+
+// Settings represents the settings.json files shared with the permission manager.
+// Permissions are read by agent.PermissionManager; everything else lives here.
+type Settings struct {
+	Sandbox sandbox.Config `koanf:"sandbox"`
+	Hooks   hooks.Config   `koanf:"hooks"`
+	LSP     lsp.Config     `koanf:"lsp"`
+	Web     web.Config     `koanf:"web"`
+	RepoMap repomap.Config `koanf:"repo_map"`
+
+	// StatusLine configures the status line below the chat editor
+	StatusLine statusline.Config `koanf:"status_line"`
+
+	// VimMode turns on vim keybindings in the chat editor
+	VimMode bool `koanf:"vim_mode"`
+
+	// RequireRead makes write and edit tools refuse to change existing files the
+	// agent has not read, or that changed on disk since it read them (default: true)
+	RequireRead *bool `koanf:"require_read"`
+
+	config *Config
+}
+
+// LoadSettings loads <globalConfigDir>/settings.json followed by
+// <projectDir>/.cc-mono/settings.local.json. Project values take precedence and
+// missing files are ignored.
+func LoadSettings(globalConfigDir, projectDir string) (*Settings, error) {
+	config := NewConfig(NewLogger(LoggerConfig{Level: LogLevelError, Output: io.Discard}))
+
+	paths := []string{
+		filepath.Join(globalConfigDir, "settings.json"),
+		filepath.Join(projectDir, ".cc-mono", "settings.local.json"),
+	}
+	for _, path := range paths {
+		if err := config.LoadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load settings from %s: %w", path, err)
+		}
+	}
+
+	settings := &Settings{config: config}
+	if err := config.UnmarshalAll(settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// RequireReadBeforeWrite reports whether files must be read before they are changed
+func (s *Settings) RequireReadBeforeWrite() bool {
+	return s.RequireRead == nil || *s.RequireRead
+}
+
+// Config returns the underlying configuration the settings were loaded from
+func (s *Settings) Config() *Config {
+	return s.config
+}
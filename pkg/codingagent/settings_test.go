@@ -0,0 +1,142 @@
+package codingagent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSettings(t *testing.T) {
+	t.Run("MissingFiles", func(t *testing.T) {
+		settings, err := LoadSettings(t.TempDir(), t.TempDir())
+		require.NoError(t, err)
+		assert.False(t, settings.Sandbox.Enabled)
+		assert.True(t, settings.RequireReadBeforeWrite())
+	})
+
+	t.Run("ProjectOverridesGlobal", func(t *testing.T) {
+		globalDir := t.TempDir()
+		projectDir := t.TempDir()
+
+		global := `{
+			"permissions": {"allow": ["Read(*)"]},
+			"sandbox": {"enabled": true, "writable_paths": ["~/.cache/go-build"]}
+		}`
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte(global), 0644))
+
+		project := `{"sandbox": {"allow_network": true}, "require_read": false}`
+		require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".cc-mono"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".cc-mono", "settings.local.json"), []byte(project), 0644))
+
+		settings, err := LoadSettings(globalDir, projectDir)
+		require.NoError(t, err)
+		assert.True(t, settings.Sandbox.Enabled)
+		assert.True(t, settings.Sandbox.AllowNetwork)
+		assert.Equal(t, []string{"~/.cache/go-build"}, settings.Sandbox.WritablePaths)
+		assert.False(t, settings.RequireReadBeforeWrite())
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		globalDir := t.TempDir()
+
+		global := `{
+			"hooks": {
+				"PreToolUse": [{"matcher": "bash|write", "hooks": [{"type": "command", "command": "./check.sh", "timeout": 10}]}],
+				"Stop": [{"hooks": [{"type": "command", "command": "make test"}]}]
+			}
+		}`
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte(global), 0644))
+
+		settings, err := LoadSettings(globalDir, t.TempDir())
+		require.NoError(t, err)
+		require.Len(t, settings.Hooks["PreToolUse"], 1)
+		assert.Equal(t, "bash|write", settings.Hooks["PreToolUse"][0].Matcher)
+		assert.Equal(t, "./check.sh", settings.Hooks["PreToolUse"][0].Hooks[0].Command)
+		assert.Equal(t, 10, settings.Hooks["PreToolUse"][0].Hooks[0].Timeout)
+		require.Len(t, settings.Hooks["Stop"], 1)
+		assert.Equal(t, "make test", settings.Hooks["Stop"][0].Hooks[0].Command)
+	})
+
+	t.Run("LSP", func(t *testing.T) {
+		globalDir := t.TempDir()
+
+		global := `{
+			"lsp": {
+				"enabled": true,
+				"servers": {"rust": {"command": "rust-analyzer", "extensions": [".rs"]}}
+			}
+		}`
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte(global), 0644))
+
+		settings, err := LoadSettings(globalDir, t.TempDir())
+		require.NoError(t, err)
+		assert.True(t, settings.LSP.Enabled)
+		assert.Equal(t, "rust-analyzer", settings.LSP.Servers["rust"].Command)
+		assert.Equal(t, []string{".rs"}, settings.LSP.Servers["rust"].Extensions)
+	})
+
+	t.Run("Web", func(t *testing.T) {
+		globalDir := t.TempDir()
+
+		global := `{
+			"web": {
+				"summary_model": "claude-haiku-4-5",
+				"search": {"backend": "searxng", "base_url": "http://localhost:8888"}
+			}
+		}`
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte(global), 0644))
+
+		settings, err := LoadSettings(globalDir, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "claude-haiku-4-5", settings.Web.SummaryModel)
+		assert.Equal(t, "searxng", settings.Web.Search.Backend)
+		assert.Equal(t, "http://localhost:8888", settings.Web.Search.BaseURL)
+	})
+
+	t.Run("RepoMap", func(t *testing.T) {
+		projectDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".cc-mono"), 0755))
+
+		project := `{"repo_map": {"system_prompt": true, "max_tokens": 2048}}`
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".cc-mono", "settings.local.json"), []byte(project), 0644))
+
+		settings, err := LoadSettings(t.TempDir(), projectDir)
+		require.NoError(t, err)
+		assert.True(t, settings.RepoMap.SystemPrompt)
+		assert.Equal(t, 2048, settings.RepoMap.MaxTokens)
+	})
+
+	t.Run("StatusLine", func(t *testing.T) {
+		globalDir := t.TempDir()
+
+		global := `{"status_line": {"command": "~/.cc-mono/statusline.sh", "refresh_seconds": 30}}`
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte(global), 0644))
+
+		settings, err := LoadSettings(globalDir, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "~/.cc-mono/statusline.sh", settings.StatusLine.Command)
+		assert.Equal(t, 30*time.Second, settings.StatusLine.RefreshInterval())
+	})
+
+	t.Run("VimMode", func(t *testing.T) {
+		projectDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".cc-mono"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".cc-mono", "settings.local.json"), []byte(`{"vim_mode": true}`), 0644))
+
+		settings, err := LoadSettings(t.TempDir(), projectDir)
+		require.NoError(t, err)
+		assert.True(t, settings.VimMode)
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		globalDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(globalDir, "settings.json"), []byte("{"), 0644))
+
+		_, err := LoadSettings(globalDir, t.TempDir())
+		assert.Error(t, err)
+	})
+}
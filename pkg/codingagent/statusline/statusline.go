@@ -0,0 +1,228 @@
+// Package statusline renders the status line shown below the chat editor.
+//
+// The line is configured in settings.json under "status_line", either as a Go
+// text/template over Info:
+//
+//	{"status_line": {"template": "{{.Model.Name}} · {{.Context.Percent}}% · {{.Git.Branch}}"}}
+//
+// or as a shell command that receives Info as JSON on stdin and prints the line:
+//
+//	{"status_line": {"command": "~/.cc-mono/statusline.sh"}}
+package statusline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// DefaultTemplate is used when neither a template nor a command is configured
+const DefaultTemplate = `{{.Model.Name}}{{if .ThinkingLevel}} · thinking {{.ThinkingLevel}}{{end}} · {{.PermissionMode}}` +
+	` · {{.Context.Percent}}% context · ${{printf "%.2f" .Cost.USD}} · {{.WorkingDir}}` +
+	`{{if .Git.Branch}} · {{.Git.Branch}}{{if .Git.Dirty}}*{{end}}{{end}}`
+
+// Defaults for commands and refreshing
+const (
+	DefaultRefreshSeconds = 5
+	DefaultTimeout        = 2 * time.Second
+)
+
+// Config configures the status line
+type Config struct {
+	// Template is a text/template over Info (default: DefaultTemplate)
+	Template string `koanf:"template" json:"template"`
+
+	// Command is run with sh -c and gets Info as JSON on stdin; the first line
+	// it prints is the status line. It takes precedence over Template.
+	Command string `koanf:"command" json:"command"`
+
+	// RefreshSeconds is how often the line is refreshed besides after agent
+	// events (default: 5)
+	RefreshSeconds int `koanf:"refresh_seconds" json:"refresh_seconds"`
+}
+
+// RefreshInterval returns how often the line is refreshed
+func (c Config) RefreshInterval() time.Duration {
+	if c.RefreshSeconds > 0 {
+		return time.Duration(c.RefreshSeconds) * time.Second
+	}
+	return DefaultRefreshSeconds * time.Second
+}
+
+// Info is what the status line describes
+type Info struct {
+	Model          ModelInfo   `json:"model"`
+	ThinkingLevel  string      `json:"thinking_level,omitempty"` // Empty when the model does not think
+	PermissionMode string      `json:"permission_mode"`          // "ask", or "sandbox" when bash runs sandboxed
+	Context        ContextInfo `json:"context"`
+	Cost           CostInfo    `json:"cost"`
+	WorkingDir     string      `json:"working_dir"`
+	Git            GitInfo     `json:"git"`
+	Session        SessionInfo `json:"session"`
+}
+
+// ModelInfo describes the model in use
+type ModelInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+}
+
+// ContextInfo describes how much of the context window the conversation uses
+type ContextInfo struct {
+	Tokens  int `json:"tokens"` // Tokens of the last request and its reply
+	Window  int `json:"window"`
+	Percent int `json:"percent"`
+}
+
+// CostInfo sums the usage of the replies in the session
+type CostInfo struct {
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	USD          float64 `json:"usd"`
+}
+
+// GitInfo describes the git checkout of the working directory
+type GitInfo struct {
+	Branch string `json:"branch,omitempty"` // Empty outside a repository
+	Dirty  bool   `json:"dirty"`
+}
+
+// SessionInfo identifies the session
+type SessionInfo struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// Collect describes the agent state. price returns the model a reply was
+// written by, for pricing; without it replies are priced with the current model.
+func Collect(state *agent.AgentState, price func(modelID string) (ai.Model, bool)) Info {
+	model := state.GetModel()
+	info := Info{
+		Model:          ModelInfo{ID: model.ID, Name: model.Name, Provider: model.Provider},
+		PermissionMode: "ask",
+		Context:        ContextInfo{Window: model.ContextWindow},
+	}
+	if info.Model.Name == "" {
+		info.Model.Name = model.ID
+	}
+	if level := state.GetThinkingLevel(); model.SupportsThinking && level != "" && level != agent.ThinkingLevelNone {
+		info.ThinkingLevel = string(level)
+	}
+	for _, tool := range state.GetTools() {
+		if tool.Sandboxed {
+			info.PermissionMode = "sandbox"
+		}
+	}
+
+	usage := agent.SumUsage(state.GetMessages(), model, price)
+	info.Cost = CostInfo{InputTokens: usage.InputTokens, OutputTokens: usage.OutputTokens, USD: usage.Cost}
+
+	// The last request holds the whole conversation
+	info.Context.Tokens = usage.Last.InputTokens + usage.Last.OutputTokens
+	if info.Context.Window > 0 {
+		info.Context.Percent = min(100, info.Context.Tokens*100/info.Context.Window)
+	}
+
+	return info
+}
+
+// GitStatus returns the branch and dirty state of the checkout at dir. It
+// returns an empty GitInfo outside a repository.
+func GitStatus(ctx context.Context, dir string) GitInfo {
+	cmd := exec.CommandContext(ctx, "git", "status", "--porcelain=v2", "--branch", "--untracked-files=normal")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return GitInfo{}
+	}
+
+	var info GitInfo
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			info.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.oid ") && info.Branch == "(detached)":
+			if oid := strings.TrimPrefix(line, "# branch.oid "); len(oid) >= 7 {
+				info.Branch = oid[:7]
+			}
+		case !strings.HasPrefix(line, "#") && line != "":
+			info.Dirty = true
+		}
+	}
+	return info
+}
+
+// Render renders the status line for info with the command or template of config
+func Render(ctx context.Context, config Config, info Info, workingDir string) (string, error) {
+	if config.Command != "" {
+		return runCommand(ctx, config.Command, info, workingDir)
+	}
+
+	text := config.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("status_line").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid status line template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, info); err != nil {
+		return "", fmt.Errorf("invalid status line template: %w", err)
+	}
+	return firstLine(b.String()), nil
+}
+
+// runCommand runs the status line command with info on stdin and returns the
+// first line it prints
+func runCommand(ctx context.Context, command string, info Info, workingDir string) (string, error) {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode status line input: %w", err)
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
+	cmd.Dir = workingDir
+	cmd.Env = append(os.Environ(), "CC_PROJECT_DIR="+workingDir)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // Don't wait on background children holding the pipes
+
+	err = cmd.Run()
+	if cmdCtx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("status line command timed out after %v", DefaultTimeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("status line command failed with exit code %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("status line command failed: %w", err)
+	}
+	return firstLine(stdout.String()), nil
+}
+
+// firstLine returns the first line of text without trailing space
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return strings.TrimRight(line, " \t\r")
+}
@@ -0,0 +1,93 @@
+package statusline
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+func reply(model string, input, output int) agent.AgentMessage {
+	msg := ai.NewAssistantMessage([]ai.Content{ai.NewTextContent("done")}, "api", "provider", model, ai.Usage{InputTokens: input, OutputTokens: output}, ai.StopReasonEndTurn)
+	return agent.NewAgentMessage(msg, model, 0)
+}
+
+func TestCollect(t *testing.T) {
+	model := ai.Model{ID: "big", Name: "Big", ContextWindow: 10000, InputCostPer1M: 1, OutputCostPer1M: 2, SupportsThinking: true}
+	tool := agent.NewAgentTool(ai.NewTool("bash", "Run", map[string]any{}), "Bash", nil)
+	tool.Sandboxed = true
+	state := agent.NewAgentState("system", model, []agent.AgentTool{tool})
+	state.SetThinkingLevel(agent.ThinkingLevelHigh)
+	state.AddMessage(agent.NewAgentMessage(ai.NewUserTextMessage("hi"), "user-1", 0))
+	state.AddMessage(reply("small", 1_000_000, 0))
+	state.AddMessage(reply("big", 2000, 500))
+
+	price := func(id string) (ai.Model, bool) {
+		return ai.Model{ID: id, InputCostPer1M: 0.5}, id == "small"
+	}
+	info := Collect(state, price)
+	assert.Equal(t, "Big", info.Model.Name)
+	assert.Equal(t, "high", info.ThinkingLevel)
+	assert.Equal(t, "sandbox", info.PermissionMode)
+	assert.Equal(t, ContextInfo{Tokens: 2500, Window: 10000, Percent: 25}, info.Context)
+	assert.Equal(t, 1_002_000, info.Cost.InputTokens)
+	assert.InDelta(t, 0.5+0.002+0.001, info.Cost.USD, 1e-9)
+}
+
+func TestRender(t *testing.T) {
+	info := Info{
+		Model:          ModelInfo{ID: "big", Name: "Big"},
+		PermissionMode: "ask",
+		Context:        ContextInfo{Percent: 42},
+		Cost:           CostInfo{USD: 1.234},
+		WorkingDir:     "~/src/app",
+		Git:            GitInfo{Branch: "main", Dirty: true},
+	}
+
+	line, err := Render(context.Background(), Config{}, info, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "Big · ask · 42% context · $1.23 · ~/src/app · main*", line)
+
+	line, err = Render(context.Background(), Config{Template: "{{.Model.ID}} {{.Context.Percent}}%\nignored"}, info, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "big 42%", line)
+
+	_, err = Render(context.Background(), Config{Template: "{{.Nope}}"}, info, t.TempDir())
+	assert.Error(t, err)
+
+	// Commands get the info as JSON on stdin
+	line, err = Render(context.Background(), Config{Command: `printf 'cmd: '; cat`}, info, t.TempDir())
+	require.NoError(t, err)
+	assert.Contains(t, line, `cmd: {"model":{"id":"big"`)
+
+	_, err = Render(context.Background(), Config{Command: "echo broken >&2; exit 3"}, info, t.TempDir())
+	assert.ErrorContains(t, err, "broken")
+}
+
+func TestGitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	assert.Equal(t, GitInfo{}, GitStatus(context.Background(), dir))
+
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "feature"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		require.NoError(t, cmd.Run())
+	}
+	assert.Equal(t, GitInfo{Branch: "feature"}, GitStatus(context.Background(), dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0644))
+	assert.Equal(t, GitInfo{Branch: "feature", Dirty: true}, GitStatus(context.Background(), dir))
+}
@@ -0,0 +1,166 @@
+// Package subagent runs delegated tasks in child agents with their own history.
+package subagent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/frontmatter"
+)
+
+// GeneralPurpose is the name of the built-in sub-agent
+const GeneralPurpose = "general-purpose"
+
+// generalPurposePrompt is the system prompt of the built-in sub-agent
+const generalPurposePrompt = `You are a sub-agent of an AI coding assistant. You were given a self-contained task by the main agent.
+
+Work through the task autonomously with the tools you have: search, read and run what you need.
+Nobody will answer questions, so make reasonable assumptions and note them.
+
+When you are done, reply with a concise final report. The report is the only thing the main agent
+sees, so include the concrete findings it needs (file paths, line numbers, names, commands, results)
+and leave out the steps you took to get there.`
+
+// Definition describes a named sub-agent
+type Definition struct {
+	Name         string
+	Description  string   // When the main agent should use this sub-agent
+	Tools        []string // Tool names the sub-agent may use; empty means all
+	Model        string   // Model ID; empty means the parent's model
+	SystemPrompt string
+	Path         string // File the definition was loaded from, empty if built in
+}
+
+// header is the front matter of a sub-agent definition file
+type header struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Tools       any    `yaml:"tools"` // "read, bash" or [read, bash]
+	Model       string `yaml:"model"`
+}
+
+// DefaultDefinitions returns the built-in sub-agents
+func DefaultDefinitions() []Definition {
+	return []Definition{
+		{
+			Name:         GeneralPurpose,
+			Description:  "General-purpose agent for researching questions, searching code and carrying out multi-step tasks",
+			SystemPrompt: generalPurposePrompt,
+		},
+	}
+}
+
+// Dirs returns the directories sub-agent definitions are loaded from, lowest precedence first
+func Dirs(globalConfigDir, projectDir string) []string {
+	return []string{
+		filepath.Join(globalConfigDir, "agents"),
+		filepath.Join(projectDir, ".cc-mono", "agents"),
+	}
+}
+
+// LoadDefinitions loads the built-in sub-agents followed by the *.md files in dirs.
+// Definitions from later directories replace earlier ones with the same name.
+// Missing directories are ignored.
+func LoadDefinitions(dirs ...string) ([]Definition, error) {
+	byName := make(map[string]Definition)
+	for _, def := range DefaultDefinitions() {
+		byName[def.Name] = def
+	}
+
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sub-agents in %s: %w", dir, err)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			def, err := LoadDefinition(path)
+			if err != nil {
+				return nil, err
+			}
+			byName[def.Name] = def
+		}
+	}
+
+	defs := make([]Definition, 0, len(byName))
+	for _, def := range byName {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+
+	return defs, nil
+}
+
+// LoadDefinition loads a sub-agent from a markdown file. The front matter sets
+// name, description, tools and model; the body is the system prompt. The name
+// defaults to the file name without extension.
+func LoadDefinition(path string) (Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Definition{}, fmt.Errorf("failed to read sub-agent %s: %w", path, err)
+	}
+
+	var h header
+	body, err := frontmatter.Parse(data, &h)
+	if err != nil {
+		return Definition{}, fmt.Errorf("invalid sub-agent %s: %w", path, err)
+	}
+
+	tools, err := parseTools(h.Tools)
+	if err != nil {
+		return Definition{}, fmt.Errorf("invalid sub-agent %s: %w", path, err)
+	}
+
+	def := Definition{
+		Name:         strings.TrimSpace(h.Name),
+		Description:  strings.TrimSpace(h.Description),
+		Tools:        tools,
+		Model:        strings.TrimSpace(h.Model),
+		SystemPrompt: strings.TrimSpace(body),
+		Path:         path,
+	}
+	if def.Name == "" {
+		def.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if def.Model == "inherit" {
+		def.Model = ""
+	}
+	if def.SystemPrompt == "" {
+		return Definition{}, fmt.Errorf("invalid sub-agent %s: system prompt is empty", path)
+	}
+
+	return def, nil
+}
+
+// parseTools accepts a comma-separated string or a list of tool names
+func parseTools(value any) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		names = strings.Split(v, ",")
+	case []any:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tools must be a list of names")
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("tools must be a list of names")
+	}
+
+	var tools []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			tools = append(tools, name)
+		}
+	}
+	return tools, nil
+}
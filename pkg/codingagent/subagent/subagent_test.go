@@ -0,0 +1,238 @@
+package subagent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider replies with canned assistant messages, one per Stream call
+type scriptedProvider struct {
+	mu       sync.Mutex
+	replies  []ai.AssistantMessage
+	contexts []ai.Context
+	options  []*ai.StreamOptions
+	models   []string
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+func (p *scriptedProvider) Stream(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.StreamOptions) *ai.AssistantMessageEventStream {
+	p.mu.Lock()
+	p.contexts = append(p.contexts, aiContext)
+	p.options = append(p.options, options)
+	p.models = append(p.models, model.ID)
+	reply := textReply("done")
+	if len(p.replies) > 0 {
+		reply = p.replies[0]
+		p.replies = p.replies[1:]
+	}
+	p.mu.Unlock()
+
+	stream := ai.NewAssistantMessageEventStream(ctx)
+	go stream.SendResult(reply)
+	return stream
+}
+
+func (p *scriptedProvider) StreamSimple(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.SimpleStreamOptions) *ai.AssistantMessageEventStream {
+	return p.Stream(ctx, model, aiContext, nil)
+}
+
+func (p *scriptedProvider) ValidateModel(model ai.Model) error { return nil }
+
+func (p *scriptedProvider) GetDefaultModel() ai.Model { return ai.Model{ID: "scripted"} }
+
+func textReply(text string) ai.AssistantMessage {
+	return ai.NewAssistantMessage([]ai.Content{ai.NewTextContent(text)}, "test", "scripted", "", ai.Usage{}, ai.StopReasonEndTurn)
+}
+
+func toolReply(name string, params map[string]any) ai.AssistantMessage {
+	return ai.NewAssistantMessage([]ai.Content{ai.NewToolCall("call-1", name, params)}, "test", "scripted", "", ai.Usage{}, ai.StopReasonToolUse)
+}
+
+// echoTool returns its "text" param
+func echoTool(name string) agent.AgentTool {
+	return agent.NewAgentTool(
+		ai.NewTool(name, "Echo", map[string]any{"type": "object"}),
+		name,
+		func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+			text, _ := params["text"].(string)
+			return agent.AgentToolResult{Content: []ai.Content{ai.NewTextContent(text)}}, nil
+		},
+	)
+}
+
+func writeDefinition(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	globalDir := filepath.Join(t.TempDir(), "agents")
+	projectDir := filepath.Join(t.TempDir(), "agents")
+
+	writeDefinition(t, globalDir, "reviewer.md", "---\ndescription: Global reviewer\n---\nReview globally.")
+	writeDefinition(t, projectDir, "reviewer.md", "---\ndescription: Project reviewer\ntools: read, bash\nmodel: cheap-model\n---\nReview the project.\n")
+	writeDefinition(t, projectDir, "explore.md", "---\nname: explorer\ndescription: Explore\ntools: [read]\nmodel: inherit\n---\nExplore.")
+
+	defs, err := LoadDefinitions(globalDir, projectDir, filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	require.Len(t, defs, 3)
+
+	explorer, ok := findDefinition(defs, "explorer")
+	require.True(t, ok)
+	assert.Equal(t, []string{"read"}, explorer.Tools)
+	assert.Empty(t, explorer.Model)
+
+	reviewer, ok := findDefinition(defs, "reviewer")
+	require.True(t, ok)
+	assert.Equal(t, "Project reviewer", reviewer.Description)
+	assert.Equal(t, []string{"read", "bash"}, reviewer.Tools)
+	assert.Equal(t, "cheap-model", reviewer.Model)
+	assert.Equal(t, "Review the project.", reviewer.SystemPrompt)
+	assert.Equal(t, filepath.Join(projectDir, "reviewer.md"), reviewer.Path)
+
+	_, ok = findDefinition(defs, GeneralPurpose)
+	assert.True(t, ok)
+
+	t.Run("EmptyPrompt", func(t *testing.T) {
+		dir := t.TempDir()
+		writeDefinition(t, dir, "empty.md", "---\ndescription: Nothing\n---\n")
+		_, err := LoadDefinitions(dir)
+		assert.Error(t, err)
+	})
+}
+
+func TestTaskTool(t *testing.T) {
+	t.Run("ReturnsFinalReport", func(t *testing.T) {
+		provider := &scriptedProvider{replies: []ai.AssistantMessage{
+			toolReply("echo", map[string]any{"text": "found it"}),
+			textReply("The answer is in main.go"),
+		}}
+		parent := agent.NewAgent(provider, "parent", ai.Model{ID: "parent-model"}, []agent.AgentTool{echoTool("echo")})
+		task := CreateTaskTool(TaskToolOptions{Parent: parent, Definitions: DefaultDefinitions()})
+		parent.AddTool(task)
+
+		events := parent.GetEventBus().Subscribe(100)
+
+		result, err := task.Execute(context.Background(), "task-1", map[string]any{
+			"description": "Find the answer",
+			"prompt":      "Where is the answer?",
+		}, nil)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		assert.Equal(t, "The answer is in main.go", result.Content[0].(ai.TextContent).Text)
+
+		// The child has its own history and cannot start tasks itself
+		require.Len(t, provider.contexts, 2)
+		assert.Len(t, provider.contexts[0].Messages, 1)
+		assert.Equal(t, generalPurposePrompt, provider.contexts[0].SystemPrompt)
+		require.Len(t, provider.options[0].Tools, 1)
+		assert.Equal(t, "echo", provider.options[0].Tools[0].Name)
+		assert.Empty(t, parent.GetState().GetMessages())
+
+		// Child events are tagged with the parent tool call
+		parent.Close()
+		var toolStarts int
+		for event := range events {
+			sub, ok := event.(agent.SubAgentEvent)
+			require.True(t, ok, "unexpected event %T", event)
+			assert.Equal(t, "task-1", sub.ParentToolCallID)
+			assert.Equal(t, GeneralPurpose, sub.AgentType)
+			if _, ok := sub.Event.(agent.ToolExecutionStartEvent); ok {
+				toolStarts++
+			}
+		}
+		assert.Equal(t, 1, toolStarts)
+	})
+
+	t.Run("RestrictedToolsAndModel", func(t *testing.T) {
+		parentProvider := &scriptedProvider{}
+		cheapProvider := &scriptedProvider{}
+		parent := agent.NewAgent(parentProvider, "parent", ai.Model{ID: "parent-model"},
+			[]agent.AgentTool{echoTool("read"), echoTool("bash")})
+
+		defs := []Definition{{Name: "reader", Tools: []string{"read"}, Model: "cheap", SystemPrompt: "Read only."}}
+		task := CreateTaskTool(TaskToolOptions{
+			Parent:      parent,
+			Definitions: defs,
+			ResolveModel: func(modelID string) (ai.Model, ai.Provider, error) {
+				return ai.Model{ID: modelID}, cheapProvider, nil
+			},
+		})
+
+		result, err := task.Execute(context.Background(), "task-1", map[string]any{
+			"description":   "Read",
+			"prompt":        "Read things",
+			"subagent_type": "reader",
+		}, nil)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+
+		assert.Empty(t, parentProvider.contexts)
+		require.Len(t, cheapProvider.models, 1)
+		assert.Equal(t, "cheap", cheapProvider.models[0])
+		require.Len(t, cheapProvider.options[0].Tools, 1)
+		assert.Equal(t, "read", cheapProvider.options[0].Tools[0].Name)
+	})
+
+	t.Run("OwnBash", func(t *testing.T) {
+		provider := &scriptedProvider{replies: []ai.AssistantMessage{
+			toolReply("bash", map[string]any{"text": "cd /tmp"}),
+			textReply("done"),
+		}}
+		parent := agent.NewAgent(provider, "parent", ai.Model{ID: "parent-model"}, []agent.AgentTool{echoTool("bash")})
+
+		var created, released int
+		task := CreateTaskTool(TaskToolOptions{
+			Parent:      parent,
+			Definitions: DefaultDefinitions(),
+			NewBash: func() (agent.AgentTool, func()) {
+				created++
+				bash := echoTool("bash")
+				bash.Execute = func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+					return agent.AgentToolResult{Content: []ai.Content{ai.NewTextContent("child shell")}}, nil
+				}
+				return bash, func() { released++ }
+			},
+		})
+
+		result, err := task.Execute(context.Background(), "task-1", map[string]any{"prompt": "Run things"}, nil)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		assert.Equal(t, 1, created)
+		assert.Equal(t, 1, released)
+
+		// The command ran in the child's shell
+		require.Len(t, provider.contexts, 2)
+		toolResult := provider.contexts[1].Messages[2].(ai.ToolResultMessage)
+		assert.Equal(t, "child shell", toolResult.Content[0].(ai.TextContent).Text)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		parent := agent.NewAgent(&scriptedProvider{}, "parent", ai.Model{}, nil)
+		defs := append(DefaultDefinitions(), Definition{Name: "shell", Tools: []string{"bash"}, SystemPrompt: "Shell."})
+		task := CreateTaskTool(TaskToolOptions{Parent: parent, Definitions: defs})
+
+		result, err := task.Execute(context.Background(), "task-1", map[string]any{"prompt": ""}, nil)
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+
+		result, err = task.Execute(context.Background(), "task-1", map[string]any{"prompt": "x", "subagent_type": "missing"}, nil)
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+
+		result, err = task.Execute(context.Background(), "task-1", map[string]any{"prompt": "x", "subagent_type": "shell"}, nil)
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].(ai.TextContent).Text, "tool not available")
+	})
+}
@@ -0,0 +1,263 @@
+package subagent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// ToolName is the name of the task tool
+const ToolName = "task"
+
+// ModelResolver returns the model and a provider for it by model ID
+type ModelResolver func(modelID string) (ai.Model, ai.Provider, error)
+
+// TaskToolOptions configures the task tool
+type TaskToolOptions struct {
+	// Parent is the agent the tool belongs to. Sub-agents use its model, provider
+	// and tools, and their events are published on its event bus.
+	Parent *agent.Agent
+
+	// Definitions are the sub-agents the tool can start
+	Definitions []Definition
+
+	// ResolveModel resolves the model of definitions that set one.
+	// Without it, every sub-agent uses the parent's model.
+	ResolveModel ModelResolver
+
+	// MaxTurns limits the turns of each sub-agent (default: 50)
+	MaxTurns int
+
+	// NewBash creates the bash tool of a sub-agent, which replaces the
+	// parent's so that each sub-agent runs commands in a shell of its own.
+	// The returned function releases the shell when the sub-agent finishes.
+	// Without it, sub-agents share the parent's bash tool.
+	NewBash func() (agent.AgentTool, func())
+}
+
+// CreateTaskTool creates the tool that delegates a task to a sub-agent and
+// returns its final report
+func CreateTaskTool(opts TaskToolOptions) agent.AgentTool {
+	if opts.MaxTurns <= 0 {
+		opts.MaxTurns = 50
+	}
+
+	var names []string
+	var descriptions []string
+	for _, def := range opts.Definitions {
+		names = append(names, def.Name)
+		descriptions = append(descriptions, fmt.Sprintf("- %s: %s", def.Name, def.Description))
+	}
+
+	tool := ai.NewTool(
+		ToolName,
+		fmt.Sprintf(`Delegate a self-contained task to a sub-agent with its own context. The sub-agent works autonomously and returns only its final report, so use it for broad searches and investigations that would otherwise fill the conversation with file contents. Start several tasks in one response to run them concurrently. The sub-agent does not see this conversation: put everything it needs in the prompt and say what the report should contain.
+
+Available sub-agents:
+%s`, strings.Join(descriptions, "\n")),
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"description": map[string]any{
+					"type":        "string",
+					"description": "Short (3-5 word) description of the task",
+				},
+				"prompt": map[string]any{
+					"type":        "string",
+					"description": "The task for the sub-agent",
+				},
+				"subagent_type": map[string]any{
+					"type":        "string",
+					"description": fmt.Sprintf("Optional: Sub-agent to use (default: %s)", GeneralPurpose),
+					"enum":        names,
+				},
+			},
+			"required": []string{"description", "prompt"},
+		},
+	)
+
+	execute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		prompt, _ := params["prompt"].(string)
+		if strings.TrimSpace(prompt) == "" {
+			return errorResult("Error: prompt must be a non-empty string"), nil
+		}
+
+		name, _ := params["subagent_type"].(string)
+		if name == "" {
+			name = GeneralPurpose
+		}
+		def, ok := findDefinition(opts.Definitions, name)
+		if !ok {
+			return errorResult(fmt.Sprintf("Error: unknown sub-agent: %s", name)), nil
+		}
+
+		if onUpdate != nil {
+			description, _ := params["description"].(string)
+			onUpdate(agent.AgentToolUpdate{
+				Type:    "progress",
+				Message: fmt.Sprintf("Running %s: %s", def.Name, description),
+			})
+		}
+
+		report, err := run(ctx, opts, def, toolCallID, prompt)
+		if err != nil {
+			return errorResult(fmt.Sprintf("Error: sub-agent %s failed: %v", def.Name, err)), nil
+		}
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(report)},
+			Details: map[string]any{
+				"subagent_type": def.Name,
+			},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Task", execute)
+}
+
+// run runs a sub-agent to completion and returns its final report
+func run(ctx context.Context, opts TaskToolOptions, def Definition, toolCallID, prompt string) (string, error) {
+	parentState := opts.Parent.GetState()
+	model := parentState.GetModel()
+	provider := opts.Parent.GetProvider()
+
+	if def.Model != "" && opts.ResolveModel != nil {
+		var err error
+		model, provider, err = opts.ResolveModel(def.Model)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve model %s: %w", def.Model, err)
+		}
+	}
+
+	tools, err := selectTools(parentState.GetTools(), def.Tools)
+	if err != nil {
+		return "", err
+	}
+	if opts.NewBash != nil {
+		for i, tool := range tools {
+			if tool.Tool.Name == "bash" {
+				bash, release := opts.NewBash()
+				defer release()
+				tools[i] = bash
+				break
+			}
+		}
+	}
+
+	child := agent.NewAgent(provider, def.SystemPrompt, model, tools)
+	child.SetThinkingLevel(parentState.GetThinkingLevel())
+
+	// Forward the child's events to the parent, tagged with the tool call
+	events := child.GetEventBus().Subscribe(100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parentBus := opts.Parent.GetEventBus()
+		for event := range events {
+			// Permission requests are answered by whoever handles the parent's
+			if _, ok := event.(agent.PermissionRequestEvent); ok {
+				parentBus.Publish(event)
+				continue
+			}
+			parentBus.Publish(agent.NewSubAgentEvent(toolCallID, def.Name, event))
+		}
+	}()
+
+	config := &agent.AgentLoopConfig{
+		MaxTurns:     opts.MaxTurns,
+		MaxToolCalls: 50,
+	}
+	userMsg := agent.NewAgentMessage(
+		ai.NewUserTextMessage(prompt),
+		fmt.Sprintf("user-%d", time.Now().UnixNano()),
+		time.Now().UnixMilli(),
+	)
+	runErr := child.RunWithConfig(ctx, []agent.AgentMessage{userMsg}, config)
+
+	child.Close()
+	<-done
+
+	if runErr != nil {
+		return "", runErr
+	}
+
+	report := finalReport(child.GetState().GetMessages())
+	if report == "" {
+		return "", fmt.Errorf("sub-agent finished without a report")
+	}
+	return report, nil
+}
+
+// selectTools returns the named tools, or all tools when names is empty.
+// The task tool itself is never passed on, so sub-agents cannot nest.
+func selectTools(available []agent.AgentTool, names []string) ([]agent.AgentTool, error) {
+	var tools []agent.AgentTool
+	if len(names) == 0 {
+		for _, tool := range available {
+			if tool.Tool.Name != ToolName {
+				tools = append(tools, tool)
+			}
+		}
+		return tools, nil
+	}
+
+	for _, name := range names {
+		found := false
+		for _, tool := range available {
+			if strings.EqualFold(tool.Tool.Name, name) && tool.Tool.Name != ToolName {
+				tools = append(tools, tool)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("tool not available to sub-agents: %s", name)
+		}
+	}
+	return tools, nil
+}
+
+// findDefinition finds a sub-agent by name
+func findDefinition(defs []Definition, name string) (Definition, bool) {
+	for _, def := range defs {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// finalReport returns the text of the last assistant message
+func finalReport(messages []agent.AgentMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg, ok := messages[i].Message.(ai.AssistantMessage)
+		if !ok {
+			continue
+		}
+		var parts []string
+		for _, content := range msg.Content {
+			if text, ok := content.(ai.TextContent); ok && strings.TrimSpace(text.Text) != "" {
+				parts = append(parts, text.Text)
+			}
+		}
+		return strings.TrimSpace(strings.Join(parts, "\n"))
+	}
+	return ""
+}
+
+// errorResult creates an error tool result
+func errorResult(message string) agent.AgentToolResult {
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent(message)},
+		IsError: true,
+	}
+}
@@ -0,0 +1,356 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
+)
+
+// fileContent is the state of a file before or after a patch
+type fileContent struct {
+	exists  bool
+	content string
+	mode    os.FileMode
+}
+
+// patchFileResult describes the change a patch made to one file
+type patchFileResult struct {
+	Action  patchAction
+	Path    string
+	MoveTo  string
+	Added   int
+	Removed int
+	Diff    string // Unified diff of the change
+}
+
+// patchPlan applies a patch in memory before anything is written
+type patchPlan struct {
+	workingDir string
+	order      []string // Touched paths, in the order they were first touched
+	original   map[string]fileContent
+	staged     map[string]fileContent
+	results    []patchFileResult
+}
+
+// CreateApplyPatchTool creates the tool that applies a unified diff or "*** Begin Patch" envelope
+func CreateApplyPatchTool(workingDir string) agent.AgentTool {
+	tool := ai.NewTool(
+		"apply_patch",
+		`Apply a patch that adds, updates, deletes or renames one or more files. All changes are applied or none are. Accepts a standard unified diff (as produced by git diff or diff -u) or this envelope format:
+
+*** Begin Patch
+*** Add File: path/to/new.go
++package main
+*** Update File: path/to/file.go
+*** Move to: path/to/renamed.go
+@@ func main() {
+ context line
+-removed line
++added line
+*** Delete File: path/to/old.go
+*** End Patch
+
+Include about 3 lines of context around each change. An "@@" line may name a line to find first, such as the enclosing function. Context is matched exactly, or else ignoring differences in whitespace.`,
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"patch": map[string]any{
+					"type":        "string",
+					"description": "The patch to apply",
+				},
+			},
+			"required": []string{"patch"},
+		},
+	)
+
+	execute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		text, _ := params["patch"].(string)
+		if strings.TrimSpace(text) == "" {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent("Error: patch must be a non-empty string")},
+				IsError: true,
+			}, nil
+		}
+
+		patches, err := parsePatch(text)
+		if err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: invalid patch: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		if onUpdate != nil {
+			onUpdate(agent.AgentToolUpdate{
+				Type:    "progress",
+				Message: fmt.Sprintf("Applying patch to %d file(s)...", len(patches)),
+			})
+		}
+
+		plan := &patchPlan{
+			workingDir: workingDir,
+			original:   make(map[string]fileContent),
+			staged:     make(map[string]fileContent),
+		}
+		for _, p := range patches {
+			if err := plan.apply(ctx, p); err != nil {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v. No files were changed.", err))},
+					IsError: true,
+				}, nil
+			}
+		}
+
+		if err := plan.commit(ctx); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v. All changes were rolled back.", err))},
+				IsError: true,
+			}, nil
+		}
+
+		var summary strings.Builder
+		summary.WriteString(fmt.Sprintf("Successfully applied patch to %d file(s):\n", len(plan.results)))
+		files := make([]map[string]any, 0, len(plan.results))
+		for _, result := range plan.results {
+			summary.WriteString(result.String())
+			summary.WriteString("\n")
+			files = append(files, map[string]any{
+				"action":  string(result.Action),
+				"path":    result.Path,
+				"move_to": result.MoveTo,
+				"added":   result.Added,
+				"removed": result.Removed,
+				"diff":    result.Diff,
+			})
+		}
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(strings.TrimSuffix(summary.String(), "\n"))},
+			Details: map[string]any{
+				"files": files,
+			},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Apply Patch", execute)
+}
+
+// String formats a result as a status line, e.g. "M main.go (+2 -1)"
+func (r patchFileResult) String() string {
+	switch r.Action {
+	case patchAdd:
+		return fmt.Sprintf("A %s (+%d)", r.Path, r.Added)
+	case patchDelete:
+		return fmt.Sprintf("D %s", r.Path)
+	}
+	if r.MoveTo != "" {
+		return fmt.Sprintf("R %s -> %s (+%d -%d)", r.Path, r.MoveTo, r.Added, r.Removed)
+	}
+	return fmt.Sprintf("M %s (+%d -%d)", r.Path, r.Added, r.Removed)
+}
+
+// apply stages the change to one file
+func (pl *patchPlan) apply(ctx context.Context, p filePatch) error {
+	path := resolvePath(pl.workingDir, p.Path)
+
+	switch p.Action {
+	case patchAdd:
+		if err := checkPatchPermission(ctx, "write", path); err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		current, err := pl.load(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		if current.exists {
+			return fmt.Errorf("%s: file already exists", p.Path)
+		}
+		content := ""
+		if len(p.Lines) > 0 {
+			content = strings.Join(p.Lines, "\n") + "\n"
+		}
+		pl.staged[path] = fileContent{exists: true, content: content, mode: 0644}
+		pl.results = append(pl.results, patchFileResult{
+			Action: patchAdd,
+			Path:   p.Path,
+			Added:  len(p.Lines),
+			Diff:   diff.Unified("", content, p.Path),
+		})
+
+	case patchDelete:
+		if err := checkPatchPermission(ctx, "write", path); err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		current, err := pl.load(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		if !current.exists {
+			return fmt.Errorf("%s: file not found", p.Path)
+		}
+		pl.staged[path] = fileContent{}
+		pl.results = append(pl.results, patchFileResult{
+			Action: patchDelete,
+			Path:   p.Path,
+			Diff:   diff.Unified(current.content, "", p.Path),
+		})
+
+	case patchUpdate:
+		if err := checkPatchPermission(ctx, "edit", path); err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		current, err := pl.load(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+		if !current.exists {
+			return fmt.Errorf("%s: file not found", p.Path)
+		}
+
+		content, err := applyHunks(current.content, p.Hunks)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Path, err)
+		}
+
+		result := patchFileResult{Action: patchUpdate, Path: p.Path, Diff: diff.Unified(current.content, content, p.Path)}
+		for _, hunk := range p.Hunks {
+			result.Added += hunk.Added
+			result.Removed += hunk.Removed
+		}
+
+		target := path
+		if p.MoveTo != "" {
+			target = resolvePath(pl.workingDir, p.MoveTo)
+			result.MoveTo = p.MoveTo
+		}
+		if target != path {
+			if err := checkPatchPermission(ctx, "write", target); err != nil {
+				return fmt.Errorf("%s: %w", p.MoveTo, err)
+			}
+			existing, err := pl.load(target)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p.MoveTo, err)
+			}
+			if existing.exists {
+				return fmt.Errorf("%s: cannot move %s, file already exists", p.MoveTo, p.Path)
+			}
+			pl.staged[path] = fileContent{}
+		}
+		pl.staged[target] = fileContent{exists: true, content: content, mode: current.mode}
+		pl.results = append(pl.results, result)
+
+	default:
+		return fmt.Errorf("%s: unknown action %q", p.Path, p.Action)
+	}
+	return nil
+}
+
+// load returns the staged state of a file, reading it from disk the first time
+func (pl *patchPlan) load(path string) (fileContent, error) {
+	if state, ok := pl.staged[path]; ok {
+		return state, nil
+	}
+
+	state := fileContent{}
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fileContent{}, err
+	case info.IsDir():
+		return fileContent{}, fmt.Errorf("is a directory")
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fileContent{}, err
+		}
+		state = fileContent{exists: true, content: string(data), mode: info.Mode().Perm()}
+	}
+
+	pl.order = append(pl.order, path)
+	pl.original[path] = state
+	pl.staged[path] = state
+	return state, nil
+}
+
+// commit writes the staged files, restoring the originals if any write fails
+func (pl *patchPlan) commit(ctx context.Context) error {
+	var written []string
+	rollback := func() {
+		for i := len(written) - 1; i >= 0; i-- {
+			_ = writeFileContent(written[i], pl.original[written[i]])
+		}
+	}
+
+	for _, path := range pl.order {
+		original, staged := pl.original[path], pl.staged[path]
+		if original == staged {
+			continue
+		}
+
+		// Record the current content so the change can be rewound
+		if err := checkpoint.Snapshot(ctx, path); err != nil {
+			rollback()
+			return fmt.Errorf("failed to create checkpoint for %s: %w", path, err)
+		}
+
+		written = append(written, path)
+		if err := writeFileContent(path, staged); err != nil {
+			rollback()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// writeFileContent puts a file in the given state, creating or removing it as needed
+func writeFileContent(path string, state fileContent) error {
+	if !state.exists {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(state.content), state.mode)
+}
+
+// checkPatchPermission applies the permission rules of the write or edit tool
+// to a path the patch touches. The patch as a whole was already approved, so
+// only rules that deny the path stop it.
+func checkPatchPermission(ctx context.Context, toolName, path string) error {
+	pm, ok := ctx.Value("permission_manager").(*agent.PermissionManager)
+	if !ok {
+		return nil
+	}
+
+	req := &agent.PermissionRequest{
+		ToolName: toolName,
+		Action:   "execute",
+		Resource: path,
+		Params:   map[string]any{"file_path": path},
+	}
+	allowed, needAsk, err := pm.CheckPermission(req)
+	if err != nil {
+		return fmt.Errorf("permission check failed: %w", err)
+	}
+	if !allowed && !needAsk {
+		return fmt.Errorf("permission denied by rule %s", req.MatchedRule)
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
+)
+
+// maxBackgroundOutput is the number of bytes of output kept per background job
+const maxBackgroundOutput = 1024 * 1024 // 1MB
+
+// Background job statuses
+const (
+	JobRunning = "running"
+	JobExited  = "exited"
+	JobKilled  = "killed"
+)
+
+// BackgroundJobInfo describes a background job
+type BackgroundJobInfo struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	Cwd       string    `json:"cwd"`
+	StartedAt time.Time `json:"started_at"`
+	Status    string    `json:"status"`
+	ExitCode  int       `json:"exit_code"` // Valid once the job is no longer running
+}
+
+// BackgroundOutput is the output of a job since the previous poll
+type BackgroundOutput struct {
+	BackgroundJobInfo
+	Output  string
+	Dropped int // Bytes of output lost because the job wrote faster than it was polled
+}
+
+// backgroundJob is a command running outside the shell
+type backgroundJob struct {
+	mu   sync.Mutex
+	info BackgroundJobInfo
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	buf  []byte // Most recent output
+	base int    // Offset of buf[0] in the whole output
+	read int    // Offset up to which output was polled
+}
+
+// Write appends output. Past maxBackgroundOutput bytes the buffer is trimmed to
+// the most recent half of that, so that jobs with a lot of output don't copy
+// the whole buffer on every write.
+func (j *backgroundJob) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.buf = append(j.buf, p...)
+	if len(j.buf) > maxBackgroundOutput {
+		extra := len(j.buf) - maxBackgroundOutput/2
+		j.buf = append(make([]byte, 0, maxBackgroundOutput), j.buf[extra:]...)
+		j.base += extra
+	}
+	return len(p), nil
+}
+
+// BackgroundManager runs commands in the background and keeps their output
+// until polled. Jobs run until they exit, are killed or the manager is closed.
+type BackgroundManager struct {
+	mu      sync.Mutex
+	sandbox *sandbox.Sandbox
+	jobs    map[string]*backgroundJob
+	nextID  int
+}
+
+// NewBackgroundManager creates a background job manager. sb is optional.
+func NewBackgroundManager(sb *sandbox.Sandbox) *BackgroundManager {
+	return &BackgroundManager{
+		sandbox: sb,
+		jobs:    make(map[string]*backgroundJob),
+	}
+}
+
+// Start starts command with bash in dir and returns the job ID. exports are
+// declarations as printed by export -p that are run before command, e.g. to
+// pass on the variables exported in a Shell.
+func (m *BackgroundManager) Start(command, dir, exports string) (string, error) {
+	script := command
+	if exports != "" {
+		// Variables bash doesn't let scripts set, like readonly ones, are skipped
+		script = fmt.Sprintf("{\n%s\n} 2>/dev/null\n%s", exports, command)
+	}
+
+	var cmd *exec.Cmd
+	if m.sandbox != nil {
+		cmd = m.sandbox.Command(context.Background(), "bash", "-c", script)
+	} else {
+		cmd = exec.Command("bash", "-c", script)
+	}
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+
+	// Don't let children that outlive the job keep Wait from returning
+	cmd.WaitDelay = time.Second
+
+	m.mu.Lock()
+	m.nextID++
+	id := fmt.Sprintf("bash_%d", m.nextID)
+	m.mu.Unlock()
+
+	job := &backgroundJob{
+		info: BackgroundJobInfo{
+			ID:        id,
+			Command:   command,
+			Cwd:       dir,
+			StartedAt: time.Now(),
+			Status:    JobRunning,
+		},
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	cmd.Stdout = job
+	cmd.Stderr = job
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start background command: %w", err)
+	}
+
+	m.mu.Lock()
+	m.jobs[id] = job
+	m.mu.Unlock()
+
+	go func() {
+		defer close(job.done)
+		_ = cmd.Wait()
+
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		if job.info.Status == JobRunning {
+			job.info.Status = JobExited
+		}
+		job.info.ExitCode = cmd.ProcessState.ExitCode()
+	}()
+
+	return id, nil
+}
+
+// Output returns the output of a job written since the previous call. With a
+// filter, only matching lines are returned; the rest is discarded.
+func (m *BackgroundManager) Output(id string, filter *regexp.Regexp) (BackgroundOutput, error) {
+	job, err := m.get(id)
+	if err != nil {
+		return BackgroundOutput{}, err
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	result := BackgroundOutput{BackgroundJobInfo: job.info}
+	start := job.read
+	if start < job.base {
+		result.Dropped = job.base - start
+		start = job.base
+	}
+	output := string(job.buf[start-job.base:])
+	job.read = job.base + len(job.buf)
+
+	if filter != nil && output != "" {
+		var lines []string
+		for _, line := range strings.SplitAfter(output, "\n") {
+			if filter.MatchString(strings.TrimSuffix(line, "\n")) {
+				lines = append(lines, line)
+			}
+		}
+		output = strings.Join(lines, "")
+	}
+	result.Output = output
+
+	return result, nil
+}
+
+// Kill kills a job and everything it started
+func (m *BackgroundManager) Kill(id string) error {
+	job, err := m.get(id)
+	if err != nil {
+		return err
+	}
+
+	job.mu.Lock()
+	if job.info.Status != JobRunning {
+		job.mu.Unlock()
+		return fmt.Errorf("background job %s is not running (%s)", id, job.info.Status)
+	}
+	job.info.Status = JobKilled
+	job.mu.Unlock()
+
+	killProcessGroup(job.cmd)
+	<-job.done
+	return nil
+}
+
+// List returns all jobs, oldest first
+func (m *BackgroundManager) List() []BackgroundJobInfo {
+	m.mu.Lock()
+	jobs := make([]*backgroundJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+
+	infos := make([]BackgroundJobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		job.mu.Lock()
+		infos = append(infos, job.info)
+		job.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt.Before(infos[j].StartedAt) })
+
+	return infos
+}
+
+// Running returns the number of running jobs
+func (m *BackgroundManager) Running() int {
+	count := 0
+	for _, info := range m.List() {
+		if info.Status == JobRunning {
+			count++
+		}
+	}
+	return count
+}
+
+// Close kills all running jobs and forgets all jobs
+func (m *BackgroundManager) Close() {
+	for _, info := range m.List() {
+		if info.Status == JobRunning {
+			_ = m.Kill(info.ID)
+		}
+	}
+
+	m.mu.Lock()
+	m.jobs = make(map[string]*backgroundJob)
+	m.mu.Unlock()
+}
+
+// get finds a job by ID
+func (m *BackgroundManager) get(id string) (*backgroundJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("background job not found: %s", id)
+	}
+	return job, nil
+}
+
+// CreateBashOutputTool creates the tool that reads new output from a background job
+func CreateBashOutputTool(manager *BackgroundManager) agent.AgentTool {
+	tool := ai.NewTool(
+		"bash_output",
+		"Read the output of a background bash command written since the last read, and whether it is still running.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"shell_id": map[string]any{
+					"type":        "string",
+					"description": "ID of the background command, as returned by bash with run_in_background",
+				},
+				"filter": map[string]any{
+					"type":        "string",
+					"description": "Optional: Regular expression; only matching lines are returned and the rest is discarded",
+				},
+			},
+			"required": []string{"shell_id"},
+		},
+	)
+
+	execute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		id, _ := params["shell_id"].(string)
+		if id == "" {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent("Error: shell_id must be a non-empty string")},
+				IsError: true,
+			}, nil
+		}
+
+		var filter *regexp.Regexp
+		if pattern, _ := params["filter"].(string); pattern != "" {
+			var err error
+			filter, err = regexp.Compile(pattern)
+			if err != nil {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: invalid filter: %v", err))},
+					IsError: true,
+				}, nil
+			}
+		}
+
+		output, err := manager.Output(id, filter)
+		if err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		var resultMsg strings.Builder
+		resultMsg.WriteString(fmt.Sprintf("Command: %s\n", output.Command))
+		resultMsg.WriteString(fmt.Sprintf("Status: %s\n", output.Status))
+		if output.Status != JobRunning {
+			resultMsg.WriteString(fmt.Sprintf("Exit code: %d\n", output.ExitCode))
+		}
+		if output.Dropped > 0 {
+			resultMsg.WriteString(fmt.Sprintf("\n... (%d bytes of earlier output dropped)\n", output.Dropped))
+		}
+		if output.Output == "" {
+			resultMsg.WriteString("\n(no new output)\n")
+		} else {
+			resultMsg.WriteString("\nOutput:\n")
+			resultMsg.WriteString(output.Output)
+		}
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(resultMsg.String())},
+			Details: map[string]any{
+				"shell_id":  id,
+				"status":    output.Status,
+				"exit_code": output.ExitCode,
+			},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Background Output", execute)
+}
+
+// CreateKillShellTool creates the tool that kills a background job
+func CreateKillShellTool(manager *BackgroundManager) agent.AgentTool {
+	tool := ai.NewTool(
+		"kill_shell",
+		"Kill a background bash command and the processes it started.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"shell_id": map[string]any{
+					"type":        "string",
+					"description": "ID of the background command to kill",
+				},
+			},
+			"required": []string{"shell_id"},
+		},
+	)
+
+	execute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		id, _ := params["shell_id"].(string)
+		if err := manager.Kill(id); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Killed background command %s", id))},
+			Details: map[string]any{"shell_id": id},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Kill Background Command", execute)
+}
@@ -1,38 +1,78 @@
 package tools
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
 )
 
+// BashToolOptions configures the bash tool
+type BashToolOptions struct {
+	// Sandbox restricts filesystem and network access of commands (optional)
+	Sandbox *sandbox.Sandbox
+
+	// Shell runs the commands (optional). By default each tool gets its own shell.
+	Shell *Shell
+
+	// Background runs commands with run_in_background (optional)
+	Background *BackgroundManager
+}
+
 // CreateBashTool creates the bash command execution tool
 func CreateBashTool(workingDir string) agent.AgentTool {
+	return CreateBashToolWithOptions(workingDir, BashToolOptions{})
+}
+
+// CreateBashToolWithOptions creates the bash command execution tool with options
+func CreateBashToolWithOptions(workingDir string, opts BashToolOptions) agent.AgentTool {
+	description := "Execute a bash command and return the output. Commands run one at a time in a persistent shell, so the working directory, environment variables and functions carry over between calls. Use with caution."
+	if opts.Sandbox != nil {
+		description += " " + opts.Sandbox.Describe()
+	}
+
+	properties := map[string]any{
+		"command": map[string]any{
+			"type":        "string",
+			"description": "The bash command to execute",
+		},
+		"timeout": map[string]any{
+			"type":        "number",
+			"description": "Optional: Timeout in seconds (default: 30). The shell is restarted when a command times out",
+		},
+		"reset": map[string]any{
+			"type":        "boolean",
+			"description": "Optional: Restart the shell in the project directory before running the command, dropping environment changes",
+		},
+	}
+	if opts.Background != nil {
+		properties["run_in_background"] = map[string]any{
+			"type":        "boolean",
+			"description": "Optional: Start the command in the background and return its ID right away, for servers, watchers and long builds. It gets the shell's working directory and exported variables, but not its functions, aliases or unexported variables. Read its output with bash_output and stop it with kill_shell",
+		}
+	}
+
 	tool := ai.NewTool(
 		"bash",
-		"Execute a bash command and return the output. Use with caution.",
+		description,
 		map[string]any{
-			"type": "object",
-			"properties": map[string]any{
-				"command": map[string]any{
-					"type":        "string",
-					"description": "The bash command to execute",
-				},
-				"timeout": map[string]any{
-					"type":        "number",
-					"description": "Optional: Timeout in seconds (default: 30)",
-				},
-			},
-			"required": []string{"command"},
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"command"},
 		},
 	)
 
+	// One shell per tool, so state carries over between calls
+	shell := opts.Shell
+	if shell == nil {
+		shell = NewShell(workingDir, opts.Sandbox)
+	}
+
 	execute := func(
 		ctx context.Context,
 		toolCallID string,
@@ -50,7 +90,18 @@ func CreateBashTool(workingDir string) agent.AgentTool {
 
 		timeout := 30 * time.Second
 		if val, ok := params["timeout"].(float64); ok {
-			timeout = time.Duration(val) * time.Second
+			timeout = time.Duration(val * float64(time.Second))
+		}
+
+		// Go back to a fresh shell in the working directory if asked
+		if reset, _ := params["reset"].(bool); reset {
+			shell.Reset()
+			if strings.TrimSpace(command) == "" {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Shell reset.\nWorking directory: %s\n", shell.Cwd()))},
+					Details: map[string]any{"command": command, "exit_code": 0, "cwd": shell.Cwd()},
+				}, nil
+			}
 		}
 
 		// Send progress update
@@ -61,42 +112,73 @@ func CreateBashTool(workingDir string) agent.AgentTool {
 			})
 		}
 
-		// Create context with timeout
-		cmdCtx, cancel := context.WithTimeout(ctx, timeout)
-		defer cancel()
+		// Start long-running commands outside the shell, in its current
+		// directory and with its exported variables
+		if background, _ := params["run_in_background"].(bool); background && opts.Background != nil {
+			exports, err := shell.Exports(ctx)
+			var id string
+			if err == nil {
+				id, err = opts.Background.Start(command, shell.Cwd(), exports)
+			}
+			if err != nil {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+					IsError: true,
+				}, nil
+			}
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf(
+					"Command running in background with ID: %s\nUse bash_output to read its output and kill_shell to stop it.\n", id))},
+				Details: map[string]any{
+					"command":  command,
+					"shell_id": id,
+					"cwd":      shell.Cwd(),
+				},
+			}, nil
+		}
 
 		// Execute command
-		cmd := exec.CommandContext(cmdCtx, "bash", "-c", command)
-		cmd.Dir = workingDir
-
-		var stdout, stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
 		startTime := time.Now()
-		err := cmd.Run()
+		result, err := shell.Run(ctx, command, timeout)
 		duration := time.Since(startTime)
 
 		// Prepare output
-		output := stdout.String()
-		errOutput := stderr.String()
-
-		// Truncate output if too large
-		maxOutputSize := 50000 // 50KB
-		if len(output) > maxOutputSize {
-			output = output[:maxOutputSize] + "\n\n... (output truncated)"
-		}
-		if len(errOutput) > maxOutputSize {
-			errOutput = errOutput[:maxOutputSize] + "\n\n... (stderr truncated)"
+		output := result.Stdout
+		errOutput := result.Stderr
+		if result.Truncated {
+			if len(output) >= maxShellOutput {
+				output += "\n\n... (output truncated)"
+			}
+			if len(errOutput) >= maxShellOutput {
+				errOutput += "\n\n... (stderr truncated)"
+			}
 		}
 
 		// Build result message
 		var resultMsg strings.Builder
 		resultMsg.WriteString(fmt.Sprintf("Command: %s\n", command))
 		resultMsg.WriteString(fmt.Sprintf("Duration: %.2fs\n", duration.Seconds()))
+		resultMsg.WriteString(fmt.Sprintf("Working directory: %s\n", result.Cwd))
+
+		details := map[string]any{
+			"command":   command,
+			"exit_code": result.ExitCode,
+			"duration":  duration.Seconds(),
+			"stdout":    output,
+			"stderr":    errOutput,
+			"cwd":       result.Cwd,
+			"sandboxed": opts.Sandbox != nil,
+		}
 
-		if err != nil {
-			resultMsg.WriteString(fmt.Sprintf("Exit code: %d\n", cmd.ProcessState.ExitCode()))
+		if err != nil || result.ExitCode != 0 {
+			switch {
+			case errors.Is(err, ErrShellTimeout):
+				resultMsg.WriteString(fmt.Sprintf("Error: command timed out after %s\n", timeout))
+			case err != nil:
+				resultMsg.WriteString(fmt.Sprintf("Error: %v\n", err))
+			default:
+				resultMsg.WriteString(fmt.Sprintf("Exit code: %d\n", result.ExitCode))
+			}
 			resultMsg.WriteString("\nStderr:\n")
 			resultMsg.WriteString(errOutput)
 
@@ -105,15 +187,16 @@ func CreateBashTool(workingDir string) agent.AgentTool {
 				resultMsg.WriteString(output)
 			}
 
+			if result.Restarted {
+				resultMsg.WriteString("\nNote: The shell was restarted; environment variables and functions from earlier commands are gone.\n")
+			}
+			if opts.Sandbox != nil {
+				resultMsg.WriteString("\nNote: " + opts.Sandbox.Describe() + " Failures may be caused by these restrictions.\n")
+			}
+
 			return agent.AgentToolResult{
 				Content: []ai.Content{ai.NewTextContent(resultMsg.String())},
-				Details: map[string]any{
-					"command":   command,
-					"exit_code": cmd.ProcessState.ExitCode(),
-					"duration":  duration.Seconds(),
-					"stdout":    output,
-					"stderr":    errOutput,
-				},
+				Details: details,
 				IsError: true,
 			}, nil
 		}
@@ -130,16 +213,13 @@ func CreateBashTool(workingDir string) agent.AgentTool {
 
 		return agent.AgentToolResult{
 			Content: []ai.Content{ai.NewTextContent(resultMsg.String())},
-			Details: map[string]any{
-				"command":   command,
-				"exit_code": 0,
-				"duration":  duration.Seconds(),
-				"stdout":    output,
-				"stderr":    errOutput,
-			},
+			Details: details,
 			IsError: false,
 		}, nil
 	}
 
-	return agent.NewAgentTool(tool, "Bash Command", execute)
+	agentTool := agent.NewAgentTool(tool, "Bash Command", execute)
+	agentTool.Sandboxed = opts.Sandbox != nil
+	agentTool.Networked = opts.Sandbox != nil && opts.Sandbox.AllowsNetwork()
+	return agentTool
 }
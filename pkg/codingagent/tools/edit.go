@@ -8,6 +8,8 @@ import (
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
 )
 
 // CreateEditTool creates the edit file tool
@@ -24,7 +26,7 @@ func CreateEditTool(workingDir string) agent.AgentTool {
 				},
 				"old_string": map[string]any{
 					"type":        "string",
-					"description": "The text to replace (must match exactly or with fuzzy matching)",
+					"description": "The text to replace (must match exactly or with fuzzy matching), without the line number prefixes shown by read",
 				},
 				"new_string": map[string]any{
 					"type":        "string",
@@ -106,6 +108,14 @@ func CreateEditTool(workingDir string) agent.AgentTool {
 			}, nil
 		}
 
+		// Record the current content so the change can be rewound
+		if err := checkpoint.Snapshot(ctx, absPath); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error creating checkpoint: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
 		// Write back
 		if err := os.WriteFile(absPath, []byte(newContent), 0644); err != nil {
 			return agent.AgentToolResult{
@@ -115,15 +125,16 @@ func CreateEditTool(workingDir string) agent.AgentTool {
 		}
 
 		// Generate diff
-		diff := generateDiff(content, newContent, filePath)
+		changes := generateDiff(content, newContent, filePath)
 
 		return agent.AgentToolResult{
-			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Successfully edited %s (%d replacement(s))\n\n%s", filePath, numReplacements, diff))},
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Successfully edited %s (%d replacement(s))\n\n%s", filePath, numReplacements, changes))},
 			Details: map[string]any{
 				"path":          filePath,
 				"replacements":  numReplacements,
 				"old_size":      len(content),
 				"new_size":      len(newContent),
+				"diff":          diff.Unified(content, newContent, filePath),
 			},
 			IsError: false,
 		}, nil
@@ -189,71 +200,11 @@ func findFuzzyMatch(content, pattern string) int {
 	return idx
 }
 
-// generateDiff generates a simple unified diff
+// generateDiff generates a unified diff of the change for the model
 func generateDiff(oldContent, newContent, filename string) string {
-	oldLines := strings.Split(oldContent, "\n")
-	newLines := strings.Split(newContent, "\n")
-
-	var diff strings.Builder
-	diff.WriteString(fmt.Sprintf("--- %s (old)\n", filename))
-	diff.WriteString(fmt.Sprintf("+++ %s (new)\n", filename))
-
-	// Find differences
-	maxLen := len(oldLines)
-	if len(newLines) > maxLen {
-		maxLen = len(newLines)
-	}
-
-	contextLines := 3
-	inDiff := false
-	diffStart := -1
-
-	for i := 0; i < maxLen; i++ {
-		oldLine := ""
-		newLine := ""
-
-		if i < len(oldLines) {
-			oldLine = oldLines[i]
-		}
-		if i < len(newLines) {
-			newLine = newLines[i]
-		}
-
-		if oldLine != newLine {
-			if !inDiff {
-				// Start of diff hunk
-				inDiff = true
-				diffStart = i - contextLines
-				if diffStart < 0 {
-					diffStart = 0
-				}
-
-				diff.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", diffStart+1, len(oldLines), diffStart+1, len(newLines)))
-
-				// Write context before diff
-				for j := diffStart; j < i; j++ {
-					if j < len(oldLines) {
-						diff.WriteString(" " + oldLines[j] + "\n")
-					}
-				}
-			}
-
-			// Write diff lines
-			if i < len(oldLines) && oldLine != "" {
-				diff.WriteString("-" + oldLine + "\n")
-			}
-			if i < len(newLines) && newLine != "" {
-				diff.WriteString("+" + newLine + "\n")
-			}
-		} else if inDiff {
-			// Context after diff
-			diff.WriteString(" " + oldLine + "\n")
-		}
-	}
-
-	if diff.Len() == 0 {
+	hunks := diff.Hunks(diff.Lines(oldContent, newContent), diff.DefaultContext)
+	if len(hunks) == 0 {
 		return "No changes"
 	}
-
-	return diff.String()
+	return fmt.Sprintf("--- %s (old)\n+++ %s (new)\n", filename, filename) + diff.Format(hunks)
 }
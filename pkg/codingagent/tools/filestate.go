@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// ErrFileNotRead is returned when an existing file is changed before it was read
+var ErrFileNotRead = errors.New("file has not been read yet")
+
+// ErrFileModified is returned when a file changed on disk since it was last read
+var ErrFileModified = errors.New("file has been modified since it was last read")
+
+// fileState is what a file looked like when the agent last saw it
+type fileState struct {
+	hash    [sha256.Size]byte
+	modTime time.Time
+	size    int64
+}
+
+// FileStateTracker remembers the files the agent has read or written in a
+// session, so write and edit tools can refuse to change a file the agent has
+// not seen or that changed on disk since it last looked.
+type FileStateTracker struct {
+	mu         sync.Mutex
+	workingDir string
+	files      map[string]fileState
+}
+
+// NewFileStateTracker creates a tracker that resolves relative paths against workingDir
+func NewFileStateTracker(workingDir string) *FileStateTracker {
+	return &FileStateTracker{
+		workingDir: workingDir,
+		files:      make(map[string]fileState),
+	}
+}
+
+// Track records the current content of a file as seen by the agent
+func (t *FileStateTracker) Track(path string) error {
+	path = t.resolve(path)
+	state, err := readFileState(path)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files[path] = state
+	return nil
+}
+
+// Check returns ErrFileNotRead or ErrFileModified when an existing file may
+// not be changed. Files that don't exist yet can always be written.
+func (t *FileStateTracker) Check(path string) error {
+	path = t.resolve(path)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	seen, ok := t.files[path]
+	t.mu.Unlock()
+	if !ok {
+		return ErrFileNotRead
+	}
+	if info.ModTime().Equal(seen.modTime) && info.Size() == seen.size {
+		return nil
+	}
+
+	// The timestamp moved; only a content change counts
+	current, err := readFileState(path)
+	if err != nil {
+		return err
+	}
+	if current.hash != seen.hash {
+		return ErrFileModified
+	}
+
+	t.mu.Lock()
+	t.files[path] = current
+	t.mu.Unlock()
+	return nil
+}
+
+// Reset forgets all files, e.g. when a new session starts
+func (t *FileStateTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files = make(map[string]fileState)
+}
+
+// WrapTool makes read record what it read, and write, edit, multi_edit and
+// apply_patch check the files before changing them and record them afterwards.
+// Other tools are returned unchanged.
+func (t *FileStateTracker) WrapTool(tool agent.AgentTool) agent.AgentTool {
+	execute := tool.Execute
+	switch tool.Tool.Name {
+	case "read":
+		tool.Execute = func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+			result, err := execute(ctx, toolCallID, params, onUpdate)
+			if path, ok := params["file_path"].(string); ok && err == nil && !result.IsError {
+				_ = t.Track(path)
+			}
+			return result, err
+		}
+
+	case "write", "edit", "multi_edit":
+		tool.Execute = func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+			path, ok := params["file_path"].(string)
+			if !ok {
+				return execute(ctx, toolCallID, params, onUpdate)
+			}
+
+			if err := t.Check(path); err != nil {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(staleFileMessage(path, err))},
+					IsError: true,
+				}, nil
+			}
+
+			result, err := execute(ctx, toolCallID, params, onUpdate)
+			if err == nil && !result.IsError {
+				// Files the agent wrote count as read, so it can keep editing them
+				_ = t.Track(path)
+			}
+			return result, err
+		}
+
+	case "apply_patch":
+		tool.Execute = func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+			text, _ := params["patch"].(string)
+			patches, err := parsePatch(text)
+			if err != nil {
+				// Let the tool report the invalid patch
+				return execute(ctx, toolCallID, params, onUpdate)
+			}
+
+			for _, p := range patches {
+				if p.Action == patchAdd {
+					continue
+				}
+				if err := t.Check(p.Path); err != nil {
+					return agent.AgentToolResult{
+						Content: []ai.Content{ai.NewTextContent(staleFileMessage(p.Path, err) + " No files were changed.")},
+						IsError: true,
+					}, nil
+				}
+			}
+
+			result, err := execute(ctx, toolCallID, params, onUpdate)
+			if err == nil && !result.IsError {
+				for _, p := range patches {
+					if p.Action != patchDelete {
+						_ = t.Track(p.target())
+					}
+				}
+			}
+			return result, err
+		}
+	}
+	return tool
+}
+
+// WrapAllTools wraps each tool with WrapTool
+func (t *FileStateTracker) WrapAllTools(tools []agent.AgentTool) []agent.AgentTool {
+	wrapped := make([]agent.AgentTool, len(tools))
+	for i, tool := range tools {
+		wrapped[i] = t.WrapTool(tool)
+	}
+	return wrapped
+}
+
+// resolve returns the absolute, cleaned path used as the tracker key
+func (t *FileStateTracker) resolve(path string) string {
+	return filepath.Clean(resolvePath(t.workingDir, path))
+}
+
+// readFileState hashes a file and records its size and modification time
+func readFileState(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{
+		hash:    sha256.Sum256(data),
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}, nil
+}
+
+// staleFileMessage tells the model why a change was refused and what to do
+func staleFileMessage(path string, err error) string {
+	switch {
+	case errors.Is(err, ErrFileNotRead):
+		return fmt.Sprintf("Error: %s has not been read yet. Read it with the read tool before changing it.", path)
+	case errors.Is(err, ErrFileModified):
+		return fmt.Sprintf("Error: %s has been modified since it was last read, either by the user or by another process. Read it again before changing it.", path)
+	default:
+		return fmt.Sprintf("Error checking %s: %v", path, err)
+	}
+}
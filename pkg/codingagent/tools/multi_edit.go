@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
+)
+
+// fileEdit is one replacement of a multi_edit call
+type fileEdit struct {
+	OldString  string
+	NewString  string
+	ReplaceAll bool
+}
+
+// CreateMultiEditTool creates the tool that applies several edits to one file at once
+func CreateMultiEditTool(workingDir string) agent.AgentTool {
+	tool := ai.NewTool(
+		"multi_edit",
+		"Apply several replacements to one file in a single atomic operation. Edits are applied in order, each to the result of the previous one. The file is written only if every edit succeeds; otherwise nothing is changed. Prefer this over several edit calls on the same file.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"file_path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file to edit",
+				},
+				"edits": map[string]any{
+					"type":        "array",
+					"description": "Edits to apply in order",
+					"minItems":    1,
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"old_string": map[string]any{
+								"type":        "string",
+								"description": "The text to replace, without the line number prefixes shown by read. Must be unique in the file unless replace_all is set",
+							},
+							"new_string": map[string]any{
+								"type":        "string",
+								"description": "The new text to insert",
+							},
+							"replace_all": map[string]any{
+								"type":        "boolean",
+								"description": "If true, replace all occurrences. Default: false",
+							},
+						},
+						"required": []string{"old_string", "new_string"},
+					},
+				},
+			},
+			"required": []string{"file_path", "edits"},
+		},
+	)
+
+	execute := func(
+		ctx context.Context,
+		toolCallID string,
+		params map[string]any,
+		onUpdate agent.AgentToolUpdateCallback,
+	) (agent.AgentToolResult, error) {
+		filePath, ok := params["file_path"].(string)
+		if !ok || filePath == "" {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent("Error: file_path must be a non-empty string")},
+				IsError: true,
+			}, nil
+		}
+
+		edits, err := parseFileEdits(params["edits"])
+		if err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		absPath := resolvePath(workingDir, filePath)
+
+		if onUpdate != nil {
+			onUpdate(agent.AgentToolUpdate{
+				Type:    "progress",
+				Message: fmt.Sprintf("Applying %d edit(s) to %s...", len(edits), filePath),
+			})
+		}
+
+		data, err := os.ReadFile(absPath)
+		if err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error reading file: %v", err))},
+				IsError: true,
+			}, nil
+		}
+		content := string(data)
+
+		// Apply every edit in memory before touching the file
+		newContent := content
+		replacements := 0
+		for i, edit := range edits {
+			var count int
+			newContent, count, err = applyFileEdit(newContent, edit)
+			if err != nil {
+				return agent.AgentToolResult{
+					Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: edit %d of %d: %v. No changes were made.", i+1, len(edits), err))},
+					IsError: true,
+				}, nil
+			}
+			replacements += count
+		}
+
+		// Record the current content so the change can be rewound
+		if err := checkpoint.Snapshot(ctx, absPath); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error creating checkpoint: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		if err := os.WriteFile(absPath, []byte(newContent), 0644); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error writing file: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
+		changes := generateDiff(content, newContent, filePath)
+
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Successfully applied %d edit(s) to %s (%d replacement(s))\n\n%s", len(edits), filePath, replacements, changes))},
+			Details: map[string]any{
+				"path":         filePath,
+				"edits":        len(edits),
+				"replacements": replacements,
+				"old_size":     len(content),
+				"new_size":     len(newContent),
+				"diff":         diff.Unified(content, newContent, filePath),
+			},
+		}, nil
+	}
+
+	return agent.NewAgentTool(tool, "Multi Edit File", execute)
+}
+
+// parseFileEdits converts the edits param
+func parseFileEdits(value any) ([]fileEdit, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("edits must be a non-empty array")
+	}
+
+	edits := make([]fileEdit, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("edit %d must be an object", i+1)
+		}
+		oldString, ok := obj["old_string"].(string)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: old_string must be a string", i+1)
+		}
+		newString, ok := obj["new_string"].(string)
+		if !ok {
+			return nil, fmt.Errorf("edit %d: new_string must be a string", i+1)
+		}
+		replaceAll, _ := obj["replace_all"].(bool)
+
+		edits = append(edits, fileEdit{OldString: oldString, NewString: newString, ReplaceAll: replaceAll})
+	}
+	return edits, nil
+}
+
+// applyFileEdit applies one edit, requiring old_string to be unique unless
+// all occurrences are replaced
+func applyFileEdit(content string, edit fileEdit) (string, int, error) {
+	if edit.OldString == "" {
+		return "", 0, fmt.Errorf("old_string must not be empty")
+	}
+	if edit.OldString == edit.NewString {
+		return "", 0, fmt.Errorf("old_string and new_string are identical")
+	}
+	if !edit.ReplaceAll {
+		if count := strings.Count(content, edit.OldString); count > 1 {
+			return "", 0, fmt.Errorf("old_string appears %d times; add surrounding context to make it unique or set replace_all", count)
+		}
+	}
+	return performEdit(content, edit.OldString, edit.NewString, edit.ReplaceAll)
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Limits on what a notebook read returns
+const (
+	maxNotebookOutputBytes = 10 * 1024 // Bytes kept of each cell output
+	maxNotebookImages      = 10        // Image outputs attached to the result
+)
+
+// ansiEscape matches the color codes notebooks keep in tracebacks
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// notebookText is text that notebooks store either whole or as a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*t = notebookText(text)
+	return nil
+}
+
+// notebook is the part of the Jupyter notebook format (nbformat 4) that is shown
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// notebookCell is a code, markdown or raw cell
+type notebookCell struct {
+	CellType       string           `json:"cell_type"`
+	Source         notebookText     `json:"source"`
+	ExecutionCount *int             `json:"execution_count"`
+	Outputs        []notebookOutput `json:"outputs"`
+}
+
+// notebookOutput is a stream, result, display or error output of a code cell
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	EName      string                  `json:"ename"`
+	EValue     string                  `json:"evalue"`
+	Traceback  []string                `json:"traceback"`
+}
+
+// language returns the notebook's programming language
+func (nb *notebook) language() string {
+	if nb.Metadata.LanguageInfo.Name != "" {
+		return nb.Metadata.LanguageInfo.Name
+	}
+	return nb.Metadata.KernelSpec.Language
+}
+
+// readNotebookFile renders a Jupyter notebook as its cells followed by their outputs
+func readNotebookFile(absPath, displayPath string) (agent.AgentToolResult, error) {
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+			IsError: true,
+		}, nil
+	}
+
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %s is not a valid notebook: %v", displayPath, err))},
+			IsError: true,
+		}, nil
+	}
+
+	var content []ai.Content
+	var b strings.Builder
+	size, images, truncated := 0, 0, false
+	flush := func() {
+		if b.Len() > 0 {
+			content = append(content, ai.NewTextContent(strings.TrimRight(b.String(), "\n")))
+			b.Reset()
+		}
+	}
+
+	header := fmt.Sprintf("Notebook: %s (%d cells", displayPath, len(nb.Cells))
+	if lang := nb.language(); lang != "" {
+		header += ", " + lang
+	}
+	b.WriteString(header + ")\n\n")
+
+	for i, cell := range nb.Cells {
+		text := renderNotebookCell(i+1, cell)
+		if size+len(text) > maxReadBytes {
+			b.WriteString(fmt.Sprintf("... (notebook truncated; %d of %d cells shown)\n", i, len(nb.Cells)))
+			truncated = true
+			break
+		}
+		size += len(text)
+		b.WriteString(text + "\n")
+
+		for _, output := range cell.Outputs {
+			for _, mediaType := range []string{"image/png", "image/jpeg", "image/gif"} {
+				image, ok := output.Data[mediaType]
+				if !ok {
+					continue
+				}
+				if images == maxNotebookImages {
+					b.WriteString(fmt.Sprintf("[%s output omitted]\n", mediaType))
+					break
+				}
+				b.WriteString(fmt.Sprintf("[%s output of cell %d]\n", mediaType, i+1))
+				flush()
+				content = append(content, ai.NewImageContentFromBase64(strings.ReplaceAll(string(image), "\n", ""), mediaType))
+				images++
+				break
+			}
+		}
+	}
+	flush()
+
+	return agent.AgentToolResult{
+		Content: content,
+		Details: map[string]any{
+			"path":      displayPath,
+			"size":      len(data),
+			"cells":     len(nb.Cells),
+			"images":    images,
+			"truncated": truncated,
+		},
+		IsError: false,
+	}, nil
+}
+
+// renderNotebookCell renders a cell's source and text outputs
+func renderNotebookCell(number int, cell notebookCell) string {
+	var b strings.Builder
+	if cell.CellType == "code" && cell.ExecutionCount != nil {
+		b.WriteString(fmt.Sprintf("Cell %d [code, execution %d]:\n", number, *cell.ExecutionCount))
+	} else {
+		b.WriteString(fmt.Sprintf("Cell %d [%s]:\n", number, cell.CellType))
+	}
+	b.WriteString(strings.TrimRight(string(cell.Source), "\n"))
+	b.WriteString("\n")
+
+	var outputs []string
+	for _, output := range cell.Outputs {
+		if text := notebookOutputText(output); text != "" {
+			if len(text) > maxNotebookOutputBytes {
+				text = strings.ToValidUTF8(text[:maxNotebookOutputBytes], "") + "\n... (output truncated)"
+			}
+			outputs = append(outputs, text)
+		}
+	}
+	if len(outputs) > 0 {
+		b.WriteString("Output:\n")
+		b.WriteString(strings.Join(outputs, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// notebookOutputText returns the text of an output, without color codes
+func notebookOutputText(output notebookOutput) string {
+	var text string
+	switch output.OutputType {
+	case "stream":
+		text = string(output.Text)
+	case "execute_result", "display_data":
+		for _, mediaType := range []string{"text/plain", "text/markdown"} {
+			if data, ok := output.Data[mediaType]; ok {
+				text = string(data)
+				break
+			}
+		}
+	case "error":
+		text = fmt.Sprintf("%s: %s", output.EName, output.EValue)
+		if len(output.Traceback) > 0 {
+			text = strings.Join(output.Traceback, "\n")
+		}
+	}
+	return strings.TrimRight(ansiEscape.ReplaceAllString(text, ""), "\n")
+}
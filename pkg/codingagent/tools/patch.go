@@ -0,0 +1,531 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// patchAction is what a patch does to a file
+type patchAction string
+
+const (
+	patchAdd    patchAction = "add"
+	patchUpdate patchAction = "update"
+	patchDelete patchAction = "delete"
+)
+
+// filePatch is the change a patch makes to one file
+type filePatch struct {
+	Action patchAction
+	Path   string
+	MoveTo string   // New path when the file is renamed
+	Lines  []string // Content of an added file
+	Hunks  []patchHunk
+}
+
+// target returns the path the file has after the patch
+func (p filePatch) target() string {
+	if p.MoveTo != "" {
+		return p.MoveTo
+	}
+	return p.Path
+}
+
+// patchHunk replaces the Old lines of a file with the New lines
+type patchHunk struct {
+	Anchor  string // Line to find before matching the hunk ("@@ func main()" in envelope patches)
+	Old     []string
+	New     []string
+	Kinds   []byte // ' ', '-' or '+' for each line of the hunk, in order
+	Start   int    // 0-based line in the original file where the hunk starts, -1 if unknown
+	AtEOF   bool   // The hunk must match the end of the file
+	Added   int
+	Removed int
+}
+
+// parsePatch parses a unified diff or a "*** Begin Patch" envelope
+func parsePatch(text string) ([]filePatch, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+
+	var patches []filePatch
+	var err error
+	if isEnvelopePatch(lines) {
+		patches, err = parseEnvelopePatch(lines)
+	} else {
+		patches, err = parseUnifiedDiff(lines)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("patch contains no file changes")
+	}
+	return patches, nil
+}
+
+// isEnvelopePatch reports whether lines hold a "*** Begin Patch" envelope
+func isEnvelopePatch(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "*** Begin Patch" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvelopePatch parses the "*** Begin Patch" format:
+//
+//	*** Begin Patch
+//	*** Add File: path
+//	+content
+//	*** Update File: path
+//	*** Move to: new/path
+//	@@ optional line to find first
+//	 context
+//	-removed
+//	+added
+//	*** Delete File: path
+//	*** End Patch
+func parseEnvelopePatch(lines []string) ([]filePatch, error) {
+	i := 0
+	for strings.TrimSpace(lines[i]) != "*** Begin Patch" {
+		i++
+	}
+	i++
+
+	var patches []filePatch
+	for i < len(lines) {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "*** End Patch":
+			return patches, nil
+
+		case strings.HasPrefix(line, "*** Add File: "):
+			p := filePatch{Action: patchAdd, Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Add File: "))}
+			i++
+			for ; i < len(lines) && !strings.HasPrefix(lines[i], "*** "); i++ {
+				if !strings.HasPrefix(lines[i], "+") {
+					return nil, fmt.Errorf("line %d: lines of an added file must start with '+'", i+1)
+				}
+				p.Lines = append(p.Lines, lines[i][1:])
+			}
+			patches = append(patches, p)
+
+		case strings.HasPrefix(line, "*** Delete File: "):
+			patches = append(patches, filePatch{Action: patchDelete, Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Delete File: "))})
+			i++
+
+		case strings.HasPrefix(line, "*** Update File: "):
+			p := filePatch{Action: patchUpdate, Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Update File: "))}
+			i++
+			if i < len(lines) && strings.HasPrefix(lines[i], "*** Move to: ") {
+				p.MoveTo = strings.TrimSpace(strings.TrimPrefix(lines[i], "*** Move to: "))
+				i++
+			}
+
+			var hunk *patchHunk
+			for ; i < len(lines) && (!strings.HasPrefix(lines[i], "*** ") || lines[i] == "*** End of File"); i++ {
+				body := lines[i]
+				if body == "*** End of File" {
+					if hunk == nil {
+						return nil, fmt.Errorf("line %d: end of file marker outside a hunk", i+1)
+					}
+					hunk.AtEOF = true
+					continue
+				}
+				if strings.HasPrefix(body, "@@") {
+					p.Hunks = append(p.Hunks, patchHunk{Anchor: strings.TrimSpace(strings.TrimPrefix(body, "@@")), Start: -1})
+					hunk = &p.Hunks[len(p.Hunks)-1]
+					continue
+				}
+				if hunk == nil {
+					p.Hunks = append(p.Hunks, patchHunk{Start: -1})
+					hunk = &p.Hunks[len(p.Hunks)-1]
+				}
+				if !addHunkLine(hunk, body) {
+					return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", i+1, body)
+				}
+			}
+
+			p.Hunks = dropEmptyHunks(p.Hunks)
+			if len(p.Hunks) == 0 && p.MoveTo == "" {
+				return nil, fmt.Errorf("update of %s contains no changes", p.Path)
+			}
+			patches = append(patches, p)
+
+		case strings.TrimSpace(line) == "":
+			i++
+
+		default:
+			return nil, fmt.Errorf("line %d: expected a file header, got %q", i+1, line)
+		}
+	}
+
+	// A missing end marker is tolerated
+	return patches, nil
+}
+
+// unifiedFile collects the headers and hunks of one file in a unified diff
+type unifiedFile struct {
+	oldPath string
+	newPath string
+	headers bool // The ---/+++ lines were seen
+	hunks   []patchHunk
+}
+
+// parseUnifiedDiff parses a unified diff, including git diffs with renames
+func parseUnifiedDiff(lines []string) ([]filePatch, error) {
+	var patches []filePatch
+	var cur *unifiedFile
+
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		p, err := cur.filePatch()
+		cur = nil
+		if err != nil {
+			return err
+		}
+		patches = append(patches, p)
+		return nil
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			cur = &unifiedFile{}
+			rest := strings.TrimPrefix(line, "diff --git ")
+			if idx := strings.Index(rest, " b/"); strings.HasPrefix(rest, "a/") && idx > 0 {
+				cur.oldPath, cur.newPath = rest[2:idx], rest[idx+3:]
+			}
+			i++
+
+		case strings.HasPrefix(line, "rename from ") && cur != nil:
+			cur.oldPath = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+			i++
+
+		case strings.HasPrefix(line, "rename to ") && cur != nil:
+			cur.newPath = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+			i++
+
+		case isFileHeader(lines, i):
+			if cur == nil || cur.headers {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				cur = &unifiedFile{}
+			}
+			cur.oldPath = diffPath(lines[i][4:], "a/")
+			cur.newPath = diffPath(lines[i+1][4:], "b/")
+			cur.headers = true
+			i += 2
+
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header", i+1)
+			}
+			hunk, next, err := parseUnifiedHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, hunk)
+			i = next
+
+		default:
+			// index, mode and similarity lines, or text around the diff
+			i++
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return patches, nil
+}
+
+// filePatch converts the collected diff of a file
+func (f *unifiedFile) filePatch() (filePatch, error) {
+	hunks := dropEmptyHunks(f.hunks)
+	switch {
+	case f.oldPath == "" && f.newPath == "":
+		return filePatch{}, fmt.Errorf("diff without file names")
+
+	case f.oldPath == "/dev/null":
+		p := filePatch{Action: patchAdd, Path: f.newPath}
+		for _, hunk := range hunks {
+			p.Lines = append(p.Lines, hunk.New...)
+		}
+		return p, nil
+
+	case f.newPath == "/dev/null":
+		return filePatch{Action: patchDelete, Path: f.oldPath}, nil
+	}
+
+	p := filePatch{Action: patchUpdate, Path: f.oldPath, Hunks: hunks}
+	if f.newPath != "" && f.newPath != f.oldPath {
+		p.MoveTo = f.newPath
+	}
+	if len(p.Hunks) == 0 && p.MoveTo == "" {
+		return filePatch{}, fmt.Errorf("diff of %s contains no changes", p.Path)
+	}
+	return p, nil
+}
+
+// parseUnifiedHunk parses the hunk starting at lines[i] and returns the index of the line after it
+func parseUnifiedHunk(lines []string, i int) (patchHunk, int, error) {
+	hunk := patchHunk{Start: -1}
+
+	// @@ -start,count +start,count @@
+	fields := strings.Fields(lines[i])
+	if len(fields) >= 2 && strings.HasPrefix(fields[1], "-") {
+		startText, countText, hasCount := strings.Cut(fields[1][1:], ",")
+		start, err := strconv.Atoi(startText)
+		if err != nil {
+			return patchHunk{}, 0, fmt.Errorf("line %d: invalid hunk header: %q", i+1, lines[i])
+		}
+		count := 1
+		if hasCount {
+			count, _ = strconv.Atoi(countText)
+		}
+		// An empty range starts after the given line
+		if count == 0 {
+			hunk.Start = start
+		} else {
+			hunk.Start = start - 1
+		}
+	}
+
+	i++
+	blank := 0 // Trailing bare empty lines, which may separate files rather than be context
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ") || isFileHeader(lines, i) {
+			break
+		}
+		if strings.HasPrefix(line, `\`) {
+			continue // "\ No newline at end of file"
+		}
+		if !addHunkLine(&hunk, line) {
+			break
+		}
+		if line == "" {
+			blank++
+		} else {
+			blank = 0
+		}
+	}
+
+	if blank > 0 {
+		hunk.Old = hunk.Old[:len(hunk.Old)-blank]
+		hunk.New = hunk.New[:len(hunk.New)-blank]
+		hunk.Kinds = hunk.Kinds[:len(hunk.Kinds)-blank]
+	}
+	return hunk, i, nil
+}
+
+// addHunkLine adds a context, removed or added line to a hunk. Bare empty
+// lines count as empty context lines.
+func addHunkLine(hunk *patchHunk, line string) bool {
+	if line == "" {
+		line = " "
+	}
+	switch line[0] {
+	case ' ':
+		hunk.Old = append(hunk.Old, line[1:])
+		hunk.New = append(hunk.New, line[1:])
+	case '-':
+		hunk.Old = append(hunk.Old, line[1:])
+		hunk.Removed++
+	case '+':
+		hunk.New = append(hunk.New, line[1:])
+		hunk.Added++
+	default:
+		return false
+	}
+	hunk.Kinds = append(hunk.Kinds, line[0])
+	return true
+}
+
+// dropEmptyHunks removes hunks that change nothing
+func dropEmptyHunks(hunks []patchHunk) []patchHunk {
+	var kept []patchHunk
+	for _, hunk := range hunks {
+		if hunk.Added > 0 || hunk.Removed > 0 {
+			kept = append(kept, hunk)
+		}
+	}
+	return kept
+}
+
+// isFileHeader reports whether lines[i] and lines[i+1] are "---" and "+++" file headers
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// diffPath extracts the file name from a ---/+++ header, dropping the a/ or b/ prefix and any timestamp
+func diffPath(header, prefix string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// applyHunks applies hunks in order and returns the new content
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+	lines, trailingNewline := splitLines(content)
+
+	cursor := 0 // Hunks apply in order, each after the previous one
+	offset := 0 // Lines added minus lines removed by earlier hunks
+	for n, hunk := range hunks {
+		if hunk.Anchor != "" {
+			idx := findAnchor(lines, hunk.Anchor, cursor)
+			if idx < 0 {
+				return "", fmt.Errorf("hunk %d: line %q not found", n+1, hunk.Anchor)
+			}
+			cursor = idx + 1
+		}
+
+		hint := cursor
+		if hunk.Start >= 0 && hunk.Start+offset > cursor {
+			hint = hunk.Start + offset
+		}
+
+		var pos int
+		switch {
+		case len(hunk.Old) > 0:
+			pos = findHunk(lines, hunk.Old, cursor, hint, hunk.AtEOF)
+			if pos < 0 {
+				return "", fmt.Errorf("hunk %d: context not found (tried exact and fuzzy matching):\n%s", n+1, strings.Join(hunk.Old, "\n"))
+			}
+		case hunk.AtEOF || (hunk.Start < 0 && hunk.Anchor == ""):
+			pos = len(lines)
+		default:
+			pos = min(hint, len(lines))
+		}
+
+		updated := make([]string, 0, len(lines)-len(hunk.Old)+len(hunk.New))
+		updated = append(updated, lines[:pos]...)
+		updated = append(updated, hunk.replacement(lines[pos:pos+len(hunk.Old)])...)
+		updated = append(updated, lines[pos+len(hunk.Old):]...)
+		lines = updated
+
+		cursor = pos + len(hunk.New)
+		offset += len(hunk.New) - len(hunk.Old)
+	}
+
+	return joinLines(lines, trailingNewline), nil
+}
+
+// replacement returns the new lines for the matched old lines. Context lines
+// keep the file's text, which may differ in whitespace after a fuzzy match.
+func (h patchHunk) replacement(matched []string) []string {
+	var lines []string
+	oldIndex, newIndex := 0, 0
+	for _, kind := range h.Kinds {
+		switch kind {
+		case ' ':
+			lines = append(lines, matched[oldIndex])
+			oldIndex++
+			newIndex++
+		case '-':
+			oldIndex++
+		case '+':
+			lines = append(lines, h.New[newIndex])
+			newIndex++
+		}
+	}
+	return lines
+}
+
+// findHunk returns the line at or after from where old matches, preferring the
+// match closest to hint. Exact matches win over matches that differ only in whitespace.
+func findHunk(lines, old []string, from, hint int, atEOF bool) int {
+	last := len(lines) - len(old)
+	if from > last {
+		return -1
+	}
+
+	candidates := func(equal func(a, b string) bool) int {
+		best := -1
+		for pos := from; pos <= last; pos++ {
+			if atEOF && pos != last {
+				continue
+			}
+			if !linesMatch(lines[pos:pos+len(old)], old, equal) {
+				continue
+			}
+			if best < 0 || abs(pos-hint) < abs(best-hint) {
+				best = pos
+			}
+		}
+		return best
+	}
+
+	if pos := candidates(func(a, b string) bool { return a == b }); pos >= 0 {
+		return pos
+	}
+
+	if findFuzzyMatch(strings.Join(lines[from:], "\n"), strings.Join(old, "\n")) < 0 {
+		return -1
+	}
+	return candidates(func(a, b string) bool { return normalizeWhitespace(a) == normalizeWhitespace(b) })
+}
+
+// findAnchor returns the first line at or after from that matches anchor, ignoring whitespace
+func findAnchor(lines []string, anchor string, from int) int {
+	anchor = normalizeWhitespace(anchor)
+	for i := from; i < len(lines); i++ {
+		if normalizeWhitespace(lines[i]) == anchor {
+			return i
+		}
+	}
+	return -1
+}
+
+// linesMatch compares two line slices of equal length
+func linesMatch(a, b []string, equal func(a, b string) bool) bool {
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLines splits content into lines and reports whether it ended with a newline
+func splitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), trailingNewline
+}
+
+// joinLines is the inverse of splitLines
+func joinLines(lines []string, trailingNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if trailingNewline {
+		content += "\n"
+	}
+	return content
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
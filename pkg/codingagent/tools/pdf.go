@@ -0,0 +1,1074 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Limits on what a PDF read returns
+const (
+	maxPDFPages       = 20               // Pages returned by one read
+	maxPDFBytes       = 64 * 1024 * 1024 // Largest PDF that is parsed
+	maxPDFStreamBytes = 32 * 1024 * 1024 // Largest decompressed stream
+	maxPDFNesting     = 64               // Deepest nesting of arrays and dictionaries
+)
+
+// readPDFFile returns the text of a range of pages of a PDF file
+func readPDFFile(absPath, displayPath, pages string) (agent.AgentToolResult, error) {
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+			IsError: true,
+		}, nil
+	}
+	if len(data) > maxPDFBytes {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %s is too large to read (%d bytes, maximum %d)", displayPath, len(data), maxPDFBytes))},
+			IsError: true,
+		}, nil
+	}
+
+	doc, err := parsePDF(data)
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: failed to read PDF %s: %v", displayPath, err))},
+			IsError: true,
+		}, nil
+	}
+	pageList := doc.pages()
+	if len(pageList) == 0 {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: no pages found in %s", displayPath))},
+			IsError: true,
+		}, nil
+	}
+
+	first, last, err := parsePageRange(pages, len(pageList))
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+			IsError: true,
+		}, nil
+	}
+
+	var b strings.Builder
+	truncated := false
+	shown := first - 1
+	for n := first; n <= last; n++ {
+		text := doc.pageText(pageList[n-1])
+		if text == "" {
+			text = "(no extractable text)"
+		}
+		entry := fmt.Sprintf("--- Page %d ---\n%s\n\n", n, text)
+		if n > first && b.Len()+len(entry) > maxReadBytes {
+			truncated = true
+			break
+		}
+		b.WriteString(entry)
+		shown = n
+	}
+
+	content := strings.TrimSuffix(b.String(), "\n\n")
+	if shown < len(pageList) && (truncated || pages == "") {
+		next := shown + 1
+		content += fmt.Sprintf("\n\n... (showing pages %d-%d of %d; use pages=\"%d-%d\" to read more)",
+			first, shown, len(pageList), next, min(next+maxPDFPages-1, len(pageList)))
+		truncated = true
+	}
+
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent(content)},
+		Details: map[string]any{
+			"path":       displayPath,
+			"size":       len(data),
+			"pages":      len(pageList),
+			"start_page": first,
+			"end_page":   shown,
+			"truncated":  truncated,
+		},
+		IsError: false,
+	}, nil
+}
+
+// parsePageRange parses "3" or "1-5" into one-based first and last pages.
+// An empty range selects the first maxPDFPages pages.
+func parsePageRange(spec string, count int) (int, int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return 1, min(count, maxPDFPages), nil
+	}
+
+	from, to, isRange := strings.Cut(spec, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid page range %q, expected e.g. \"3\" or \"1-5\"", spec)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return 0, 0, fmt.Errorf("invalid page range %q, expected e.g. \"3\" or \"1-5\"", spec)
+		}
+	}
+
+	switch {
+	case first < 1 || last < first:
+		return 0, 0, fmt.Errorf("invalid page range %q", spec)
+	case first > count:
+		return 0, 0, fmt.Errorf("page %d is beyond the end of the document (%d pages)", first, count)
+	case last-first+1 > maxPDFPages:
+		return 0, 0, fmt.Errorf("at most %d pages can be read at once", maxPDFPages)
+	}
+	return first, min(last, count), nil
+}
+
+// PDF objects are represented by these types, float64 for numbers and bool
+// for booleans. Null is nil.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ Num, Gen int }
+	pdfStream  struct {
+		Dict pdfDict
+		Data []byte
+	}
+)
+
+// pdfDocument holds the objects of a PDF file, enough of it to extract page text
+type pdfDocument struct {
+	objects map[int]any
+	trailer pdfDict
+	fonts   map[pdfRef]*pdfFont
+}
+
+var (
+	// objectHeader finds "N G obj" at the start of indirect objects
+	objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+	// trailerHeader finds trailer dictionaries
+	trailerHeader = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// parsePDF reads every object in a PDF file. The file is scanned for objects
+// instead of trusting its cross-reference table, which tolerates damaged files.
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]any), trailer: pdfDict{}, fonts: make(map[pdfRef]*pdfFont)}
+	pos := 0
+	for {
+		loc := objectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		lex := &pdfLexer{data: data, pos: pos + loc[1]}
+		pos += loc[1]
+
+		obj, err := lex.object()
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok {
+			if stream, end, ok := lex.stream(dict); ok {
+				obj = stream
+				lex.pos = end
+			}
+		}
+		doc.objects[num] = obj
+		pos = lex.pos
+	}
+
+	// Trailer dictionaries, and cross-reference streams that replace them
+	for _, idx := range trailerHeader.FindAllIndex(data, -1) {
+		lex := &pdfLexer{data: data, pos: idx[0] + len("trailer")}
+		if obj, err := lex.object(); err == nil {
+			dict, _ := obj.(pdfDict)
+			for key, value := range dict {
+				doc.trailer[key] = value
+			}
+		}
+	}
+	for _, obj := range doc.objects {
+		if stream, ok := obj.(pdfStream); ok && stream.Dict["Type"] == pdfName("XRef") {
+			for _, key := range []pdfName{"Root", "Encrypt"} {
+				if value, ok := stream.Dict[key]; ok {
+					doc.trailer[key] = value
+				}
+			}
+		}
+	}
+	if _, ok := doc.trailer["Encrypt"]; ok {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+
+	doc.loadObjectStreams()
+	if len(doc.objects) == 0 {
+		return nil, errors.New("no objects found")
+	}
+	return doc, nil
+}
+
+// loadObjectStreams adds the objects stored compressed in object streams
+func (d *pdfDocument) loadObjectStreams() {
+	for _, obj := range d.objects {
+		stream, ok := obj.(pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		count, _ := d.resolve(stream.Dict["N"]).(float64)
+		first, _ := d.resolve(stream.Dict["First"]).(float64)
+
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(count); i++ {
+			num, err1 := header.next()
+			offset, err2 := header.next()
+			n, ok1 := num.(float64)
+			o, ok2 := offset.(float64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if _, exists := d.objects[int(n)]; exists {
+				continue
+			}
+			// Written so that NaN offsets fail too
+			if !(first >= 0 && o >= 0 && first+o < float64(len(data))) {
+				continue
+			}
+			// Offsets are relative to First and must land inside the stream
+			lex := &pdfLexer{data: data, pos: int(first + o)}
+			if value, err := lex.object(); err == nil {
+				d.objects[int(n)] = value
+			}
+		}
+	}
+}
+
+// resolve follows references to the object they point to
+func (d *pdfDocument) resolve(obj any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.Num]
+	}
+	return nil
+}
+
+// dict resolves obj and returns it if it is a dictionary or a stream's dictionary
+func (d *pdfDocument) dict(obj any) pdfDict {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.Dict
+	}
+	return nil
+}
+
+// pdfPage is a page dictionary with its inherited resources
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages in document order
+func (d *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[any]bool)
+
+	var walk func(node any, resources pdfDict)
+	walk = func(node any, resources pdfDict) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil {
+			return
+		}
+		if r := d.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+		if kids, ok := d.resolve(dict["Kids"]).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		if dict["Type"] == pdfName("Page") || dict["Contents"] != nil {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+		}
+	}
+
+	if root := d.dict(d.trailer["Root"]); root != nil {
+		walk(root["Pages"], nil)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	// Without a usable page tree, take the page objects in object order
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := d.objects[num].(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+	return pages
+}
+
+// pageText extracts the text of a page
+func (d *pdfDocument) pageText(page pdfPage) string {
+	var content []byte
+	contents := d.resolve(page.dict["Contents"])
+	streams, ok := contents.(pdfArray)
+	if !ok {
+		streams = pdfArray{contents}
+	}
+	for _, s := range streams {
+		if stream, ok := d.resolve(s).(pdfStream); ok {
+			if data, err := d.decodeStream(stream); err == nil {
+				content = append(content, data...)
+				content = append(content, '\n')
+			}
+		}
+	}
+
+	w := &pdfTextWriter{}
+	d.extractText(content, page.resources, w, 0)
+	return w.String()
+}
+
+// decodeStream applies a stream's filters
+func (d *pdfDocument) decodeStream(stream pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(stream.Dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case pdfArray:
+		filters = f
+	}
+
+	data := stream.Data
+	for _, f := range filters {
+		var err error
+		switch d.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data)
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data, err = decodeASCIIHex(data)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data, err = decodeASCII85(data)
+		default:
+			err = fmt.Errorf("unsupported filter %v", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping what was read from truncated streams.
+// Streams that decompress to more than maxPDFStreamBytes are rejected.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamBytes+1))
+	if len(out) > maxPDFStreamBytes {
+		return nil, fmt.Errorf("stream decompresses to more than %d bytes", maxPDFStreamBytes)
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeASCIIHex decodes hex digits, ignoring whitespace, up to the > marker
+func decodeASCIIHex(data []byte) ([]byte, error) {
+	if i := bytes.IndexByte(data, '>'); i >= 0 {
+		data = data[:i]
+	}
+	digits := bytes.Map(func(r rune) rune {
+		if isPDFSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, data)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, err := hex.Decode(out, digits)
+	return out, err
+}
+
+// decodeASCII85 decodes base-85 data between the optional <~ and ~> markers
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	// "z" stands for four zero bytes
+	out := make([]byte, len(data)*4+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// pdfFont maps character codes to text using a font's ToUnicode CMap
+type pdfFont struct {
+	codeLen   int               // Bytes per character code
+	toUnicode map[string]string // Character codes to text; nil if the font has no CMap
+	composite bool              // Type0 fonts have no usable fallback encoding
+}
+
+// font loads the font named in a content stream's resources
+func (d *pdfDocument) font(resources pdfDict, name pdfName) *pdfFont {
+	fonts := d.dict(resources["Font"])
+	ref, isRef := fonts[name].(pdfRef)
+	if isRef {
+		if font, ok := d.fonts[ref]; ok {
+			return font
+		}
+	}
+
+	font := &pdfFont{codeLen: 1}
+	dict := d.dict(fonts[name])
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+		font.composite = true
+	}
+	if stream, ok := d.resolve(dict["ToUnicode"]).(pdfStream); ok {
+		if data, err := d.decodeStream(stream); err == nil {
+			font.parseCMap(data)
+		}
+	}
+
+	if isRef {
+		d.fonts[ref] = font
+	}
+	return font
+}
+
+// parseCMap reads the codespace, bfchar and bfrange sections of a ToUnicode CMap
+func (f *pdfFont) parseCMap(data []byte) {
+	f.toUnicode = make(map[string]string)
+	lex := &pdfLexer{data: data}
+	var operands []any
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			return
+		}
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					f.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					f.toUnicode[string(src)] = decodeUTF16BE(dst)
+				}
+			}
+		case "endbfrange":
+			f.parseBFRange(operands)
+		case "[":
+			// Arrays are only used by bfrange; collect them as one operand
+			var array pdfArray
+			for {
+				item, err := lex.next()
+				if err != nil || item == pdfKeyword("]") {
+					break
+				}
+				array = append(array, item)
+			}
+			operands = append(operands, array)
+			continue
+		}
+		operands = operands[:0]
+	}
+}
+
+// parseBFRange adds mappings for "<lo> <hi> <dst>" and "<lo> <hi> [<dst>...]" entries
+func (f *pdfFont) parseBFRange(operands []any) {
+	for i := 0; i+2 < len(operands); i += 3 {
+		lo, ok1 := operands[i].(pdfString)
+		hi, ok2 := operands[i+1].(pdfString)
+		if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+			continue
+		}
+		start, end := bytesToInt(lo), bytesToInt(hi)
+		if end < start || end-start > 0xFFFF {
+			continue
+		}
+
+		for code := start; code <= end; code++ {
+			src := string(intToBytes(code, len(lo)))
+			switch dst := operands[i+2].(type) {
+			case pdfString:
+				if len(dst) == 0 {
+					continue
+				}
+				// The last byte of the destination counts up with the code
+				next := append([]byte(nil), dst...)
+				next[len(next)-1] += byte(code - start)
+				f.toUnicode[src] = decodeUTF16BE(next)
+			case pdfArray:
+				if code-start >= len(dst) {
+					continue
+				}
+				if s, ok := dst[code-start].(pdfString); ok {
+					f.toUnicode[src] = decodeUTF16BE(s)
+				}
+			}
+		}
+	}
+}
+
+// decode converts a string shown with the font to text
+func (f *pdfFont) decode(s pdfString) string {
+	if f == nil {
+		return latin1(s)
+	}
+	if f.toUnicode == nil {
+		if f.composite {
+			return ""
+		}
+		return latin1(s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i += f.codeLen {
+		end := min(i+f.codeLen, len(s))
+		if text, ok := f.toUnicode[string(s[i:end])]; ok {
+			b.WriteString(text)
+		} else if f.codeLen == 1 {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWriter collects text, inserting spaces and line breaks
+type pdfTextWriter struct {
+	b strings.Builder
+}
+
+// write appends text
+func (w *pdfTextWriter) write(text string) {
+	w.b.WriteString(text)
+}
+
+// space separates words, unless there already is a separator
+func (w *pdfTextWriter) space() {
+	s := w.b.String()
+	if s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		w.b.WriteByte(' ')
+	}
+}
+
+// newline ends the current line, unless it is empty
+func (w *pdfTextWriter) newline() {
+	s := w.b.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		w.b.WriteByte('\n')
+	}
+}
+
+// String returns the text with trailing spaces and extra blank lines removed
+func (w *pdfTextWriter) String() string {
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// extractText runs the text operators of a content stream. Form XObjects are
+// followed so text drawn through them is included.
+func (d *pdfDocument) extractText(content []byte, resources pdfDict, w *pdfTextWriter, depth int) {
+	lex := &pdfLexer{data: content}
+	var font *pdfFont
+	var operands []any
+	lineY, hasY := 0.0, false
+
+	for {
+		obj, err := lex.object()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = d.font(resources, name)
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				if s, ok := operands[0].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "'", "\"":
+			w.newline()
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if array, ok := operands[0].(pdfArray); ok {
+					for _, item := range array {
+						switch v := item.(type) {
+						case pdfString:
+							w.write(font.decode(v))
+						case float64:
+							// Large negative adjustments move the next glyph right, as a space would
+							if v < -200 {
+								w.space()
+							}
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, _ := operands[0].(float64)
+				ty, _ := operands[1].(float64)
+				if ty != 0 {
+					w.newline()
+					lineY += ty
+				} else if tx != 0 {
+					w.space()
+				}
+			}
+		case "T*":
+			w.newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[5].(float64)
+				if hasY && y != lineY {
+					w.newline()
+				} else {
+					w.space()
+				}
+				lineY, hasY = y, true
+			}
+		case "ET":
+			w.space()
+		case "Do":
+			if len(operands) >= 1 && depth < 8 {
+				if name, ok := operands[0].(pdfName); ok {
+					d.extractXObject(resources, name, w, depth)
+				}
+			}
+		case "ID":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// extractXObject extracts the text of a Form XObject
+func (d *pdfDocument) extractXObject(resources pdfDict, name pdfName, w *pdfTextWriter, depth int) {
+	xobjects := d.dict(resources["XObject"])
+	stream, ok := d.resolve(xobjects[name]).(pdfStream)
+	if !ok || stream.Dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	if r := d.dict(stream.Dict["Resources"]); r != nil {
+		resources = r
+	}
+	w.newline()
+	d.extractText(data, resources, w, depth+1)
+	w.newline()
+}
+
+// pdfLexer reads tokens and objects from PDF data
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // Nesting of the arrays and dictionaries being read
+}
+
+// isPDFSpace reports whether c is PDF whitespace
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c ends a name, number or keyword
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// next reads a number, name, string or keyword. Delimiters such as "[" and
+// "<<" are returned as keywords.
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return pdfKeyword("<<"), nil
+	case c == '>' && l.peek(1) == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		// Invalid digits are ignored rather than failing the whole stream
+		s, _ := decodeASCIIHex(l.data[l.pos+1 : l.pos+end])
+		l.pos += end + 1
+		return pdfString(s), nil
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular(true)), nil
+	case strings.IndexByte("[]{}", c) >= 0:
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == ')' || c == '>':
+		l.pos++
+		return l.next()
+	}
+
+	word := l.regular(false)
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// peek returns the byte at an offset from the current position, or 0
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+// regular reads a run of regular characters, decoding #xx escapes in names
+func (l *pdfLexer) regular(name bool) string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if name && strings.Contains(word, "#") {
+		var b strings.Builder
+		for i := 0; i < len(word); i++ {
+			if word[i] == '#' && i+2 < len(word) {
+				if v, err := strconv.ParseUint(word[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(word[i])
+		}
+		word = b.String()
+	}
+	if word == "" && l.pos < len(l.data) {
+		// Skip a stray character so lexing always moves forward
+		l.pos++
+	}
+	return word
+}
+
+// literalString reads a (string) with balanced parentheses and escapes
+func (l *pdfLexer) literalString() pdfString {
+	var s []byte
+	depth := 0
+	l.pos++ // (
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return s
+			}
+			depth--
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.peek(0) >= '0' && l.peek(0) <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+// object reads a complete object: arrays and dictionaries are read whole, and
+// "N G R" becomes a reference
+func (l *pdfLexer) object() (any, error) {
+	tok, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok == pdfKeyword("[") || tok == pdfKeyword("<<") {
+		if l.depth >= maxPDFNesting {
+			return nil, errors.New("objects nested too deeply")
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+	}
+
+	switch tok {
+	case pdfKeyword("["):
+		var array pdfArray
+		for {
+			l.skipSpace()
+			if l.peek(0) == ']' {
+				l.pos++
+				return array, nil
+			}
+			item, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+	case pdfKeyword("<<"):
+		dict := pdfDict{}
+		for {
+			key, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			if key == pdfKeyword(">>") {
+				return dict, nil
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				continue
+			}
+			value, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = value
+		}
+	}
+
+	// A reference is two integers followed by R
+	if num, ok := tok.(float64); ok {
+		save := l.pos
+		gen, err1 := l.next()
+		r, err2 := l.next()
+		if g, ok := gen.(float64); ok && err1 == nil && err2 == nil && r == pdfKeyword("R") {
+			return pdfRef{Num: int(num), Gen: int(g)}, nil
+		}
+		l.pos = save
+	}
+	return tok, nil
+}
+
+// stream reads the data following a stream dictionary, returning the stream
+// and the position after "endstream"
+func (l *pdfLexer) stream(dict pdfDict) (pdfStream, int, bool) {
+	save := l.pos
+	if tok, err := l.next(); err != nil || tok != pdfKeyword("stream") {
+		l.pos = save
+		return pdfStream{}, 0, false
+	}
+
+	start := l.pos
+	if l.peek(0) == '\r' {
+		start++
+	}
+	if start < len(l.data) && l.data[start] == '\n' {
+		start++
+	}
+
+	// Trust a direct Length when endstream follows it; otherwise search
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(l.data)-start) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], " \t\r\n")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			after := end + bytes.Index(l.data[end:], []byte("endstream")) + len("endstream")
+			return pdfStream{Dict: dict, Data: l.data[start:end]}, after, true
+		}
+	}
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		return pdfStream{Dict: dict, Data: l.data[start:]}, len(l.data), true
+	}
+	data := bytes.TrimRight(l.data[start:start+end], "\r\n")
+	return pdfStream{Dict: dict, Data: data}, start + end + len("endstream"), true
+}
+
+// skipInlineImage skips the binary data of an inline image up to EI
+func (l *pdfLexer) skipInlineImage() {
+	for i := l.pos; i+2 < len(l.data); i++ {
+		if isPDFSpace(l.data[i]) && l.data[i+1] == 'E' && l.data[i+2] == 'I' && (i+3 == len(l.data) || isPDFDelimiter(l.data[i+3])) {
+			l.pos = i + 3
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// decodeUTF16BE decodes the UTF-16BE text of a CMap destination
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	if len(b)%2 == 1 {
+		units = append(units, uint16(b[len(b)-1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 converts single-byte text to UTF-8
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// bytesToInt reads a big-endian character code
+func bytesToInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// intToBytes writes a big-endian character code of size bytes
+func intToBytes(n, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
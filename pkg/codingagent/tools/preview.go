@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
+)
+
+// PreviewDiff returns the unified diff a write, edit, multi_edit or
+// apply_patch call would make, without changing any file. ok is false for
+// other tools and for calls that would fail.
+func PreviewDiff(workingDir, toolName string, params map[string]any) (string, bool) {
+	filePath, _ := params["file_path"].(string)
+
+	switch toolName {
+	case "write":
+		content, ok := params["content"].(string)
+		if !ok || filePath == "" {
+			return "", false
+		}
+		oldContent := ""
+		if data, err := os.ReadFile(resolvePath(workingDir, filePath)); err == nil {
+			oldContent = string(data)
+		}
+		return diff.Unified(oldContent, content, filePath), true
+
+	case "edit":
+		oldString, ok1 := params["old_string"].(string)
+		newString, ok2 := params["new_string"].(string)
+		if !ok1 || !ok2 || filePath == "" {
+			return "", false
+		}
+		replaceAll, _ := params["replace_all"].(bool)
+		data, err := os.ReadFile(resolvePath(workingDir, filePath))
+		if err != nil {
+			return "", false
+		}
+		newContent, _, err := performEdit(string(data), oldString, newString, replaceAll)
+		if err != nil {
+			return "", false
+		}
+		return diff.Unified(string(data), newContent, filePath), true
+
+	case "multi_edit":
+		edits, err := parseFileEdits(params["edits"])
+		if err != nil || filePath == "" {
+			return "", false
+		}
+		data, err := os.ReadFile(resolvePath(workingDir, filePath))
+		if err != nil {
+			return "", false
+		}
+		newContent := string(data)
+		for _, edit := range edits {
+			if newContent, _, err = applyFileEdit(newContent, edit); err != nil {
+				return "", false
+			}
+		}
+		return diff.Unified(string(data), newContent, filePath), true
+
+	case "apply_patch":
+		text, _ := params["patch"].(string)
+		patches, err := parsePatch(text)
+		if err != nil {
+			return "", false
+		}
+		plan := &patchPlan{
+			workingDir: workingDir,
+			original:   make(map[string]fileContent),
+			staged:     make(map[string]fileContent),
+		}
+		var sb strings.Builder
+		for _, p := range patches {
+			// Without a permission manager in the context nothing is asked
+			if err := plan.apply(context.Background(), p); err != nil {
+				return "", false
+			}
+		}
+		for _, result := range plan.results {
+			sb.WriteString(result.Diff)
+		}
+		return sb.String(), true
+	}
+	return "", false
+}
@@ -1,14 +1,27 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+)
+
+// Limits on what a single read returns
+const (
+	defaultReadLimit = 2000       // Lines returned when no limit is given
+	maxReadBytes     = 256 * 1024 // Bytes of output, whatever the limit
+	maxLineBytes     = 2000       // Bytes kept of each line
+	binarySniffSize  = 8000       // Bytes checked for NUL to detect binary files
 )
 
 // ReadToolParams represents parameters for the read tool
@@ -16,13 +29,16 @@ type ReadToolParams struct {
 	FilePath string `json:"file_path"`
 	Offset   int    `json:"offset,omitempty"`
 	Limit    int    `json:"limit,omitempty"`
+	Pages    string `json:"pages,omitempty"`
 }
 
 // CreateReadTool creates the read file tool
 func CreateReadTool(workingDir string) agent.AgentTool {
 	tool := ai.NewTool(
 		"read",
-		"Read a file from the filesystem. Returns file contents. Supports images.",
+		"Read a file from the filesystem. Text is returned with line numbers (cat -n style), which are not part of the file. "+
+			"Long files are cut at 2000 lines; use offset and limit to read the rest. "+
+			"Supports images, PDFs (text of up to 20 pages at a time) and Jupyter notebooks (cells with their outputs).",
 		map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -36,7 +52,11 @@ func CreateReadTool(workingDir string) agent.AgentTool {
 				},
 				"limit": map[string]any{
 					"type":        "number",
-					"description": "Optional: Number of lines to read",
+					"description": "Optional: Number of lines to read (default: 2000)",
+				},
+				"pages": map[string]any{
+					"type":        "string",
+					"description": "Optional: Page range for PDF files, e.g. \"3\" or \"1-5\" (default: the first 20 pages)",
 				},
 			},
 			"required": []string{"file_path"},
@@ -107,6 +127,15 @@ func CreateReadTool(workingDir string) agent.AgentTool {
 			return readImageFile(absPath, filePath)
 		}
 
+		// PDFs and notebooks are rendered as text
+		switch strings.ToLower(filepath.Ext(absPath)) {
+		case ".pdf":
+			pages, _ := params["pages"].(string)
+			return readPDFFile(absPath, filePath, pages)
+		case ".ipynb":
+			return readNotebookFile(absPath, filePath)
+		}
+
 		// Read text file
 		return readTextFile(absPath, filePath, offset, limit)
 	}
@@ -122,22 +151,15 @@ func resolvePath(workingDir, path string) string {
 	return filepath.Join(workingDir, path)
 }
 
-// isImageFile checks if a file is an image based on extension
+// isImageFile checks if a file is an image the model can see
 func isImageFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	imageExts := []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp", ".svg"}
-
-	for _, imgExt := range imageExts {
-		if ext == imgExt {
-			return true
-		}
-	}
-	return false
+	return images.MediaType(path) != ""
 }
 
-// readImageFile reads an image file and returns it as base64
+// readImageFile reads an image file and returns it as base64, scaled down to
+// the provider limits
 func readImageFile(absPath, displayPath string) (agent.AgentToolResult, error) {
-	data, err := os.ReadFile(absPath)
+	info, err := os.Stat(absPath)
 	if err != nil {
 		return agent.AgentToolResult{
 			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error reading image: %v", err))},
@@ -145,83 +167,207 @@ func readImageFile(absPath, displayPath string) (agent.AgentToolResult, error) {
 		}, nil
 	}
 
-	// Determine media type
-	ext := strings.ToLower(filepath.Ext(absPath))
-	mediaType := "image/png"
-	switch ext {
-	case ".jpg", ".jpeg":
-		mediaType = "image/jpeg"
-	case ".gif":
-		mediaType = "image/gif"
-	case ".webp":
-		mediaType = "image/webp"
-	case ".svg":
-		mediaType = "image/svg+xml"
+	image, err := images.Load(absPath)
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error reading image: %v", err))},
+			IsError: true,
+		}, nil
 	}
 
 	// Create image content
 	content := []ai.Content{
-		ai.NewTextContent(fmt.Sprintf("Image: %s (%d bytes)", displayPath, len(data))),
-		ai.NewImageContentFromBase64(string(data), mediaType),
+		ai.NewTextContent(fmt.Sprintf("Image: %s (%d bytes)", displayPath, info.Size())),
+		image,
 	}
 
 	return agent.AgentToolResult{
 		Content: content,
 		Details: map[string]any{
 			"path":      displayPath,
-			"size":      len(data),
-			"mediaType": mediaType,
+			"size":      info.Size(),
+			"mediaType": image.Source.MediaType,
 		},
 		IsError: false,
 	}, nil
 }
 
-// readTextFile reads a text file with optional offset and limit
+// readTextFile reads a text file with optional offset and limit. Lines are
+// numbered, and output is capped at defaultReadLimit lines and maxReadBytes
+// bytes, so huge files are never loaded whole.
 func readTextFile(absPath, displayPath string, offset, limit int) (agent.AgentToolResult, error) {
-	data, err := os.ReadFile(absPath)
+	file, err := os.Open(absPath)
 	if err != nil {
 		return agent.AgentToolResult{
 			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
 			IsError: true,
 		}, nil
 	}
+	defer file.Close()
 
-	content := string(data)
-	lines := strings.Split(content, "\n")
+	info, err := file.Stat()
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+			IsError: true,
+		}, nil
+	}
+
+	r := bufio.NewReaderSize(file, 64*1024)
+	if head, _ := r.Peek(binarySniffSize); bytes.IndexByte(head, 0) >= 0 {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %s appears to be a binary file (%d bytes) and cannot be shown as text", displayPath, info.Size()))},
+			IsError: true,
+		}, nil
+	}
+
+	// Without a limit, stop at the default so the model asks for more explicitly
+	capped := false
+	defaultLimit := limit <= 0
+	if defaultLimit {
+		limit = defaultReadLimit
+	}
 
-	// Apply offset and limit
-	if offset > 0 || limit > 0 {
-		start := offset
-		if start >= len(lines) {
+	var b strings.Builder
+	lineNo, shown := 0, 0
+	for {
+		line, cut, err := readLine(r, maxLineBytes)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			return agent.AgentToolResult{
-				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: Offset %d exceeds file length %d", offset, len(lines)))},
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
 				IsError: true,
 			}, nil
 		}
 
-		end := len(lines)
-		if limit > 0 && start+limit < end {
-			end = start + limit
+		lineNo++
+		if lineNo <= offset {
+			continue
+		}
+
+		entry := fmt.Sprintf("%6d\t%s", lineNo, line)
+		if cut {
+			entry += "... (line truncated)"
+		}
+		if shown > 0 && b.Len()+len(entry)+1 > maxReadBytes {
+			capped = true
+			break
+		}
+		b.WriteString(entry)
+		b.WriteString("\n")
+		shown++
+
+		if shown == limit {
+			break
 		}
+	}
+
+	// Count the rest of the file without keeping it
+	rest, err := countLines(r)
+	if err != nil {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %v", err))},
+			IsError: true,
+		}, nil
+	}
+	total := lineNo + rest
+	if defaultLimit && shown == limit && rest > 0 {
+		capped = true
+	}
 
-		lines = lines[start:end]
-		content = strings.Join(lines, "\n")
+	if offset > 0 && offset >= total {
+		return agent.AgentToolResult{
+			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: Offset %d exceeds file length %d", offset, total))},
+			IsError: true,
+		}, nil
 	}
 
-	// Check file size
-	maxSize := 1024 * 1024 // 1MB
-	if len(content) > maxSize {
-		content = content[:maxSize] + "\n\n... (content truncated, file too large)"
+	content := strings.TrimSuffix(b.String(), "\n")
+	if total == 0 {
+		content = "(empty file)"
+	}
+	endLine := offset + shown
+	if capped {
+		content += fmt.Sprintf("\n\n... (showing lines %d-%d of %d; use offset=%d to read more)", offset+1, endLine, total, endLine)
 	}
 
 	return agent.AgentToolResult{
 		Content: []ai.Content{ai.NewTextContent(content)},
 		Details: map[string]any{
-			"path":      displayPath,
-			"size":      len(data),
-			"lines":     len(lines),
-			"truncated": len(content) > maxSize,
+			"path":       displayPath,
+			"size":       int(info.Size()),
+			"lines":      total,
+			"start_line": offset + 1,
+			"end_line":   endLine,
+			"truncated":  capped,
 		},
 		IsError: false,
 	}, nil
 }
+
+// readLine reads a line without its newline, keeping at most max bytes of it.
+// It reports whether the line was cut, and returns io.EOF only when no line is left.
+func readLine(r *bufio.Reader, max int) (string, bool, error) {
+	var line []byte
+	cut := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+
+		if room := max - len(line); len(chunk) > room {
+			chunk = chunk[:room]
+			cut = true
+		}
+		line = append(line, chunk...)
+
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case nil:
+		case io.EOF:
+			if len(line) == 0 && !cut {
+				return "", false, io.EOF
+			}
+		default:
+			return "", false, err
+		}
+		break
+	}
+
+	if cut {
+		// Don't leave half a character behind
+		for i := 0; i < utf8.UTFMax-1 && len(line) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(line); r != utf8.RuneError || size != 1 {
+				break
+			}
+			line = line[:len(line)-1]
+		}
+	}
+	return string(line), cut, nil
+}
+
+// countLines counts the remaining lines in r, including a last line without a newline
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	count, last := 0, byte('\n')
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			count += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			if last != '\n' {
+				count++
+			}
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
+)
+
+// maxShellOutput is the maximum number of bytes kept from each output stream
+const maxShellOutput = 50000 // 50KB
+
+// ErrShellTimeout is returned when a command does not finish in time
+var ErrShellTimeout = errors.New("command timed out")
+
+// Shell is a long-lived bash process that runs commands one at a time, so the
+// working directory, environment variables and shell functions carry over
+// between commands. Each command is followed by a random marker on stdout and
+// stderr that tells where its output ends and what its exit code was.
+type Shell struct {
+	mu         sync.Mutex
+	workingDir string
+	sandbox    *sandbox.Sandbox
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout chan []byte
+	stderr chan []byte
+	exited chan struct{}
+	cwd    string
+}
+
+// ShellResult is the outcome of a shell command
+type ShellResult struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	Cwd       string // Working directory after the command
+	Truncated bool   // Output was longer than the limit
+	Restarted bool   // The shell exited or was killed and will start over on the next command
+}
+
+// NewShell creates a shell that starts in workingDir. The bash process is
+// started on the first command. sb is optional.
+func NewShell(workingDir string, sb *sandbox.Sandbox) *Shell {
+	return &Shell{
+		workingDir: workingDir,
+		sandbox:    sb,
+		cwd:        workingDir,
+	}
+}
+
+// Cwd returns the current working directory of the shell
+func (s *Shell) Cwd() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cwd
+}
+
+// Exports returns the variables exported in the shell as export -p prints
+// them, or nothing if the shell hasn't started
+func (s *Shell) Exports(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	started := s.cmd != nil
+	s.mu.Unlock()
+	if !started {
+		return "", nil
+	}
+
+	result, err := s.Run(ctx, "export -p", 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to read shell environment: %w", err)
+	}
+	return result.Stdout, nil
+}
+
+// Reset kills the shell, dropping its environment, and goes back to the initial working directory
+func (s *Shell) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	s.cwd = s.workingDir
+}
+
+// Close kills the shell
+func (s *Shell) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+}
+
+// Run runs command in the shell. When the command does not finish within
+// timeout or ctx is cancelled, the shell is killed and ErrShellTimeout or the
+// context error is returned with the output so far; the next command starts a
+// new shell in the last known working directory.
+func (s *Shell) Run(ctx context.Context, command string, timeout time.Duration) (ShellResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return ShellResult{Cwd: s.cwd}, err
+		}
+	}
+
+	marker, err := newMarker()
+	if err != nil {
+		return ShellResult{Cwd: s.cwd}, err
+	}
+
+	// Run the command through eval so syntax errors don't end the shell, and
+	// without stdin so it can't read the commands that follow
+	script := fmt.Sprintf("eval %s </dev/null\n__cc_status=$?\nprintf '%%s%%d %%s\\n' '%s' \"$__cc_status\" \"$PWD\"\nprintf '%%s\\n' '%s' >&2\n",
+		shellQuote(command), marker, marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		s.stop()
+		return ShellResult{Cwd: s.cwd, ExitCode: -1, Restarted: true}, fmt.Errorf("failed to write to shell: %w", err)
+	}
+
+	stdout := newMarkerCollector(marker)
+	stderr := newMarkerCollector(marker)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var runErr error
+	stdoutCh, stderrCh := s.stdout, s.stderr
+	for !stdout.done || !stderr.done {
+		select {
+		case chunk, ok := <-stdoutCh:
+			if !ok {
+				stdoutCh = nil
+				stdout.done = true
+				continue
+			}
+			stdout.write(chunk)
+		case chunk, ok := <-stderrCh:
+			if !ok {
+				stderrCh = nil
+				stderr.done = true
+				continue
+			}
+			stderr.write(chunk)
+		case <-timer.C:
+			runErr = ErrShellTimeout
+		case <-ctx.Done():
+			runErr = ctx.Err()
+		}
+		if runErr != nil {
+			break
+		}
+	}
+
+	result := ShellResult{
+		Stdout:    string(stdout.output),
+		Stderr:    string(stderr.output),
+		Truncated: stdout.truncated || stderr.truncated,
+		Cwd:       s.cwd,
+	}
+
+	if runErr != nil {
+		s.stop()
+		result.ExitCode = -1
+		result.Restarted = true
+		return result, runErr
+	}
+
+	// No trailer means the command ended the shell (exit, exec, set -e, ...)
+	if stdout.trailer == "" {
+		result.ExitCode = s.wait()
+		result.Restarted = true
+		s.stop()
+		return result, nil
+	}
+
+	status, cwd, _ := strings.Cut(stdout.trailer, " ")
+	result.ExitCode, _ = strconv.Atoi(status)
+	if cwd != "" {
+		s.cwd = cwd
+		result.Cwd = cwd
+	}
+	return result, nil
+}
+
+// start starts the bash process in the current working directory
+func (s *Shell) start() error {
+	var cmd *exec.Cmd
+	if s.sandbox != nil {
+		cmd = s.sandbox.Command(context.Background(), "bash", "--noprofile", "--norc")
+	} else {
+		cmd = exec.Command("bash", "--noprofile", "--norc")
+	}
+	cmd.Dir = s.cwd
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create shell stdin: %w", err)
+	}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create shell stdout: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create shell stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	var readers sync.WaitGroup
+	s.cmd = cmd
+	s.stdin = stdin
+	s.stdout = readChunks(stdoutPipe, &readers)
+	s.stderr = readChunks(stderrPipe, &readers)
+	s.exited = make(chan struct{})
+
+	// Reap the process once both pipes are read to the end
+	exited := s.exited
+	go func() {
+		defer close(exited)
+		readers.Wait()
+		_ = cmd.Wait()
+	}()
+
+	return nil
+}
+
+// wait waits for an exiting shell and returns its exit code
+func (s *Shell) wait() int {
+	select {
+	case <-s.exited:
+	case <-time.After(5 * time.Second):
+		return -1
+	}
+	return s.cmd.ProcessState.ExitCode()
+}
+
+// stop kills the shell and everything it started
+func (s *Shell) stop() {
+	if s.cmd == nil {
+		return
+	}
+
+	_ = s.stdin.Close()
+	killProcessGroup(s.cmd)
+
+	// Drain the pipes so the reaper can finish
+	go drain(s.stdout)
+	go drain(s.stderr)
+
+	s.cmd = nil
+	s.stdin = nil
+	s.stdout = nil
+	s.stderr = nil
+}
+
+// readChunks reads r in the background until EOF
+func readChunks(r io.Reader, readers *sync.WaitGroup) chan []byte {
+	ch := make(chan []byte, 16)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		defer close(ch)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				ch <- chunk
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// drain discards what is left in ch
+func drain(ch chan []byte) {
+	for range ch {
+	}
+}
+
+// markerCollector collects a command's output up to the marker that ends it
+type markerCollector struct {
+	marker    []byte
+	output    []byte // Output before the marker, up to maxShellOutput
+	window    []byte // Bytes that may still be part of the marker or its trailer
+	truncated bool
+	trailer   string // Text between the marker and the end of its line
+	done      bool
+}
+
+func newMarkerCollector(marker string) *markerCollector {
+	return &markerCollector{marker: []byte(marker)}
+}
+
+// write adds a chunk of output
+func (c *markerCollector) write(chunk []byte) {
+	if c.done {
+		return
+	}
+	c.window = append(c.window, chunk...)
+
+	if i := bytes.Index(c.window, c.marker); i >= 0 {
+		rest := c.window[i+len(c.marker):]
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return // Wait for the rest of the trailer
+		}
+		c.keep(c.window[:i])
+		c.trailer = string(rest[:end])
+		c.window = nil
+		c.done = true
+		return
+	}
+
+	// Keep just enough of the window to find a marker split across chunks
+	if n := len(c.window) - len(c.marker); n > 0 {
+		c.keep(c.window[:n])
+		c.window = append([]byte(nil), c.window[n:]...)
+	}
+}
+
+// keep appends to the output, dropping what doesn't fit
+func (c *markerCollector) keep(p []byte) {
+	room := maxShellOutput - len(c.output)
+	if len(p) > room {
+		p = p[:room]
+		c.truncated = true
+	}
+	c.output = append(c.output, p...)
+}
+
+// newMarker returns a random marker that won't appear in command output
+func newMarker() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate marker: %w", err)
+	}
+	return "__CC_DONE_" + hex.EncodeToString(b) + "__", nil
+}
+
+// shellQuote quotes s as a single bash word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
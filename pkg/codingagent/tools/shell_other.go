@@ -0,0 +1,15 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// setProcessGroup is a no-op: process groups are only used on Unix
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd; its children are left running
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
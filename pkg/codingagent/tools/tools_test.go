@@ -1,17 +1,31 @@
 package tools
 
 import (
+	"bytes"
+	"compress/zlib"
 	"context"
+	"encoding/ascii85"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/sandbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain lets the test binary act as the sandbox helper
+func TestMain(m *testing.M) {
+	sandbox.MaybeRunHelper(os.Args)
+	os.Exit(m.Run())
+}
+
 // Helper function to execute a tool
 func executeTool(t *testing.T, tool agent.AgentTool, params map[string]any) agent.AgentToolResult {
 	ctx := context.Background()
@@ -39,13 +53,15 @@ func TestReadTool_ReadTextFile(t *testing.T) {
 
 	textContent, ok := result.Content[0].(ai.TextContent)
 	require.True(t, ok)
-	assert.Equal(t, testContent, textContent.Text)
+	assert.Equal(t, "     1\tHello, World!\n     2\tLine 2\n     3\tLine 3", textContent.Text)
 
 	// Verify details
 	details, ok := result.Details.(map[string]any)
 	require.True(t, ok)
 	assert.Equal(t, "test.txt", details["path"])
 	assert.Equal(t, len(testContent), details["size"])
+	assert.Equal(t, 3, details["lines"])
+	assert.Equal(t, false, details["truncated"])
 }
 
 func TestReadTool_ReadWithOffsetAndLimit(t *testing.T) {
@@ -68,7 +84,276 @@ func TestReadTool_ReadWithOffsetAndLimit(t *testing.T) {
 
 	textContent, ok := result.Content[0].(ai.TextContent)
 	require.True(t, ok)
-	assert.Equal(t, "Line 2\nLine 3", textContent.Text)
+	assert.Equal(t, "     2\tLine 2\n     3\tLine 3", textContent.Text)
+}
+
+func TestReadTool_LargeFile(t *testing.T) {
+	tempDir := t.TempDir()
+	var b strings.Builder
+	for i := 1; i <= 2500; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	b.WriteString(strings.Repeat("x", 3000) + "é\n")
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "big.log"), []byte(b.String()), 0644))
+
+	tool := CreateReadTool(tempDir)
+
+	// Without a limit, reading stops at the default with a hint to continue
+	result := executeTool(t, tool, map[string]any{"file_path": "big.log"})
+	require.False(t, result.IsError)
+	text := result.Content[0].(ai.TextContent).Text
+	assert.True(t, strings.HasPrefix(text, "     1\tline 1\n"))
+	assert.Contains(t, text, "  2000\tline 2000\n")
+	assert.NotContains(t, text, "line 2001")
+	assert.True(t, strings.HasSuffix(text, "(showing lines 1-2000 of 2501; use offset=2000 to read more)"))
+	details := result.Details.(map[string]any)
+	assert.Equal(t, 2501, details["lines"])
+	assert.Equal(t, true, details["truncated"])
+
+	// Long lines are cut
+	result = executeTool(t, tool, map[string]any{"file_path": "big.log", "offset": float64(2500)})
+	require.False(t, result.IsError)
+	assert.Equal(t, "  2501\t"+strings.Repeat("x", maxLineBytes)+"... (line truncated)", result.Content[0].(ai.TextContent).Text)
+
+	result = executeTool(t, tool, map[string]any{"file_path": "big.log", "offset": float64(2501)})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].(ai.TextContent).Text, "exceeds file length 2501")
+
+	// Output is capped in bytes too
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "wide.txt"), []byte(strings.Repeat(strings.Repeat("y", 1000)+"\n", 1000)), 0644))
+	result = executeTool(t, tool, map[string]any{"file_path": "wide.txt", "limit": float64(1000)})
+	require.False(t, result.IsError)
+	text = result.Content[0].(ai.TextContent).Text
+	assert.Less(t, len(text), maxReadBytes+200)
+	assert.Contains(t, text, "of 1000; use offset=")
+}
+
+func TestReadTool_BinaryFile(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "app.bin"), []byte("ELF\x00\x01\x02"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "empty.txt"), nil, 0644))
+
+	tool := CreateReadTool(tempDir)
+
+	result := executeTool(t, tool, map[string]any{"file_path": "app.bin"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].(ai.TextContent).Text, "binary file")
+
+	result = executeTool(t, tool, map[string]any{"file_path": "empty.txt"})
+	assert.False(t, result.IsError)
+	assert.Equal(t, "(empty file)", result.Content[0].(ai.TextContent).Text)
+}
+
+// buildTestPDF creates a PDF with one page per content stream. Font F1 is a
+// simple font; F2 is a composite font whose codes map to text through a ToUnicode CMap.
+func buildTestPDF(t *testing.T, contents ...string) []byte {
+	t.Helper()
+	compress := func(s string) string {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		_, err := w.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return b.String()
+	}
+
+	cmap := "/CIDInit /ProcSet findresource begin\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		"1 beginbfchar\n<0001> <00E9>\nendbfchar\n1 beginbfrange\n<0010> <0012> <0041>\nendbfrange\nend\n"
+	kids := make([]string, len(contents))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 5 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(cmap), cmap),
+	}
+	for i, content := range contents {
+		data := compress(content)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents %d 0 R >>", len(objects)+2),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(data), data))
+		kids[i] = fmt.Sprintf("%d 0 R", len(objects)-1)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>", strings.Join(kids, " "), len(contents))
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\n%%%%EOF\n", len(objects)+1)
+	return []byte(b.String())
+}
+
+func TestReadTool_PDF(t *testing.T) {
+	tempDir := t.TempDir()
+	pdf := buildTestPDF(t,
+		"BT /F1 12 Tf 72 720 Td (Hello, \\(PDF\\) world) Tj 0 -14 Td [(Second) -250 (line)] TJ ET",
+		"BT /F2 12 Tf 1 0 0 1 72 720 Tm <001000110012> Tj 1 0 0 1 72 700 Tm <0001> Tj ET",
+		"q 10 0 0 10 0 0 cm BI /W 1 /H 1 /BPC 8 /CS /G ID \x00\xff EI Q BT /F1 12 Tf 72 720 Td (Third page) Tj ET",
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "doc.pdf"), pdf, 0644))
+
+	tool := CreateReadTool(tempDir)
+
+	result := executeTool(t, tool, map[string]any{"file_path": "doc.pdf"})
+	require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+	assert.Equal(t,
+		"--- Page 1 ---\nHello, (PDF) world\nSecond line\n\n--- Page 2 ---\nABC\né\n\n--- Page 3 ---\nThird page",
+		result.Content[0].(ai.TextContent).Text)
+	details := result.Details.(map[string]any)
+	assert.Equal(t, 3, details["pages"])
+
+	result = executeTool(t, tool, map[string]any{"file_path": "doc.pdf", "pages": "2-3"})
+	require.False(t, result.IsError)
+	assert.True(t, strings.HasPrefix(result.Content[0].(ai.TextContent).Text, "--- Page 2 ---\nABC"))
+
+	for _, pages := range []string{"4", "0-1", "3-1", "x", "1-21"} {
+		result = executeTool(t, tool, map[string]any{"file_path": "doc.pdf", "pages": pages})
+		assert.True(t, result.IsError, pages)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "fake.pdf"), []byte("not a pdf"), 0644))
+	result = executeTool(t, tool, map[string]any{"file_path": "fake.pdf"})
+	assert.True(t, result.IsError)
+}
+
+func TestReadTool_MalformedPDF(t *testing.T) {
+	tempDir := t.TempDir()
+	tool := CreateReadTool(tempDir)
+	page := func(contents string) string {
+		return "%PDF-1.4\n" +
+			"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+			"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>\nendobj\n" +
+			"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n" +
+			contents +
+			"5 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n" +
+			"trailer\n<< /Size 6 /Root 1 0 R >>\n%%EOF\n"
+	}
+	content := "BT /F1 12 Tf (Still here) Tj ET"
+	// Each "z" stands for four zero bytes, which lex as whitespace
+	encoded := make([]byte, ascii85.MaxEncodedLen(len(content)))
+	encoded = append([]byte("zzzz"), encoded[:ascii85.Encode(encoded, []byte(content))]...)
+
+	tests := []struct {
+		name string
+		pdf  string
+		want string
+	}{
+		{
+			name: "negative length",
+			pdf:  page("4 0 obj\n<< /Length -1000 >>\nstream\n" + content + "\nendstream\nendobj\n"),
+			want: "Still here",
+		},
+		{
+			name: "huge length",
+			pdf:  page("4 0 obj\n<< /Length 1e300 >>\nstream\n" + content + "\nendstream\nendobj\n"),
+			want: "Still here",
+		},
+		{
+			name: "negative object stream offsets",
+			pdf: page(fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content) +
+				"6 0 obj\n<< /Type /ObjStm /N 1 /First -40 /Length 8 >>\nstream\n7 0 (x)\nendstream\nendobj\n" +
+				"8 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 8 >>\nstream\n9 -9 ()\nendstream\nendobj\n"),
+			want: "Still here",
+		},
+		{
+			name: "NaN object stream offsets",
+			pdf: page(fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content) +
+				"6 0 obj\n<< /Type /ObjStm /N 1 /First NaN /Length 6 >>\nstream\n7 0 ()\nendstream\nendobj\n"),
+			want: "Still here",
+		},
+		{
+			name: "zero runs in ASCII85",
+			pdf:  page(fmt.Sprintf("4 0 obj\n<< /Filter /ASCII85Decode /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(encoded), encoded)),
+			want: "Still here",
+		},
+		{
+			name: "deeply nested arrays",
+			pdf: page(fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content) +
+				"6 0 obj\n" + strings.Repeat("[", 100000) + "\nendobj\n"),
+			want: "Still here",
+		},
+		{
+			name: "name at end of file",
+			pdf: page(fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)) +
+				"9 0 obj\n/",
+			want: "Still here",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(tempDir, "bad.pdf"), []byte(tt.pdf), 0644))
+			result := executeTool(t, tool, map[string]any{"file_path": "bad.pdf"})
+			require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+			assert.Contains(t, result.Content[0].(ai.TextContent).Text, tt.want)
+		})
+	}
+}
+
+func TestInflateLimit(t *testing.T) {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	_, err := w.Write(make([]byte, maxPDFStreamBytes+1))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = inflate(b.Bytes())
+	assert.ErrorContains(t, err, "decompresses to more than")
+
+	b.Reset()
+	w = zlib.NewWriter(&b)
+	_, err = w.Write([]byte("small"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	out, err := inflate(b.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(out))
+}
+
+func TestReadTool_Notebook(t *testing.T) {
+	tempDir := t.TempDir()
+	notebook := `{
+		"metadata": {"language_info": {"name": "python"}},
+		"nbformat": 4,
+		"cells": [
+			{"cell_type": "markdown", "source": ["# Analysis\n", "Some notes"]},
+			{"cell_type": "code", "execution_count": 1, "source": "print('hi')\n1 + 1", "outputs": [
+				{"output_type": "stream", "name": "stdout", "text": ["hi\n"]},
+				{"output_type": "execute_result", "data": {"text/plain": "2"}}
+			]},
+			{"cell_type": "code", "execution_count": 2, "source": "plot()", "outputs": [
+				{"output_type": "display_data", "data": {"image/png": "iVBORw0KGgo=\n", "text/plain": "<Figure>"}}
+			]},
+			{"cell_type": "code", "execution_count": 3, "source": "1/0", "outputs": [
+				{"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero",
+				 "traceback": ["\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"]}
+			]}
+		]
+	}`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "analysis.ipynb"), []byte(notebook), 0644))
+
+	tool := CreateReadTool(tempDir)
+	result := executeTool(t, tool, map[string]any{"file_path": "analysis.ipynb"})
+	require.False(t, result.IsError)
+	require.Len(t, result.Content, 3)
+
+	text := result.Content[0].(ai.TextContent).Text
+	assert.Contains(t, text, "Notebook: analysis.ipynb (4 cells, python)")
+	assert.Contains(t, text, "Cell 1 [markdown]:\n# Analysis\nSome notes\n")
+	assert.Contains(t, text, "Cell 2 [code, execution 1]:\nprint('hi')\n1 + 1\nOutput:\nhi\n2\n")
+	assert.Contains(t, text, "[image/png output of cell 3]")
+
+	image := result.Content[1].(ai.ImageContent)
+	assert.Equal(t, "image/png", image.Source.MediaType)
+	assert.Equal(t, "iVBORw0KGgo=", image.Source.Data)
+
+	assert.Equal(t, "Cell 4 [code, execution 3]:\n1/0\nOutput:\nZeroDivisionError: division by zero", result.Content[2].(ai.TextContent).Text)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "broken.ipynb"), []byte("{"), 0644))
+	result = executeTool(t, tool, map[string]any{"file_path": "broken.ipynb"})
+	assert.True(t, result.IsError)
 }
 
 func TestReadTool_ReadImageFile(t *testing.T) {
@@ -334,6 +619,387 @@ func TestEditTool_DiffGeneration(t *testing.T) {
 	assert.Contains(t, textContent.Text, "+++")
 	assert.Contains(t, textContent.Text, "-Line 2")
 	assert.Contains(t, textContent.Text, "+Modified Line 2")
+
+	details := result.Details.(map[string]any)
+	assert.Contains(t, details["diff"], "@@ -1,3 +1,3 @@\n Line 1\n-Line 2\n+Modified Line 2\n Line 3\n")
+}
+
+func TestPreviewDiff(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.txt")
+	require.NoError(t, os.WriteFile(testFile, []byte("one\ntwo\n"), 0644))
+
+	preview, ok := PreviewDiff(tempDir, "edit", map[string]any{"file_path": "test.txt", "old_string": "two", "new_string": "2"})
+	require.True(t, ok)
+	assert.Equal(t, "--- test.txt\n+++ test.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n", preview)
+
+	preview, ok = PreviewDiff(tempDir, "write", map[string]any{"file_path": "new.txt", "content": "hello\n"})
+	require.True(t, ok)
+	assert.Contains(t, preview, "@@ -0,0 +1 @@\n+hello\n")
+
+	preview, ok = PreviewDiff(tempDir, "apply_patch", map[string]any{"patch": "*** Begin Patch\n*** Update File: test.txt\n@@\n one\n-two\n+three\n*** End Patch"})
+	require.True(t, ok)
+	assert.Contains(t, preview, "-two\n+three\n")
+
+	_, ok = PreviewDiff(tempDir, "edit", map[string]any{"file_path": "test.txt", "old_string": "missing", "new_string": "x"})
+	assert.False(t, ok)
+	_, ok = PreviewDiff(tempDir, "bash", map[string]any{"command": "ls"})
+	assert.False(t, ok)
+
+	// Nothing was written
+	data, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(data))
+	assert.NoFileExists(t, filepath.Join(tempDir, "new.txt"))
+}
+
+func TestWriteAndEditTools_Checkpoint(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.txt")
+	require.NoError(t, os.WriteFile(testFile, []byte("original"), 0644))
+
+	store, err := checkpoint.Open(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, store.Begin("msg-1"))
+	ctx := checkpoint.WithStore(context.Background(), store)
+
+	_, err = CreateEditTool(tempDir).Execute(ctx, "call-1", map[string]any{
+		"file_path":  "test.txt",
+		"old_string": "original",
+		"new_string": "edited",
+	}, nil)
+	require.NoError(t, err)
+	_, err = CreateWriteTool(tempDir).Execute(ctx, "call-2", map[string]any{
+		"file_path": "new.txt",
+		"content":   "new file",
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = store.Restore("msg-1")
+	require.NoError(t, err)
+
+	restored, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(restored))
+	assert.NoFileExists(t, filepath.Join(tempDir, "new.txt"))
+}
+
+func TestMultiEditTool(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.go")
+	original := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n"
+	require.NoError(t, os.WriteFile(testFile, []byte(original), 0644))
+
+	tool := CreateMultiEditTool(tempDir)
+
+	t.Run("AppliesEditsInOrder", func(t *testing.T) {
+		result := executeTool(t, tool, map[string]any{
+			"file_path": "test.go",
+			"edits": []any{
+				map[string]any{"old_string": "func a()", "new_string": "func first()"},
+				map[string]any{"old_string": "func first() {\n\treturn 1", "new_string": "func first() {\n\treturn 2"},
+				map[string]any{"old_string": "return", "new_string": "return  ", "replace_all": true},
+			},
+		})
+		require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+
+		text := result.Content[0].(ai.TextContent).Text
+		assert.Contains(t, text, "Successfully applied 3 edit(s)")
+		assert.Contains(t, text, "-func a() {")
+		assert.Contains(t, text, "+func first() {")
+
+		modified, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		assert.Equal(t, "func first() {\n\treturn   2\n}\n\nfunc b() {\n\treturn   1\n}\n", string(modified))
+
+		details, ok := result.Details.(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, 3, details["edits"])
+		assert.Equal(t, 4, details["replacements"])
+	})
+
+	t.Run("FailureLeavesFileUntouched", func(t *testing.T) {
+		before, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+
+		for name, edits := range map[string][]any{
+			"NotFound": {
+				map[string]any{"old_string": "func b()", "new_string": "func second()"},
+				map[string]any{"old_string": "missing", "new_string": "x"},
+			},
+			"NotUnique": {
+				map[string]any{"old_string": "func b()", "new_string": "func second()"},
+				map[string]any{"old_string": "return", "new_string": "yield"},
+			},
+			"Identical": {
+				map[string]any{"old_string": "func b()", "new_string": "func b()"},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				result := executeTool(t, tool, map[string]any{"file_path": "test.go", "edits": edits})
+				assert.True(t, result.IsError)
+				assert.Contains(t, result.Content[0].(ai.TextContent).Text, "No changes were made")
+
+				after, err := os.ReadFile(testFile)
+				require.NoError(t, err)
+				assert.Equal(t, string(before), string(after))
+			})
+		}
+	})
+
+	t.Run("InvalidEdits", func(t *testing.T) {
+		for _, edits := range []any{nil, []any{}, []any{"x"}, []any{map[string]any{"old_string": "a"}}} {
+			result := executeTool(t, tool, map[string]any{"file_path": "test.go", "edits": edits})
+			assert.True(t, result.IsError)
+		}
+	})
+
+	t.Run("Checkpoint", func(t *testing.T) {
+		store, err := checkpoint.Open(t.TempDir())
+		require.NoError(t, err)
+		require.NoError(t, store.Begin("msg-1"))
+		ctx := checkpoint.WithStore(context.Background(), store)
+
+		before, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+
+		result, err := tool.Execute(ctx, "call-1", map[string]any{
+			"file_path": "test.go",
+			"edits":     []any{map[string]any{"old_string": "func b()", "new_string": "func second()"}},
+		}, nil)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+
+		_, err = store.Restore("msg-1")
+		require.NoError(t, err)
+		restored, err := os.ReadFile(testFile)
+		require.NoError(t, err)
+		assert.Equal(t, string(before), string(restored))
+	})
+}
+
+func TestFileStateTracker(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.txt")
+	require.NoError(t, os.WriteFile(testFile, []byte("original"), 0644))
+
+	tracker := NewFileStateTracker(tempDir)
+	tools := tracker.WrapAllTools([]agent.AgentTool{
+		CreateReadTool(tempDir),
+		CreateWriteTool(tempDir),
+		CreateEditTool(tempDir),
+	})
+	read, write, edit := tools[0], tools[1], tools[2]
+
+	editParams := func(oldString, newString string) map[string]any {
+		return map[string]any{"file_path": "test.txt", "old_string": oldString, "new_string": newString}
+	}
+
+	// Editing a file that was never read is refused
+	result := executeTool(t, edit, editParams("original", "edited"))
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].(ai.TextContent).Text, "has not been read yet")
+
+	// After reading, consecutive edits work
+	require.False(t, executeTool(t, read, map[string]any{"file_path": "test.txt"}).IsError)
+	require.False(t, executeTool(t, edit, editParams("original", "edited")).IsError)
+	require.False(t, executeTool(t, edit, editParams("edited", "edited twice")).IsError)
+
+	// A change made outside the agent makes the file stale
+	require.NoError(t, os.WriteFile(testFile, []byte("changed by the user"), 0644))
+	result = executeTool(t, write, map[string]any{"file_path": testFile, "content": "overwrite"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].(ai.TextContent).Text, "modified since it was last read")
+	data, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "changed by the user", string(data))
+
+	// Touching a file without changing it is fine
+	require.False(t, executeTool(t, read, map[string]any{"file_path": "test.txt"}).IsError)
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(testFile, future, future))
+	require.False(t, executeTool(t, edit, editParams("changed", "fixed")).IsError)
+
+	// Files the agent creates can be edited right away
+	require.False(t, executeTool(t, write, map[string]any{"file_path": "new.txt", "content": "new file"}).IsError)
+	result = executeTool(t, edit, map[string]any{"file_path": "new.txt", "old_string": "new", "new_string": "created"})
+	assert.False(t, result.IsError)
+
+	tracker.Reset()
+	assert.ErrorIs(t, tracker.Check("new.txt"), ErrFileNotRead)
+	assert.NoError(t, tracker.Check("missing.txt"))
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	setup := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, content := range files {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		}
+		return dir
+	}
+	readFile := func(t *testing.T, path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("Envelope", func(t *testing.T) {
+		dir := setup(t, map[string]string{
+			"main.go": "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+			"old.go":  "package main\n",
+			"util.go": "package main\n\nfunc helper() int {\n\treturn 1\n}\n",
+		})
+		patch := `*** Begin Patch
+*** Add File: pkg/new.go
++package pkg
++
++const Answer = 42
+*** Update File: main.go
+@@ func main() {
+-	println("hi")
++	println("hello")
+*** Delete File: old.go
+*** Update File: util.go
+*** Move to: helpers.go
+@@
+ func helper() int {
+-	return 1
++	return 2
+ }
+*** End Patch`
+
+		result := executeTool(t, CreateApplyPatchTool(dir), map[string]any{"patch": patch})
+		require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+
+		text := result.Content[0].(ai.TextContent).Text
+		assert.Contains(t, text, "A pkg/new.go (+3)")
+		assert.Contains(t, text, "M main.go (+1 -1)")
+		assert.Contains(t, text, "D old.go")
+		assert.Contains(t, text, "R util.go -> helpers.go (+1 -1)")
+
+		assert.Equal(t, "package pkg\n\nconst Answer = 42\n", readFile(t, filepath.Join(dir, "pkg", "new.go")))
+		assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n", readFile(t, filepath.Join(dir, "main.go")))
+		assert.NoFileExists(t, filepath.Join(dir, "old.go"))
+		assert.NoFileExists(t, filepath.Join(dir, "util.go"))
+		assert.Equal(t, "package main\n\nfunc helper() int {\n\treturn 2\n}\n", readFile(t, filepath.Join(dir, "helpers.go")))
+	})
+
+	t.Run("UnifiedDiff", func(t *testing.T) {
+		lines := make([]string, 20)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("line %d", i+1)
+		}
+		original := strings.Join(lines, "\n") + "\n"
+		dir := setup(t, map[string]string{"a.txt": original, "b.txt": "one\ntwo\n"})
+
+		// The second hunk has whitespace that doesn't match the file
+		patch := `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -2,3 +2,3 @@
+ line 2
+-line 3
++line three
+ line 4
+@@ -17,3 +17,4 @@
+ line  17
+ line 18
++line 18.5
+ line 19
+diff --git a/b.txt b/c.txt
+similarity index 50%
+rename from b.txt
+rename to c.txt
+--- a/b.txt
++++ b/c.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++2
+--- /dev/null
++++ b/d.txt
+@@ -0,0 +1,2 @@
++new
++file
+`
+		result := executeTool(t, CreateApplyPatchTool(dir), map[string]any{"patch": patch})
+		require.False(t, result.IsError, result.Content[0].(ai.TextContent).Text)
+
+		expected := strings.Replace(original, "line 3\n", "line three\n", 1)
+		expected = strings.Replace(expected, "line 18\n", "line 18\nline 18.5\n", 1)
+		assert.Equal(t, expected, readFile(t, filepath.Join(dir, "a.txt")))
+		assert.NoFileExists(t, filepath.Join(dir, "b.txt"))
+		assert.Equal(t, "one\n2\n", readFile(t, filepath.Join(dir, "c.txt")))
+		assert.Equal(t, "new\nfile\n", readFile(t, filepath.Join(dir, "d.txt")))
+	})
+
+	t.Run("FailureChangesNothing", func(t *testing.T) {
+		dir := setup(t, map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"})
+		patch := `*** Begin Patch
+*** Update File: a.txt
+-alpha
++ALPHA
+*** Add File: c.txt
++gamma
+*** Update File: b.txt
+-missing
++BETA
+*** End Patch`
+
+		result := executeTool(t, CreateApplyPatchTool(dir), map[string]any{"patch": patch})
+		assert.True(t, result.IsError)
+		text := result.Content[0].(ai.TextContent).Text
+		assert.Contains(t, text, "b.txt")
+		assert.Contains(t, text, "No files were changed")
+
+		assert.Equal(t, "alpha\n", readFile(t, filepath.Join(dir, "a.txt")))
+		assert.Equal(t, "beta\n", readFile(t, filepath.Join(dir, "b.txt")))
+		assert.NoFileExists(t, filepath.Join(dir, "c.txt"))
+	})
+
+	t.Run("RollsBackFailedWrites", func(t *testing.T) {
+		dir := setup(t, map[string]string{"a.txt": "alpha\n"})
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "locked"), 0555))
+		if os.WriteFile(filepath.Join(dir, "locked", "probe"), nil, 0644) == nil {
+			t.Skip("directory permissions are not enforced")
+		}
+
+		patch := "*** Begin Patch\n*** Update File: a.txt\n-alpha\n+ALPHA\n*** Add File: locked/b.txt\n+beta\n*** End Patch"
+		result := executeTool(t, CreateApplyPatchTool(dir), map[string]any{"patch": patch})
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].(ai.TextContent).Text, "rolled back")
+		assert.Equal(t, "alpha\n", readFile(t, filepath.Join(dir, "a.txt")))
+	})
+
+	t.Run("DenyRules", func(t *testing.T) {
+		dir := setup(t, map[string]string{"a.txt": "alpha\n", "secret/key.txt": "key\n"})
+		configDir := t.TempDir()
+		settings := fmt.Sprintf(`{"permissions": {"deny": ["Edit(%s/secret/*)"]}}`, dir)
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, "settings.json"), []byte(settings), 0644))
+		pm, err := agent.NewPermissionManager(configDir, t.TempDir())
+		require.NoError(t, err)
+		ctx := context.WithValue(context.Background(), "permission_manager", pm)
+
+		patch := "*** Begin Patch\n*** Update File: a.txt\n-alpha\n+ALPHA\n*** Update File: secret/key.txt\n-key\n+stolen\n*** End Patch"
+		result, err := CreateApplyPatchTool(dir).Execute(ctx, "call-1", map[string]any{"patch": patch}, nil)
+		require.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].(ai.TextContent).Text, "permission denied")
+		assert.Equal(t, "alpha\n", readFile(t, filepath.Join(dir, "a.txt")))
+		assert.Equal(t, "key\n", readFile(t, filepath.Join(dir, "secret", "key.txt")))
+	})
+
+	t.Run("InvalidPatch", func(t *testing.T) {
+		for _, patch := range []string{"", "just some text", "*** Begin Patch\n*** Frobnicate File: a.txt\n*** End Patch"} {
+			result := executeTool(t, CreateApplyPatchTool(t.TempDir()), map[string]any{"patch": patch})
+			assert.True(t, result.IsError, patch)
+		}
+	})
 }
 
 // Test Bash Tool
@@ -514,6 +1180,99 @@ func TestBashTool_EnvironmentVariables(t *testing.T) {
 	assert.Contains(t, textContent.Text, "test value")
 }
 
+func TestBashTool_PersistentShell(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "sub"), 0755))
+	tool := CreateBashTool(tempDir)
+
+	text := func(result agent.AgentToolResult) string {
+		textContent, ok := result.Content[0].(ai.TextContent)
+		require.True(t, ok)
+		return textContent.Text
+	}
+
+	// State from one call is visible in the next
+	result := executeTool(t, tool, map[string]any{
+		"command": "cd sub && export GREETING=hello && greet() { echo \"$GREETING $1\"; }",
+	})
+	require.False(t, result.IsError, text(result))
+	assert.Equal(t, filepath.Join(tempDir, "sub"), result.Details.(map[string]any)["cwd"])
+
+	result = executeTool(t, tool, map[string]any{"command": "pwd; greet world"})
+	require.False(t, result.IsError, text(result))
+	assert.Contains(t, text(result), filepath.Join(tempDir, "sub")+"\n")
+	assert.Contains(t, text(result), "hello world")
+	assert.Contains(t, text(result), "Working directory: "+filepath.Join(tempDir, "sub"))
+
+	// Syntax errors and commands reading stdin don't break the shell
+	result = executeTool(t, tool, map[string]any{"command": "if then"})
+	assert.True(t, result.IsError)
+	result = executeTool(t, tool, map[string]any{"command": "cat; echo after"})
+	require.False(t, result.IsError, text(result))
+	assert.Contains(t, text(result), "after")
+
+	// Output without a trailing newline is kept as is
+	result = executeTool(t, tool, map[string]any{"command": "printf partial"})
+	assert.Equal(t, "partial", result.Details.(map[string]any)["stdout"])
+
+	// A timeout restarts the shell in the last working directory
+	result = executeTool(t, tool, map[string]any{"command": "sleep 10", "timeout": float64(0.2)})
+	assert.True(t, result.IsError)
+	assert.Contains(t, text(result), "timed out")
+	result = executeTool(t, tool, map[string]any{"command": "pwd; echo \"[$GREETING]\""})
+	require.False(t, result.IsError, text(result))
+	assert.Contains(t, text(result), filepath.Join(tempDir, "sub"))
+	assert.Contains(t, text(result), "[]")
+
+	// Exiting the shell reports the exit code and starts over on the next call
+	result = executeTool(t, tool, map[string]any{"command": "exit 3"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, 3, result.Details.(map[string]any)["exit_code"])
+	result = executeTool(t, tool, map[string]any{"command": "echo alive"})
+	require.False(t, result.IsError, text(result))
+	assert.Contains(t, text(result), "alive")
+
+	// Reset goes back to the working directory
+	result = executeTool(t, tool, map[string]any{"command": "pwd", "reset": true})
+	require.False(t, result.IsError, text(result))
+	assert.Equal(t, tempDir+"\n", result.Details.(map[string]any)["stdout"])
+}
+
+func TestBashTool_Sandbox(t *testing.T) {
+	tempDir := t.TempDir()
+	sb, err := sandbox.New(sandbox.Config{Enabled: true}, tempDir)
+	if err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+
+	tool := CreateBashToolWithOptions(tempDir, BashToolOptions{Sandbox: sb})
+	assert.True(t, tool.Sandboxed)
+	assert.False(t, tool.Networked)
+
+	// Writes inside the working directory succeed
+	result := executeTool(t, tool, map[string]any{
+		"command": "echo ok > inside.txt",
+	})
+	assert.False(t, result.IsError)
+	assert.FileExists(t, filepath.Join(tempDir, "inside.txt"))
+
+	// Writes elsewhere fail and the model is told why
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	result = executeTool(t, tool, map[string]any{
+		"command": "echo nope > " + outside,
+	})
+	assert.True(t, result.IsError)
+	assert.NoFileExists(t, outside)
+
+	textContent, ok := result.Content[0].(ai.TextContent)
+	require.True(t, ok)
+	assert.Contains(t, textContent.Text, "sandbox")
+
+	details, ok := result.Details.(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, true, details["sandboxed"])
+}
+
 // Test tool callbacks
 func TestTool_UpdateCallback(t *testing.T) {
 	tempDir := t.TempDir()
@@ -611,3 +1370,108 @@ func TestBashTool_InvalidParameters(t *testing.T) {
 	// Tool may return error or set IsError
 	assert.True(t, err != nil || result.IsError)
 }
+
+func TestBackgroundTools(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "sub"), 0755))
+
+	manager := NewBackgroundManager(nil)
+	defer manager.Close()
+	bash := CreateBashToolWithOptions(tempDir, BashToolOptions{Background: manager})
+	bashOutput := CreateBashOutputTool(manager)
+	killShell := CreateKillShellTool(manager)
+
+	text := func(result agent.AgentToolResult) string {
+		textContent, ok := result.Content[0].(ai.TextContent)
+		require.True(t, ok)
+		return textContent.Text
+	}
+
+	// Background commands start in the shell's current directory with its
+	// exported variables and return right away
+	executeTool(t, bash, map[string]any{"command": "cd sub; export CC_GREETING='hello there'; CC_LOCAL=1"})
+	result := executeTool(t, bash, map[string]any{
+		"command":           "pwd; echo \"$CC_GREETING${CC_LOCAL:-!}\"; echo ready; echo noise; sleep 30",
+		"run_in_background": true,
+	})
+	require.False(t, result.IsError, text(result))
+	id := result.Details.(map[string]any)["shell_id"].(string)
+	assert.Contains(t, text(result), id)
+
+	// New output only, optionally filtered
+	require.Eventually(t, func() bool {
+		out, err := manager.Output(id, nil)
+		require.NoError(t, err)
+		if strings.Contains(out.Output, "noise") {
+			assert.Contains(t, out.Output, filepath.Join(tempDir, "sub"))
+			assert.Contains(t, out.Output, "hello there!\n")
+			assert.NotContains(t, out.Output, "readonly")
+			return true
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+
+	result = executeTool(t, bashOutput, map[string]any{"shell_id": id})
+	assert.Contains(t, text(result), "Status: running")
+	assert.Contains(t, text(result), "(no new output)")
+
+	jobs := manager.List()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, JobRunning, jobs[0].Status)
+	assert.Equal(t, 1, manager.Running())
+
+	// Killing stops the job
+	result = executeTool(t, killShell, map[string]any{"shell_id": id})
+	require.False(t, result.IsError, text(result))
+	assert.Equal(t, JobKilled, manager.List()[0].Status)
+	assert.Equal(t, 0, manager.Running())
+
+	result = executeTool(t, killShell, map[string]any{"shell_id": id})
+	assert.True(t, result.IsError)
+	result = executeTool(t, bashOutput, map[string]any{"shell_id": "missing"})
+	assert.True(t, result.IsError)
+
+	// Finished jobs report their exit code, and filters drop other lines
+	result = executeTool(t, bash, map[string]any{
+		"command":           "echo fine; echo error: bad; exit 4",
+		"run_in_background": true,
+	})
+	id = result.Details.(map[string]any)["shell_id"].(string)
+	require.Eventually(t, func() bool {
+		return manager.Running() == 0
+	}, 5*time.Second, 20*time.Millisecond)
+
+	result = executeTool(t, bashOutput, map[string]any{"shell_id": id, "filter": "^error"})
+	assert.Contains(t, text(result), "Exit code: 4")
+	assert.Contains(t, text(result), "error: bad")
+	assert.NotContains(t, text(result), "fine\n")
+
+	// Close kills everything
+	executeTool(t, bash, map[string]any{"command": "sleep 30", "run_in_background": true})
+	manager.Close()
+	assert.Empty(t, manager.List())
+}
+
+func TestBackgroundJob_Write(t *testing.T) {
+	job := &backgroundJob{}
+	chunk := []byte(strings.Repeat("x", 1000) + "\n")
+
+	written := 0
+	for written <= 3*maxBackgroundOutput {
+		n, err := job.Write(chunk)
+		require.NoError(t, err)
+		written += n
+
+		// At least the most recent half of the limit is kept, and never more than the limit
+		assert.LessOrEqual(t, len(job.buf), maxBackgroundOutput)
+		assert.GreaterOrEqual(t, len(job.buf), min(written, maxBackgroundOutput/2))
+		assert.Equal(t, written, job.base+len(job.buf))
+	}
+
+	manager := NewBackgroundManager(nil)
+	manager.jobs["bash_1"] = job
+	out, err := manager.Output("bash_1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, job.base, out.Dropped)
+	assert.Len(t, out.Output, len(job.buf))
+}
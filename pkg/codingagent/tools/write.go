@@ -8,6 +8,8 @@ import (
 
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/diff"
 )
 
 // CreateWriteTool creates the write file tool
@@ -65,6 +67,22 @@ func CreateWriteTool(workingDir string) agent.AgentTool {
 			})
 		}
 
+		// Keep the old content for the diff
+		oldContent := ""
+		created := true
+		if data, err := os.ReadFile(absPath); err == nil {
+			oldContent = string(data)
+			created = false
+		}
+
+		// Record the current content so the change can be rewound
+		if err := checkpoint.Snapshot(ctx, absPath); err != nil {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error creating checkpoint: %v", err))},
+				IsError: true,
+			}, nil
+		}
+
 		// Create parent directories
 		dir := filepath.Dir(absPath)
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -85,8 +103,10 @@ func CreateWriteTool(workingDir string) agent.AgentTool {
 		return agent.AgentToolResult{
 			Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Successfully wrote to %s (%d bytes)", filePath, len(content)))},
 			Details: map[string]any{
-				"path": filePath,
-				"size": len(content),
+				"path":    filePath,
+				"size":    len(content),
+				"created": created,
+				"diff":    diff.Unified(oldContent, content, filePath),
 			},
 			IsError: false,
 		}, nil
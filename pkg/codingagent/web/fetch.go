@@ -0,0 +1,296 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Limits on what web_fetch downloads and returns
+const (
+	maxFetchBytes   = 5 * 1024 * 1024 // Bytes downloaded
+	maxContentBytes = 100 * 1024      // Bytes of page content returned
+	maxSummaryBytes = 400 * 1024      // Bytes of page content a prompt is answered from
+	maxRedirects    = 10
+)
+
+// summaryPrompt instructs the model that answers questions about fetched pages
+const summaryPrompt = `You answer a request about the content of a web page.
+Use only the page content. Be concise, quote code and commands exactly, and say so if the page does not contain the answer.`
+
+// FetchOptions configures the web_fetch tool
+type FetchOptions struct {
+	// HTTPClient makes the requests (default: a client with a 30 second timeout)
+	HTTPClient *http.Client
+
+	// Provider and Model answer the optional prompt about a page. Without a
+	// provider the page content is returned instead.
+	Provider ai.Provider
+	Model    ai.Model
+}
+
+// page is a fetched document
+type page struct {
+	URL         string // Final URL, after redirects
+	Status      int
+	ContentType string
+	Title       string
+	Content     string // Markdown for HTML, otherwise the text as is
+	Bytes       int    // Bytes downloaded
+	Redirect    string // Set when the server redirected to another host
+}
+
+// CreateWebFetchTool creates the tool that fetches a URL and returns it as markdown
+func CreateWebFetchTool(options FetchOptions) agent.AgentTool {
+	tool := ai.NewTool(
+		"web_fetch",
+		"Fetch a web page or text document and return its content as markdown. "+
+			"Give a prompt to get an answer about the page instead of the page itself; "+
+			fmt.Sprintf("answers cover the first %d KB of the page, the content returned the first %d KB. ", maxSummaryBytes/1024, maxContentBytes/1024)+
+			"Redirects to another host are reported, not followed; fetch the new URL to continue.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{
+					"type":        "string",
+					"description": "The http or https URL to fetch",
+				},
+				"prompt": map[string]any{
+					"type":        "string",
+					"description": "Optional: What to extract from the page, e.g. \"list the function signatures\"",
+				},
+			},
+			"required": []string{"url"},
+		},
+	)
+
+	client := options.HTTPClient
+	if client == nil {
+		client = defaultClient
+	}
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		rawURL, _ := params["url"].(string)
+		u, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errorResult("url must be an absolute http or https URL"), nil
+		}
+		prompt, _ := params["prompt"].(string)
+
+		if onUpdate != nil {
+			onUpdate(agent.AgentToolUpdate{
+				Type:    "progress",
+				Message: fmt.Sprintf("Fetching %s...", u),
+			})
+		}
+
+		p, err := fetch(ctx, client, u)
+		if err != nil {
+			return errorResult("failed to fetch %s: %v", u, err), nil
+		}
+		details := map[string]any{
+			"url":          p.URL,
+			"status":       p.Status,
+			"content_type": p.ContentType,
+			"bytes":        p.Bytes,
+		}
+
+		if p.Redirect != "" {
+			details["redirect"] = p.Redirect
+			return textResult(fmt.Sprintf("%s redirected to a different host: %s\nFetch that URL to continue.", p.URL, p.Redirect), details), nil
+		}
+		if p.Status >= 400 {
+			return agent.AgentToolResult{
+				Content: []ai.Content{ai.NewTextContent(fmt.Sprintf("Error: %s returned HTTP %d", p.URL, p.Status))},
+				Details: details,
+				IsError: true,
+			}, nil
+		}
+
+		var header strings.Builder
+		header.WriteString("URL: " + p.URL + "\n")
+		if p.Title != "" {
+			header.WriteString("Title: " + p.Title + "\n")
+		}
+
+		if prompt != "" && options.Provider != nil {
+			content, truncated := truncate(p.Content, maxSummaryBytes)
+			answer, err := summarize(ctx, options.Provider, options.Model, p.URL, content, prompt)
+			if err == nil {
+				details["summarized"] = true
+				details["truncated"] = truncated
+				return textResult(header.String()+"\n"+answer, details), nil
+			}
+			header.WriteString(fmt.Sprintf("(Could not answer the prompt: %v. The page content follows.)\n", err))
+		}
+
+		content, truncated := truncate(p.Content, maxContentBytes)
+		details["truncated"] = truncated
+		if truncated {
+			content += fmt.Sprintf("\n\n... (content truncated at %d KB; give a prompt to ask about the first %d KB of the page)", maxContentBytes/1024, maxSummaryBytes/1024)
+		}
+		return textResult(header.String()+"\n"+content, details), nil
+	}
+
+	return agent.NewAgentTool(tool, "Fetch URL", execute)
+}
+
+// fetch downloads a URL, following redirects within the same host, and converts HTML to markdown
+func fetch(ctx context.Context, client *http.Client, u *url.URL) (*page, error) {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if !sameHost(req.URL, via[0].URL) {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/markdown, text/html;q=0.9, text/plain;q=0.8, */*;q=0.5")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := &page{URL: resp.Request.URL.String(), Status: resp.StatusCode}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		if location, err := resp.Location(); err == nil {
+			p.Redirect = location.String()
+			return p, nil
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	p.Bytes = len(body)
+
+	p.ContentType = resp.Header.Get("Content-Type")
+	if p.ContentType == "" {
+		p.ContentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(p.ContentType)
+
+	text := strings.ToValidUTF8(string(body), "\uFFFD")
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		p.Content, p.Title = htmlToMarkdown(text, resp.Request.URL)
+	case isTextType(mediaType):
+		p.Content = text
+	case resp.StatusCode >= 400:
+		// The status is reported; the body doesn't matter
+	default:
+		return nil, fmt.Errorf("unsupported content type %s; only web pages and text can be fetched", mediaType)
+	}
+	return p, nil
+}
+
+// sameHost reports whether a redirect target is on the original host or its
+// www subdomain, which domain permission rules for the original host also cover
+func sameHost(target, origin *url.URL) bool {
+	t, o := strings.ToLower(target.Hostname()), strings.ToLower(origin.Hostname())
+	return t == o || t == "www."+o
+}
+
+// isTextType reports whether a media type is text that can be shown as is
+func isTextType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return false
+}
+
+// truncate cuts s to at most max bytes without splitting a character
+func truncate(s string, max int) (string, bool) {
+	if len(s) <= max {
+		return s, false
+	}
+	s = s[:max]
+	for len(s) > 0 {
+		if r, size := utf8.DecodeLastRuneInString(s); r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s, true
+}
+
+// summarize asks a model to answer a prompt about a page
+func summarize(ctx context.Context, provider ai.Provider, model ai.Model, pageURL, content, prompt string) (string, error) {
+	message := ai.NewUserMessage([]ai.Content{ai.NewTextContent(
+		fmt.Sprintf("Content of %s:\n\n%s\n\n---\n\nRequest: %s", pageURL, content, prompt),
+	)})
+	stream := provider.StreamSimple(ctx, model, ai.Context{
+		SystemPrompt: summaryPrompt,
+		Messages:     []ai.Message{message},
+	}, &ai.SimpleStreamOptions{})
+
+	// Consume the events so the provider never blocks on a full buffer; the
+	// answer is taken from the final result
+events:
+	for {
+		select {
+		case event, ok := <-stream.Events():
+			if !ok {
+				break events
+			}
+			if event.Type == ai.EventTypeError {
+				stream.Close()
+				return "", fmt.Errorf("stream error: %s", event.Error)
+			}
+		case <-ctx.Done():
+			stream.Close()
+			return "", ctx.Err()
+		}
+	}
+	if err := stream.Error(); err != nil {
+		return "", err
+	}
+
+	var result ai.AssistantMessage
+	select {
+	case result = <-stream.Result():
+	default:
+		// Closed without a result, e.g. cancelled
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("the model stream ended without an answer")
+	}
+
+	var answer strings.Builder
+	for _, c := range result.Content {
+		if text, ok := c.(ai.TextContent); ok {
+			answer.WriteString(text.Text)
+		}
+	}
+	if strings.TrimSpace(answer.String()) == "" {
+		return "", fmt.Errorf("the model returned no answer")
+	}
+	return strings.TrimSpace(answer.String()), nil
+}
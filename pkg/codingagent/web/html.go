@@ -0,0 +1,527 @@
+package web
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// htmlToken is a tag or a run of text in an HTML document
+type htmlToken struct {
+	text    string            // Text, with entities still encoded
+	tag     string            // Lowercase tag name; empty for text
+	closing bool              // </tag>
+	attrs   map[string]string // Attribute values, decoded
+}
+
+// rawTextTags hold text that is not HTML, up to their closing tag
+var rawTextTags = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// skippedTags are dropped with everything inside them
+var skippedTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "canvas": true, "iframe": true, "nav": true, "footer": true, "form": true,
+}
+
+// voidTags have no closing tag
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// blockTags start and end paragraphs
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "header": true,
+	"address": true, "figure": true, "figcaption": true, "details": true, "summary": true,
+	"dl": true, "dt": true, "dd": true, "table": true, "ul": true, "ol": true,
+}
+
+// attrPattern matches name, name=value, name="value" and name='value'
+var attrPattern = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+
+// tokenizeHTML splits a document into tags and text. Comments, doctypes and
+// processing instructions are dropped.
+func tokenizeHTML(doc string) []htmlToken {
+	var tokens []htmlToken
+	for len(doc) > 0 {
+		lt := strings.IndexByte(doc, '<')
+		if lt < 0 {
+			tokens = append(tokens, htmlToken{text: doc})
+			break
+		}
+		if lt > 0 {
+			tokens = append(tokens, htmlToken{text: doc[:lt]})
+			doc = doc[lt:]
+		}
+
+		switch {
+		case strings.HasPrefix(doc, "<!--"):
+			end := strings.Index(doc, "-->")
+			if end < 0 {
+				return tokens
+			}
+			doc = doc[end+3:]
+			continue
+		case strings.HasPrefix(doc, "<!") || strings.HasPrefix(doc, "<?"):
+			end := strings.IndexByte(doc, '>')
+			if end < 0 {
+				return tokens
+			}
+			doc = doc[end+1:]
+			continue
+		}
+
+		// "<" not starting a tag is text
+		closing := strings.HasPrefix(doc, "</")
+		nameStart := 1
+		if closing {
+			nameStart = 2
+		}
+		if len(doc) <= nameStart || !isLetter(doc[nameStart]) {
+			tokens = append(tokens, htmlToken{text: "<"})
+			doc = doc[1:]
+			continue
+		}
+
+		end := tagEnd(doc)
+		if end < 0 {
+			return tokens
+		}
+		inner := doc[nameStart:end]
+		doc = doc[end+1:]
+
+		nameEnd := strings.IndexFunc(inner, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '/' || r == '\f'
+		})
+		if nameEnd < 0 {
+			nameEnd = len(inner)
+		}
+		tok := htmlToken{tag: strings.ToLower(inner[:nameEnd]), closing: closing}
+		if !closing {
+			tok.attrs = parseAttrs(inner[nameEnd:])
+		}
+		tokens = append(tokens, tok)
+
+		// The content of raw text elements runs to their closing tag
+		if !closing && rawTextTags[tok.tag] {
+			closeTag := "</" + tok.tag
+			idx := strings.Index(strings.ToLower(doc), closeTag)
+			if idx < 0 {
+				idx = len(doc)
+			}
+			tokens = append(tokens, htmlToken{text: doc[:idx]})
+			doc = doc[idx:]
+		}
+	}
+	return tokens
+}
+
+// tagEnd finds the ">" that ends the tag at the start of doc, skipping quoted attribute values
+func tagEnd(doc string) int {
+	var quote byte
+	for i := 1; i < len(doc); i++ {
+		c := doc[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseAttrs parses the attributes of a tag
+func parseAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+// isLetter reports whether c is an ASCII letter
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// markdownWriter builds markdown, collapsing whitespace and blank lines
+type markdownWriter struct {
+	out  []byte
+	base *url.URL
+
+	pre       int        // Depth of <pre> elements
+	lists     []listInfo // Open lists, innermost last
+	links     []linkInfo // Open links, innermost last
+	quotes    []int      // Output positions where open blockquotes started
+	rowCells  int        // Cells written in the current table row
+	headerRow bool       // The current table row has th cells
+}
+
+// listInfo is an open ul or ol element
+type listInfo struct {
+	ordered bool
+	next    int
+}
+
+// linkInfo is an open a element
+type linkInfo struct {
+	href  string
+	start int
+}
+
+// lastByte returns the last byte written, or '\n' at the start
+func (w *markdownWriter) lastByte() byte {
+	if len(w.out) == 0 {
+		return '\n'
+	}
+	return w.out[len(w.out)-1]
+}
+
+// text writes text, collapsing whitespace outside <pre>
+func (w *markdownWriter) text(s string) {
+	s = html.UnescapeString(s)
+	if w.pre > 0 {
+		w.out = append(w.out, s...)
+		return
+	}
+	if s == "" {
+		return
+	}
+
+	fields := strings.FieldsFunc(s, isSpace)
+	if isSpace(rune(s[0])) {
+		w.space()
+	}
+	for i, field := range fields {
+		if i > 0 {
+			w.space()
+		}
+		w.out = append(w.out, field...)
+	}
+	if len(fields) > 0 && isSpace(rune(s[len(s)-1])) {
+		w.space()
+	}
+}
+
+// space separates words, unless a separator is already there
+func (w *markdownWriter) space() {
+	if c := w.lastByte(); c != '\n' && c != ' ' {
+		w.out = append(w.out, ' ')
+	}
+}
+
+// inline writes markup that attaches to the surrounding text, such as ** or `.
+// Closing markup goes before any trailing space.
+func (w *markdownWriter) inline(s string, opening bool) {
+	if opening {
+		w.out = append(w.out, s...)
+		return
+	}
+	spaced := w.lastByte() == ' '
+	w.trimSpace()
+	w.out = append(w.out, s...)
+	if spaced {
+		w.out = append(w.out, ' ')
+	}
+}
+
+// trimSpace removes trailing spaces
+func (w *markdownWriter) trimSpace() {
+	for len(w.out) > 0 && w.out[len(w.out)-1] == ' ' {
+		w.out = w.out[:len(w.out)-1]
+	}
+}
+
+// newlines ends the current line and makes sure at least n-1 blank lines follow
+func (w *markdownWriter) newlines(n int) {
+	w.trimSpace()
+	if len(w.out) == 0 {
+		return
+	}
+	have := 0
+	for i := len(w.out) - 1; i >= 0 && w.out[i] == '\n'; i-- {
+		have++
+	}
+	for ; have < n; have++ {
+		w.out = append(w.out, '\n')
+	}
+}
+
+// resolve makes a link absolute relative to the page
+func (w *markdownWriter) resolve(ref string) string {
+	if w.base == nil {
+		return ref
+	}
+	u, err := w.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// htmlToMarkdown converts an HTML document to markdown and returns its title.
+// Links and images are made absolute relative to base, which may be nil.
+func htmlToMarkdown(doc string, base *url.URL) (string, string) {
+	tokens := tokenizeHTML(doc)
+	w := &markdownWriter{base: base}
+	title := ""
+	skip := 0 // Depth of skipped elements
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.tag == "" {
+			if skip == 0 {
+				w.text(tok.text)
+			}
+			continue
+		}
+
+		if tok.tag == "title" && !tok.closing && title == "" && i+1 < len(tokens) {
+			title = strings.Join(strings.Fields(html.UnescapeString(tokens[i+1].text)), " ")
+		}
+		if skippedTags[tok.tag] {
+			switch {
+			case tok.closing && skip > 0:
+				skip--
+			case !tok.closing && !voidTags[tok.tag]:
+				skip++
+			}
+			continue
+		}
+		if skip > 0 {
+			continue
+		}
+
+		if tok.closing {
+			w.closeTag(tok.tag)
+		} else {
+			w.openTag(tok)
+		}
+	}
+
+	return cleanMarkdown(string(w.out)), title
+}
+
+// openTag writes the markdown that starts an element
+func (w *markdownWriter) openTag(tok htmlToken) {
+	switch tag := tok.tag; tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.newlines(2)
+		level, _ := strconv.Atoi(tag[1:])
+		w.out = append(w.out, strings.Repeat("#", level)+" "...)
+	case "br":
+		if w.pre > 0 {
+			w.out = append(w.out, '\n')
+		} else {
+			w.newlines(1)
+		}
+	case "hr":
+		w.newlines(2)
+		w.out = append(w.out, "---"...)
+		w.newlines(2)
+	case "pre":
+		w.newlines(2)
+		w.out = append(w.out, "```"+codeLanguage(tok.attrs["class"])+"\n"...)
+		w.pre++
+	case "code":
+		if w.pre > 0 {
+			// The language may be on the code element; fill it in if the fence has none
+			if lang := codeLanguage(tok.attrs["class"]); lang != "" && strings.HasSuffix(string(w.out), "```\n") {
+				w.out = append(w.out[:len(w.out)-1], lang+"\n"...)
+			}
+			return
+		}
+		w.inline("`", true)
+	case "strong", "b":
+		w.inline("**", true)
+	case "em", "i":
+		w.inline("_", true)
+	case "a":
+		href := tok.attrs["href"]
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			href = ""
+		}
+		w.links = append(w.links, linkInfo{href: w.resolve(href), start: len(w.out)})
+	case "img":
+		src := tok.attrs["src"]
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		w.out = append(w.out, "!["+strings.Join(strings.Fields(tok.attrs["alt"]), " ")+"]("+w.resolve(src)+")"...)
+	case "ul", "ol":
+		w.newlines(1)
+		start := 1
+		if n, err := strconv.Atoi(tok.attrs["start"]); err == nil {
+			start = n
+		}
+		w.lists = append(w.lists, listInfo{ordered: tag == "ol", next: start})
+	case "li":
+		w.newlines(1)
+		indent := ""
+		marker := "- "
+		if n := len(w.lists); n > 0 {
+			indent = strings.Repeat("  ", n-1)
+			if list := &w.lists[n-1]; list.ordered {
+				marker = strconv.Itoa(list.next) + ". "
+				list.next++
+			}
+		}
+		w.out = append(w.out, indent+marker...)
+	case "blockquote":
+		w.newlines(2)
+		w.quotes = append(w.quotes, len(w.out))
+	case "tr":
+		w.newlines(1)
+		w.rowCells, w.headerRow = 0, false
+	case "td", "th":
+		if w.rowCells == 0 {
+			w.out = append(w.out, '|')
+		}
+		w.out = append(w.out, ' ')
+		w.rowCells++
+		w.headerRow = w.headerRow || tag == "th"
+	default:
+		if blockTags[tag] {
+			w.newlines(2)
+		}
+	}
+}
+
+// closeTag writes the markdown that ends an element
+func (w *markdownWriter) closeTag(tag string) {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.newlines(2)
+	case "pre":
+		if w.pre > 0 {
+			w.pre--
+			if w.lastByte() != '\n' {
+				w.out = append(w.out, '\n')
+			}
+			w.out = append(w.out, "```"...)
+			w.newlines(2)
+		}
+	case "code":
+		if w.pre == 0 {
+			w.inline("`", false)
+		}
+	case "strong", "b":
+		w.inline("**", false)
+	case "em", "i":
+		w.inline("_", false)
+	case "a":
+		if len(w.links) == 0 {
+			return
+		}
+		link := w.links[len(w.links)-1]
+		w.links = w.links[:len(w.links)-1]
+		inner := string(w.out[link.start:])
+		text := strings.TrimSpace(inner)
+		if link.href == "" || text == "" {
+			return
+		}
+		w.out = append(w.out[:link.start], "["+text+"]("+link.href+")"...)
+		if strings.HasPrefix(inner, " ") {
+			w.out = append(w.out[:link.start], append([]byte{' '}, w.out[link.start:]...)...)
+		}
+		if strings.HasSuffix(inner, " ") {
+			w.out = append(w.out, ' ')
+		}
+	case "ul", "ol":
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+		w.newlines(2)
+	case "li":
+		w.newlines(1)
+	case "blockquote":
+		if len(w.quotes) == 0 {
+			return
+		}
+		start := w.quotes[len(w.quotes)-1]
+		w.quotes = w.quotes[:len(w.quotes)-1]
+		w.trimSpace()
+		lines := strings.Split(strings.Trim(string(w.out[start:]), "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		w.out = append(w.out[:start], strings.Join(lines, "\n")...)
+		w.newlines(2)
+	case "td", "th":
+		w.trimSpace()
+		w.out = append(w.out, " |"...)
+	case "tr":
+		if w.headerRow && w.rowCells > 0 {
+			w.out = append(w.out, '\n', '|')
+			w.out = append(w.out, strings.Repeat(" --- |", w.rowCells)...)
+		}
+		w.newlines(1)
+	case "table":
+		w.newlines(2)
+	default:
+		if blockTags[tag] {
+			w.newlines(2)
+		}
+	}
+}
+
+// codeLanguage finds the language in a class such as "language-go" or "lang-go"
+func codeLanguage(class string) string {
+	for _, c := range strings.Fields(class) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(c, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// cleanMarkdown trims trailing spaces and collapses runs of blank lines
+func cleanMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	var out []string
+	blank := 0
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+		if !inFence {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" && !inFence {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// isSpace reports whether r is whitespace, counting non-breaking spaces
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '\u00a0'
+}
+
+// stripTags removes tags from a snippet of HTML and decodes its entities
+func stripTags(s string) string {
+	var b strings.Builder
+	for _, tok := range tokenizeHTML(s) {
+		if tok.tag == "" {
+			b.WriteString(tok.text)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
@@ -0,0 +1,259 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Limits on search results
+const (
+	defaultSearchResults = 5
+	maxSearchResults     = 20
+)
+
+// SearchResult is one hit returned by a search backend
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchBackend runs web searches
+type SearchBackend interface {
+	// Name identifies the backend in tool results
+	Name() string
+
+	// Search returns up to limit results for a query
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchConfig selects and configures a search backend
+type SearchConfig struct {
+	Backend string `koanf:"backend" json:"backend"` // "brave" or "searxng"
+	APIKey  string `koanf:"api_key" json:"api_key"` // ${VAR} is expanded from the environment
+	BaseURL string `koanf:"base_url" json:"base_url"`
+}
+
+// NewSearchBackend creates the configured backend. It returns nil when no
+// backend is configured.
+func NewSearchBackend(config SearchConfig, client *http.Client) (SearchBackend, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	apiKey := os.ExpandEnv(config.APIKey)
+
+	switch strings.ToLower(config.Backend) {
+	case "":
+		return nil, nil
+	case "brave":
+		if apiKey == "" {
+			return nil, fmt.Errorf("the brave search backend needs an api_key")
+		}
+		return &BraveBackend{APIKey: apiKey, BaseURL: config.BaseURL, Client: client}, nil
+	case "searxng":
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("the searxng search backend needs a base_url")
+		}
+		return &SearXNGBackend{BaseURL: config.BaseURL, Client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", config.Backend)
+	}
+}
+
+// CreateWebSearchTool creates the tool that searches the web with a backend
+func CreateWebSearchTool(backend SearchBackend) agent.AgentTool {
+	tool := ai.NewTool(
+		"web_search",
+		"Search the web. Returns titles, URLs and snippets; use web_fetch to read a result.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "The search query",
+				},
+				"limit": map[string]any{
+					"type":        "number",
+					"description": "Optional: Number of results (default: 5, maximum: 20)",
+				},
+			},
+			"required": []string{"query"},
+		},
+	)
+
+	execute := func(ctx context.Context, toolCallID string, params map[string]any, onUpdate agent.AgentToolUpdateCallback) (agent.AgentToolResult, error) {
+		query, _ := params["query"].(string)
+		query = strings.TrimSpace(query)
+		if query == "" {
+			return errorResult("query must be a non-empty string"), nil
+		}
+		limit := defaultSearchResults
+		if val, ok := params["limit"].(float64); ok && val >= 1 {
+			limit = min(int(val), maxSearchResults)
+		}
+
+		if onUpdate != nil {
+			onUpdate(agent.AgentToolUpdate{
+				Type:    "progress",
+				Message: fmt.Sprintf("Searching for %s...", query),
+			})
+		}
+
+		results, err := backend.Search(ctx, query, limit)
+		if err != nil {
+			return errorResult("%s search failed: %v", backend.Name(), err), nil
+		}
+		if len(results) > limit {
+			results = results[:limit]
+		}
+
+		details := map[string]any{
+			"backend": backend.Name(),
+			"query":   query,
+			"results": results,
+		}
+		if len(results) == 0 {
+			return textResult(fmt.Sprintf("No results for %q", query), details), nil
+		}
+
+		var b strings.Builder
+		for i, r := range results {
+			b.WriteString(fmt.Sprintf("%d. %s\n   %s\n", i+1, r.Title, r.URL))
+			if r.Snippet != "" {
+				b.WriteString("   " + r.Snippet + "\n")
+			}
+		}
+		return textResult(strings.TrimSuffix(b.String(), "\n"), details), nil
+	}
+
+	return agent.NewAgentTool(tool, "Web Search", execute)
+}
+
+// BraveBackend searches with the Brave Search API
+type BraveBackend struct {
+	APIKey  string
+	BaseURL string // Default: https://api.search.brave.com
+	Client  *http.Client
+}
+
+// Name returns "brave"
+func (b *BraveBackend) Name() string {
+	return "brave"
+}
+
+// Search queries the web search endpoint
+func (b *BraveBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	base := b.BaseURL
+	if base == "" {
+		base = "https://api.search.brave.com"
+	}
+	endpoint := strings.TrimSuffix(base, "/") + "/res/v1/web/search?" + url.Values{
+		"q":     {query},
+		"count": {fmt.Sprint(limit)},
+	}.Encode()
+
+	var response struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	header := http.Header{"X-Subscription-Token": {b.APIKey}}
+	if err := getJSON(ctx, b.Client, endpoint, header, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(response.Web.Results))
+	for _, r := range response.Web.Results {
+		results = append(results, SearchResult{Title: stripTags(r.Title), URL: r.URL, Snippet: stripTags(r.Description)})
+	}
+	return results, nil
+}
+
+// SearXNGBackend searches with a SearXNG instance that has the JSON format enabled
+type SearXNGBackend struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// Name returns "searxng"
+func (s *SearXNGBackend) Name() string {
+	return "searxng"
+}
+
+// Search queries the instance's search endpoint
+func (s *SearXNGBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	endpoint := strings.TrimSuffix(s.BaseURL, "/") + "/search?" + url.Values{
+		"q":      {query},
+		"format": {"json"},
+	}.Encode()
+
+	var response struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, s.Client, endpoint, nil, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, min(len(response.Results), limit))
+	for _, r := range response.Results {
+		if len(results) == limit {
+			break
+		}
+		results = append(results, SearchResult{Title: stripTags(r.Title), URL: r.URL, Snippet: stripTags(r.Content)})
+	}
+	return results, nil
+}
+
+// getJSON sends a GET request and decodes the JSON response
+func getJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, v any) error {
+	if client == nil {
+		client = defaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := truncate(strings.TrimSpace(string(body)), 200)
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
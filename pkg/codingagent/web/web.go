@@ -0,0 +1,45 @@
+// Package web provides the web_fetch and web_search tools.
+//
+// Which sites may be fetched is decided by the permission manager before a
+// tool runs, with rules such as "WebFetch(domain:go.dev)". The tools only
+// follow redirects within the same host, so a redirect cannot escape them.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+)
+
+// Config configures the web tools
+type Config struct {
+	// SummaryModel answers questions about fetched pages (default: the session's model)
+	SummaryModel string `koanf:"summary_model" json:"summary_model"`
+
+	Search SearchConfig `koanf:"search" json:"search"`
+}
+
+// userAgent identifies requests made by the tools
+const userAgent = "cc-mono (+https://github.com/myersguo/cc-mono)"
+
+// defaultClient is used when no HTTP client is given
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// textResult creates a successful tool result
+func textResult(text string, details map[string]any) agent.AgentToolResult {
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent(text)},
+		Details: details,
+	}
+}
+
+// errorResult creates an error tool result
+func errorResult(format string, args ...any) agent.AgentToolResult {
+	return agent.AgentToolResult{
+		Content: []ai.Content{ai.NewTextContent("Error: " + fmt.Sprintf(format, args...))},
+		IsError: true,
+	}
+}
@@ -0,0 +1,359 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/myersguo/cc-mono/pkg/agent"
+	"github.com/myersguo/cc-mono/pkg/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider answers every StreamSimple call with a fixed reply
+type scriptedProvider struct {
+	mu       sync.Mutex
+	reply    string
+	contexts []ai.Context
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+
+func (p *scriptedProvider) Stream(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.StreamOptions) *ai.AssistantMessageEventStream {
+	p.mu.Lock()
+	p.contexts = append(p.contexts, aiContext)
+	p.mu.Unlock()
+
+	stream := ai.NewAssistantMessageEventStream(ctx)
+	reply := ai.NewAssistantMessage([]ai.Content{ai.NewTextContent(p.reply)}, "test", "scripted", "", ai.Usage{}, ai.StopReasonEndTurn)
+	go stream.SendResult(reply)
+	return stream
+}
+
+func (p *scriptedProvider) StreamSimple(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.SimpleStreamOptions) *ai.AssistantMessageEventStream {
+	return p.Stream(ctx, model, aiContext, nil)
+}
+
+func (p *scriptedProvider) ValidateModel(model ai.Model) error { return nil }
+
+func (p *scriptedProvider) GetDefaultModel() ai.Model { return ai.Model{ID: "scripted"} }
+
+// streamingProvider behaves like the real providers: it reads the options,
+// streams its reply as more deltas than the event buffer holds and then sends
+// the result, or fails part way through when err is set
+type streamingProvider struct {
+	err error
+}
+
+func (p *streamingProvider) Name() string { return "streaming" }
+
+func (p *streamingProvider) Stream(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.StreamOptions) *ai.AssistantMessageEventStream {
+	return p.StreamSimple(ctx, model, aiContext, nil)
+}
+
+func (p *streamingProvider) StreamSimple(ctx context.Context, model ai.Model, aiContext ai.Context, options *ai.SimpleStreamOptions) *ai.AssistantMessageEventStream {
+	stream := ai.NewAssistantMessageEventStream(ctx)
+	go func() {
+		_ = options.Temperature
+		var reply strings.Builder
+		for i := 0; i < 150; i++ {
+			if i == 50 && p.err != nil {
+				stream.SendError(p.err)
+				return
+			}
+			if err := stream.SendEvent(ai.NewTextDeltaEvent("a")); err != nil {
+				return
+			}
+			reply.WriteString("a")
+		}
+		stream.SendResult(ai.NewAssistantMessage([]ai.Content{ai.NewTextContent(reply.String())}, "test", "streaming", "", ai.Usage{}, ai.StopReasonEndTurn))
+	}()
+	return stream
+}
+
+func (p *streamingProvider) ValidateModel(model ai.Model) error { return nil }
+
+func (p *streamingProvider) GetDefaultModel() ai.Model { return ai.Model{ID: "streaming"} }
+
+func execute(t *testing.T, tool agent.AgentTool, params map[string]any) (string, agent.AgentToolResult) {
+	t.Helper()
+	result, err := tool.Execute(context.Background(), "call-1", params, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Content)
+	text, ok := result.Content[0].(ai.TextContent)
+	require.True(t, ok)
+	return text.Text, result
+}
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head><title>Effective &amp; Go</title><style>body { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<h1>Introduction</h1>
+<p>Go is a <b>new</b> language. See <a href="/doc/spec">the spec</a>.</p>
+<script>alert("hi")</script>
+<ul><li>One</li><li>Two</li></ul>
+<pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}</code></pre>
+<table><tr><th>Name</th><th>Value</th></tr><tr><td>a</td><td>1</td></tr></table>
+<footer>Copyright</footer>
+</body>
+</html>`
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://go.dev/doc/effective_go")
+	markdown, title := htmlToMarkdown(testPage, base)
+
+	assert.Equal(t, "Effective & Go", title)
+	assert.Contains(t, markdown, "# Introduction")
+	assert.Contains(t, markdown, "Go is a **new** language. See [the spec](https://go.dev/doc/spec).")
+	assert.Contains(t, markdown, "- One\n- Two")
+	assert.Contains(t, markdown, "```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```")
+	assert.Contains(t, markdown, "| Name | Value |")
+	assert.Contains(t, markdown, "| a | 1 |")
+
+	assert.NotContains(t, markdown, "alert")
+	assert.NotContains(t, markdown, "color: red")
+	assert.NotContains(t, markdown, "Home")
+	assert.NotContains(t, markdown, "Copyright")
+}
+
+func TestWebFetchTool(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("elsewhere"))
+	}))
+	defer other.Close()
+	// Both servers listen on 127.0.0.1; reach the other one by another name
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("plain <b>text</b>"))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/big.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", maxContentBytes+100)))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tool := CreateWebFetchTool(FetchOptions{})
+
+	t.Run("HTML", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/page"})
+		assert.False(t, result.IsError)
+		assert.True(t, strings.HasPrefix(text, "URL: "+server.URL+"/page\nTitle: Effective & Go\n\n"), text)
+		assert.Contains(t, text, "# Introduction")
+		assert.Contains(t, text, "[the spec]("+server.URL+"/doc/spec)")
+		assert.Equal(t, http.StatusOK, result.Details.(map[string]any)["status"])
+	})
+
+	t.Run("SameHostRedirect", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/moved"})
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "URL: "+server.URL+"/page")
+		assert.Contains(t, text, "# Introduction")
+	})
+
+	t.Run("CrossHostRedirect", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/away"})
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "redirected to a different host: "+otherURL+"/landing")
+		assert.NotContains(t, text, "elsewhere")
+		assert.Equal(t, otherURL+"/landing", result.Details.(map[string]any)["redirect"])
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/missing"})
+		assert.True(t, result.IsError)
+		assert.Contains(t, text, "HTTP 404")
+	})
+
+	t.Run("PlainText", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/notes.txt"})
+		assert.False(t, result.IsError)
+		assert.True(t, strings.HasSuffix(text, "\n\nplain <b>text</b>"), text)
+	})
+
+	t.Run("UnsupportedContentType", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/image.png"})
+		assert.True(t, result.IsError)
+		assert.Contains(t, text, "unsupported content type image/png")
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/big.txt"})
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "content truncated")
+		assert.Equal(t, true, result.Details.(map[string]any)["truncated"])
+	})
+
+	t.Run("InvalidURL", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": "file:///etc/passwd"})
+		assert.True(t, result.IsError)
+		assert.Contains(t, text, "absolute http or https URL")
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		provider := &scriptedProvider{reply: "Go is new."}
+		tool := CreateWebFetchTool(FetchOptions{Provider: provider, Model: ai.Model{ID: "small"}})
+
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/page", "prompt": "What is Go?"})
+		assert.False(t, result.IsError)
+		assert.Equal(t, "URL: "+server.URL+"/page\nTitle: Effective & Go\n\nGo is new.", text)
+		assert.Equal(t, true, result.Details.(map[string]any)["summarized"])
+
+		require.Len(t, provider.contexts, 1)
+		request := provider.contexts[0].Messages[0].(ai.UserMessage).Content[0].(ai.TextContent).Text
+		assert.Contains(t, request, "# Introduction")
+		assert.Contains(t, request, "Request: What is Go?")
+	})
+
+	t.Run("PromptOnLongPage", func(t *testing.T) {
+		provider := &scriptedProvider{reply: "Lots of x."}
+		tool := CreateWebFetchTool(FetchOptions{Provider: provider})
+
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/big.txt", "prompt": "What is this?"})
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "Lots of x.")
+		assert.Equal(t, false, result.Details.(map[string]any)["truncated"])
+
+		// The prompt is answered from more than the content returned without one
+		require.Len(t, provider.contexts, 1)
+		request := provider.contexts[0].Messages[0].(ai.UserMessage).Content[0].(ai.TextContent).Text
+		assert.Contains(t, request, strings.Repeat("x", maxContentBytes+100))
+	})
+
+	t.Run("PromptStreamed", func(t *testing.T) {
+		tool := CreateWebFetchTool(FetchOptions{Provider: &streamingProvider{}})
+
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/page", "prompt": "What is Go?"})
+		assert.False(t, result.IsError)
+		assert.True(t, strings.HasSuffix(text, "\n\n"+strings.Repeat("a", 150)), text)
+		assert.Equal(t, true, result.Details.(map[string]any)["summarized"])
+	})
+
+	t.Run("PromptStreamError", func(t *testing.T) {
+		tool := CreateWebFetchTool(FetchOptions{Provider: &streamingProvider{err: errors.New("overloaded")}})
+
+		done := make(chan struct{})
+		var text string
+		var result agent.AgentToolResult
+		go func() {
+			defer close(done)
+			text, result = execute(t, tool, map[string]any{"url": server.URL + "/page", "prompt": "What is Go?"})
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("web_fetch did not return after the stream failed")
+		}
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "Could not answer the prompt: overloaded")
+		assert.Contains(t, text, "# Introduction")
+	})
+
+	t.Run("PromptWithoutProvider", func(t *testing.T) {
+		text, result := execute(t, tool, map[string]any{"url": server.URL + "/page", "prompt": "What is Go?"})
+		assert.False(t, result.IsError)
+		assert.Contains(t, text, "# Introduction")
+	})
+}
+
+func TestWebSearchTool(t *testing.T) {
+	t.Run("Brave", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/res/v1/web/search", r.URL.Path)
+			assert.Equal(t, "secret", r.Header.Get("X-Subscription-Token"))
+			assert.Equal(t, "golang generics", r.URL.Query().Get("q"))
+			assert.Equal(t, "2", r.URL.Query().Get("count"))
+			w.Write([]byte(`{"web": {"results": [
+				{"title": "Tutorial: <strong>Generics</strong>", "url": "https://go.dev/doc/tutorial/generics", "description": "Get started with <strong>generics</strong>."},
+				{"title": "Spec", "url": "https://go.dev/ref/spec", "description": ""}
+			]}}`))
+		}))
+		defer server.Close()
+
+		backend, err := NewSearchBackend(SearchConfig{Backend: "brave", APIKey: "secret", BaseURL: server.URL}, nil)
+		require.NoError(t, err)
+
+		text, result := execute(t, CreateWebSearchTool(backend), map[string]any{"query": "golang generics", "limit": float64(2)})
+		assert.False(t, result.IsError)
+		assert.Equal(t, "1. Tutorial: Generics\n   https://go.dev/doc/tutorial/generics\n   Get started with generics.\n"+
+			"2. Spec\n   https://go.dev/ref/spec", text)
+	})
+
+	t.Run("SearXNG", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/search", r.URL.Path)
+			assert.Equal(t, "json", r.URL.Query().Get("format"))
+			w.Write([]byte(`{"results": [
+				{"title": "A", "url": "https://a.example", "content": "first"},
+				{"title": "B", "url": "https://b.example", "content": "second"}
+			]}`))
+		}))
+		defer server.Close()
+
+		backend, err := NewSearchBackend(SearchConfig{Backend: "searxng", BaseURL: server.URL}, nil)
+		require.NoError(t, err)
+
+		text, result := execute(t, CreateWebSearchTool(backend), map[string]any{"query": "anything", "limit": float64(1)})
+		assert.False(t, result.IsError)
+		assert.Equal(t, "1. A\n   https://a.example\n   first", text)
+		assert.Equal(t, "searxng", result.Details.(map[string]any)["backend"])
+	})
+
+	t.Run("BackendError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		backend := &SearXNGBackend{BaseURL: server.URL}
+		text, result := execute(t, CreateWebSearchTool(backend), map[string]any{"query": "anything"})
+		assert.True(t, result.IsError)
+		assert.Contains(t, text, "searxng search failed: HTTP 429: rate limited")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		backend, err := NewSearchBackend(SearchConfig{}, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, backend)
+
+		t.Setenv("TEST_BRAVE_KEY", "from-env")
+		backend, err = NewSearchBackend(SearchConfig{Backend: "Brave", APIKey: "${TEST_BRAVE_KEY}"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", backend.(*BraveBackend).APIKey)
+
+		_, err = NewSearchBackend(SearchConfig{Backend: "brave"}, nil)
+		assert.Error(t, err)
+		_, err = NewSearchBackend(SearchConfig{Backend: "searxng"}, nil)
+		assert.Error(t, err)
+		_, err = NewSearchBackend(SearchConfig{Backend: "bing"}, nil)
+		assert.Error(t, err)
+	})
+}
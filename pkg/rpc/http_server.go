@@ -13,6 +13,9 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/codingagent/tools"
 )
 
 var upgrader = websocket.Upgrader{
@@ -33,6 +36,10 @@ type HTTPServer struct {
 	modelRegistry  *codingagent.ModelRegistry
 	providerConfig *codingagent.ProvidersConfig
 	sessionManager *codingagent.SessionManager
+	auditLog       *agent.AuditLog
+	checkpoints    *checkpoint.Store
+	background     *tools.BackgroundManager
+	commands       *commands.Registry
 }
 
 // NewHTTPServer 创建新的 HTTP 服务器实例
@@ -53,6 +60,26 @@ func NewHTTPServer(
 	}
 }
 
+// SetAuditLog 设置审计日志，每个连接的 RPC Server 都会使用它
+func (h *HTTPServer) SetAuditLog(auditLog *agent.AuditLog) {
+	h.auditLog = auditLog
+}
+
+// SetCheckpoints 设置检查点存储，每个连接的 RPC Server 都会使用它
+func (h *HTTPServer) SetCheckpoints(store *checkpoint.Store) {
+	h.checkpoints = store
+}
+
+// SetBackgroundJobs 设置后台任务管理器，每个连接的 RPC Server 都会使用它
+func (h *HTTPServer) SetBackgroundJobs(background *tools.BackgroundManager) {
+	h.background = background
+}
+
+// SetCommands 设置斜杠命令注册表，每个连接的 RPC Server 都会使用它
+func (h *HTTPServer) SetCommands(registry *commands.Registry) {
+	h.commands = registry
+}
+
 // Start 启动 HTTP 和 WebSocket 服务器
 func (h *HTTPServer) Start() error {
 	http.HandleFunc("/health", h.healthHandler)
@@ -191,6 +218,10 @@ func (h *HTTPServer) rpcWebSocketHandler(w http.ResponseWriter, r *http.Request)
 	
 	// 创建并运行 RPC Server
 	srv := NewServer(h.agent, h.modelRegistry, h.providerConfig, h.sessionManager, wrapper, wrapper)
+	srv.SetAuditLog(h.auditLog)
+	srv.SetCheckpoints(h.checkpoints)
+	srv.SetBackgroundJobs(h.background)
+	srv.SetCommands(h.commands)
 	
 	go func() {
 		defer func() {
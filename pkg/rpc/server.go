@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,10 @@ import (
 	"github.com/myersguo/cc-mono/pkg/agent"
 	"github.com/myersguo/cc-mono/pkg/ai"
 	"github.com/myersguo/cc-mono/pkg/codingagent"
+	"github.com/myersguo/cc-mono/pkg/codingagent/checkpoint"
+	"github.com/myersguo/cc-mono/pkg/codingagent/commands"
+	"github.com/myersguo/cc-mono/pkg/codingagent/images"
+	"github.com/myersguo/cc-mono/pkg/codingagent/tools"
 )
 
 // Server 是 RPC 服务器
@@ -24,6 +29,10 @@ type Server struct {
 	providerConfig *codingagent.ProvidersConfig
 	sessionManager *codingagent.SessionManager
 	agentContext   *agent.AgentContext
+	auditLog       *agent.AuditLog
+	checkpoints    *checkpoint.Store
+	background     *tools.BackgroundManager
+	commands       *commands.Registry
 	ctx            context.Context
 	cancel         context.CancelFunc
 
@@ -60,6 +69,48 @@ func NewServer(
 	}
 }
 
+// SetAuditLog 设置审计日志，记录工具调用的权限决策和执行结果
+func (s *Server) SetAuditLog(auditLog *agent.AuditLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditLog = auditLog
+	s.ctx = s.withContextValues(s.ctx)
+}
+
+// SetCheckpoints 设置检查点存储，write/edit 工具修改文件前会在其中保存快照，供 rewind 使用
+func (s *Server) SetCheckpoints(store *checkpoint.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints = store
+	s.ctx = s.withContextValues(s.ctx)
+}
+
+// SetBackgroundJobs 设置 bash 工具的后台任务管理器，供 list_background_jobs 和 kill_background_job 使用
+func (s *Server) SetBackgroundJobs(background *tools.BackgroundManager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.background = background
+}
+
+// SetCommands 设置斜杠命令注册表，供 get_commands 和 run_command 使用
+func (s *Server) SetCommands(registry *commands.Registry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = registry
+}
+
+// withContextValues 将审计日志和检查点存储附加到上下文
+func (s *Server) withContextValues(ctx context.Context) context.Context {
+	if s.auditLog != nil {
+		ctx = context.WithValue(ctx, "audit_log", s.auditLog)
+	}
+	return checkpoint.WithStore(ctx, s.checkpoints)
+}
+
 // Run 启动 RPC 服务器，监听输入并响应命令
 func (s *Server) Run(ctx context.Context) error {
 	// 设置事件监听器
@@ -148,6 +199,9 @@ func (s *Server) setupEventListeners() {
 				case agent.PromptAddedEvent:
 					rpcEvent.Type = "prompt_added"
 					rpcEvent.Data = e
+				case agent.SubAgentEvent:
+					rpcEvent.Type = EventTypeSubAgent
+					rpcEvent.Data = e
 				default:
 					rpcEvent.Type = "unknown"
 					rpcEvent.Data = e
@@ -187,6 +241,16 @@ func (s *Server) handleCommand(ctx context.Context, cmd RpcCommand) {
 		s.handleGetMessages(cmd)
 	case CommandGetSessionStats:
 		s.handleGetSessionStats(cmd)
+	case CommandRewind:
+		s.handleRewind(cmd)
+	case CommandListBackgroundJobs:
+		s.handleListBackgroundJobs(cmd)
+	case CommandKillBackgroundJob:
+		s.handleKillBackgroundJob(cmd)
+	case CommandGetCommands:
+		s.handleGetCommands(cmd)
+	case CommandRunCommand:
+		s.handleRunCommand(cmd)
 	default:
 		s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Unknown command: %s", cmd.Type))
 	}
@@ -198,19 +262,53 @@ func (s *Server) handlePrompt(cmd RpcCommand) {
 		return
 	}
 
+	// 附带的图片会按提供商的限制缩小，且模型必须支持图片
+	content := []ai.Content{ai.NewTextContent(cmd.Message)}
+	for i, image := range cmd.Images {
+		imageContent, err := images.FromBase64(image.Data, image.MimeType)
+		if err != nil {
+			s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Invalid image %d: %v", i+1, err))
+			return
+		}
+		content = append(content, imageContent)
+	}
+	if err := images.CheckModel(s.agent.GetState().GetModel(), content); err != nil {
+		s.sendError(cmd.ID, cmd.Type, err.Error())
+		return
+	}
+
+	s.runPrompt(cmd, content, nil, nil)
+}
+
+// runPrompt 将 content 作为用户消息运行代理，结束后调用 done（可为 nil）并以 data 作为成功响应
+func (s *Server) runPrompt(cmd RpcCommand, content []ai.Content, data interface{}, done func()) {
 	// 创建用户消息
 	userMsg := agent.AgentMessage{
-		Message:   ai.NewUserTextMessage(cmd.Message),
+		Message:   ai.NewUserMessage(content),
 		ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
 		CreatedAt: time.Now().UnixMilli(),
 	}
 
-	// 运行代理
+	// 为该消息开始新的检查点
+	if s.checkpoints != nil {
+		if err := s.checkpoints.Begin(userMsg.ID); err != nil {
+			if done != nil {
+				done()
+			}
+			s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Failed to create checkpoint: %v", err))
+			return
+		}
+	}
+
+	// 运行代理，结束后撤销命令为该提示词所做的设置
 	go func() {
+		if done != nil {
+			defer done()
+		}
 		if err := s.agent.Run(s.ctx, []agent.AgentMessage{userMsg}); err != nil {
 			s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Prompt failed: %v", err))
 		} else {
-			s.sendSuccess(cmd.ID, cmd.Type, nil)
+			s.sendSuccess(cmd.ID, cmd.Type, data)
 		}
 	}()
 }
@@ -260,7 +358,7 @@ func (s *Server) handleAbort(cmd RpcCommand) {
 	
 	// 重新创建上下文以允许后续运行
 	ctx, cancel := context.WithCancel(context.Background())
-	s.ctx = ctx
+	s.ctx = s.withContextValues(ctx)
 	s.cancel = cancel
 	
 	s.sendSuccess(cmd.ID, cmd.Type, nil)
@@ -285,6 +383,11 @@ func (s *Server) handleGetState(cmd RpcCommand) {
 }
 
 func (s *Server) handleNewSession(cmd RpcCommand) {
+	// 会话结束时清理后台任务
+	if s.background != nil {
+		s.background.Close()
+	}
+
 	s.sendSuccess(cmd.ID, cmd.Type, map[string]interface{}{
 		"cancelled": false,
 	})
@@ -371,6 +474,112 @@ func (s *Server) handleBash(cmd RpcCommand) {
 	s.sendSuccess(cmd.ID, cmd.Type, bashResult)
 }
 
+func (s *Server) handleListBackgroundJobs(cmd RpcCommand) {
+	jobs := []tools.BackgroundJobInfo{}
+	if s.background != nil {
+		jobs = s.background.List()
+	}
+
+	s.sendSuccess(cmd.ID, cmd.Type, map[string]interface{}{
+		"jobs": jobs,
+	})
+}
+
+func (s *Server) handleKillBackgroundJob(cmd RpcCommand) {
+	if s.background == nil {
+		s.sendError(cmd.ID, cmd.Type, "Background jobs not available")
+		return
+	}
+	if cmd.ShellID == "" {
+		s.sendError(cmd.ID, cmd.Type, "Shell ID is required")
+		return
+	}
+
+	if err := s.background.Kill(cmd.ShellID); err != nil {
+		s.sendError(cmd.ID, cmd.Type, err.Error())
+		return
+	}
+
+	s.sendSuccess(cmd.ID, cmd.Type, map[string]string{
+		"shell_id": cmd.ShellID,
+	})
+}
+
+func (s *Server) handleGetCommands(cmd RpcCommand) {
+	s.mu.Lock()
+	registry := s.commands
+	s.mu.Unlock()
+
+	infos := []CommandInfo{}
+	if registry != nil {
+		for _, c := range registry.List() {
+			infos = append(infos, CommandInfo{
+				Name:        c.Name,
+				Description: c.Description,
+				Usage:       c.Usage,
+			})
+		}
+	}
+
+	s.sendSuccess(cmd.ID, cmd.Type, map[string]interface{}{
+		"commands": infos,
+	})
+}
+
+func (s *Server) handleRunCommand(cmd RpcCommand) {
+	s.mu.Lock()
+	registry, ctx, checkpoints := s.commands, s.ctx, s.checkpoints
+	s.mu.Unlock()
+
+	if registry == nil {
+		s.sendError(cmd.ID, cmd.Type, "Commands not available")
+		return
+	}
+
+	// 允许省略开头的斜杠
+	line := strings.TrimSpace(cmd.Command)
+	if !strings.HasPrefix(line, "/") {
+		line = "/" + line
+	}
+
+	result, err := registry.Execute(ctx, line)
+	if err != nil {
+		s.sendError(cmd.ID, cmd.Type, err.Error())
+		return
+	}
+
+	// /clear 和 /fork 会切换到新会话
+	data := CommandResult{Output: result.Output, Prompt: result.Prompt}
+	if s.sessionManager != nil {
+		if current := s.sessionManager.GetCurrent(); current != nil {
+			data.SessionID = current.Metadata.ID
+			if s.auditLog != nil {
+				s.auditLog.SetSessionID(current.Metadata.ID)
+			}
+			// 每个会话使用自己的检查点目录
+			if checkpoints != nil && filepath.Base(checkpoints.Dir()) != current.Metadata.ID {
+				store, err := checkpoint.Open(filepath.Join(filepath.Dir(checkpoints.Dir()), current.Metadata.ID))
+				if err != nil {
+					s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Failed to open checkpoints: %v", err))
+					return
+				}
+				s.SetCheckpoints(store)
+			}
+		}
+	}
+
+	// 命令返回的提示词交给代理运行
+	if result.Prompt != "" && s.agent != nil {
+		s.runPrompt(cmd, []ai.Content{ai.NewTextContent(result.Prompt)}, data, result.Done)
+		return
+	}
+	if result.Done != nil {
+		result.Done()
+	}
+
+	s.sendSuccess(cmd.ID, cmd.Type, data)
+}
+
 func (s *Server) handleGetMessages(cmd RpcCommand) {
 	if s.agent == nil {
 		s.sendError(cmd.ID, cmd.Type, "Agent not initialized")
@@ -405,6 +614,54 @@ func (s *Server) handleGetSessionStats(cmd RpcCommand) {
 	s.sendSuccess(cmd.ID, cmd.Type, stats)
 }
 
+func (s *Server) handleRewind(cmd RpcCommand) {
+	if s.agent == nil || s.sessionManager == nil {
+		s.sendError(cmd.ID, cmd.Type, "Agent not initialized")
+		return
+	}
+	if s.agent.GetState().GetIsStreaming() {
+		s.sendError(cmd.ID, cmd.Type, "Cannot rewind while the agent is running")
+		return
+	}
+	if cmd.MessageID == "" {
+		s.sendError(cmd.ID, cmd.Type, "Message ID is required")
+		return
+	}
+
+	mode, err := codingagent.ParseRewindMode(cmd.Mode)
+	if err != nil {
+		s.sendError(cmd.ID, cmd.Type, err.Error())
+		return
+	}
+
+	session := s.sessionManager.GetCurrent()
+	if session == nil {
+		s.sendError(cmd.ID, cmd.Type, "No active session")
+		return
+	}
+
+	// 恢复代码和/或对话；fork 时在新分支会话中继续
+	result, err := s.sessionManager.Rewind(session, s.checkpoints, cmd.MessageID, mode, cmd.Fork)
+	if err != nil {
+		s.sendError(cmd.ID, cmd.Type, fmt.Sprintf("Rewind failed: %v", err))
+		return
+	}
+
+	if result.Checkpoints != s.checkpoints {
+		s.SetCheckpoints(result.Checkpoints)
+	}
+	if result.Session != session && s.auditLog != nil {
+		s.auditLog.SetSessionID(result.Session.Metadata.ID)
+	}
+
+	s.sendSuccess(cmd.ID, cmd.Type, RewindResult{
+		SessionID:     result.Session.Metadata.ID,
+		RestoredFiles: result.RestoredFiles,
+		Prompt:        result.Prompt,
+		Messages:      s.agent.GetState().GetMessages(),
+	})
+}
+
 func (s *Server) sendSuccess(id, command string, data interface{}) {
 	res := RpcResponse{
 		ID:      id,
@@ -24,6 +24,11 @@ const (
 	CommandAbortBash        = "abort_bash"
 	CommandGetSessionStats  = "get_session_stats"
 	CommandGetMessages      = "get_messages"
+	CommandRewind           = "rewind"
+	CommandListBackgroundJobs = "list_background_jobs"
+	CommandKillBackgroundJob  = "kill_background_job"
+	CommandGetCommands        = "get_commands"
+	CommandRunCommand         = "run_command"
 )
 
 // RpcCommand 表示 RPC 命令
@@ -35,7 +40,11 @@ type RpcCommand struct {
 	Provider string      `json:"provider,omitempty"` // set_model 命令的提供商
 	ModelID string      `json:"model_id,omitempty"` // set_model 命令的模型 ID
 	Level   string      `json:"level,omitempty"`    // set_thinking_level 命令的思考级别
-	Command string      `json:"command,omitempty"`  // bash 命令
+	Command string      `json:"command,omitempty"`  // bash 命令，或 run_command 的斜杠命令行，如 "/model gpt-4o"
+	MessageID string    `json:"message_id,omitempty"` // rewind 命令的目标用户消息 ID
+	Mode    string      `json:"mode,omitempty"`     // rewind 模式：code、conversation 或 both（默认）
+	Fork    bool        `json:"fork,omitempty"`     // rewind 时在新分支会话中继续，保留原会话历史
+	ShellID string      `json:"shell_id,omitempty"` // kill_background_job 命令的后台任务 ID
 }
 
 // ImageContent 表示图片内容
@@ -79,6 +88,28 @@ type SessionStats struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// RewindResult 表示 rewind 命令结果
+type RewindResult struct {
+	SessionID     string               `json:"session_id"`
+	RestoredFiles []string             `json:"restored_files"`
+	Prompt        string               `json:"prompt"` // 被撤回的用户消息文本
+	Messages      []agent.AgentMessage `json:"messages"`
+}
+
+// CommandInfo 表示一个斜杠命令
+type CommandInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Usage       string `json:"usage,omitempty"`
+}
+
+// CommandResult 表示 run_command 命令结果
+type CommandResult struct {
+	Output    string `json:"output,omitempty"`
+	Prompt    string `json:"prompt,omitempty"` // 命令发送给代理的提示词，代理运行结束后才返回响应
+	SessionID string `json:"session_id,omitempty"`
+}
+
 // BashResult 表示 Bash 命令结果
 type BashResult struct {
 	Output   string `json:"output"`
@@ -95,6 +126,7 @@ const (
 	EventTypeToolCall       = "tool_call"
 	EventTypeToolResult     = "tool_result"
 	EventTypeError          = "error"
+	EventTypeSubAgent       = "subagent"
 )
 
 // RpcEvent 表示 RPC 事件
@@ -0,0 +1,36 @@
+package shared
+
+import "context"
+
+// Command is a slash command typed in the chat, such as /help or /model
+type Command struct {
+	// Name is the command name without the leading slash
+	Name string
+
+	// Description is a one-line summary shown in /help and completions
+	Description string
+
+	// Usage describes the arguments, e.g. "<model-id>" (empty if none)
+	Usage string
+
+	// RawArgs passes the text after the name to Run as is, as its only
+	// argument, instead of splitting it into words
+	RawArgs bool
+
+	// Run executes the command with its parsed arguments
+	Run func(ctx context.Context, args []string) (CommandResult, error)
+}
+
+// CommandResult is the outcome of a command
+type CommandResult struct {
+	// Output is text shown to the user
+	Output string
+
+	// Prompt, if set, is sent to the agent as a user message
+	Prompt string
+
+	// Done, if set, is called once the agent has answered Prompt, or right
+	// away if there is no prompt. Commands use it to undo settings that only
+	// apply to their prompt, such as a different model.
+	Done func()
+}
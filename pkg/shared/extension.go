@@ -24,6 +24,10 @@ type Extension interface {
 	// This is called once during extension initialization
 	RegisterTools() []agent.AgentTool
 
+	// RegisterCommands returns slash commands to add to the chat and RPC mode
+	// This is called once during extension initialization
+	RegisterCommands() []Command
+
 	// OnToolCall is called before a tool is executed
 	// Return modified params or nil to skip modification
 	// Return error to abort tool execution
@@ -67,6 +71,7 @@ func (e *BaseExtension) Description() string { return e.description }
 
 func (e *BaseExtension) Init(config map[string]any) error { return nil }
 func (e *BaseExtension) RegisterTools() []agent.AgentTool  { return nil }
+func (e *BaseExtension) RegisterCommands() []Command        { return nil }
 
 func (e *BaseExtension) OnToolCall(ctx context.Context, toolName string, params map[string]any) (map[string]any, error) {
 	return nil, nil // No modification